@@ -20,6 +20,9 @@ import (
 	"github.com/kubecube-io/kubecube/pkg/authentication/authenticators/jwt"
 	"github.com/kubecube-io/kubecube/pkg/authentication/identityprovider/generic"
 	"github.com/kubecube-io/kubecube/pkg/authentication/identityprovider/ldap"
+	"github.com/kubecube-io/kubecube/pkg/authentication/identityprovider/saml"
+	"github.com/kubecube-io/kubecube/pkg/authentication/passwordreset"
+	"github.com/kubecube-io/kubecube/pkg/authorizer/policy"
 	"github.com/kubecube-io/kubecube/pkg/utils/constants"
 	"github.com/urfave/cli/v2"
 )
@@ -67,6 +70,12 @@ func init() {
 			Name:        "ldap-admin-password",
 			Destination: &ldap.Config.LdapAdminPassword,
 		},
+		&cli.IntFlag{
+			Name:        "ldap-sync-page-size",
+			Value:       500,
+			Usage:       "page size of searching users when sync with ldap directory",
+			Destination: &ldap.Config.LdapSyncPageSize,
+		},
 
 		// jwt
 		&cli.Int64Flag{
@@ -118,5 +127,100 @@ func init() {
 			Name:        "generic-auth-tls-key",
 			Destination: &generic.Config.TLSKey,
 		},
+		&cli.StringFlag{
+			Name:        "generic-auth-groups-claim",
+			Value:       "groups",
+			Usage:       "claim in response of generic auth carrying oidc groups of user, which members of oidc groups are synced from",
+			Destination: &generic.Config.GroupsClaim,
+		},
+
+		// saml
+		&cli.BoolFlag{
+			Name:        "saml-is-enable",
+			Value:       false,
+			Destination: &saml.Config.SAMLIsEnable,
+		},
+		&cli.StringFlag{
+			Name:        "saml-entity-id",
+			Usage:       "entity id of kubecube as saml service provider, metadata url is used if empty",
+			Destination: &saml.Config.EntityID,
+		},
+		&cli.StringFlag{
+			Name:        "saml-root-url",
+			Usage:       "external url of kubecube that saml IdP redirects browsers to, e.g. https://kubecube.example.com",
+			Destination: &saml.Config.RootURL,
+		},
+		&cli.StringFlag{
+			Name:        "saml-idp-metadata-url",
+			Destination: &saml.Config.IdPMetadataURL,
+		},
+		&cli.StringFlag{
+			Name:        "saml-idp-metadata-file",
+			Destination: &saml.Config.IdPMetadataFile,
+		},
+		&cli.StringFlag{
+			Name:        "saml-sp-cert",
+			Usage:       "cert of saml service provider used to sign AuthnRequests",
+			Destination: &saml.Config.SPCert,
+		},
+		&cli.StringFlag{
+			Name:        "saml-sp-key",
+			Usage:       "rsa key of saml service provider used to sign AuthnRequests",
+			Destination: &saml.Config.SPKey,
+		},
+		&cli.StringFlag{
+			Name:        "saml-username-attribute",
+			Usage:       "attribute of assertion used as username, name id is used if empty",
+			Destination: &saml.Config.UsernameAttribute,
+		},
+		&cli.StringFlag{
+			Name:        "saml-email-attribute",
+			Value:       "email",
+			Destination: &saml.Config.EmailAttribute,
+		},
+		&cli.StringFlag{
+			Name:        "saml-groups-attribute",
+			Value:       "groups",
+			Destination: &saml.Config.GroupsAttribute,
+		},
+		&cli.BoolFlag{
+			Name:        "saml-allow-idp-initiated",
+			Value:       false,
+			Destination: &saml.Config.AllowIDPInitiated,
+		},
+
+		// password reset
+		&cli.StringFlag{
+			Name:        "password-reset-url",
+			Usage:       "page of front end to set new password, password reset by mail is disabled if empty",
+			Destination: &passwordreset.Config.ResetURL,
+		},
+		&cli.IntFlag{
+			Name:        "password-reset-token-ttl-minutes",
+			Value:       30,
+			Destination: &passwordreset.Config.TokenTTLMinutes,
+		},
+
+		// policy
+		&cli.StringFlag{
+			Name:        "policy-webhook-url",
+			Usage:       "external decision service consulted before proxying requests to clusters, disabled if empty",
+			Destination: &policy.Config.WebhookURL,
+		},
+		&cli.StringFlag{
+			Name:        "policy-webhook-ca-cert",
+			Destination: &policy.Config.WebhookCACert,
+		},
+		&cli.IntFlag{
+			Name:        "policy-webhook-timeout-seconds",
+			Value:       5,
+			Destination: &policy.Config.WebhookTimeoutSeconds,
+		},
+		&cli.BoolFlag{
+			Name:        "policy-webhook-fail-open",
+			Value:       false,
+			Usage:       "allow requests when decision service is unavailable",
+			Destination: &policy.Config.WebhookFailOpen,
+		},
 	}...)
 }
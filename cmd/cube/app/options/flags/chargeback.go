@@ -0,0 +1,52 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flags
+
+import (
+	"github.com/urfave/cli/v2"
+
+	"github.com/kubecube-io/kubecube/pkg/chargeback"
+)
+
+// chargeback flags
+func init() {
+	Flags = append(Flags, []cli.Flag{
+		&cli.StringFlag{
+			Name:        "chargeback-data-dir",
+			Value:       "/var/lib/kubecube/chargeback",
+			Usage:       "directory that samples of chargeback are stored in, it should be on a persistent volume. Samples are written and reports are served by the leader only, requests to other replicas are forwarded to the leader",
+			Destination: &chargeback.Config.DataDir,
+		},
+		&cli.IntFlag{
+			Name:        "chargeback-sample-interval-minutes",
+			Value:       0,
+			Usage:       "interval of sampling resources of projects for chargeback, never sample if 0",
+			Destination: &chargeback.Config.SampleIntervalMinutes,
+		},
+		&cli.IntFlag{
+			Name:        "chargeback-retention-days",
+			Value:       400,
+			Usage:       "days that samples of chargeback are kept, forever if 0",
+			Destination: &chargeback.Config.RetentionDays,
+		},
+		&cli.StringFlag{
+			Name:        "chargeback-price-file",
+			Usage:       "yaml file of prices of resources per unit-hour, all resources are free if empty",
+			Destination: &chargeback.Config.PriceFile,
+		},
+	}...)
+}
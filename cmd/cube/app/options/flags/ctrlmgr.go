@@ -55,5 +55,29 @@ func init() {
 			Value:       10,
 			Usage:       "the time that wait for warden start",
 		},
+		&cli.IntFlag{
+			Name:        "key-idle-timeout-days",
+			Destination: &CubeOpts.CtrlMgrOpts.KeyIdleTimeoutDays,
+			Value:       0,
+			Usage:       "disable access keys not used for such days, never disable if 0",
+		},
+		&cli.IntFlag{
+			Name:        "ldap-sync-interval-minutes",
+			Destination: &CubeOpts.CtrlMgrOpts.LdapSyncIntervalMinutes,
+			Value:       60,
+			Usage:       "interval of syncing ldap users with directory when ldap enabled, never sync if 0",
+		},
+		&cli.IntFlag{
+			Name:        "quota-drift-check-interval-minutes",
+			Destination: &CubeOpts.CtrlMgrOpts.QuotaDriftCheckIntervalMinutes,
+			Value:       30,
+			Usage:       "interval of checking used of cube resource quotas against child quotas, never check if 0",
+		},
+		&cli.BoolFlag{
+			Name:        "quota-drift-repair",
+			Destination: &CubeOpts.CtrlMgrOpts.QuotaDriftRepair,
+			Value:       false,
+			Usage:       "repair drifted cube resource quotas found by periodic check, only report them if false",
+		},
 	}...)
 }
@@ -0,0 +1,74 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flags
+
+import (
+	"github.com/urfave/cli/v2"
+
+	"github.com/kubecube-io/kubecube/pkg/notification"
+)
+
+// notification flags
+func init() {
+	Flags = append(Flags, []cli.Flag{
+		// smtp
+		&cli.StringFlag{
+			Name:        "smtp-host",
+			Usage:       "host of smtp server, mail notification is disabled if empty",
+			Destination: &notification.Config.SMTPHost,
+		},
+		&cli.IntFlag{
+			Name:        "smtp-port",
+			Value:       25,
+			Destination: &notification.Config.SMTPPort,
+		},
+		&cli.StringFlag{
+			Name:        "smtp-username",
+			Destination: &notification.Config.SMTPUsername,
+		},
+		&cli.StringFlag{
+			Name:        "smtp-password",
+			Destination: &notification.Config.SMTPPassword,
+		},
+		&cli.StringFlag{
+			Name:        "smtp-from",
+			Usage:       "sender address of mail, e.g. KubeCube <noreply@example.com>",
+			Destination: &notification.Config.SMTPFrom,
+		},
+		&cli.StringFlag{
+			Name:        "smtp-tls-mode",
+			Value:       notification.SMTPStartTLS,
+			Usage:       "one of none, starttls and tls",
+			Destination: &notification.Config.SMTPTLSMode,
+		},
+		&cli.BoolFlag{
+			Name:        "smtp-insecure-skip-verify",
+			Destination: &notification.Config.SMTPInsecureSkipVerify,
+		},
+		// webhook
+		&cli.StringFlag{
+			Name:        "notification-webhook-url",
+			Usage:       "url that notifications are posted to as json when smtp is not configured",
+			Destination: &notification.Webhook.WebhookURL,
+		},
+		&cli.StringFlag{
+			Name:        "notification-webhook-token",
+			Usage:       "bearer token sent to notification webhook",
+			Destination: &notification.Webhook.WebhookToken,
+		},
+	}...)
+}
@@ -18,6 +18,9 @@ package options
 
 import (
 	"github.com/urfave/cli/v2"
+
+	"github.com/kubecube-io/kubecube/pkg/authorizer/policy"
+	"github.com/kubecube-io/kubecube/pkg/quota/history"
 )
 
 var (
@@ -174,5 +177,59 @@ var (
 			Value:       "udp-services",
 			Destination: &WardenOpts.GenericWardenOpts.NginxUdpServiceConfigMap,
 		},
+
+		// quota history
+		&cli.StringFlag{
+			Name:        "quota-history-data-dir",
+			Value:       "/var/lib/kubecube/history",
+			Usage:       "directory that samples of quota history are stored in",
+			Destination: &history.Config.DataDir,
+		},
+		&cli.IntFlag{
+			Name:        "quota-history-sample-interval-minutes",
+			Value:       10,
+			Usage:       "interval of sampling quotas of local cluster, never sample if 0",
+			Destination: &history.Config.SampleIntervalMinutes,
+		},
+		&cli.IntFlag{
+			Name:        "quota-history-retention-days",
+			Value:       90,
+			Usage:       "days that samples of quota history are kept, forever if 0",
+			Destination: &history.Config.RetentionDays,
+		},
+		&cli.IntFlag{
+			Name:        "quota-history-downsample-after-days",
+			Value:       7,
+			Usage:       "days after which samples of quota history are downsampled, never downsample if 0",
+			Destination: &history.Config.DownsampleAfterDays,
+		},
+		&cli.IntFlag{
+			Name:        "quota-history-downsample-minutes",
+			Value:       60,
+			Usage:       "step in minutes that old samples of quota history are downsampled to",
+			Destination: &history.Config.DownsampleMinutes,
+		},
+
+		// policy
+		&cli.StringFlag{
+			Name:        "policy-webhook-url",
+			Usage:       "external decision service consulted before proxying requests to clusters, disabled if empty",
+			Destination: &policy.Config.WebhookURL,
+		},
+		&cli.StringFlag{
+			Name:        "policy-webhook-ca-cert",
+			Destination: &policy.Config.WebhookCACert,
+		},
+		&cli.IntFlag{
+			Name:        "policy-webhook-timeout-seconds",
+			Value:       5,
+			Destination: &policy.Config.WebhookTimeoutSeconds,
+		},
+		&cli.BoolFlag{
+			Name:        "policy-webhook-fail-open",
+			Value:       false,
+			Usage:       "allow requests when decision service is unavailable",
+			Destination: &policy.Config.WebhookFailOpen,
+		},
 	}
 )
@@ -0,0 +1,29 @@
+#!/usr/bin/env bash
+
+#Copyright 2023 KubeCube Authors
+#
+#Licensed under the Apache License, Version 2.0 (the "License");
+#you may not use this file except in compliance with the License.
+#You may obtain a copy of the License at
+#
+#    http://www.apache.org/licenses/LICENSE-2.0
+#
+#Unless required by applicable law or agreed to in writing, software
+#distributed under the License is distributed on an "AS IS" BASIS,
+#WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+#See the License for the specific language governing permissions and
+#limitations under the License.
+
+# run a local smtp stand-in which catches all mails for testing password
+# reset, mails can be viewed in web ui at http://localhost:${SMTP_UI_PORT}.
+
+SMTP_IMAGE=${SMTP_IMAGE:-mailhog/mailhog:v1.0.1}
+SMTP_PORT=${SMTP_PORT:-1025}
+SMTP_UI_PORT=${SMTP_UI_PORT:-8025}
+
+docker rm -f kubecube-smtp >/dev/null 2>&1
+docker run -d --name kubecube-smtp -p "${SMTP_PORT}":1025 -p "${SMTP_UI_PORT}":8025 "${SMTP_IMAGE}"
+
+echo "smtp stand-in is running, start kubecube with:"
+echo "  --smtp-host=127.0.0.1 --smtp-port=${SMTP_PORT} --smtp-tls-mode=none --smtp-from=noreply@example.com"
+echo "  --password-reset-url=http://localhost:8080/reset-password"
@@ -21,7 +21,8 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// GlobalNodesPool means
+// GlobalNodesPool means the nodes pool of all the nodes in cluster, nodes
+// of other pools are labeled with node.kubecube.io/pool
 const GlobalNodesPool = "global"
 
 type TargetObj struct {
@@ -50,8 +51,69 @@ type CubeResourceQuotaSpec struct {
 
 	// Target point to the subject object quota to effect
 	Target TargetObj `json:"target,omitempty"`
+
+	// Elastic enables project quota to borrow unused capacity of sibling
+	// projects under the same tenant quota, Hard is the guaranteed amount
+	// when set.
+	// +optional
+	Elastic *ElasticQuota `json:"elastic,omitempty"`
+
+	// Thresholds enables notifying when utilisation of status hard crosses
+	// warning or critical percentage.
+	// +optional
+	Thresholds *QuotaThresholds `json:"thresholds,omitempty"`
+
+	// DefaultRequests are set to containers of pods without requests of
+	// cpu or memory, which are created in namespaces under the quota. The
+	// defaults of nearest quota in hierarchy apply.
+	// +optional
+	DefaultRequests v1.ResourceList `json:"defaultRequests,omitempty"`
+}
+
+// QuotaThresholds are the utilisation percentages of quota to notify at
+type QuotaThresholds struct {
+	// Warning is the percentage of hard to warn at, defaults to 80.
+	// +optional
+	Warning int32 `json:"warning,omitempty"`
+	// Critical is the percentage of hard to alert at, defaults to 95.
+	// +optional
+	Critical int32 `json:"critical,omitempty"`
+	// Receivers are the mail addresses notified when level of quota changed,
+	// platform admins are notified if empty.
+	// +optional
+	Receivers []string `json:"receivers,omitempty"`
+}
+
+type ThresholdLevel string
+
+const (
+	ThresholdNormal   ThresholdLevel = "Normal"
+	ThresholdWarning  ThresholdLevel = "Warning"
+	ThresholdCritical ThresholdLevel = "Critical"
+)
+
+// ElasticQuota is the borrowing setting of project quota
+type ElasticQuota struct {
+	// Max is the borrowing ceiling of each resource, resources not in max
+	// can not be borrowed. Max must not be less than hard.
+	Max v1.ResourceList `json:"max,omitempty"`
+
+	// ReclaimPolicy tells how borrowed resources are reclaimed when siblings
+	// need their guaranteed amount back, defaults to Flag.
+	// +optional
+	ReclaimPolicy ReclaimPolicy `json:"reclaimPolicy,omitempty"`
 }
 
+type ReclaimPolicy string
+
+const (
+	// ReclaimFlag flags over borrowers in status and events only
+	ReclaimFlag ReclaimPolicy = "Flag"
+	// ReclaimBlockNewPods blocks new pods of over borrowers until borrowed
+	// resources are returned
+	ReclaimBlockNewPods ReclaimPolicy = "BlockNewPods"
+)
+
 // CubeResourceQuotaStatus defines the observed state of CubeResourceQuota
 type CubeResourceQuotaStatus struct {
 	// Hard is the set of enforced hard limits for each named resource.
@@ -62,11 +124,54 @@ type CubeResourceQuotaStatus struct {
 	// +optional
 	Used v1.ResourceList `json:"used,omitempty"`
 
+	// Charged is the usage of pods in namespaces without ResourceQuota which
+	// are charged against the quota directly, it is not part of used. It is
+	// recorded by warden of the cluster and lags behind admission of pods.
+	// +optional
+	Charged v1.ResourceList `json:"charged,omitempty"`
+
 	// SubResourceQuotas contains child resource quotas of cube resource quota.
 	// {name}.{namespace}.quota means resource quota
 	// {name}.quota means cube resource quota
 	// +optional
 	SubResourceQuotas []string `json:"subResourceQuotas,omitempty"`
+
+	// Borrowed is the used beyond guaranteed hard of elastic quota, which
+	// is borrowed from siblings
+	// +optional
+	Borrowed v1.ResourceList `json:"borrowed,omitempty"`
+	// Reclaiming is the part of borrowed that siblings need back, elastic
+	// quota can not grow until it is returned
+	// +optional
+	Reclaiming v1.ResourceList `json:"reclaiming,omitempty"`
+
+	// ThresholdLevels are the levels of resources whose utilisation crossed
+	// thresholds, resources at normal level are omitted. It is remembered
+	// so that notification is sent only once level changed.
+	// +optional
+	ThresholdLevels map[v1.ResourceName]ThresholdLevel `json:"thresholdLevels,omitempty"`
+
+	// History records changes of hard made by approved quota requests,
+	// the latest last. Only the latest MaxQuotaHistory records are kept.
+	// +optional
+	History []QuotaChangeRecord `json:"history,omitempty"`
+}
+
+// MaxQuotaHistory is the number of change records kept in status
+const MaxQuotaHistory = 20
+
+// QuotaChangeRecord is a change of hard of quota
+type QuotaChangeRecord struct {
+	// Request is the name of quota request made the change
+	Request   string      `json:"request"`
+	Requester string      `json:"requester"`
+	Approver  string      `json:"approver"`
+	Reason    string      `json:"reason"`
+	Time      metav1.Time `json:"time"`
+	// From is the hard of changed resources before change
+	From v1.ResourceList `json:"from,omitempty"`
+	// To is the hard of changed resources after change
+	To v1.ResourceList `json:"to,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -0,0 +1,118 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type QuotaRequestPhase string
+
+const (
+	QuotaRequestPending   QuotaRequestPhase = "Pending"
+	QuotaRequestApproved  QuotaRequestPhase = "Approved"
+	QuotaRequestRejected  QuotaRequestPhase = "Rejected"
+	QuotaRequestCancelled QuotaRequestPhase = "Cancelled"
+)
+
+// QuotaRequestSpec defines the desired state of QuotaRequest
+type QuotaRequestSpec struct {
+	// User is the name of user who files the request.
+	User string `json:"user"`
+
+	// Quota is the name of CubeResourceQuota of project to change.
+	Quota string `json:"quota"`
+
+	// Hard is the desired hard of resources to change, resources not
+	// listed are left unchanged.
+	Hard v1.ResourceList `json:"hard"`
+
+	// Reason tells approvers why the change is needed.
+	Reason string `json:"reason"`
+}
+
+// QuotaRequestStatus defines the observed state of QuotaRequest
+type QuotaRequestStatus struct {
+	// Phase is the current phase of request.
+	// +optional
+	Phase QuotaRequestPhase `json:"phase,omitempty"`
+
+	// Approvers are the admins of tenant when the request created, any
+	// of them or platform admins can decide the request.
+	// +optional
+	Approvers []string `json:"approvers,omitempty"`
+
+	// DecidedBy is the user who approved, rejected or cancelled the request.
+	// +optional
+	DecidedBy string `json:"decidedBy,omitempty"`
+
+	// DecisionTime is when the request left pending phase.
+	// +optional
+	DecisionTime *metav1.Time `json:"decisionTime,omitempty"`
+
+	// Comment is left by the user who decided the request.
+	// +optional
+	Comment string `json:"comment,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:categories="quota",scope="Cluster"
+//+kubebuilder:printcolumn:name="User",type="string",JSONPath=".spec.user"
+//+kubebuilder:printcolumn:name="Quota",type="string",JSONPath=".spec.quota"
+//+kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+
+// QuotaRequest is the Schema for the quotarequests API, a project admin
+// asks for changing hard of project quota and the tenant admins decide it.
+type QuotaRequest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   QuotaRequestSpec   `json:"spec,omitempty"`
+	Status QuotaRequestStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// QuotaRequestList contains a list of QuotaRequest
+type QuotaRequestList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []QuotaRequest `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&QuotaRequest{}, &QuotaRequestList{})
+}
+
+// GetPhase returns phase of request, pending by default
+func (r *QuotaRequest) GetPhase() QuotaRequestPhase {
+	if len(r.Status.Phase) == 0 {
+		return QuotaRequestPending
+	}
+	return r.Status.Phase
+}
+
+// IsApprover tells if user is one of approvers of request
+func (r *QuotaRequest) IsApprover(user string) bool {
+	for _, a := range r.Status.Approvers {
+		if a == user {
+			return true
+		}
+	}
+	return false
+}
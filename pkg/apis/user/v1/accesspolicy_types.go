@@ -0,0 +1,147 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type ConditionOperator string
+
+const (
+	ConditionExists     ConditionOperator = "Exists"
+	ConditionNotExists  ConditionOperator = "NotExists"
+	ConditionIn         ConditionOperator = "In"
+	ConditionNotIn      ConditionOperator = "NotIn"
+	ConditionMatches    ConditionOperator = "Matches"
+	ConditionNotMatches ConditionOperator = "NotMatches"
+)
+
+// AccessPolicySpec defines the desired state of AccessPolicy
+type AccessPolicySpec struct {
+	// Clusters the policy takes effect in, all clusters if empty.
+	// +optional
+	Clusters []string `json:"clusters,omitempty"`
+
+	// Rules of policy, request matches any of them is denied.
+	Rules []AccessPolicyRule `json:"rules"`
+}
+
+// AccessPolicyRule denies the requests it matches, verbs, api groups and
+// resources are matched the same way as rbac rules.
+type AccessPolicyRule struct {
+	// Verbs like create or delete, "*" means all.
+	Verbs []string `json:"verbs"`
+
+	// APIGroups of resources, "" means core group and "*" means all.
+	APIGroups []string `json:"apiGroups"`
+
+	// Resources like pods or pods/exec, "*" means all.
+	Resources []string `json:"resources"`
+
+	// Namespaces the rule takes effect in, all if empty.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// ExemptUsers are not limited by the rule.
+	// +optional
+	ExemptUsers []string `json:"exemptUsers,omitempty"`
+
+	// ExemptGroups are the groups whose members are not limited by the rule.
+	// +optional
+	ExemptGroups []string `json:"exemptGroups,omitempty"`
+
+	// TimeWindow limits the rule to take effect within the window only,
+	// the rule takes effect all the time if empty.
+	// +optional
+	TimeWindow *TimeWindow `json:"timeWindow,omitempty"`
+
+	// Conditions on object of request body, the rule matches only if all
+	// of them are met. Rule with conditions never matches requests without
+	// body like get and delete, and always matches requests whose body is
+	// not an object in json or yaml, like json patch or protobuf.
+	// +optional
+	Conditions []ObjectCondition `json:"conditions,omitempty"`
+
+	// Message tells user why the request is denied.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// TimeWindow is a period of day, like 09:00 to 17:00
+type TimeWindow struct {
+	// Start of window in format of 15:04.
+	Start string `json:"start"`
+
+	// End of window in format of 15:04, window crosses midnight if end
+	// is before start.
+	End string `json:"end"`
+
+	// Weekdays the window takes effect in, like Mon or Sat, all days if empty.
+	// +optional
+	Weekdays []string `json:"weekdays,omitempty"`
+
+	// TimeZone of window like Asia/Shanghai, UTC if empty.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// ObjectCondition is a condition on values of object field
+type ObjectCondition struct {
+	// Path is the jsonpath of field, like {.spec.containers[*].image}
+	// or {..image} for images of all kinds of workloads.
+	Path string `json:"path"`
+
+	// Operator between field values and condition values. In and Matches
+	// are met if any field value is in values or matches any of regexps,
+	// NotIn and NotMatches are met if any field value is not.
+	// +kubebuilder:validation:Enum=Exists;NotExists;In;NotIn;Matches;NotMatches
+	Operator ConditionOperator `json:"operator"`
+
+	// Values are plain values for In and NotIn, regexps for Matches and
+	// NotMatches.
+	// +optional
+	Values []string `json:"values,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:categories="user",scope="Cluster"
+
+// AccessPolicy is the Schema for the accesspolicies API, it denies
+// requests proxied to clusters which rbac can not express. Policies are
+// read from the cluster requests proxied to, so the ones created in pivot
+// cluster take effect in member clusters only if annotated with
+// kubecube.io/sync: "true". Requests matching an invalid rule are denied.
+type AccessPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec AccessPolicySpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// AccessPolicyList contains a list of AccessPolicy
+type AccessPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AccessPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AccessPolicy{}, &AccessPolicyList{})
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type AccessRequestPhase string
+
+const (
+	AccessRequestPending   AccessRequestPhase = "Pending"
+	AccessRequestApproved  AccessRequestPhase = "Approved"
+	AccessRequestRejected  AccessRequestPhase = "Rejected"
+	AccessRequestCancelled AccessRequestPhase = "Cancelled"
+)
+
+// AccessRequestSpec defines the desired state of AccessRequest
+type AccessRequestSpec struct {
+	// User is the name of user who asks for the role.
+	User string `json:"user"`
+
+	// ScopeType is the type of scope the role is asked in.
+	// +kubebuilder:validation:Enum=tenant;project
+	ScopeType BindingScopeType `json:"scopeType"`
+
+	// ScopeName is the name of tenant or project.
+	ScopeName string `json:"scopeName"`
+
+	// Role is the name of cluster role asked for.
+	Role string `json:"role"`
+
+	// Justification tells approvers why the role is needed.
+	Justification string `json:"justification"`
+
+	// Duration is how long the granted binding lasts, the binding is
+	// permanent if empty.
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+}
+
+// AccessRequestComment is a comment left on access request
+type AccessRequestComment struct {
+	User    string      `json:"user"`
+	Message string      `json:"message"`
+	Time    metav1.Time `json:"time"`
+}
+
+// AccessRequestStatus defines the observed state of AccessRequest
+type AccessRequestStatus struct {
+	// Phase is the current phase of request.
+	// +optional
+	Phase AccessRequestPhase `json:"phase,omitempty"`
+
+	// Approvers are the admins of scope when the request created, any
+	// of them or platform admins can decide the request.
+	// +optional
+	Approvers []string `json:"approvers,omitempty"`
+
+	// Comments are left by requester and approvers in order.
+	// +optional
+	Comments []AccessRequestComment `json:"comments,omitempty"`
+
+	// DecidedBy is the user who approved, rejected or cancelled the request.
+	// +optional
+	DecidedBy string `json:"decidedBy,omitempty"`
+
+	// DecisionTime is when the request left pending phase.
+	// +optional
+	DecisionTime *metav1.Time `json:"decisionTime,omitempty"`
+
+	// BindingExpireTime is when the granted binding expires, the binding
+	// is permanent if empty.
+	// +optional
+	BindingExpireTime *metav1.Time `json:"bindingExpireTime,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:categories="user",scope="Cluster"
+//+kubebuilder:printcolumn:name="User",type="string",JSONPath=".spec.user"
+//+kubebuilder:printcolumn:name="ScopeType",type="string",JSONPath=".spec.scopeType"
+//+kubebuilder:printcolumn:name="ScopeName",type="string",JSONPath=".spec.scopeName"
+//+kubebuilder:printcolumn:name="Role",type="string",JSONPath=".spec.role"
+//+kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+
+// AccessRequest is the Schema for the accessrequests API, a user asks
+// for role in tenant or project and the scope admins decide it.
+type AccessRequest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AccessRequestSpec   `json:"spec,omitempty"`
+	Status AccessRequestStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// AccessRequestList contains a list of AccessRequest
+type AccessRequestList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AccessRequest `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AccessRequest{}, &AccessRequestList{})
+}
+
+// GetPhase returns phase of request, pending by default
+func (r *AccessRequest) GetPhase() AccessRequestPhase {
+	if len(r.Status.Phase) == 0 {
+		return AccessRequestPending
+	}
+	return r.Status.Phase
+}
+
+// IsApprover tells if user is one of approvers of request
+func (r *AccessRequest) IsApprover(user string) bool {
+	for _, a := range r.Status.Approvers {
+		if a == user {
+			return true
+		}
+	}
+	return false
+}
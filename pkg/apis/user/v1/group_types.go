@@ -0,0 +1,120 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type GroupSource string
+
+const (
+	// StaticGroup members are maintained by hand
+	StaticGroup GroupSource = "static"
+	// LdapGroup members are synced from ldap group
+	LdapGroup GroupSource = "ldap"
+	// OidcGroup members are synced from groups claim of oidc provider,
+	// which is returned by generic auth
+	OidcGroup GroupSource = "oidc"
+	// GitHubGroup members are synced from organizations and teams of github
+	// user, teams are named as org/team-slug
+	GitHubGroup GroupSource = "github"
+	// SamlGroup members are synced from groups attribute of saml assertion
+	SamlGroup GroupSource = "saml"
+	// ScimGroup members are provisioned by scim client
+	ScimGroup GroupSource = "scim"
+)
+
+// GroupSpec defines the desired state of Group
+type GroupSpec struct {
+	DisplayName string `json:"displayName,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	// Source indicates where members of group come from, static if empty.
+	// +kubebuilder:validation:Enum=static;ldap;oidc;github;saml;scim
+	// +optional
+	Source GroupSource `json:"source,omitempty"`
+
+	// ExternalName is the group name in identity provider, the name of
+	// Group is used if empty. Only works with ldap, oidc, github and saml source.
+	// +optional
+	ExternalName string `json:"externalName,omitempty"`
+
+	// Members are names of users in group. For the groups of ldap, oidc,
+	// github and saml source, members are synced from identity provider, and
+	// members of scim groups are provisioned by scim client.
+	// +optional
+	Members []string `json:"members,omitempty"`
+
+	// ScopeBindings indicates group relationships with tenant,project or platform,
+	// all members of group inherit them.
+	// +optional
+	ScopeBindings []ScopeBinding `json:"scopeBindings,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:categories="user",scope="Cluster"
+//+kubebuilder:printcolumn:name="Source",type="string",JSONPath=".spec.source"
+//+kubebuilder:printcolumn:name="ExternalName",type="string",JSONPath=".spec.externalName"
+
+// Group is the Schema for the groups API, group is bound as
+// Group subject of rbac.
+type Group struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec GroupSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// GroupList contains a list of Group
+type GroupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Group `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Group{}, &GroupList{})
+}
+
+// HasMember tells if user is member of group
+func (g *Group) HasMember(user string) bool {
+	for _, m := range g.Spec.Members {
+		if m == user {
+			return true
+		}
+	}
+	return false
+}
+
+// GetSource returns source of group, static by default
+func (g *Group) GetSource() GroupSource {
+	if len(g.Spec.Source) == 0 {
+		return StaticGroup
+	}
+	return g.Spec.Source
+}
+
+// GetExternalName returns the group name in identity provider
+func (g *Group) GetExternalName() string {
+	if len(g.Spec.ExternalName) == 0 {
+		return g.Name
+	}
+	return g.Spec.ExternalName
+}
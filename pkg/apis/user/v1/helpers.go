@@ -16,6 +16,8 @@ limitations under the License.
 
 package v1
 
+import "time"
+
 func IsPlatformAdmin(user *User) bool {
 	return user.Status.PlatformAdmin
 }
@@ -37,3 +39,34 @@ func BelongsToProject(user *User, project string) bool {
 	}
 	return false
 }
+
+// IsExpired tells if the binding no longer takes effect at given time.
+func (b ScopeBinding) IsExpired(now time.Time) bool {
+	return b.ExpireTime != nil && !now.Before(b.ExpireTime.Time)
+}
+
+// ActiveScopeBindings returns the bindings not expired at given time.
+func ActiveScopeBindings(bindings []ScopeBinding, now time.Time) []ScopeBinding {
+	res := make([]ScopeBinding, 0, len(bindings))
+	for _, b := range bindings {
+		if !b.IsExpired(now) {
+			res = append(res, b)
+		}
+	}
+	return res
+}
+
+// NextScopeBindingExpiry returns the duration until the first active binding
+// expires, zero returned if none of bindings will expire.
+func NextScopeBindingExpiry(bindings []ScopeBinding, now time.Time) time.Duration {
+	var next time.Duration
+	for _, b := range bindings {
+		if b.ExpireTime == nil || b.IsExpired(now) {
+			continue
+		}
+		if d := b.ExpireTime.Sub(now); next == 0 || d < next {
+			next = d
+		}
+	}
+	return next
+}
@@ -17,24 +17,87 @@ limitations under the License.
 package v1
 
 import (
+	"time"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+type KeyPhase string
+
+const (
+	KeyActive   KeyPhase = "Active"
+	KeyExpired  KeyPhase = "Expired"
+	KeyDisabled KeyPhase = "Disabled"
+)
+
 // KeySpec defines the desired state of Key
 type KeySpec struct {
 	SecretKey string `json:"secretKey,omitempty"`
 	User      string `json:"user,omitempty"`
+
+	// ExpireTime is the time after which the key can not be used, never expired if empty.
+	// +optional
+	ExpireTime *metav1.Time `json:"expireTime,omitempty"`
+
+	// Disabled indicates the key can not be used anymore.
+	// +optional
+	Disabled bool `json:"disabled,omitempty"`
+
+	// Scope limits the tokens issued by the key, no limit if empty.
+	// +optional
+	Scope *KeyScope `json:"scope,omitempty"`
+}
+
+// KeyScope limits what the tokens issued by key can access,
+// the limits of clusters, tenants and projects are ANDed.
+// Tokens with any scope can not call the apis managing users, keys, robots,
+// groups and role bindings, and only write resources of clusters.
+type KeyScope struct {
+	// ReadOnly allows read verbs only.
+	// +optional
+	ReadOnly bool `json:"readOnly,omitempty"`
+
+	// Clusters limits the clusters can be accessed, all clusters allowed if empty.
+	// +optional
+	Clusters []string `json:"clusters,omitempty"`
+
+	// Tenants limits the namespaces of tenants can be accessed, all tenants allowed if empty.
+	// +optional
+	Tenants []string `json:"tenants,omitempty"`
+
+	// Projects limits the namespaces of projects can be accessed, all projects allowed if empty.
+	// +optional
+	Projects []string `json:"projects,omitempty"`
 }
 
 // KeyStatus defines the observed state of Key
 type KeyStatus struct {
+	// Phase is the current phase of key.
+	// +optional
+	Phase KeyPhase `json:"phase,omitempty"`
+
+	// Reason tells why the key is not active.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// LastUsedTime is the last time the key used to issue token.
+	// +optional
+	LastUsedTime *metav1.Time `json:"lastUsedTime,omitempty"`
+
+	// LastUsedIP is the client address of the last time the key used.
+	// +optional
+	LastUsedIP string `json:"lastUsedIP,omitempty"`
 }
 
 //+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
 
 // Key is the Schema for the keys API
 // +kubebuilder:resource:categories="kubecube",scope="Cluster"
 // +kubebuilder:printcolumn:name="User",type="string",JSONPath=".spec.user"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="ExpireTime",type="date",JSONPath=".spec.expireTime"
+// +kubebuilder:printcolumn:name="LastUsedTime",type="date",JSONPath=".status.lastUsedTime"
 type Key struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -55,3 +118,14 @@ type KeyList struct {
 func init() {
 	SchemeBuilder.Register(&Key{}, &KeyList{})
 }
+
+// IsAvailable tells if the key can be used to issue token at now.
+func (k *Key) IsAvailable(now time.Time) bool {
+	if k.Spec.Disabled {
+		return false
+	}
+	if k.Spec.ExpireTime != nil && !now.Before(k.Spec.ExpireTime.Time) {
+		return false
+	}
+	return true
+}
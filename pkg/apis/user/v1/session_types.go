@@ -0,0 +1,99 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SessionSpec defines the desired state of Session
+type SessionSpec struct {
+	// User is the owner of session.
+	User string `json:"user"`
+
+	// LoginType is the login method used to open the session.
+	// +optional
+	LoginType LoginType `json:"loginType,omitempty"`
+
+	// AccessKey is the key used to open the session, empty if not opened by key.
+	// +optional
+	AccessKey string `json:"accessKey,omitempty"`
+
+	// ClientIP is the address where the session opened from.
+	// +optional
+	ClientIP string `json:"clientIP,omitempty"`
+
+	// UserAgent is the user agent of the client which opened the session.
+	// +optional
+	UserAgent string `json:"userAgent,omitempty"`
+
+	// Impersonator is the platform admin who acts as the user by the session,
+	// empty if the session is opened by the user itself.
+	// +optional
+	Impersonator string `json:"impersonator,omitempty"`
+
+	// ExpireTime is the hard deadline of session, tokens of the session are
+	// rejected after it even if they are refreshed.
+	// +optional
+	ExpireTime *metav1.Time `json:"expireTime,omitempty"`
+
+	// Revoked indicates tokens of the session are no longer accepted.
+	// +optional
+	Revoked bool `json:"revoked,omitempty"`
+
+	// RevokedReason tells why the session was revoked.
+	// +optional
+	RevokedReason string `json:"revokedReason,omitempty"`
+}
+
+// SessionStatus defines the observed state of Session
+type SessionStatus struct {
+	// LastActiveTime is the last time the token of session was refreshed.
+	// +optional
+	LastActiveTime *metav1.Time `json:"lastActiveTime,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:categories="user",scope="Cluster"
+//+kubebuilder:printcolumn:name="User",type="string",JSONPath=".spec.user"
+//+kubebuilder:printcolumn:name="Impersonator",type="string",JSONPath=".spec.impersonator"
+//+kubebuilder:printcolumn:name="Revoked",type="boolean",JSONPath=".spec.revoked"
+//+kubebuilder:printcolumn:name="LastActiveTime",type="date",JSONPath=".status.lastActiveTime"
+
+// Session is the Schema for the sessions API, the name of
+// Session is the id (jti) carried by tokens of the session.
+type Session struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SessionSpec   `json:"spec,omitempty"`
+	Status SessionStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// SessionList contains a list of Session
+type SessionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Session `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Session{}, &SessionList{})
+}
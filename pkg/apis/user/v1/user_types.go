@@ -23,6 +23,7 @@ import (
 type UserState string
 type LoginType string
 type Language string
+type AccountType string
 
 const (
 	NormalState    UserState = "normal"
@@ -32,9 +33,16 @@ const (
 	OpenIdLogin LoginType = "openId"
 	LDAPLogin   LoginType = "ldap"
 	GitHubLogin LoginType = "github"
+	SAMLLogin   LoginType = "saml"
 
 	English Language = "en"
 	Chinese Language = "zh"
+
+	// HumanAccount is the default account type for users who can login
+	HumanAccount AccountType = "human"
+	// RobotAccount is the account type for machines like CI systems, which
+	// has no password and can not login, only be accessed by keys
+	RobotAccount AccountType = "robot"
 )
 
 // UserSpec defines the desired state of User
@@ -53,6 +61,23 @@ type UserSpec struct {
 	// ScopeBindings indicates user relationships with tenant,project or platform
 	// +optional
 	ScopeBindings []ScopeBinding `json:"scopeBindings,omitempty"`
+
+	// AccountType is the type of account, human if empty
+	// +optional
+	AccountType AccountType `json:"accountType,omitempty"`
+
+	// Owner is the tenant or project which robot account belongs to
+	// +optional
+	Owner *AccountOwner `json:"owner,omitempty"`
+}
+
+// AccountOwner indicates the tenant or project owns the robot account
+type AccountOwner struct {
+	// ScopeType the owner scope type that support tenant and project.
+	ScopeType BindingScopeType `json:"scopeType"`
+
+	// ScopeName the specific tenant or project name.
+	ScopeName string `json:"scopeName"`
 }
 
 type BindingScopeType string
@@ -72,6 +97,11 @@ type ScopeBinding struct {
 
 	// Role the rbac role name.
 	Role string `json:"role"`
+
+	// ExpireTime is when the binding stops to take effect, the binding
+	// never expires if unset.
+	// +optional
+	ExpireTime *metav1.Time `json:"expireTime,omitempty"`
 }
 
 // UserStatus defines the observed state of User
@@ -107,6 +137,7 @@ type ProjectInfo struct {
 //+kubebuilder:subresource:status
 //+kubebuilder:resource:categories="user",scope="Cluster"
 //+kubebuilder:printcolumn:name="LoginType",type="string",JSONPath=".spec.loginType"
+//+kubebuilder:printcolumn:name="AccountType",type="string",JSONPath=".spec.accountType"
 //+kubebuilder:printcolumn:name="LastLoginTime",type="date",JSONPath=".status.lastLoginTime"
 
 // User is the Schema for the users API
@@ -141,3 +172,8 @@ func (u *User) IsUserPlatformScope() bool {
 	}
 	return platformScope
 }
+
+// IsRobot tells if the user is a robot account
+func (u *User) IsRobot() bool {
+	return u.Spec.AccountType == RobotAccount
+}
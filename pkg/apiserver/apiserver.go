@@ -24,24 +24,33 @@ import (
 
 	_ "github.com/kubecube-io/kubecube/docs"
 	_ "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/apiserver/cubeapi/accessrequest"
 	"github.com/kubecube-io/kubecube/pkg/apiserver/cubeapi/authorization"
+	"github.com/kubecube-io/kubecube/pkg/apiserver/cubeapi/chargeback"
 	"github.com/kubecube-io/kubecube/pkg/apiserver/cubeapi/cluster"
 	"github.com/kubecube-io/kubecube/pkg/apiserver/cubeapi/healthz"
+	"github.com/kubecube-io/kubecube/pkg/apiserver/cubeapi/history"
 	"github.com/kubecube-io/kubecube/pkg/apiserver/cubeapi/k8s"
 	"github.com/kubecube-io/kubecube/pkg/apiserver/cubeapi/key"
+	"github.com/kubecube-io/kubecube/pkg/apiserver/cubeapi/quota"
+	"github.com/kubecube-io/kubecube/pkg/apiserver/cubeapi/quotarequest"
 	resourcemanage "github.com/kubecube-io/kubecube/pkg/apiserver/cubeapi/resourcemanage/handle"
+	"github.com/kubecube-io/kubecube/pkg/apiserver/cubeapi/scim"
 	"github.com/kubecube-io/kubecube/pkg/apiserver/cubeapi/scout"
 	"github.com/kubecube-io/kubecube/pkg/apiserver/cubeapi/user"
 	"github.com/kubecube-io/kubecube/pkg/apiserver/cubeapi/yamldeploy"
 	"github.com/kubecube-io/kubecube/pkg/apiserver/middlewares"
+	"github.com/kubecube-io/kubecube/pkg/authentication/identityprovider/saml"
 	"github.com/kubecube-io/kubecube/pkg/clog"
 	"github.com/kubecube-io/kubecube/pkg/utils/constants"
 	_ "github.com/kubecube-io/kubecube/pkg/utils/errcode"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	_ "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
 // APIServer aggregates all cube apis
@@ -71,8 +80,26 @@ func registerCubeAPI(cfg *Config) http.Handler {
 	// authZ apis handler
 	authorization.NewHandler().AddApisTo(router)
 
+	// access requests apis handler
+	accessrequest.NewHandler().AddApisTo(router)
+
+	// cube resource quotas apis handler
+	quota.NewHandler().AddApisTo(router)
+	quotarequest.NewHandler().AddApisTo(router)
+	// chargeback requests are forwarded to leader on the port replicas serve
+	leaderPort, leaderCert := cfg.InsecurePort, ""
+	if cfg.SecurePort != 0 {
+		leaderPort, leaderCert = cfg.SecurePort, cfg.TlsCert
+	}
+	chargeback.NewHandler(leaderPort, leaderCert).AddApisTo(router)
+	history.NewHandler().AddApisTo(router)
+
 	router.POST(constants.ApiPathRoot+"/login", user.Login)
 	router.GET(constants.ApiPathRoot+"/oauth/redirect", user.GitHubLogin)
+	router.GET(saml.MetadataPath, user.SamlMetadata)
+	router.GET(saml.LoginPath, user.SamlLogin)
+	router.POST(saml.AcsPath, user.SamlAcs)
+	router.POST(constants.ApiPathRoot+"/logout", user.Logout)
 
 	userManage := router.Group(constants.ApiPathRoot + "/user")
 	{
@@ -85,6 +112,14 @@ func registerCubeAPI(cfg *Config) http.Handler {
 		userManage.GET("/members", user.GetMembersByNS)
 		userManage.GET("/valid/:username", user.CheckUserValid)
 		userManage.PUT("/pwd", user.UpdatePwd)
+		userManage.POST("/pwd/reset-request", user.RequestPwdReset)
+		userManage.POST("/pwd/reset", user.ConfirmPwdReset)
+		userManage.GET("/sessions", user.ListSessions)
+		userManage.DELETE("/sessions", user.RevokeSessions)
+		userManage.DELETE("/sessions/:session", user.RevokeSession)
+		userManage.POST("/impersonate", user.StartImpersonate)
+		userManage.DELETE("/impersonate", user.EndImpersonate)
+		userManage.POST("/ldap/sync", user.SyncLdap)
 	}
 
 	keyManage := router.Group(constants.ApiPathRoot + "/key")
@@ -95,6 +130,26 @@ func registerCubeAPI(cfg *Config) http.Handler {
 		keyManage.GET("", key.ListKey)
 	}
 
+	robotManage := router.Group(constants.ApiPathRoot + "/robots")
+	{
+		robotManage.POST("", user.CreateRobot)
+		robotManage.GET("", user.ListRobots)
+		robotManage.PUT("/:robot", user.UpdateRobot)
+		robotManage.DELETE("/:robot", user.DeleteRobot)
+		robotManage.POST("/:robot/keys", user.CreateRobotKey)
+		robotManage.GET("/:robot/keys", user.ListRobotKeys)
+		robotManage.DELETE("/:robot/keys/:key", user.DeleteRobotKey)
+	}
+
+	groupManage := router.Group(constants.ApiPathRoot + "/groups")
+	{
+		groupManage.POST("", user.CreateGroup)
+		groupManage.GET("", user.ListGroups)
+		groupManage.GET("/:group", user.GetGroup)
+		groupManage.PUT("/:group", user.UpdateGroup)
+		groupManage.DELETE("/:group", user.DeleteGroup)
+	}
+
 	k8sApiProxy := router.Group(constants.ApiPathRoot + "/proxy")
 	{
 		proxyHandler := resourcemanage.NewProxyHandler(cfg.EnableVersionConversion)
@@ -160,9 +215,11 @@ func withSimpleServer(s *APIServer) *APIServer {
 
 func apisOutsideMiddlewares(root *gin.Engine) {
 	scout.AddApisTo(root)
+	scim.NewHandler().AddApisTo(root)
 
 	root.GET(constants.ApiPathRoot+"/extend/configmap/:configmap", resourcemanage.GetConfigMap)
 	root.Any(constants.ApiK8sProxyPath+"/*path", k8s.NewHandler().LocalClusterProxy)
+	root.GET("/metrics", gin.WrapH(promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{})))
 }
 
 func (s *APIServer) Initialize() error {
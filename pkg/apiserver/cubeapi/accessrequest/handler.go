@@ -0,0 +1,523 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package accessrequest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	tenantv1 "github.com/kubecube-io/kubecube/pkg/apis/tenant/v1"
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/apiserver/cubeapi/approval"
+	"github.com/kubecube-io/kubecube/pkg/authorizer/rbac"
+	"github.com/kubecube-io/kubecube/pkg/clients"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	mgrclient "github.com/kubecube-io/kubecube/pkg/multicluster/client"
+	"github.com/kubecube-io/kubecube/pkg/utils/audit"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+	"github.com/kubecube-io/kubecube/pkg/utils/errcode"
+	"github.com/kubecube-io/kubecube/pkg/utils/response"
+	"github.com/kubecube-io/kubecube/pkg/utils/transition"
+)
+
+const (
+	subPath = "/accessrequests"
+
+	resourceType = "accessrequest"
+)
+
+func (h *handler) AddApisTo(root *gin.Engine) {
+	r := root.Group(constants.ApiPathRoot + subPath)
+	r.POST("", h.createAccessRequest)
+	r.GET("", h.listAccessRequests)
+	r.GET("/:name", h.getAccessRequest)
+	r.POST("/:name/approve", h.approveAccessRequest)
+	r.POST("/:name/reject", h.rejectAccessRequest)
+	r.POST("/:name/cancel", h.cancelAccessRequest)
+	r.POST("/:name/comments", h.commentAccessRequest)
+}
+
+type result struct {
+	Total int                    `json:"total"`
+	Items []userv1.AccessRequest `json:"items"`
+}
+
+// CreateParam is the body to ask for role in tenant or project
+type CreateParam struct {
+	ScopeType     userv1.BindingScopeType `json:"scopeType"`
+	ScopeName     string                  `json:"scopeName"`
+	Role          string                  `json:"role"`
+	Justification string                  `json:"justification"`
+	// Duration is how long the granted role lasts such as "72h", permanent if empty
+	Duration *metav1.Duration `json:"duration,omitempty"`
+}
+
+// CommentParam is the body to comment or decide access request
+type CommentParam struct {
+	Comment string `json:"comment"`
+}
+
+type handler struct {
+	rbac.Interface
+	mgrclient.Client
+
+	now func() time.Time
+}
+
+func NewHandler() *handler {
+	h := new(handler)
+	h.Interface = rbac.NewDefaultResolver(constants.LocalCluster)
+	h.Client = clients.Interface().Kubernetes(constants.LocalCluster)
+	h.now = time.Now
+	return h
+}
+
+// createAccessRequest asks for role in tenant or project
+// @Summary Create access request
+// @Description current user asks for role in tenant or project, the request is routed to admins of the scope
+// @Tags accessrequest
+// @Param param body CreateParam true "role asked for"
+// @Success 200 {object} userv1.AccessRequest
+// @Failure 400 {object} errcode.ErrorInfo
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/accessrequests [post]
+func (h *handler) createAccessRequest(c *gin.Context) {
+	ctx := c.Request.Context()
+	userName := c.GetString(constants.UserName)
+
+	param := &CreateParam{}
+	if err := c.ShouldBindJSON(param); err != nil {
+		response.FailReturn(c, errcode.InvalidBodyFormat)
+		return
+	}
+	if len(param.ScopeName) == 0 || len(param.Role) == 0 || len(strings.TrimSpace(param.Justification)) == 0 {
+		response.FailReturn(c, errcode.ParamsInvalid(fmt.Errorf("scopeName, role and justification are required")))
+		return
+	}
+	if param.Duration != nil && param.Duration.Duration <= 0 {
+		response.FailReturn(c, errcode.ParamsInvalid(fmt.Errorf("duration must be positive")))
+		return
+	}
+
+	approvers, err := h.approversOf(ctx, param.ScopeType, param.ScopeName)
+	if err != nil {
+		response.FailReturn(c, errcode.ParamsInvalid(err))
+		return
+	}
+	if err = h.checkRole(param.ScopeType, param.Role); err != nil {
+		response.FailReturn(c, errcode.ParamsInvalid(err))
+		return
+	}
+
+	list := &userv1.AccessRequestList{}
+	if err = h.Direct().List(ctx, list); err != nil {
+		clog.Error("list access requests failed: %v", err)
+		response.FailReturn(c, errcode.GetResourceError(resourceType))
+		return
+	}
+	for _, r := range list.Items {
+		if r.GetPhase() == userv1.AccessRequestPending && r.Spec.User == userName &&
+			r.Spec.ScopeType == param.ScopeType && r.Spec.ScopeName == param.ScopeName && r.Spec.Role == param.Role {
+			response.FailReturn(c, errcode.AlreadyExist(r.Name))
+			return
+		}
+	}
+
+	emails := make([]string, 0, len(approvers))
+	names := make([]string, 0, len(approvers))
+	for _, u := range approvers {
+		// requester can not approve own request
+		if u.Name == userName {
+			continue
+		}
+		names = append(names, u.Name)
+		if len(u.Spec.Email) > 0 {
+			emails = append(emails, u.Spec.Email)
+		}
+	}
+	sort.Strings(names)
+
+	r := &userv1.AccessRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: uuid.NewString()},
+		Spec: userv1.AccessRequestSpec{
+			User:          userName,
+			ScopeType:     param.ScopeType,
+			ScopeName:     param.ScopeName,
+			Role:          param.Role,
+			Justification: param.Justification,
+			Duration:      param.Duration,
+		},
+		Status: userv1.AccessRequestStatus{
+			Phase:     userv1.AccessRequestPending,
+			Approvers: names,
+		},
+	}
+	if err = h.Direct().Create(ctx, r); err != nil {
+		clog.Error("create access request of user %v failed: %v", userName, err)
+		response.FailReturn(c, errcode.CreateResourceError(resourceType))
+		return
+	}
+
+	clog.Info("user %v asks for role %v of %v %v, approvers: %v", userName, r.Spec.Role, r.Spec.ScopeType, r.Spec.ScopeName, names)
+	approval.Notify(emails, fmt.Sprintf("Access request from %v", userName),
+		fmt.Sprintf("User %v asks for role %v of %v %v.\n\nJustification: %v\n\nRequest: %v\n",
+			userName, r.Spec.Role, r.Spec.ScopeType, r.Spec.ScopeName, r.Spec.Justification, r.Name))
+
+	c = audit.SetAuditInfo(c, audit.CreateAccessRequest, r.Name, param)
+	response.SuccessReturn(c, r)
+}
+
+// listAccessRequests list access requests visible to current user
+// @Summary List access requests
+// @Description list access requests asked by current user or routed to current user, platform admins see all
+// @Tags accessrequest
+// @Param phase query string false "phase of request: Pending, Approved, Rejected or Cancelled"
+// @Success 200 {object} result
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/accessrequests [get]
+func (h *handler) listAccessRequests(c *gin.Context) {
+	userName := c.GetString(constants.UserName)
+	phase := c.Query("phase")
+
+	list := &userv1.AccessRequestList{}
+	if err := h.Direct().List(c.Request.Context(), list); err != nil {
+		clog.Error("list access requests failed: %v", err)
+		response.FailReturn(c, errcode.GetResourceError(resourceType))
+		return
+	}
+
+	admin := h.workflow().IsPlatformAdmin(userName)
+	items := make([]userv1.AccessRequest, 0)
+	for _, r := range list.Items {
+		if len(phase) > 0 && string(r.GetPhase()) != phase {
+			continue
+		}
+		if admin || r.Spec.User == userName || r.IsApprover(userName) {
+			items = append(items, r)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[j].CreationTimestamp.Before(&items[i].CreationTimestamp)
+	})
+
+	response.SuccessReturn(c, result{Total: len(items), Items: items})
+}
+
+// getAccessRequest get access request by name
+// @Summary Get access request
+// @Description get access request asked by current user or routed to current user
+// @Tags accessrequest
+// @Param name path string true "access request name"
+// @Success 200 {object} userv1.AccessRequest
+// @Failure 403 {object} errcode.ErrorInfo
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/accessrequests/{name} [get]
+func (h *handler) getAccessRequest(c *gin.Context) {
+	userName := c.GetString(constants.UserName)
+
+	w := h.workflow()
+	r, errInfo := w.Get(c.Request.Context(), c.Param("name"))
+	if errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+	if !w.CanView(r, userName) {
+		response.FailReturn(c, errcode.ForbiddenErr)
+		return
+	}
+
+	response.SuccessReturn(c, r)
+}
+
+// approveAccessRequest approve access request and grant the role
+// @Summary Approve access request
+// @Description approver or platform admin approves the request, the role is granted to requester at once
+// @Tags accessrequest
+// @Param name path string true "access request name"
+// @Param param body CommentParam false "comment of approval"
+// @Success 200 {object} userv1.AccessRequest
+// @Failure 403 {object} errcode.ErrorInfo
+// @Failure 409 {object} errcode.ErrorInfo
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/accessrequests/{name}/approve [post]
+func (h *handler) approveAccessRequest(c *gin.Context) {
+	h.decide(c, userv1.AccessRequestApproved)
+}
+
+// rejectAccessRequest reject access request
+// @Summary Reject access request
+// @Description approver or platform admin rejects the request
+// @Tags accessrequest
+// @Param name path string true "access request name"
+// @Param param body CommentParam false "comment of rejection"
+// @Success 200 {object} userv1.AccessRequest
+// @Failure 403 {object} errcode.ErrorInfo
+// @Failure 409 {object} errcode.ErrorInfo
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/accessrequests/{name}/reject [post]
+func (h *handler) rejectAccessRequest(c *gin.Context) {
+	h.decide(c, userv1.AccessRequestRejected)
+}
+
+// cancelAccessRequest cancel access request
+// @Summary Cancel access request
+// @Description requester cancels own pending request
+// @Tags accessrequest
+// @Param name path string true "access request name"
+// @Param param body CommentParam false "comment of cancellation"
+// @Success 200 {object} userv1.AccessRequest
+// @Failure 403 {object} errcode.ErrorInfo
+// @Failure 409 {object} errcode.ErrorInfo
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/accessrequests/{name}/cancel [post]
+func (h *handler) cancelAccessRequest(c *gin.Context) {
+	h.decide(c, userv1.AccessRequestCancelled)
+}
+
+// commentAccessRequest comment on access request
+// @Summary Comment access request
+// @Description requester, approvers and platform admins leave comment on request
+// @Tags accessrequest
+// @Param name path string true "access request name"
+// @Param param body CommentParam true "comment"
+// @Success 200 {object} userv1.AccessRequest
+// @Failure 403 {object} errcode.ErrorInfo
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/accessrequests/{name}/comments [post]
+func (h *handler) commentAccessRequest(c *gin.Context) {
+	ctx := c.Request.Context()
+	userName := c.GetString(constants.UserName)
+	name := c.Param("name")
+
+	param := &CommentParam{}
+	if err := c.ShouldBindJSON(param); err != nil {
+		response.FailReturn(c, errcode.InvalidBodyFormat)
+		return
+	}
+	if len(strings.TrimSpace(param.Comment)) == 0 {
+		response.FailReturn(c, errcode.ParamsMissing("comment"))
+		return
+	}
+
+	w := h.workflow()
+	r, errInfo := w.Get(ctx, name)
+	if errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+	if !w.CanView(r, userName) {
+		response.FailReturn(c, errcode.ForbiddenErr)
+		return
+	}
+
+	r, errInfo = w.Update(ctx, name, func(r *userv1.AccessRequest) *errcode.ErrorInfo {
+		r.Status.Comments = append(r.Status.Comments, userv1.AccessRequestComment{User: userName, Message: param.Comment, Time: metav1.NewTime(h.now())})
+		return nil
+	})
+	if errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+
+	c = audit.SetAuditInfo(c, audit.CommentAccessRequest, name, param)
+	response.SuccessReturn(c, r)
+}
+
+// decide moves pending request to given phase, the role is granted
+// after the request is marked approved.
+func (h *handler) decide(c *gin.Context, phase userv1.AccessRequestPhase) {
+	param := &CommentParam{}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(param); err != nil {
+			response.FailReturn(c, errcode.InvalidBodyFormat)
+			return
+		}
+	}
+
+	r, errInfo := h.workflow().Decide(c, string(phase), param.Comment, h.grant)
+	if errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+
+	event := audit.ApproveAccessRequest
+	switch phase {
+	case userv1.AccessRequestRejected:
+		event = audit.RejectAccessRequest
+	case userv1.AccessRequestCancelled:
+		event = audit.CancelAccessRequest
+	}
+	c = audit.SetAuditInfo(c, event, r.Name, param)
+	response.SuccessReturn(c, r)
+}
+
+// grant adds the scope binding asked by approved request to requester
+func (h *handler) grant(ctx context.Context, r *userv1.AccessRequest) *errcode.ErrorInfo {
+	u := &userv1.User{}
+	err := h.Direct().Get(ctx, types.NamespacedName{Name: r.Spec.User}, u)
+	if err == nil {
+		transition.GrantUserScopeBinding(u, string(r.Spec.ScopeType), r.Spec.ScopeName, r.Spec.Role, r.Status.BindingExpireTime)
+		err = transition.UpdateUserSpec(ctx, h.Direct(), u)
+	}
+	if err != nil {
+		clog.Error("grant role %v of %v %v to user %v failed: %v", r.Spec.Role, r.Spec.ScopeType, r.Spec.ScopeName, r.Spec.User, err)
+		return errcode.UpdateResourceError("user")
+	}
+	return nil
+}
+
+// scopeAdmin is the admin role bound in namespace of scope
+type scopeAdmin struct {
+	role      string
+	namespace string
+}
+
+// approversOf returns admins of tenant or project, tenant admins are
+// approvers of the projects under the tenant as well.
+func (h *handler) approversOf(ctx context.Context, scopeType userv1.BindingScopeType, scopeName string) ([]*userv1.User, error) {
+	var refs []scopeAdmin
+	switch scopeType {
+	case userv1.TenantScope:
+		tenant := &tenantv1.Tenant{}
+		if err := h.Cache().Get(ctx, types.NamespacedName{Name: scopeName}, tenant); err != nil {
+			return nil, fmt.Errorf("get tenant %v failed: %v", scopeName, err)
+		}
+		refs = append(refs, scopeAdmin{constants.TenantAdmin, constants.TenantNsPrefix + scopeName})
+	case userv1.ProjectScope:
+		project := &tenantv1.Project{}
+		if err := h.Cache().Get(ctx, types.NamespacedName{Name: scopeName}, project); err != nil {
+			return nil, fmt.Errorf("get project %v failed: %v", scopeName, err)
+		}
+		refs = append(refs, scopeAdmin{constants.ProjectAdmin, constants.ProjectNsPrefix + scopeName})
+		if tenant := project.Labels[constants.TenantLabel]; len(tenant) > 0 {
+			refs = append(refs, scopeAdmin{constants.TenantAdmin, constants.TenantNsPrefix + tenant})
+		}
+	default:
+		return nil, fmt.Errorf("scopeType must be %v or %v", userv1.TenantScope, userv1.ProjectScope)
+	}
+
+	var approvers []*userv1.User
+	seen := make(map[string]bool)
+	for _, ref := range refs {
+		users, err := h.UsersFor(rbacv1.RoleRef{APIGroup: constants.K8sGroupRBAC, Kind: constants.KindClusterRole, Name: ref.role}, ref.namespace)
+		if err != nil {
+			clog.Warn("resolve users of %v in namespace %v failed: %v", ref.role, ref.namespace, err)
+		}
+		for _, u := range users {
+			if !seen[u.Name] {
+				seen[u.Name] = true
+				approvers = append(approvers, u)
+			}
+		}
+	}
+	return approvers, nil
+}
+
+// checkRole ensures the role asked for is a cluster role of scope level
+func (h *handler) checkRole(scopeType userv1.BindingScopeType, role string) error {
+	clusterRole, err := h.GetClusterRole(role)
+	if err != nil {
+		return fmt.Errorf("get cluster role %v failed: %v", role, err)
+	}
+	if clusterRole.Labels[constants.RoleLabel] != string(scopeType) {
+		return fmt.Errorf("cluster role %v is not a %v role", role, scopeType)
+	}
+	return nil
+}
+
+func (h *handler) workflow() *approval.Workflow[*userv1.AccessRequest] {
+	return &approval.Workflow[*userv1.AccessRequest]{
+		Adapter:  adapter{},
+		Resolver: h.Interface,
+		Client:   h.Client,
+		Now:      h.now,
+		Resource: resourceType,
+	}
+}
+
+// adapter lets access requests go through approval workflow
+type adapter struct{}
+
+func (adapter) New() *userv1.AccessRequest {
+	return &userv1.AccessRequest{}
+}
+
+func (adapter) Phase(r *userv1.AccessRequest) string {
+	return string(r.GetPhase())
+}
+
+func (adapter) Requester(r *userv1.AccessRequest) string {
+	return r.Spec.User
+}
+
+func (adapter) Decider(r *userv1.AccessRequest) string {
+	return r.Status.DecidedBy
+}
+
+func (adapter) IsApprover(r *userv1.AccessRequest, user string) bool {
+	return r.IsApprover(user)
+}
+
+// Record records decision and the time granted binding expires if approved
+func (adapter) Record(r *userv1.AccessRequest, d *approval.Decision) {
+	r.Status.Phase = userv1.AccessRequestPhase(d.Phase)
+	r.Status.DecidedBy = d.Decider
+	r.Status.DecisionTime = d.Time.DeepCopy()
+	if d.Phase == approval.Approved && r.Spec.Duration != nil {
+		r.Status.BindingExpireTime = &metav1.Time{Time: d.Time.Add(r.Spec.Duration.Duration)}
+	}
+	if len(d.Comment) > 0 {
+		r.Status.Comments = append(r.Status.Comments, userv1.AccessRequestComment{User: d.Decider, Message: d.Comment, Time: d.Time})
+	}
+}
+
+func (adapter) Undo(r *userv1.AccessRequest, d *approval.Decision) {
+	r.Status.Phase = userv1.AccessRequestPending
+	r.Status.DecidedBy = ""
+	r.Status.DecisionTime = nil
+	r.Status.BindingExpireTime = nil
+	if len(d.Comment) == 0 {
+		return
+	}
+	// time of comment is stored in seconds
+	for i := len(r.Status.Comments) - 1; i >= 0; i-- {
+		cm := r.Status.Comments[i]
+		if cm.User == d.Decider && cm.Message == d.Comment && cm.Time.Unix() == d.Time.Unix() {
+			r.Status.Comments = append(r.Status.Comments[:i], r.Status.Comments[i+1:]...)
+			return
+		}
+	}
+}
+
+func (adapter) Decided(phase string) *errcode.ErrorInfo {
+	return errcode.AccessRequestDecided(phase)
+}
+
+func (adapter) Notice(r *userv1.AccessRequest) (string, string) {
+	return fmt.Sprintf("Access request %v", strings.ToLower(string(r.Status.Phase))),
+		fmt.Sprintf("Your request for role %v of %v %v is %v by %v.\n\nRequest: %v\n",
+			r.Spec.Role, r.Spec.ScopeType, r.Spec.ScopeName, strings.ToLower(string(r.Status.Phase)), r.Status.DecidedBy, r.Name)
+}
@@ -0,0 +1,234 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package accessrequest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubecube-io/kubecube/pkg/apis"
+	tenantv1 "github.com/kubecube-io/kubecube/pkg/apis/tenant/v1"
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/authorizer/rbac"
+	"github.com/kubecube-io/kubecube/pkg/multicluster/client/fake"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+func newTestHandler(t *testing.T) (*gin.Engine, *handler, *string) {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	apis.AddToScheme(scheme)
+
+	newUser := func(name string, admin bool) client.Object {
+		return &userv1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     userv1.UserStatus{PlatformAdmin: admin},
+		}
+	}
+	newRole := func(name, level string) client.Object {
+		return &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{constants.RoleLabel: level}}}
+	}
+	cli := fake.NewFakeClients(&fake.Options{Scheme: scheme, Objs: []client.Object{
+		newUser("alice", false), newUser("bob", false), newUser("carol", false), newUser("admin", true),
+		newRole(constants.ProjectAdmin, constants.ClusterRoleProject), newRole("reviewer", constants.ClusterRoleProject),
+		newRole(constants.TenantAdmin, constants.ClusterRoleTenant),
+		&tenantv1.Tenant{ObjectMeta: metav1.ObjectMeta{Name: "tenant-1"}},
+		&tenantv1.Project{ObjectMeta: metav1.ObjectMeta{Name: "project-1", Labels: map[string]string{constants.TenantLabel: "tenant-1"}}},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "bob-project-admin", Namespace: constants.ProjectNsPrefix + "project-1"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: constants.K8sGroupRBAC, Kind: constants.KindClusterRole, Name: constants.ProjectAdmin},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "bob"}},
+		},
+	}})
+
+	h := &handler{Interface: &rbac.DefaultResolver{Cache: cli.Cache()}, Client: cli, now: time.Now}
+	requester := new(string)
+	router := gin.New()
+	// stands in for auth middleware
+	router.Use(func(c *gin.Context) {
+		c.Set(constants.UserName, *requester)
+	})
+	h.AddApisTo(router)
+	return router, h, requester
+}
+
+func perform(router *gin.Engine, method, path string, body interface{}) *httptest.ResponseRecorder {
+	data, _ := json.Marshal(body)
+	req, _ := http.NewRequest(method, constants.ApiPathRoot+subPath+path, bytes.NewReader(data))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestApproveAccessRequest(t *testing.T) {
+	router, h, requester := newTestHandler(t)
+
+	*requester = "alice"
+	w := perform(router, http.MethodPost, "", CreateParam{
+		ScopeType:     userv1.ProjectScope,
+		ScopeName:     "project-1",
+		Role:          "reviewer",
+		Justification: "review release of project-1",
+		Duration:      &metav1.Duration{Duration: 72 * time.Hour},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("create access request failed: %v", w.Body.String())
+	}
+	r := &userv1.AccessRequest{}
+	_ = json.Unmarshal(w.Body.Bytes(), r)
+	if len(r.Status.Approvers) != 1 || r.Status.Approvers[0] != "bob" {
+		t.Fatalf("project admin should be approver, got %v", r.Status.Approvers)
+	}
+
+	// same pending request is not allowed
+	w = perform(router, http.MethodPost, "", r.Spec)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("duplicated request should be rejected, got %v", w.Code)
+	}
+
+	for _, u := range []string{"alice", "carol"} {
+		*requester = u
+		if w = perform(router, http.MethodPost, "/"+r.Name+"/approve", nil); w.Code != http.StatusForbidden {
+			t.Fatalf("%v should not approve the request, got %v", u, w.Code)
+		}
+	}
+	if w = perform(router, http.MethodGet, "/"+r.Name, nil); w.Code != http.StatusForbidden {
+		t.Fatalf("carol should not see the request, got %v", w.Code)
+	}
+
+	*requester = "bob"
+	if w = perform(router, http.MethodPost, "/"+r.Name+"/comments", CommentParam{Comment: "for how long?"}); w.Code != http.StatusOK {
+		t.Fatalf("approver should comment the request: %v", w.Body.String())
+	}
+	if w = perform(router, http.MethodPost, "/"+r.Name+"/approve", CommentParam{Comment: "ok"}); w.Code != http.StatusOK {
+		t.Fatalf("approver should approve the request: %v", w.Body.String())
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), r)
+	if r.Status.Phase != userv1.AccessRequestApproved || r.Status.DecidedBy != "bob" || len(r.Status.Comments) != 2 {
+		t.Fatalf("unexpected status after approval: %+v", r.Status)
+	}
+
+	u := &userv1.User{}
+	if err := h.Direct().Get(context.Background(), types.NamespacedName{Name: "alice"}, u); err != nil {
+		t.Fatal(err)
+	}
+	if len(u.Spec.ScopeBindings) != 1 || u.Spec.ScopeBindings[0].Role != "reviewer" ||
+		!u.Spec.ScopeBindings[0].ExpireTime.Equal(r.Status.BindingExpireTime) {
+		t.Fatalf("role should be granted until %v, got %+v", r.Status.BindingExpireTime, u.Spec.ScopeBindings)
+	}
+
+	*requester = "admin"
+	if w = perform(router, http.MethodPost, "/"+r.Name+"/reject", nil); w.Code != http.StatusConflict {
+		t.Fatalf("decided request can not be rejected, got %v", w.Code)
+	}
+}
+
+func TestApproveRollbackWhenGrantFails(t *testing.T) {
+	router, h, requester := newTestHandler(t)
+
+	*requester = "alice"
+	w := perform(router, http.MethodPost, "", CreateParam{
+		ScopeType:     userv1.ProjectScope,
+		ScopeName:     "project-1",
+		Role:          "reviewer",
+		Justification: "review release of project-1",
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("create access request failed: %v", w.Body.String())
+	}
+	r := &userv1.AccessRequest{}
+	_ = json.Unmarshal(w.Body.Bytes(), r)
+
+	// role can not be granted to user gone
+	ctx := context.Background()
+	if err := h.Direct().Delete(ctx, &userv1.User{ObjectMeta: metav1.ObjectMeta{Name: "alice"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	*requester = "bob"
+	if w = perform(router, http.MethodPost, "/"+r.Name+"/approve", CommentParam{Comment: "ok"}); w.Code != http.StatusInternalServerError {
+		t.Fatalf("approval should fail when grant fails, got %v", w.Code)
+	}
+	if err := h.Direct().Get(ctx, types.NamespacedName{Name: r.Name}, r); err != nil {
+		t.Fatal(err)
+	}
+	if r.GetPhase() != userv1.AccessRequestPending || len(r.Status.DecidedBy) > 0 || len(r.Status.Comments) != 0 {
+		t.Fatalf("request should be back to pending, got %+v", r.Status)
+	}
+}
+
+func TestCancelAccessRequest(t *testing.T) {
+	router, _, requester := newTestHandler(t)
+
+	*requester = "carol"
+	w := perform(router, http.MethodPost, "", CreateParam{
+		ScopeType:     userv1.ProjectScope,
+		ScopeName:     "project-1",
+		Role:          constants.TenantAdmin,
+		Justification: "manage tenant",
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("tenant role can not be asked in project, got %v", w.Code)
+	}
+
+	w = perform(router, http.MethodPost, "", CreateParam{
+		ScopeType:     userv1.TenantScope,
+		ScopeName:     "tenant-1",
+		Role:          constants.TenantAdmin,
+		Justification: "manage tenant",
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("create access request failed: %v", w.Body.String())
+	}
+	r := &userv1.AccessRequest{}
+	_ = json.Unmarshal(w.Body.Bytes(), r)
+
+	*requester = "admin"
+	if w = perform(router, http.MethodPost, "/"+r.Name+"/cancel", nil); w.Code != http.StatusForbidden {
+		t.Fatalf("only requester cancels the request, got %v", w.Code)
+	}
+	w = perform(router, http.MethodGet, "?phase=Pending", nil)
+	list := &struct{ Total int }{}
+	_ = json.Unmarshal(w.Body.Bytes(), list)
+	if list.Total != 1 {
+		t.Fatalf("platform admin should see pending request, got %v", w.Body.String())
+	}
+
+	*requester = "carol"
+	if w = perform(router, http.MethodPost, "/"+r.Name+"/cancel", nil); w.Code != http.StatusOK {
+		t.Fatalf("requester should cancel the request: %v", w.Body.String())
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), r)
+	if r.Status.Phase != userv1.AccessRequestCancelled {
+		t.Fatalf("request should be cancelled, got %v", r.Status.Phase)
+	}
+}
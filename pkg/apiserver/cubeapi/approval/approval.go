@@ -0,0 +1,251 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package approval is the workflow shared by requests decided by approvers,
+// such as access requests and quota requests. A request is filed pending,
+// then approved or rejected by approvers or platform admins, or cancelled
+// by requester.
+package approval
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/authorizer/rbac"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	mgrclient "github.com/kubecube-io/kubecube/pkg/multicluster/client"
+	"github.com/kubecube-io/kubecube/pkg/notification"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+	"github.com/kubecube-io/kubecube/pkg/utils/errcode"
+)
+
+// phases shared by all kinds of requests
+const (
+	Pending   = "Pending"
+	Approved  = "Approved"
+	Rejected  = "Rejected"
+	Cancelled = "Cancelled"
+)
+
+// Decision is made on pending request by decider
+type Decision struct {
+	Phase   string
+	Decider string
+	Time    metav1.Time
+	Comment string
+}
+
+// Adapter reads and writes fields of one kind of request for workflow
+type Adapter[R client.Object] interface {
+	// New returns empty request to get into
+	New() R
+	Phase(r R) string
+	Requester(r R) string
+	Decider(r R) string
+	IsApprover(r R, user string) bool
+
+	// Record records decision on request
+	Record(r R, d *Decision)
+	// Undo reverts recorded decision so that request is pending again
+	Undo(r R, d *Decision)
+	// Decided is the error returned when request is not pending
+	Decided(phase string) *errcode.ErrorInfo
+	// Notice is the mail sent to requester once request decided
+	Notice(r R) (subject, body string)
+}
+
+// Workflow moves requests of one kind between phases
+type Workflow[R client.Object] struct {
+	Adapter[R]
+
+	Resolver rbac.Interface
+	Client   mgrclient.Client
+	Now      func() time.Time
+
+	// Resource is the resource type of request in errors and logs
+	Resource string
+}
+
+// Decide moves pending request named in path to given phase by current
+// user. Apply is called after the request is marked approved and the
+// request goes back to pending if apply fails.
+func (w *Workflow[R]) Decide(c *gin.Context, phase, comment string, apply func(ctx context.Context, r R) *errcode.ErrorInfo) (R, *errcode.ErrorInfo) {
+	ctx := c.Request.Context()
+	userName := c.GetString(constants.UserName)
+	name := c.Param("name")
+
+	var zero R
+	r, errInfo := w.Get(ctx, name)
+	if errInfo != nil {
+		return zero, errInfo
+	}
+	if errInfo = w.CheckDecider(c, r, phase); errInfo != nil {
+		return zero, errInfo
+	}
+	if w.Phase(r) != Pending {
+		return zero, w.Decided(w.Phase(r))
+	}
+
+	d := &Decision{Phase: phase, Decider: userName, Time: metav1.NewTime(w.Now()), Comment: strings.TrimSpace(comment)}
+
+	// the phase is moved first so that concurrent decisions can not both
+	// succeed and nothing is applied by a request decided otherwise
+	r, errInfo = w.Update(ctx, name, func(r R) *errcode.ErrorInfo {
+		if w.Phase(r) != Pending {
+			return w.Decided(w.Phase(r))
+		}
+		w.Record(r, d)
+		return nil
+	})
+	if errInfo != nil {
+		return zero, errInfo
+	}
+
+	if phase == Approved && apply != nil {
+		if errInfo = apply(ctx, r); errInfo != nil {
+			w.rollback(ctx, name, d)
+			return zero, errInfo
+		}
+	}
+
+	clog.Info("%v %v of user %v is %v by %v", w.Resource, name, w.Requester(r), strings.ToLower(phase), userName)
+	if phase != Cancelled {
+		w.NotifyRequester(ctx, r)
+	}
+	return r, nil
+}
+
+// rollback moves request approved by decision back to pending when it
+// can not be applied, so that it can be approved again
+func (w *Workflow[R]) rollback(ctx context.Context, name string, d *Decision) {
+	_, errInfo := w.Update(ctx, name, func(r R) *errcode.ErrorInfo {
+		if w.Phase(r) != Approved || w.Decider(r) != d.Decider {
+			return w.Decided(w.Phase(r))
+		}
+		w.Undo(r, d)
+		return nil
+	})
+	if errInfo != nil {
+		clog.Error("rollback approval of %v %v failed: %v", w.Resource, name, errInfo.Message)
+	}
+}
+
+// CheckDecider tells if current user can move request to given phase,
+// only requester cancels request, and approvers or platform admins
+// approve or reject request of others.
+func (w *Workflow[R]) CheckDecider(c *gin.Context, r R, phase string) *errcode.ErrorInfo {
+	userName := c.GetString(constants.UserName)
+	if phase == Cancelled {
+		if w.Requester(r) != userName {
+			return errcode.ForbiddenErr
+		}
+		return nil
+	}
+	// the decision is made by real identity
+	if len(c.GetString(constants.Impersonator)) > 0 || w.Requester(r) == userName {
+		return errcode.ForbiddenErr
+	}
+	if !w.IsApprover(r, userName) && !w.IsPlatformAdmin(userName) {
+		return errcode.ForbiddenErr
+	}
+	return nil
+}
+
+// CanView tells if user is requester, approver of request or platform admin
+func (w *Workflow[R]) CanView(r R, userName string) bool {
+	return w.Requester(r) == userName || w.IsApprover(r, userName) || w.IsPlatformAdmin(userName)
+}
+
+func (w *Workflow[R]) Get(ctx context.Context, name string) (R, *errcode.ErrorInfo) {
+	r := w.New()
+	if err := w.Client.Direct().Get(ctx, types.NamespacedName{Name: name}, r); err != nil {
+		var zero R
+		if errors.IsNotFound(err) {
+			return zero, errcode.NotFoundErr
+		}
+		clog.Error("get %v %v failed: %v", w.Resource, name, err)
+		return zero, errcode.GetResourceError(w.Resource)
+	}
+	return r, nil
+}
+
+// Update applies fn to the latest request and retries on conflict
+func (w *Workflow[R]) Update(ctx context.Context, name string, fn func(r R) *errcode.ErrorInfo) (R, *errcode.ErrorInfo) {
+	var (
+		r       R
+		zero    R
+		errInfo *errcode.ErrorInfo
+	)
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		r, errInfo = w.Get(ctx, name)
+		if errInfo != nil {
+			return nil
+		}
+		if errInfo = fn(r); errInfo != nil {
+			return nil
+		}
+		return w.Client.Direct().Update(ctx, r)
+	})
+	if errInfo != nil {
+		return zero, errInfo
+	}
+	if err != nil {
+		clog.Error("update %v %v failed: %v", w.Resource, name, err)
+		return zero, errcode.UpdateResourceError(w.Resource)
+	}
+	return r, nil
+}
+
+func (w *Workflow[R]) IsPlatformAdmin(name string) bool {
+	u, err := w.Resolver.GetUser(name)
+	if err != nil {
+		return false
+	}
+	return userv1.IsPlatformAdmin(&u)
+}
+
+// NotifyRequester mails requester the decision on request
+func (w *Workflow[R]) NotifyRequester(ctx context.Context, r R) {
+	u := &userv1.User{}
+	if err := w.Client.Cache().Get(ctx, types.NamespacedName{Name: w.Requester(r)}, u); err != nil || len(u.Spec.Email) == 0 {
+		return
+	}
+	subject, body := w.Notice(r)
+	Notify([]string{u.Spec.Email}, subject, body)
+}
+
+// Notify mails users in background, requests work without notification
+func Notify(to []string, subject, body string) {
+	sender := notification.GetSender()
+	if sender == nil || len(to) == 0 {
+		return
+	}
+	go func() {
+		err := sender.Send(context.Background(), &notification.Message{To: to, Subject: subject, Body: body})
+		if err != nil {
+			clog.Warn("send notification %q failed: %v", subject, err)
+		}
+	}()
+}
@@ -22,11 +22,13 @@ import (
 	"net/http"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
@@ -42,6 +44,7 @@ import (
 	"github.com/kubecube-io/kubecube/pkg/clients"
 	"github.com/kubecube-io/kubecube/pkg/clog"
 	mgrclient "github.com/kubecube-io/kubecube/pkg/multicluster/client"
+	"github.com/kubecube-io/kubecube/pkg/utils/audit"
 	"github.com/kubecube-io/kubecube/pkg/utils/constants"
 	"github.com/kubecube-io/kubecube/pkg/utils/env"
 	"github.com/kubecube-io/kubecube/pkg/utils/errcode"
@@ -59,14 +62,20 @@ func (h *handler) AddApisTo(root *gin.Engine) {
 	r.GET("tenants", h.getTenantByUser)
 	r.GET("projects", h.getProjectByUser)
 	r.GET("identities", h.getIdentity)
+	r.GET("bindings", h.getBindsByUser)
 	r.POST("bindings", h.createBinds)
 	r.DELETE("bindings", h.deleteBinds)
 	r.POST("access", h.authorization)
+	r.POST("whocan", h.whoCan)
+	r.POST("whycan", h.whyCan)
 	r.POST("resources", h.resourcesGate)
 	r.GET("authitems/:clusterrole", h.getAuthItems)
 	r.GET("authitems", h.getAuthItemsByLabelSelector)
 	r.POST("authitems", h.setAuthItems)
 	r.POST("authitems/permissions", h.getPermissions)
+	r.POST("authitems/simulate", h.simulateAuthItems)
+	r.GET("authitems/:clusterrole/history", h.getAuthItemsHistory)
+	r.POST("authitems/:clusterrole/rollback", h.rollbackAuthItems)
 	r.GET("deamonsets/level", h.getDaemonSetsLevel)
 }
 
@@ -216,6 +225,11 @@ func (h *handler) setAuthItems(c *gin.Context) {
 		}
 	}
 
+	var previous *rbacv1.ClusterRole
+	if err == nil {
+		previous = clusterRole.DeepCopy()
+	}
+
 	newClusterRole := mapping.RoleAuthMapping(body, cmData)
 	newClusterRole.Annotations = annotations
 	newClusterRole.Labels = labels
@@ -230,6 +244,12 @@ func (h *handler) setAuthItems(c *gin.Context) {
 		return
 	}
 
+	// history is kept for rollback, it does not block the change
+	if err = h.recordRevision(c.Request.Context(), previous, runtimeObject, c.GetString(constants.UserName)); err != nil {
+		clog.Warn("record revision of ClusterRole %v failed: %v", runtimeObject.Name, err)
+	}
+
+	c = audit.SetAuditInfo(c, audit.UpdateAuthItems, body.ClusterRoleName, body)
 	response.SuccessReturn(c, nil)
 }
 
@@ -657,9 +677,52 @@ func (h *handler) getIdentity(c *gin.Context) {
 	response.SuccessReturn(c, r)
 }
 
+// scopeBindingStatus is scope binding with the countdown to its expire time
+type scopeBindingStatus struct {
+	user.ScopeBinding
+	// RemainingSeconds is the seconds before binding expires, omitted if binding never expires
+	RemainingSeconds *int64 `json:"remainingSeconds,omitempty"`
+}
+
+// getBindsByUser get scope bindings of user with remaining time
+// @Summary Get scope bindings
+// @Description get scope bindings of user, the remaining seconds are given for bindings will expire
+// @Tags authorization
+// @Param user query string false "user name, default to current user"
+// @Success 200 {object} result
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/authorization/bindings [get]
+func (h *handler) getBindsByUser(c *gin.Context) {
+	userName := c.Query("user")
+	if userName == "" {
+		userName = c.GetString(constants.UserName)
+	}
+
+	u := &user.User{}
+	err := h.Cache().Get(c.Request.Context(), types.NamespacedName{Name: userName}, u)
+	if err != nil {
+		clog.Error(err.Error())
+		response.FailReturn(c, errcode.BadRequest(err))
+		return
+	}
+
+	now := time.Now()
+	items := make([]scopeBindingStatus, 0, len(u.Spec.ScopeBindings))
+	for _, b := range user.ActiveScopeBindings(u.Spec.ScopeBindings, now) {
+		item := scopeBindingStatus{ScopeBinding: b}
+		if b.ExpireTime != nil {
+			remaining := int64(b.ExpireTime.Sub(now).Seconds())
+			item.RemainingSeconds = &remaining
+		}
+		items = append(items, item)
+	}
+
+	response.SuccessReturn(c, result{Total: len(items), Items: items})
+}
+
 // createBinds create roleBinding and clusterRoleBinding
 // @Summary Create roleBinding
-// @Description create roleBinding and clusterRoleBinding
+// @Description create roleBinding and clusterRoleBinding, the binding expires at the RFC3339 time given by annotation user.kubecube.io/expire-time if set
 // @Tags authorization
 // @Param roleBinding body rbacv1.RoleBinding true "roleBinding data"
 // @Success 200 {string} string "success"
@@ -688,6 +751,17 @@ func (h *handler) createBinds(c *gin.Context) {
 		return
 	}
 
+	// binding is permanent unless expire time given
+	var expireTime *metav1.Time
+	if v, ok := roleBinding.Annotations[constants.BindingExpireTimeAnnotation]; ok {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil || !t.After(time.Now()) {
+			response.FailReturn(c, errcode.ParamsInvalid(fmt.Errorf("%v must be a future RFC3339 time", constants.BindingExpireTimeAnnotation)))
+			return
+		}
+		expireTime = &metav1.Time{Time: t}
+	}
+
 	// add user scope binding
 	u := &user.User{}
 	err = cli.Cache().Get(ctx, types.NamespacedName{Name: userName}, u)
@@ -697,7 +771,7 @@ func (h *handler) createBinds(c *gin.Context) {
 		return
 	}
 
-	transition.AddUserScopeBindings(u, scopeType, scopeName, role)
+	transition.GrantUserScopeBinding(u, scopeType, scopeName, role, expireTime)
 
 	err = transition.UpdateUserSpec(ctx, cli.Direct(), u)
 	if err != nil {
@@ -706,6 +780,12 @@ func (h *handler) createBinds(c *gin.Context) {
 		return
 	}
 
+	c = audit.SetAuditInfo(c, audit.GrantBinding, userName, user.ScopeBinding{
+		ScopeType:  user.BindingScopeType(scopeType),
+		ScopeName:  scopeName,
+		Role:       role,
+		ExpireTime: expireTime,
+	})
 	response.SuccessJsonReturn(c, "success")
 }
 
@@ -812,17 +892,8 @@ type attributes struct {
 	Path            string `json:"path,omitempty"`
 }
 
-// authorization is out way for authorize by KubeCube
-func (h *handler) authorization(c *gin.Context) {
-	a := &attributes{}
-	err := c.ShouldBindJSON(a)
-	if err != nil {
-		clog.Error(err.Error())
-		response.FailReturn(c, errcode.InvalidBodyFormat)
-		return
-	}
-
-	record := &authorizer.AttributesRecord{
+func (a *attributes) record() *authorizer.AttributesRecord {
+	return &authorizer.AttributesRecord{
 		User:            &userinfo.DefaultInfo{Name: a.User},
 		Verb:            a.Verb,
 		Namespace:       a.Namespace,
@@ -834,6 +905,19 @@ func (h *handler) authorization(c *gin.Context) {
 		ResourceRequest: a.ResourceRequest,
 		Path:            a.Path,
 	}
+}
+
+// authorization is out way for authorize by KubeCube
+func (h *handler) authorization(c *gin.Context) {
+	a := &attributes{}
+	err := c.ShouldBindJSON(a)
+	if err != nil {
+		clog.Error(err.Error())
+		response.FailReturn(c, errcode.InvalidBodyFormat)
+		return
+	}
+
+	record := a.record()
 
 	// do auth access in local cluster by default
 	// todo: remove it as soon as other caller complete retrofit
@@ -259,6 +259,14 @@ func isPlatformRole(labels map[string]string) bool {
 	return labels[constants.RoleLabel] == constants.ClusterRolePlatform
 }
 
+// authItemsOf returns auth items config of ClusterRole with labels
+func (h *handler) authItemsOf(labels map[string]string) map[string]string {
+	if isPlatformRole(labels) {
+		return h.platformCmData
+	}
+	return h.cmData
+}
+
 func GetVisibleTenants(ctx context.Context, cli mgrclient.Client, username string) ([]tenantv1.Tenant, error) {
 	user := userv1.User{}
 	err := cli.Cache().Get(ctx, types.NamespacedName{Name: username}, &user)
@@ -0,0 +1,242 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package authorization
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	appsv1 "k8s.io/api/apps/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubecube-io/kubecube/pkg/authorizer/mapping"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/utils/audit"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+	"github.com/kubecube-io/kubecube/pkg/utils/env"
+	"github.com/kubecube-io/kubecube/pkg/utils/errcode"
+	"github.com/kubecube-io/kubecube/pkg/utils/response"
+)
+
+// maxRoleRevisions is the number of revisions kept for every ClusterRole
+const maxRoleRevisions = 20
+
+// revisionData is the content of ClusterRole revision
+type revisionData struct {
+	Rules []rbacv1.PolicyRule `json:"rules"`
+}
+
+type roleRevision struct {
+	Revision  int64                 `json:"revision"`
+	ChangedBy string                `json:"changedBy,omitempty"`
+	Time      metav1.Time           `json:"time"`
+	AuthItems *mapping.RoleAuthBody `json:"authItems"`
+}
+
+type rollbackParam struct {
+	Revision int64 `json:"revision"`
+}
+
+// listRevisions returns revisions of ClusterRole in ascending order, the
+// revisions are kept as ControllerRevision in namespace of kubecube.
+func (h *handler) listRevisions(ctx context.Context, clusterRole string) ([]appsv1.ControllerRevision, error) {
+	list := &appsv1.ControllerRevisionList{}
+	err := h.Direct().List(ctx, list, client.InNamespace(env.CubeNamespace()), client.MatchingLabels{constants.ClusterRoleLabel: clusterRole})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list.Items, func(i, j int) bool {
+		return list.Items[i].Revision < list.Items[j].Revision
+	})
+	return list.Items, nil
+}
+
+// recordRevision records rules of ClusterRole as next revision, the
+// previous rules are recorded first if ClusterRole has no history yet.
+func (h *handler) recordRevision(ctx context.Context, previous, current *rbacv1.ClusterRole, user string) error {
+	revisions, err := h.listRevisions(ctx, current.Name)
+	if err != nil {
+		return err
+	}
+
+	var next int64 = 1
+	if len(revisions) > 0 {
+		next = revisions[len(revisions)-1].Revision + 1
+	} else if previous != nil {
+		if err = h.createRevision(ctx, previous, next, ""); err != nil {
+			return err
+		}
+		next++
+	}
+	if err = h.createRevision(ctx, current, next, user); err != nil {
+		return err
+	}
+
+	// drop the oldest revisions
+	for i := 0; i < len(revisions)+1-maxRoleRevisions; i++ {
+		if err = h.Direct().Delete(ctx, &revisions[i]); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *handler) createRevision(ctx context.Context, clusterRole *rbacv1.ClusterRole, revision int64, user string) error {
+	data, err := json.Marshal(revisionData{Rules: clusterRole.Rules})
+	if err != nil {
+		return err
+	}
+	r := &appsv1.ControllerRevision{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        fmt.Sprintf("%v-%d", clusterRole.Name, revision),
+			Namespace:   env.CubeNamespace(),
+			Labels:      map[string]string{constants.ClusterRoleLabel: clusterRole.Name},
+			Annotations: map[string]string{constants.ChangedByAnnotation: user},
+		},
+		Data:     runtime.RawExtension{Raw: data},
+		Revision: revision,
+	}
+	return h.Direct().Create(ctx, r)
+}
+
+// getAuthItemsHistory get revisions of ClusterRole
+// @Summary Get history of auth items
+// @Description list revisions of ClusterRole changed by setting auth items or rollback
+// @Tags authorization
+// @Param clusterrole path string true "ClusterRole name"
+// @Param verbose query string false "show resources of auth items if true"
+// @Success 200 {object} result
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/authorization/authitems/{clusterrole}/history [get]
+func (h *handler) getAuthItemsHistory(c *gin.Context) {
+	name := c.Param("clusterrole")
+	verbose := c.Query("verbose") == "true"
+
+	clusterRole := &rbacv1.ClusterRole{}
+	if err := h.Direct().Get(c.Request.Context(), types.NamespacedName{Name: name}, clusterRole); err != nil {
+		if errors.IsNotFound(err) {
+			response.FailReturn(c, errcode.CustomReturn(http.StatusNotFound, err.Error()))
+			return
+		}
+		response.FailReturn(c, errcode.BadRequest(err))
+		return
+	}
+	cmData := h.authItemsOf(clusterRole.Labels)
+
+	revisions, err := h.listRevisions(c.Request.Context(), name)
+	if err != nil {
+		response.FailReturn(c, errcode.BadRequest(err))
+		return
+	}
+
+	items := make([]roleRevision, 0, len(revisions))
+	// latest revision first
+	for i := len(revisions) - 1; i >= 0; i-- {
+		rules, err := revisionRules(&revisions[i])
+		if err != nil {
+			clog.Warn("decode revision %v failed: %v", revisions[i].Name, err)
+			continue
+		}
+		items = append(items, roleRevision{
+			Revision:  revisions[i].Revision,
+			ChangedBy: revisions[i].Annotations[constants.ChangedByAnnotation],
+			Time:      revisions[i].CreationTimestamp,
+			AuthItems: mapping.ClusterRoleMapping(&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: name}, Rules: rules}, cmData, verbose),
+		})
+	}
+
+	response.SuccessReturn(c, result{Total: len(items), Items: items})
+}
+
+// rollbackAuthItems roll back ClusterRole to given revision
+// @Summary Rollback auth items
+// @Description roll back rules of ClusterRole to given revision, the rollback is recorded as a new revision
+// @Tags authorization
+// @Param clusterrole path string true "ClusterRole name"
+// @Param param body rollbackParam true "revision to roll back to"
+// @Success 200 {string} string "success"
+// @Failure 400 {object} errcode.ErrorInfo
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/authorization/authitems/{clusterrole}/rollback [post]
+func (h *handler) rollbackAuthItems(c *gin.Context) {
+	ctx := c.Request.Context()
+	name := c.Param("clusterrole")
+
+	param := &rollbackParam{}
+	if err := c.ShouldBindJSON(param); err != nil {
+		response.FailReturn(c, errcode.InvalidBodyFormat)
+		return
+	}
+
+	revisions, err := h.listRevisions(ctx, name)
+	if err != nil {
+		response.FailReturn(c, errcode.BadRequest(err))
+		return
+	}
+	var target *appsv1.ControllerRevision
+	for i := range revisions {
+		if revisions[i].Revision == param.Revision {
+			target = &revisions[i]
+		}
+	}
+	if target == nil {
+		response.FailReturn(c, errcode.ParamsInvalid(fmt.Errorf("revision %v of %v not found", param.Revision, name)))
+		return
+	}
+	rules, err := revisionRules(target)
+	if err != nil {
+		response.FailReturn(c, errcode.BadRequest(err))
+		return
+	}
+
+	clusterRole := &rbacv1.ClusterRole{}
+	if err = h.Direct().Get(ctx, types.NamespacedName{Name: name}, clusterRole); err != nil {
+		response.FailReturn(c, errcode.BadRequest(err))
+		return
+	}
+	clusterRole.Rules = rules
+	if err = h.Direct().Update(ctx, clusterRole); err != nil {
+		response.FailReturn(c, errcode.BadRequest(err))
+		return
+	}
+
+	user := c.GetString(constants.UserName)
+	if err = h.recordRevision(ctx, nil, clusterRole, user); err != nil {
+		clog.Warn("record revision of ClusterRole %v failed: %v", name, err)
+	}
+	clog.Info("ClusterRole %v rolled back to revision %v by %v", name, param.Revision, user)
+
+	c = audit.SetAuditInfo(c, audit.RollbackAuthItems, name, param)
+	response.SuccessJsonReturn(c, "success")
+}
+
+func revisionRules(r *appsv1.ControllerRevision) ([]rbacv1.PolicyRule, error) {
+	data := &revisionData{}
+	if err := json.Unmarshal(r.Data.Raw, data); err != nil {
+		return nil, err
+	}
+	return data.Rules, nil
+}
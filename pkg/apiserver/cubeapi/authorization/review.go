@@ -0,0 +1,154 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package authorization
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kubecube-io/kubecube/pkg/authorizer/rbac"
+	"github.com/kubecube-io/kubecube/pkg/clients"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+	"github.com/kubecube-io/kubecube/pkg/utils/errcode"
+	"github.com/kubecube-io/kubecube/pkg/utils/response"
+)
+
+// accessReview is the action reviewed in clusters
+type accessReview struct {
+	attributes
+	// Clusters are where the action is reviewed, the cluster of
+	// attributes or pivot cluster is used if empty.
+	Clusters []string `json:"clusters,omitempty"`
+}
+
+type clusterSubjects struct {
+	Cluster string `json:"cluster"`
+	*rbac.AccessSubjects
+}
+
+type clusterReasons struct {
+	Cluster string              `json:"cluster"`
+	Allowed bool                `json:"allowed"`
+	Reasons []rbac.AccessReason `json:"reasons"`
+}
+
+// resolvers returns the rbac resolver of every cluster reviewed
+func (a *accessReview) resolvers() (map[string]*rbac.DefaultResolver, []string, *errcode.ErrorInfo) {
+	clusters := a.Clusters
+	if len(clusters) == 0 {
+		clusters = []string{a.Cluster}
+		if len(a.Cluster) == 0 {
+			clusters = []string{constants.LocalCluster}
+		}
+	}
+
+	resolvers := make(map[string]*rbac.DefaultResolver, len(clusters))
+	for _, cluster := range clusters {
+		cli := clients.Interface().Kubernetes(cluster)
+		if cli == nil {
+			return nil, nil, errcode.ClusterNotFoundError(cluster)
+		}
+		resolvers[cluster] = &rbac.DefaultResolver{Cache: cli.Cache()}
+	}
+	return resolvers, clusters, nil
+}
+
+// whoCan lists subjects able to perform the action
+// @Summary Who can
+// @Description list users, groups and service accounts able to perform the action in pivot or given clusters, user of body is ignored
+// @Tags authorization
+// @Param accessReview body accessReview true "action to review"
+// @Success 200 {array} clusterSubjects
+// @Failure 400 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/authorization/whocan [post]
+func (h *handler) whoCan(c *gin.Context) {
+	a := &accessReview{}
+	if err := c.ShouldBindJSON(a); err != nil {
+		response.FailReturn(c, errcode.InvalidBodyFormat)
+		return
+	}
+	if len(a.Verb) == 0 {
+		response.FailReturn(c, errcode.ParamsMissing("verb"))
+		return
+	}
+
+	resolvers, clusters, errInfo := a.resolvers()
+	if errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+
+	record := a.record()
+	res := make([]clusterSubjects, 0, len(clusters))
+	for _, cluster := range clusters {
+		subjects, err := resolvers[cluster].WhoCan(record)
+		if err != nil {
+			if subjects == nil {
+				response.FailReturn(c, errcode.BadRequest(fmt.Errorf("review cluster %v failed: %v", cluster, err)))
+				return
+			}
+			// the subjects of bindings resolved are still valid
+			clog.Warn("review who can %v %v in cluster %v: %v", a.Verb, a.Resource, cluster, err)
+		}
+		res = append(res, clusterSubjects{Cluster: cluster, AccessSubjects: subjects})
+	}
+
+	response.SuccessReturn(c, res)
+}
+
+// whyCan explains the decision of user performing the action
+// @Summary Why can
+// @Description name the bindings and rules allow user to perform the action in pivot or given clusters
+// @Tags authorization
+// @Param accessReview body accessReview true "action to review"
+// @Success 200 {array} clusterReasons
+// @Failure 400 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/authorization/whycan [post]
+func (h *handler) whyCan(c *gin.Context) {
+	a := &accessReview{}
+	if err := c.ShouldBindJSON(a); err != nil {
+		response.FailReturn(c, errcode.InvalidBodyFormat)
+		return
+	}
+	if len(a.User) == 0 || len(a.Verb) == 0 {
+		response.FailReturn(c, errcode.ParamsMissing("user and verb"))
+		return
+	}
+
+	resolvers, clusters, errInfo := a.resolvers()
+	if errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+
+	record := a.record()
+	res := make([]clusterReasons, 0, len(clusters))
+	for _, cluster := range clusters {
+		reasons, err := resolvers[cluster].WhyCan(record)
+		if err != nil {
+			clog.Warn("review why %v can %v %v in cluster %v: %v", a.User, a.Verb, a.Resource, cluster, err)
+		}
+		if reasons == nil {
+			reasons = []rbac.AccessReason{}
+		}
+		res = append(res, clusterReasons{Cluster: cluster, Allowed: len(reasons) > 0, Reasons: reasons})
+	}
+
+	response.SuccessReturn(c, res)
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package authorization
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	user "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/authorizer/mapping"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+	"github.com/kubecube-io/kubecube/pkg/utils/errcode"
+	"github.com/kubecube-io/kubecube/pkg/utils/response"
+)
+
+// roleHolder is a user bound to ClusterRole in scope, with the other
+// roles user has in the same scope or platform.
+type roleHolder struct {
+	user       string
+	scopeType  user.BindingScopeType
+	scopeName  string
+	otherRoles []string
+}
+
+type userImpact struct {
+	User      string                   `json:"user"`
+	ScopeType user.BindingScopeType    `json:"scopeType"`
+	ScopeName string                   `json:"scopeName"`
+	Changes   []mapping.ResourceChange `json:"changes"`
+}
+
+type simulateResult struct {
+	ClusterRoleName string                   `json:"clusterRoleName"`
+	Changes         []mapping.ResourceChange `json:"changes"`
+	Total           int                      `json:"total"`
+	Users           []userImpact             `json:"users"`
+}
+
+// simulateAuthItems previews the effect of setting auth items
+// @Summary Simulate auth items
+// @Description preview permissions every bound user gains or loses per resource if auth items set, nothing is changed
+// @Tags authorization
+// @Param roleAuthBody body mapping.RoleAuthBody true "proposed auth items"
+// @Success 200 {object} simulateResult
+// @Failure 400 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/authorization/authitems/simulate [post]
+func (h *handler) simulateAuthItems(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	body := &mapping.RoleAuthBody{}
+	if err := c.ShouldBindJSON(body); err != nil {
+		response.FailReturn(c, errcode.InvalidBodyFormat)
+		return
+	}
+	if len(body.ClusterRoleName) == 0 || len(body.AuthItems) == 0 {
+		response.FailReturn(c, errcode.InvalidBodyFormat)
+		return
+	}
+
+	current := &rbacv1.ClusterRole{}
+	err := h.Cache().Get(ctx, types.NamespacedName{Name: body.ClusterRoleName}, current)
+	if err != nil && !errors.IsNotFound(err) {
+		response.FailReturn(c, errcode.BadRequest(err))
+		return
+	}
+	labels := current.Labels
+	if errors.IsNotFound(err) {
+		current = &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: body.ClusterRoleName}}
+		labels = map[string]string{constants.RoleLabel: body.Scope}
+	}
+	cmData := h.authItemsOf(labels)
+	proposed := mapping.RoleAuthMapping(body, cmData)
+
+	res := simulateResult{
+		ClusterRoleName: body.ClusterRoleName,
+		Changes:         mapping.DiffRoleAuth(mapping.ClusterRoleMapping(current, cmData, true), mapping.ClusterRoleMapping(proposed, cmData, true)),
+		Users:           make([]userImpact, 0),
+	}
+	if len(res.Changes) == 0 {
+		response.SuccessReturn(c, res)
+		return
+	}
+
+	holders, err := h.roleHolders(ctx, body.ClusterRoleName)
+	if err != nil {
+		response.FailReturn(c, errcode.BadRequest(err))
+		return
+	}
+	for _, holder := range holders {
+		others := h.rulesOf(ctx, holder.otherRoles)
+		before := &rbacv1.ClusterRole{Rules: append(append([]rbacv1.PolicyRule{}, current.Rules...), others...)}
+		after := &rbacv1.ClusterRole{Rules: append(append([]rbacv1.PolicyRule{}, proposed.Rules...), others...)}
+		changes := mapping.DiffRoleAuth(mapping.ClusterRoleMapping(before, cmData, true), mapping.ClusterRoleMapping(after, cmData, true))
+		// the change is covered by other roles of user
+		if len(changes) == 0 {
+			continue
+		}
+		res.Users = append(res.Users, userImpact{
+			User:      holder.user,
+			ScopeType: holder.scopeType,
+			ScopeName: holder.scopeName,
+			Changes:   changes,
+		})
+	}
+	res.Total = len(res.Users)
+
+	response.SuccessReturn(c, res)
+}
+
+// roleHolders returns users bound to ClusterRole by their own or
+// group scope bindings, ordered by user and scope.
+func (h *handler) roleHolders(ctx context.Context, clusterRole string) ([]roleHolder, error) {
+	users := &user.UserList{}
+	if err := h.Cache().List(ctx, users); err != nil {
+		return nil, err
+	}
+	groups := &user.GroupList{}
+	if err := h.Cache().List(ctx, groups); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var holders []roleHolder
+	for _, u := range users.Items {
+		bindings := user.ActiveScopeBindings(u.Spec.ScopeBindings, now)
+		for _, g := range groups.Items {
+			if g.HasMember(u.Name) {
+				bindings = append(bindings, user.ActiveScopeBindings(g.Spec.ScopeBindings, now)...)
+			}
+		}
+
+		seen := make(map[string]bool)
+		for _, b := range bindings {
+			if b.Role != clusterRole || seen[string(b.ScopeType)+"/"+b.ScopeName] {
+				continue
+			}
+			seen[string(b.ScopeType)+"/"+b.ScopeName] = true
+
+			holder := roleHolder{user: u.Name, scopeType: b.ScopeType, scopeName: b.ScopeName}
+			for _, other := range bindings {
+				if other.Role == clusterRole {
+					continue
+				}
+				if other.ScopeType == user.PlatformScope || (other.ScopeType == b.ScopeType && other.ScopeName == b.ScopeName) {
+					holder.otherRoles = append(holder.otherRoles, other.Role)
+				}
+			}
+			holders = append(holders, holder)
+		}
+	}
+
+	sort.SliceStable(holders, func(i, j int) bool {
+		if holders[i].user != holders[j].user {
+			return holders[i].user < holders[j].user
+		}
+		return holders[i].scopeName < holders[j].scopeName
+	})
+	return holders, nil
+}
+
+// rulesOf returns rules of ClusterRoles, missing roles are ignored
+func (h *handler) rulesOf(ctx context.Context, clusterRoles []string) []rbacv1.PolicyRule {
+	var rules []rbacv1.PolicyRule
+	for _, name := range clusterRoles {
+		clusterRole := &rbacv1.ClusterRole{}
+		if err := h.Cache().Get(ctx, types.NamespacedName{Name: name}, clusterRole); err != nil {
+			continue
+		}
+		rules = append(rules, clusterRole.Rules...)
+	}
+	return rules
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package authorization
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubecube-io/kubecube/pkg/apis"
+	user "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/authorizer/mapping"
+	"github.com/kubecube-io/kubecube/pkg/multicluster/client/fake"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+var (
+	readVerbs = []string{"get", "list", "watch"}
+	allVerbs  = []string{"create", "delete", "deletecollection", "get", "list", "patch", "update", "watch"}
+)
+
+func newAuthItemsRouter(t *testing.T) (*gin.Engine, *handler) {
+	t.Helper()
+	t.Setenv("AUDIT_IS_ENABLE", "false")
+
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	apis.AddToScheme(scheme)
+
+	projectRole := func(name string, rules ...rbacv1.PolicyRule) *rbacv1.ClusterRole {
+		return &rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{constants.RoleLabel: constants.ClusterRoleProject}},
+			Rules:      rules,
+		}
+	}
+	cli := fake.NewFakeClients(&fake.Options{Scheme: scheme, Objs: []client.Object{
+		projectRole("developer", rbacv1.PolicyRule{APIGroups: []string{"*"}, Resources: []string{"deployments"}, Verbs: readVerbs}),
+		projectRole("deployer", rbacv1.PolicyRule{APIGroups: []string{"*"}, Resources: []string{"deployments"}, Verbs: allVerbs}),
+		&user.User{ObjectMeta: metav1.ObjectMeta{Name: "alice"}, Spec: user.UserSpec{ScopeBindings: []user.ScopeBinding{
+			{ScopeType: user.ProjectScope, ScopeName: "project-1", Role: "developer"},
+		}}},
+		// bob could write deployments already
+		&user.User{ObjectMeta: metav1.ObjectMeta{Name: "bob"}, Spec: user.UserSpec{ScopeBindings: []user.ScopeBinding{
+			{ScopeType: user.ProjectScope, ScopeName: "project-1", Role: "developer"},
+			{ScopeType: user.ProjectScope, ScopeName: "project-1", Role: "deployer"},
+		}}},
+		&user.User{ObjectMeta: metav1.ObjectMeta{Name: "carol"}},
+		&user.Group{ObjectMeta: metav1.ObjectMeta{Name: "dev"}, Spec: user.GroupSpec{
+			Members:       []string{"carol"},
+			ScopeBindings: []user.ScopeBinding{{ScopeType: user.ProjectScope, ScopeName: "project-2", Role: "developer"}},
+		}},
+	}})
+
+	h := &handler{Client: cli, cmData: map[string]string{"deployment.manage": "deployments"}}
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set(constants.UserName, "admin")
+	})
+	router.POST(constants.ApiPathRoot+subPath+"/authitems", h.setAuthItems)
+	router.POST(constants.ApiPathRoot+subPath+"/authitems/simulate", h.simulateAuthItems)
+	router.GET(constants.ApiPathRoot+subPath+"/authitems/:clusterrole/history", h.getAuthItemsHistory)
+	router.POST(constants.ApiPathRoot+subPath+"/authitems/:clusterrole/rollback", h.rollbackAuthItems)
+	return router, h
+}
+
+func performAuthItems(router *gin.Engine, method, path string, body interface{}) *httptest.ResponseRecorder {
+	data, _ := json.Marshal(body)
+	req, _ := http.NewRequest(method, constants.ApiPathRoot+subPath+"/authitems"+path, bytes.NewReader(data))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestSimulateAuthItems(t *testing.T) {
+	router, _ := newAuthItemsRouter(t)
+
+	w := performAuthItems(router, http.MethodPost, "/simulate", mapping.RoleAuthBody{
+		ClusterRoleName: "developer",
+		AuthItems:       map[string]mapping.AuthItem{"deployment.manage": {Verb: mapping.All}},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("simulate failed: %v", w.Body.String())
+	}
+	res := &simulateResult{}
+	_ = json.Unmarshal(w.Body.Bytes(), res)
+
+	want := []mapping.ResourceChange{{AuthItem: "deployment.manage", Resource: "deployments", Before: mapping.Read, After: mapping.All, Gained: mapping.Write, Lost: mapping.Null}}
+	if len(res.Changes) != 1 || res.Changes[0] != want[0] {
+		t.Fatalf("unexpected changes of role: %+v", res.Changes)
+	}
+	if res.Total != 2 || res.Users[0].User != "alice" || res.Users[1].User != "carol" || res.Users[1].ScopeName != "project-2" {
+		t.Fatalf("alice and carol of group should gain write only, got %+v", res.Users)
+	}
+}
+
+func TestAuthItemsHistory(t *testing.T) {
+	router, h := newAuthItemsRouter(t)
+
+	for _, verb := range []mapping.VerbRepresent{mapping.All, mapping.Null} {
+		w := performAuthItems(router, http.MethodPost, "", mapping.RoleAuthBody{
+			ClusterRoleName: "developer",
+			AuthItems:       map[string]mapping.AuthItem{"deployment.manage": {Verb: verb}},
+		})
+		if w.Code != http.StatusOK {
+			t.Fatalf("set auth items failed: %v", w.Body.String())
+		}
+	}
+
+	w := performAuthItems(router, http.MethodGet, "/developer/history", nil)
+	history := &struct {
+		Total int
+		Items []roleRevision
+	}{}
+	_ = json.Unmarshal(w.Body.Bytes(), history)
+	// the original role is recorded before the first change
+	if history.Total != 3 || history.Items[0].Revision != 3 || history.Items[2].ChangedBy != "" ||
+		history.Items[1].AuthItems.AuthItems["deployment.manage"].Verb != mapping.All {
+		t.Fatalf("unexpected history: %v", w.Body.String())
+	}
+
+	if w = performAuthItems(router, http.MethodPost, "/developer/rollback", rollbackParam{Revision: 1}); w.Code != http.StatusOK {
+		t.Fatalf("rollback failed: %v", w.Body.String())
+	}
+	clusterRole := &rbacv1.ClusterRole{}
+	if err := h.Direct().Get(context.Background(), types.NamespacedName{Name: "developer"}, clusterRole); err != nil {
+		t.Fatal(err)
+	}
+	if len(clusterRole.Rules) != 1 || len(clusterRole.Rules[0].Verbs) != len(readVerbs) {
+		t.Fatalf("role should be rolled back to read deployments, got %+v", clusterRole.Rules)
+	}
+	revisions, _ := h.listRevisions(context.Background(), "developer")
+	if len(revisions) != 4 || revisions[3].Annotations[constants.ChangedByAnnotation] != "admin" {
+		t.Fatalf("rollback should be recorded as new revision, got %v", len(revisions))
+	}
+
+	if w = performAuthItems(router, http.MethodPost, "/developer/rollback", rollbackParam{Revision: 9}); w.Code != http.StatusBadRequest {
+		t.Fatalf("rollback to unknown revision should fail, got %v", w.Code)
+	}
+}
@@ -0,0 +1,283 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chargeback
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	rbacv1 "k8s.io/api/rbac/v1"
+
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/authorizer/rbac"
+	"github.com/kubecube-io/kubecube/pkg/chargeback"
+	"github.com/kubecube-io/kubecube/pkg/clients"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/tsdb"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+	"github.com/kubecube-io/kubecube/pkg/utils/errcode"
+	"github.com/kubecube-io/kubecube/pkg/utils/response"
+)
+
+const (
+	subPath = "/chargeback"
+
+	resourceType = "chargeback"
+
+	// defaultPeriod is the period of report when start is not given
+	defaultPeriod = 30 * 24 * time.Hour
+
+	// forwardedHeader marks request forwarded to leader, it is never
+	// forwarded again in case leader changed meanwhile
+	forwardedHeader = "X-Chargeback-Forwarded"
+)
+
+var errNotLeader = errcode.CustomReturn(http.StatusServiceUnavailable, "chargeback reports are only served by the leader")
+
+func (h *handler) AddApisTo(root *gin.Engine) {
+	r := root.Group(constants.ApiPathRoot + subPath)
+	r.GET("/reports", h.getReport)
+}
+
+type handler struct {
+	rbac.Interface
+
+	store    func() (*tsdb.Store, error)
+	prices   func() (*chargeback.PriceTable, error)
+	now      func() time.Time
+	sampling func() bool
+
+	// leader returns url of leader which samples are kept by, requests
+	// to other replicas are forwarded to leader
+	leader    func(ctx context.Context) (*url.URL, error)
+	transport http.RoundTripper
+}
+
+// NewHandler returns handler of chargeback, port and tlsCert are what cube
+// api server of every replica serves with, tlsCert is empty for http.
+func NewHandler(port int, tlsCert string) *handler {
+	h := new(handler)
+	h.Interface = rbac.NewDefaultResolver(constants.LocalCluster)
+	h.store = chargeback.Store
+	// price table is reloaded for each report, so prices can be changed
+	// without restart
+	h.prices = func() (*chargeback.PriceTable, error) {
+		return chargeback.LoadPriceTable(chargeback.Config.PriceFile)
+	}
+	h.now = time.Now
+	h.sampling = chargeback.Sampling
+
+	scheme := "http"
+	h.transport = http.DefaultTransport
+	if len(tlsCert) > 0 {
+		scheme = "https"
+		h.transport = pinnedTransport(tlsCert)
+	}
+	h.leader = func(ctx context.Context) (*url.URL, error) {
+		ip, err := chargeback.LeaderIP(ctx, clients.Interface().Kubernetes(constants.LocalCluster).Direct())
+		if err != nil {
+			return nil, err
+		}
+		return &url.URL{Scheme: scheme, Host: net.JoinHostPort(ip, strconv.Itoa(port))}, nil
+	}
+	return h
+}
+
+// pinnedTransport trusts the serving certificate of cube only, replicas
+// share the certificate but it is not issued for pod ip of leader.
+func pinnedTransport(tlsCert string) http.RoundTripper {
+	data, err := os.ReadFile(tlsCert)
+	if err != nil {
+		clog.Warn("read tls cert %v failed, chargeback requests can not be forwarded to leader: %v", tlsCert, err)
+		return nil
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		clog.Warn("no pem data in tls cert %v, chargeback requests can not be forwarded to leader", tlsCert)
+		return nil
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 || !bytes.Equal(cs.PeerCertificates[0].Raw, block.Bytes) {
+				return fmt.Errorf("certificate of leader does not match the serving certificate of cube")
+			}
+			return nil
+		},
+	}
+	return transport
+}
+
+// getReport reports cost of resources in period
+// @Summary Get chargeback report
+// @Description integrate sampled resources of projects over period and price them, platform admins see all tenants and tenant admins see their own tenant, requests to replicas not leader are forwarded to leader
+// @Tags chargeback
+// @Param start query string false "RFC3339 start of period, 30 days before end by default"
+// @Param end query string false "RFC3339 end of period, now by default"
+// @Param groupBy query string false "one of tenant, project and cluster, tenant by default"
+// @Param basis query string false "one of hard, requested and used, hard by default"
+// @Param tenant query string false "only report tenant"
+// @Param project query string false "only report project"
+// @Param cluster query string false "only report cluster"
+// @Param format query string false "csv or json, json by default"
+// @Success 200 {object} chargeback.Report
+// @Failure 400 {object} errcode.ErrorInfo
+// @Failure 403 {object} errcode.ErrorInfo
+// @Failure 500 {object} errcode.ErrorInfo
+// @Failure 503 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/chargeback/reports [get]
+func (h *handler) getReport(c *gin.Context) {
+	userName := c.GetString(constants.UserName)
+
+	opts, err := h.reportOptions(c)
+	if err != nil {
+		response.FailReturn(c, errcode.ParamsInvalid(err))
+		return
+	}
+	matcher := tsdb.Matcher{}
+	for _, label := range []string{chargeback.LabelTenant, chargeback.LabelProject, chargeback.LabelCluster} {
+		if v := c.Query(label); len(v) > 0 {
+			matcher[label] = v
+		}
+	}
+	if !h.isPlatformAdmin(userName) && !h.isTenantAdmin(userName, matcher[chargeback.LabelTenant]) {
+		response.FailReturn(c, errcode.ForbiddenErr)
+		return
+	}
+
+	// samples are only in data dir of leader, reports of other replicas are empty
+	if !h.sampling() {
+		h.forwardToLeader(c)
+		return
+	}
+
+	store, err := h.store()
+	if err != nil {
+		clog.Error("open store of chargeback failed: %v", err)
+		response.FailReturn(c, errcode.GetResourceError(resourceType))
+		return
+	}
+	prices, err := h.prices()
+	if err != nil {
+		clog.Error("load price table failed: %v", err)
+		response.FailReturn(c, errcode.GetResourceError(resourceType))
+		return
+	}
+	report, err := chargeback.GenerateReport(store, prices, opts, matcher)
+	if err != nil {
+		clog.Error("generate chargeback report failed: %v", err)
+		response.FailReturn(c, errcode.GetResourceError(resourceType))
+		return
+	}
+
+	if c.Query("format") != "csv" {
+		response.SuccessReturn(c, report)
+		return
+	}
+	data := &bytes.Buffer{}
+	data.WriteString("\xEF\xBB\xBF")
+	if err = report.WriteCSV(data); err != nil {
+		response.FailReturn(c, errcode.BadRequest(fmt.Errorf("write chargeback report error: %s", err)))
+		return
+	}
+	fileName := fmt.Sprintf("chargeback-%v-%v.csv", opts.Start.Format("20060102"), opts.End.Format("20060102"))
+	c.Writer.Header().Set(constants.HttpHeaderContentDisposition, fmt.Sprintf("attachment;filename=%s", fileName))
+	c.Data(http.StatusOK, "text/csv", data.Bytes())
+}
+
+// forwardToLeader proxies request to leader which serves report from its samples
+func (h *handler) forwardToLeader(c *gin.Context) {
+	if len(c.GetHeader(forwardedHeader)) > 0 || h.transport == nil {
+		response.FailReturn(c, errNotLeader)
+		return
+	}
+	target, err := h.leader(c.Request.Context())
+	if err != nil {
+		clog.Warn("find leader of chargeback failed: %v", err)
+		response.FailReturn(c, errNotLeader)
+		return
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.Transport = h.transport
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		clog.Warn("forward chargeback request to leader %v failed: %v", target.Host, err)
+		response.FailReturn(c, errNotLeader)
+	}
+	c.Request.Header.Set(forwardedHeader, constants.TrueStr)
+	proxy.ServeHTTP(c.Writer, c.Request)
+}
+
+func (h *handler) reportOptions(c *gin.Context) (chargeback.ReportOptions, error) {
+	opts := chargeback.ReportOptions{
+		End:     h.now(),
+		GroupBy: chargeback.GroupBy(c.DefaultQuery("groupBy", string(chargeback.GroupByTenant))),
+		Basis:   chargeback.Basis(c.DefaultQuery("basis", string(chargeback.BasisHard))),
+	}
+	if v := c.Query("end"); len(v) > 0 {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return opts, fmt.Errorf("end must be RFC3339 time")
+		}
+		opts.End = t
+	}
+	opts.Start = opts.End.Add(-defaultPeriod)
+	if v := c.Query("start"); len(v) > 0 {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return opts, fmt.Errorf("start must be RFC3339 time")
+		}
+		opts.Start = t
+	}
+	return opts, opts.Validate()
+}
+
+func (h *handler) isPlatformAdmin(name string) bool {
+	u, err := h.GetUser(name)
+	if err != nil {
+		return false
+	}
+	return userv1.IsPlatformAdmin(&u)
+}
+
+func (h *handler) isTenantAdmin(name, tenant string) bool {
+	if len(tenant) == 0 {
+		return false
+	}
+	users, err := h.UsersFor(rbacv1.RoleRef{APIGroup: constants.K8sGroupRBAC, Kind: constants.KindClusterRole, Name: constants.TenantAdmin}, constants.TenantNsPrefix+tenant)
+	if err != nil {
+		clog.Warn("resolve admins of tenant %v failed: %v", tenant, err)
+	}
+	for _, u := range users {
+		if u.Name == name {
+			return true
+		}
+	}
+	return false
+}
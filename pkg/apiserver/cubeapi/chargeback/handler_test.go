@@ -0,0 +1,169 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chargeback
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubecube-io/kubecube/pkg/apis"
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/authorizer/rbac"
+	"github.com/kubecube-io/kubecube/pkg/chargeback"
+	"github.com/kubecube-io/kubecube/pkg/multicluster/client/fake"
+	"github.com/kubecube-io/kubecube/pkg/tsdb"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+func TestGetReport(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	apis.AddToScheme(scheme)
+	cli := fake.NewFakeClients(&fake.Options{Scheme: scheme, Objs: []client.Object{
+		&userv1.User{ObjectMeta: metav1.ObjectMeta{Name: "admin"}, Status: userv1.UserStatus{PlatformAdmin: true}},
+		&userv1.User{ObjectMeta: metav1.ObjectMeta{Name: "carol"}},
+		&userv1.User{ObjectMeta: metav1.ObjectMeta{Name: "dave"}},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "carol-" + constants.TenantAdmin, Namespace: constants.TenantNsPrefix + "tenant-1"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: constants.K8sGroupRBAC, Kind: constants.KindClusterRole, Name: constants.TenantAdmin},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "carol"}},
+		},
+	}})
+
+	store, err := tsdb.Open(tsdb.Options{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2023, 3, 2, 0, 0, 0, 0, time.UTC)
+	for _, tenant := range []string{"tenant-1", "tenant-2"} {
+		store.Append(tsdb.Sample{
+			Time:   now.Add(-time.Hour),
+			Labels: map[string]string{chargeback.LabelCluster: "pivot-cluster", chargeback.LabelTenant: tenant, chargeback.LabelProject: "project"},
+			Values: map[string]float64{"seconds": 3600, "hard/requests.cpu": 2},
+		})
+	}
+
+	h := &handler{
+		Interface: &rbac.DefaultResolver{Cache: cli.Cache()},
+		store:     func() (*tsdb.Store, error) { return store, nil },
+		prices: func() (*chargeback.PriceTable, error) {
+			return &chargeback.PriceTable{Default: map[v1.ResourceName]float64{v1.ResourceRequestsCPU: 0.5}}, nil
+		},
+		now:      func() time.Time { return now },
+		sampling: func() bool { return true },
+	}
+	requester := ""
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set(constants.UserName, requester)
+	})
+	h.AddApisTo(router)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, constants.ApiPathRoot+subPath+"/reports?"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	requester = "admin"
+	w := get("")
+	if w.Code != http.StatusOK {
+		t.Fatalf("want 200, got %v: %v", w.Code, w.Body.String())
+	}
+	report := &chargeback.Report{}
+	json.Unmarshal(w.Body.Bytes(), report)
+	if len(report.Items) != 2 || report.Total != 2 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	requester = "carol"
+	if w = get(""); w.Code != http.StatusForbidden {
+		t.Errorf("tenant admin should not see all tenants, got %v", w.Code)
+	}
+	w = get("tenant=tenant-1&groupBy=project&format=csv")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "tenant-1,project,2.0000,1.0000") || strings.Contains(w.Body.String(), "tenant-2") {
+		t.Errorf("unexpected csv report of tenant admin: %v %v", w.Code, w.Body.String())
+	}
+
+	requester = "dave"
+	if w = get("tenant=tenant-1"); w.Code != http.StatusForbidden {
+		t.Errorf("want 403 for user not admin, got %v", w.Code)
+	}
+
+	requester = "admin"
+	if w = get("groupBy=namespace"); w.Code != http.StatusBadRequest {
+		t.Errorf("want 400 for invalid groupBy, got %v", w.Code)
+	}
+	if w = get("start=" + now.Format(time.RFC3339) + "&end=" + now.Add(-time.Hour).Format(time.RFC3339)); w.Code != http.StatusBadRequest {
+		t.Errorf("want 400 for start after end, got %v", w.Code)
+	}
+
+	// replica not leader forwards request to leader
+	leader := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(forwardedHeader) != constants.TrueStr || r.URL.Query().Get("tenant") != "tenant-1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"total":1}`))
+	}))
+	defer leader.Close()
+	h.sampling = func() bool { return false }
+	h.transport = http.DefaultTransport
+	h.leader = func(ctx context.Context) (*url.URL, error) { return url.Parse(leader.URL) }
+	// reverse proxy needs a real response writer
+	replica := httptest.NewServer(router)
+	defer replica.Close()
+	forward := func(query string, forwarded bool) (int, string) {
+		req, _ := http.NewRequest(http.MethodGet, replica.URL+constants.ApiPathRoot+subPath+"/reports?"+query, nil)
+		if forwarded {
+			req.Header.Set(forwardedHeader, constants.TrueStr)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+	if code, body := forward("tenant=tenant-1", false); code != http.StatusOK || body != `{"total":1}` {
+		t.Errorf("want report from leader, got %v %v", code, body)
+	}
+	if code, _ := forward("", true); code != http.StatusServiceUnavailable {
+		t.Errorf("want 503 for request forwarded to replica not leader, got %v", code)
+	}
+	leader.Close()
+	if code, _ := forward("", false); code != http.StatusServiceUnavailable {
+		t.Errorf("want 503 when leader is unreachable, got %v", code)
+	}
+}
@@ -0,0 +1,329 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	mgrclient "github.com/kubecube-io/kubecube/pkg/multicluster/client"
+	"github.com/kubecube-io/kubecube/pkg/quota"
+	"github.com/kubecube-io/kubecube/pkg/quota/cube"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+const defaultTopConsumers = 10
+
+// capacityResources maps the resources capacity is planned for to the
+// resources of pods and metrics they are counted by
+var capacityResources = []struct {
+	name v1.ResourceName
+	pod  v1.ResourceName
+}{
+	{v1.ResourceRequestsCPU, v1.ResourceCPU},
+	{v1.ResourceRequestsMemory, v1.ResourceMemory},
+	{quota.ResourceNvidiaGPU, constants.GpuNvidia},
+}
+
+// capacityInfo puts what nodes provide, what tenant quotas committed, what
+// pods requested and what pods used side by side
+type capacityInfo struct {
+	Cluster   string `json:"cluster"`
+	NodesPool string `json:"nodesPool,omitempty"`
+	// NodeCount counts schedulable nodes, cordoned nodes are not planned
+	NodeCount int `json:"nodeCount"`
+	// MetricsAvailable is false if metrics server can not be reached, used
+	// is absent then
+	MetricsAvailable bool               `json:"metricsAvailable"`
+	Resources        []resourceCapacity `json:"resources"`
+	TopConsumers     []capacityConsumer `json:"topConsumers"`
+}
+
+type resourceCapacity struct {
+	Resource v1.ResourceName `json:"resource"`
+	// Allocatable is the sum of allocatable of schedulable nodes
+	Allocatable resource.Quantity `json:"allocatable"`
+	// Committed is the sum of hard of tenant quotas
+	Committed resource.Quantity `json:"committed"`
+	// Requested is the sum of requests of pods running on nodes
+	Requested resource.Quantity  `json:"requested"`
+	Used      *resource.Quantity `json:"used,omitempty"`
+	// OvercommitRatio is committed divided by allocatable, greater than 1
+	// means overcommitted
+	OvercommitRatio float64 `json:"overcommitRatio"`
+	// Headroom is what left to commit, negative if overcommitted
+	Headroom resource.Quantity `json:"headroom"`
+	// SchedulableHeadroom is what left to request by pods
+	SchedulableHeadroom resource.Quantity `json:"schedulableHeadroom"`
+}
+
+// capacityConsumer is a project or a namespace not of project that pods
+// run in, ranked by requests
+type capacityConsumer struct {
+	Tenant    string          `json:"tenant,omitempty"`
+	Project   string          `json:"project,omitempty"`
+	Namespace string          `json:"namespace,omitempty"`
+	Requested v1.ResourceList `json:"requested"`
+	Used      v1.ResourceList `json:"used,omitempty"`
+}
+
+type consumerKey struct {
+	tenant    string
+	project   string
+	namespace string
+}
+
+type capacityOpts struct {
+	// pool is the nodes pool to plan, whole cluster if empty
+	pool string
+	// top is how many consumers are listed
+	top int
+	// sortBy is the resource consumers are ranked by
+	sortBy v1.ResourceName
+}
+
+// makeCapacityInfo computes capacity of cluster or nodes pool of cluster,
+// quotas are read from pivot and the others are read from cluster.
+func makeCapacityInfo(ctx context.Context, pivot client.Reader, cli mgrclient.Client, cluster string, opts capacityOpts) (*capacityInfo, error) {
+	pool := opts.pool
+	if len(pool) == 0 {
+		pool = quotav1.GlobalNodesPool
+	}
+
+	nodes, err := cube.PoolNodes(ctx, cli.Cache(), pool)
+	if err != nil {
+		return nil, fmt.Errorf("get nodes of cluster %v failed: %v", cluster, err)
+	}
+	allocatable := cube.NodesHard(nodes)
+	nodeNames := make(map[string]struct{}, len(nodes))
+	for _, n := range nodes {
+		nodeNames[n.Name] = struct{}{}
+	}
+
+	committed, err := committedOf(ctx, pivot, cluster, pool)
+	if err != nil {
+		return nil, fmt.Errorf("get quotas of cluster %v failed: %v", cluster, err)
+	}
+
+	// pods not scheduled to nodes do not take capacity
+	namespaces := &v1.NamespaceList{}
+	if err = cli.Cache().List(ctx, namespaces); err != nil {
+		return nil, fmt.Errorf("get namespaces of cluster %v failed: %v", cluster, err)
+	}
+	nsLabels := make(map[string]map[string]string, len(namespaces.Items))
+	for _, ns := range namespaces.Items {
+		nsLabels[ns.Name] = ns.Labels
+	}
+	pods := &v1.PodList{}
+	if err = cli.Cache().List(ctx, pods); err != nil {
+		return nil, fmt.Errorf("get pods of cluster %v failed: %v", cluster, err)
+	}
+	requested := v1.ResourceList{}
+	consumers := make(map[consumerKey]*capacityConsumer)
+	consumerOfPod := make(map[string]*capacityConsumer)
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if _, ok := nodeNames[pod.Spec.NodeName]; !ok || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		reqs, _ := quota.PodRequestsAndLimits(pod)
+		addCapacityResources(requested, reqs)
+
+		c := consumerOf(consumers, pod.Namespace, nsLabels[pod.Namespace])
+		addCapacityResources(c.Requested, reqs)
+		consumerOfPod[pod.Namespace+"/"+pod.Name] = c
+	}
+
+	info := &capacityInfo{
+		Cluster:      cluster,
+		NodesPool:    opts.pool,
+		NodeCount:    len(nodes),
+		Resources:    []resourceCapacity{},
+		TopConsumers: []capacityConsumer{},
+	}
+
+	// usage is optional since metrics server may not be installed
+	used, err := usedOf(ctx, cli, nodeNames, consumerOfPod)
+	if err != nil {
+		clog.Warn("get metrics of cluster %v failed: %v", cluster, err)
+	}
+	info.MetricsAvailable = err == nil
+
+	for _, r := range capacityResources {
+		rc := resourceCapacity{
+			Resource:    r.name,
+			Allocatable: quantityOf(allocatable, r.name),
+			Committed:   quantityOf(committed, r.name),
+			Requested:   quantityOf(requested, r.name),
+		}
+		if info.MetricsAvailable {
+			u := quantityOf(used, r.name)
+			rc.Used = &u
+		}
+		if rc.Allocatable.Sign() > 0 {
+			ratio := rc.Committed.AsApproximateFloat64() / rc.Allocatable.AsApproximateFloat64()
+			rc.OvercommitRatio = math.Round(ratio*100) / 100
+		}
+		rc.Headroom = rc.Allocatable.DeepCopy()
+		rc.Headroom.Sub(rc.Committed)
+		rc.SchedulableHeadroom = rc.Allocatable.DeepCopy()
+		rc.SchedulableHeadroom.Sub(rc.Requested)
+		info.Resources = append(info.Resources, rc)
+	}
+
+	for _, c := range consumers {
+		info.TopConsumers = append(info.TopConsumers, *c)
+	}
+	sortBy := opts.sortBy
+	sort.Slice(info.TopConsumers, func(i, j int) bool {
+		a, b := info.TopConsumers[i].Requested[sortBy], info.TopConsumers[j].Requested[sortBy]
+		if cmp := a.Cmp(b); cmp != 0 {
+			return cmp > 0
+		}
+		return consumerName(info.TopConsumers[i]) < consumerName(info.TopConsumers[j])
+	})
+	if len(info.TopConsumers) > opts.top {
+		info.TopConsumers = info.TopConsumers[:opts.top]
+	}
+
+	return info, nil
+}
+
+// committedOf sums hard of tenant quotas of cluster, only tenant quotas
+// under the quota of nodes pool are counted unless pool is global
+func committedOf(ctx context.Context, pivot client.Reader, cluster, pool string) (v1.ResourceList, error) {
+	list := &quotav1.CubeResourceQuotaList{}
+	if err := pivot.List(ctx, list, client.MatchingLabels{constants.ClusterLabel: cluster}); err != nil {
+		return nil, err
+	}
+
+	poolQuota := ""
+	if pool != quotav1.GlobalNodesPool {
+		for _, q := range list.Items {
+			if cube.IsNodesPool(&q) && q.Spec.Target.Name == pool {
+				poolQuota = q.Name
+			}
+		}
+	}
+
+	committed := v1.ResourceList{}
+	for _, q := range list.Items {
+		if q.Spec.Target.Kind != quotav1.TenantObj || q.DeletionTimestamp != nil {
+			continue
+		}
+		if pool != quotav1.GlobalNodesPool && (len(poolQuota) == 0 || q.Spec.ParentQuota != poolQuota) {
+			continue
+		}
+		addCapacityHard(committed, q.Spec.Hard)
+	}
+	return committed, nil
+}
+
+// addCapacityHard adds hard of quota into list by names of capacity resources
+func addCapacityHard(list, hard v1.ResourceList) {
+	for _, r := range capacityResources {
+		if v, ok := hard[r.name]; ok {
+			sum := quantityOf(list, r.name)
+			sum.Add(v)
+			list[r.name] = sum
+		}
+	}
+}
+
+// usedOf sums usage of nodes and usage of pods into their consumers
+func usedOf(ctx context.Context, cli mgrclient.Client, nodeNames map[string]struct{}, consumerOfPod map[string]*capacityConsumer) (v1.ResourceList, error) {
+	nodeMetrics, err := cli.Metrics().MetricsV1beta1().NodeMetricses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	used := v1.ResourceList{}
+	for _, m := range nodeMetrics.Items {
+		if _, ok := nodeNames[m.Name]; ok {
+			addCapacityResources(used, m.Usage)
+		}
+	}
+
+	podMetrics, err := cli.Metrics().MetricsV1beta1().PodMetricses(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range podMetrics.Items {
+		c, ok := consumerOfPod[m.Namespace+"/"+m.Name]
+		if !ok {
+			continue
+		}
+		if c.Used == nil {
+			c.Used = v1.ResourceList{}
+		}
+		for _, container := range m.Containers {
+			addCapacityResources(c.Used, container.Usage)
+		}
+	}
+	return used, nil
+}
+
+// consumerOf returns the consumer of namespace, namespaces of project are
+// counted as the project
+func consumerOf(consumers map[consumerKey]*capacityConsumer, namespace string, labels map[string]string) *capacityConsumer {
+	key := consumerKey{namespace: namespace}
+	if project, ok := labels[constants.HncProjectLabel]; ok {
+		key = consumerKey{tenant: labels[constants.HncTenantLabel], project: project}
+	}
+	c, ok := consumers[key]
+	if !ok {
+		c = &capacityConsumer{Tenant: key.tenant, Project: key.project, Namespace: key.namespace, Requested: v1.ResourceList{}}
+		consumers[key] = c
+	}
+	return c
+}
+
+func consumerName(c capacityConsumer) string {
+	if len(c.Project) > 0 {
+		return c.Tenant + "/" + c.Project
+	}
+	return c.Namespace
+}
+
+// addCapacityResources adds resources of pod or node into list by names of
+// capacity resources
+func addCapacityResources(list, new v1.ResourceList) {
+	for _, r := range capacityResources {
+		v, ok := new[r.pod]
+		if !ok {
+			continue
+		}
+		sum := quantityOf(list, r.name)
+		sum.Add(v)
+		list[r.name] = sum
+	}
+}
+
+func quantityOf(list v1.ResourceList, name v1.ResourceName) resource.Quantity {
+	if v, ok := list[name]; ok {
+		return v.DeepCopy()
+	}
+	return quota.ZeroQ()
+}
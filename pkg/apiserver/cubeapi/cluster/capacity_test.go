@@ -0,0 +1,166 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	clienttesting "k8s.io/client-go/testing"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	metricsfake "k8s.io/metrics/pkg/client/clientset/versioned/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
+	"github.com/kubecube-io/kubecube/pkg/multicluster/client/fake"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+func TestMakeCapacityInfo(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	quotav1.AddToScheme(scheme)
+
+	node := func(name, pool, cpu string) client.Object {
+		n := &v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{}},
+			Status: v1.NodeStatus{Allocatable: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse(cpu),
+				v1.ResourceMemory: resource.MustParse("16Gi"),
+			}},
+		}
+		if len(pool) > 0 {
+			n.Labels[constants.LabelNodePool] = pool
+		}
+		return n
+	}
+	pod := func(ns, name, node, cpu string) client.Object {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name},
+			Spec: v1.PodSpec{NodeName: node, Containers: []v1.Container{{
+				Name:      "c",
+				Resources: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse(cpu)}},
+			}}},
+			Status: v1.PodStatus{Phase: v1.PodRunning},
+		}
+	}
+	tenantQuota := func(name, parent, cpu string) client.Object {
+		return &quotav1.CubeResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{constants.ClusterLabel: "member-1"}},
+			Spec: quotav1.CubeResourceQuotaSpec{
+				ParentQuota: parent,
+				Target:      quotav1.TargetObj{Kind: quotav1.TenantObj, Name: name},
+				Hard:        v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse(cpu)},
+			},
+		}
+	}
+
+	// cordoned nodes and pods on them are not planned
+	cordoned := node("node-3", "", "8").(*v1.Node)
+	cordoned.Spec.Unschedulable = true
+
+	pivot := fake.NewFakeClients(&fake.Options{Scheme: scheme, Objs: []client.Object{
+		&quotav1.CubeResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: "member-1.gpu", Labels: map[string]string{constants.ClusterLabel: "member-1"}},
+			Spec:       quotav1.CubeResourceQuotaSpec{Target: quotav1.TargetObj{Kind: quotav1.NodesPoolObj, Name: "gpu"}},
+		},
+		tenantQuota("tenant-1", "", "10"),
+		tenantQuota("tenant-2", "member-1.gpu", "6"),
+		&quotav1.CubeResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: "project-1", Labels: map[string]string{constants.ClusterLabel: "member-1"}},
+			Spec: quotav1.CubeResourceQuotaSpec{
+				ParentQuota: "tenant-1",
+				Target:      quotav1.TargetObj{Kind: quotav1.ProjectObj, Name: "project-1"},
+				Hard:        v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("10")},
+			},
+		},
+	}})
+	member := fake.NewFakeClients(&fake.Options{Scheme: scheme, Objs: []client.Object{
+		node("node-1", "", "8"),
+		node("node-2", "gpu", "4"),
+		cordoned,
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-1", Labels: map[string]string{constants.HncTenantLabel: "tenant-1", constants.HncProjectLabel: "project-1"}}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-2", Labels: map[string]string{constants.HncTenantLabel: "tenant-1", constants.HncProjectLabel: "project-1"}}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+		pod("ns-1", "a", "node-1", "2"),
+		pod("ns-2", "b", "node-2", "3"),
+		pod("kube-system", "c", "node-2", "500m"),
+		pod("kube-system", "pending", "", "8"),
+		pod("ns-1", "drained", "node-3", "4"),
+	}})
+	// tracker of fake metrics clientset does not list metrics by resource
+	// nodes and pods, so lists are served by reactors
+	metrics := member.Metrics().(*metricsfake.Clientset)
+	metrics.PrependReactor("list", "nodes", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, &metricsv1beta1.NodeMetricsList{Items: []metricsv1beta1.NodeMetrics{
+			{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}, Usage: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "node-2"}, Usage: v1.ResourceList{v1.ResourceCPU: resource.MustParse("2")}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "node-3"}, Usage: v1.ResourceList{v1.ResourceCPU: resource.MustParse("4")}},
+		}}, nil
+	})
+	metrics.PrependReactor("list", "pods", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, &metricsv1beta1.PodMetricsList{Items: []metricsv1beta1.PodMetrics{
+			{ObjectMeta: metav1.ObjectMeta{Namespace: "ns-2", Name: "b"}, Containers: []metricsv1beta1.ContainerMetrics{{Name: "c", Usage: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1500m")}}}},
+		}}, nil
+	})
+
+	info, err := makeCapacityInfo(context.Background(), pivot.Direct(), member, "member-1", capacityOpts{top: 10, sortBy: v1.ResourceRequestsCPU})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cpu := info.Resources[0]
+	if info.NodeCount != 2 || !info.MetricsAvailable || cpu.Resource != v1.ResourceRequestsCPU {
+		t.Fatalf("unexpected capacity: %+v", info)
+	}
+	wantQ := func(what string, got resource.Quantity, want string) {
+		if got.Cmp(resource.MustParse(want)) != 0 {
+			t.Errorf("want %v %v, got %v", what, want, got.String())
+		}
+	}
+	wantQ("allocatable", cpu.Allocatable, "12")
+	wantQ("committed", cpu.Committed, "16")
+	wantQ("requested", cpu.Requested, "5500m")
+	wantQ("used", *cpu.Used, "3")
+	wantQ("headroom", cpu.Headroom, "-4")
+	wantQ("schedulable headroom", cpu.SchedulableHeadroom, "6500m")
+	if cpu.OvercommitRatio != 1.33 {
+		t.Errorf("want overcommit ratio 1.33, got %v", cpu.OvercommitRatio)
+	}
+	if len(info.TopConsumers) != 2 || info.TopConsumers[0].Project != "project-1" || info.TopConsumers[1].Namespace != "kube-system" {
+		t.Fatalf("unexpected top consumers: %+v", info.TopConsumers)
+	}
+	wantQ("requested of project", info.TopConsumers[0].Requested[v1.ResourceRequestsCPU], "5")
+	wantQ("used of project", info.TopConsumers[0].Used[v1.ResourceRequestsCPU], "1500m")
+
+	info, err = makeCapacityInfo(context.Background(), pivot.Direct(), member, "member-1", capacityOpts{pool: "gpu", top: 1, sortBy: v1.ResourceRequestsCPU})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cpu = info.Resources[0]
+	if info.NodeCount != 1 || cpu.OvercommitRatio != 1.5 || len(info.TopConsumers) != 1 {
+		t.Fatalf("unexpected capacity of pool: %+v", info)
+	}
+	wantQ("allocatable of pool", cpu.Allocatable, "4")
+	wantQ("committed of pool", cpu.Committed, "6")
+	wantQ("requested of pool", cpu.Requested, "3500m")
+	wantQ("used of pool", *cpu.Used, "2")
+}
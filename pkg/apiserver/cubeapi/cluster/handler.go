@@ -18,6 +18,7 @@ package cluster
 
 import (
 	"encoding/base64"
+	"fmt"
 	"net/http"
 	"sort"
 	"strconv"
@@ -60,6 +61,7 @@ func (h *handler) AddApisTo(root *gin.Engine) {
 	r.GET("/:cluster/livedata", h.getClusterLivedata)
 	r.GET("namespaces", h.getClusterNames)
 	r.GET("resources", h.getClusterResource)
+	r.GET("/:cluster/capacity", h.getClusterCapacity)
 	r.GET("subnamespaces", h.getSubNamespaces)
 	r.POST("register", h.registerCluster)
 	r.POST("add", h.addCluster)
@@ -389,6 +391,63 @@ func (h *handler) getClusterResource(c *gin.Context) {
 	response.SuccessReturn(c, res)
 }
 
+// getClusterCapacity compares quota committed against capacity of cluster
+// @Summary Get capacity of cluster
+// @Description put allocatable of nodes, hard of tenant quotas, requests of pods and usage from metrics side by side for cluster or nodes pool, with overcommit ratio, headroom and top consumers
+// @Tags cluster
+// @Param cluster path string true "cluster name"
+// @Param pool query string false "nodes pool of cluster, whole cluster if empty"
+// @Param top query int false "count of top consumers, 10 by default"
+// @Param sortBy query string false "resource consumers are ranked by requests of, requests.cpu by default"
+// @Success 200 {object} capacityInfo
+// @Failure 400 {object} errcode.ErrorInfo
+// @Failure 403 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/clusters/{cluster}/capacity  [get]
+func (h *handler) getClusterCapacity(c *gin.Context) {
+	cluster := c.Param("cluster")
+	opts := capacityOpts{
+		pool:   c.Query("pool"),
+		top:    defaultTopConsumers,
+		sortBy: v1.ResourceName(c.DefaultQuery("sortBy", string(v1.ResourceRequestsCPU))),
+	}
+	if v := c.Query("top"); len(v) > 0 {
+		top, err := strconv.Atoi(v)
+		if err != nil || top < 0 {
+			response.FailReturn(c, errcode.ParamsInvalid(fmt.Errorf("top must be a non-negative integer")))
+			return
+		}
+		opts.top = top
+	}
+	valid := false
+	for _, r := range capacityResources {
+		valid = valid || r.name == opts.sortBy
+	}
+	if !valid {
+		response.FailReturn(c, errcode.ParamsInvalid(fmt.Errorf("consumers can not be sorted by %v", opts.sortBy)))
+		return
+	}
+
+	// top consumers tell resources of all tenants
+	if !access.AllowAccess(constants.LocalCluster, c.Request, constants.ListVerb, &quotav1.CubeResourceQuota{}) {
+		response.FailReturn(c, errcode.ForbiddenErr)
+		return
+	}
+
+	cli := clients.Interface().Kubernetes(cluster)
+	if cli == nil {
+		response.FailReturn(c, errcode.ClusterNotFoundError(cluster))
+		return
+	}
+
+	info, err := makeCapacityInfo(c.Request.Context(), h.Cache(), cli, cluster, opts)
+	if err != nil {
+		response.FailReturn(c, errcode.CustomReturn(http.StatusBadRequest, err.Error()))
+		return
+	}
+
+	response.SuccessReturn(c, info)
+}
+
 type respBody struct {
 	Namespace     string       `json:"namespace"`
 	Cluster       string       `json:"cluster"`
@@ -733,6 +792,8 @@ type cubeResourceQuotaData struct {
 	CubeResourceQuota *quotav1.CubeResourceQuota `json:"cubeResourceQuota"`
 	ExclusiveNodeHard map[string]v1.ResourceList `json:"exclusiveNodeHard"`
 	ClusterState      *clusterv1.ClusterState    `json:"clusterState"`
+	// ThresholdLevel is the highest threshold level among resources of quota
+	ThresholdLevel quotav1.ThresholdLevel `json:"thresholdLevel,omitempty"`
 }
 
 func (h *handler) getCubeResourceQuota(c *gin.Context) {
@@ -150,59 +150,6 @@ func paginateClusterInfos(infos []clusterInfo, pageNum int, pageSize int) []clus
 	return infos[start:end]
 }
 
-func podRequestsAndLimits(pod *corev1.Pod) (reqs, limits corev1.ResourceList) {
-	reqs, limits = corev1.ResourceList{}, corev1.ResourceList{}
-	for _, container := range pod.Spec.Containers {
-		addResourceList(reqs, container.Resources.Requests)
-		addResourceList(limits, container.Resources.Limits)
-	}
-	// init containers define the minimum of any resource
-	for _, container := range pod.Spec.InitContainers {
-		maxResourceList(reqs, container.Resources.Requests)
-		maxResourceList(limits, container.Resources.Limits)
-	}
-
-	// Add overhead for running a pod to the sum of requests and to non-zero limits:
-	if pod.Spec.Overhead != nil {
-		addResourceList(reqs, pod.Spec.Overhead)
-
-		for name, quantity := range pod.Spec.Overhead {
-			if value, ok := limits[name]; ok && !value.IsZero() {
-				value.Add(quantity)
-				limits[name] = value
-			}
-		}
-	}
-	return
-}
-
-// addResourceList adds the resources in newList to list
-func addResourceList(list, new corev1.ResourceList) {
-	for name, quantity := range new {
-		if value, ok := list[name]; !ok {
-			list[name] = quantity.DeepCopy()
-		} else {
-			value.Add(quantity)
-			list[name] = value
-		}
-	}
-}
-
-// maxResourceList sets list to the greater of list/newList for every resource
-// either list
-func maxResourceList(list, new corev1.ResourceList) {
-	for name, quantity := range new {
-		if value, ok := list[name]; !ok {
-			list[name] = quantity.DeepCopy()
-			continue
-		} else {
-			if quantity.Cmp(value) > 0 {
-				list[name] = quantity.DeepCopy()
-			}
-		}
-	}
-}
-
 func makeMonitorInfo(ctx context.Context, cluster string) (*monitorInfo, error) {
 	cli := clients.Interface().Kubernetes(cluster)
 	if cli == nil {
@@ -344,7 +291,7 @@ func makeLivedataInfo(ctx context.Context, cli mgrclient.Client, cluster string,
 	for i := range podList.Items {
 		statusPhase := podList.Items[i].Status.Phase
 		if nodesName.Has(podList.Items[i].Spec.NodeName) && statusPhase != corev1.PodSucceeded && statusPhase != corev1.PodFailed {
-			req, limit := podRequestsAndLimits(&podList.Items[i])
+			req, limit := quota.PodRequestsAndLimits(&podList.Items[i])
 			cpuReq, cpuLimit, memoryReq, memoryLimit := req[corev1.ResourceCPU], limit[corev1.ResourceCPU], req[corev1.ResourceMemory], limit[corev1.ResourceMemory]
 			info.UsedCPURequest += int(cpuReq.MilliValue())                    // 1000 m
 			info.UsedCPULimit += int(cpuLimit.MilliValue())                    // 1000 m
@@ -470,19 +417,11 @@ func getAssignedResource(cli mgrclient.Client, cluster string) (cpu resource.Qua
 		return resource.Quantity{}, resource.Quantity{}, resource.Quantity{}, err
 	}
 
+	assigned := corev1.ResourceList{}
 	for _, obj := range listObjs.Items {
-		hard := obj.Spec.Hard
-		if v, ok := hard[corev1.ResourceRequestsCPU]; ok {
-			cpu.Add(v)
-		}
-		if v, ok := hard[corev1.ResourceRequestsMemory]; ok {
-			mem.Add(v)
-		}
-		if v, ok := hard[quota.ResourceNvidiaGPU]; ok {
-			gpu.Add(v)
-		}
+		addCapacityHard(assigned, obj.Spec.Hard)
 	}
-	return cpu, mem, gpu, err
+	return quantityOf(assigned, corev1.ResourceRequestsCPU), quantityOf(assigned, corev1.ResourceRequestsMemory), quantityOf(assigned, quota.ResourceNvidiaGPU), nil
 }
 
 func listAllHncNsFunc(ctx context.Context) func(cli mgrclient.Client) (corev1.NamespaceList, error) {
@@ -605,6 +544,9 @@ func listCubeResourceQuota(ctx context.Context, cli mgrclient.Client, tenants []
 			q, ok := quotaMap[quotaName]
 			if ok {
 				v.CubeResourceQuota = &q
+				if q.Spec.Thresholds != nil {
+					v.ThresholdLevel = quota.ThresholdLevelOf(&q)
+				}
 			}
 			data, ok := clusterMap[cluster]
 			if ok {
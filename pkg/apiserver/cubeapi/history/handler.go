@@ -0,0 +1,159 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kubecube-io/kubecube/pkg/authentication/authenticators/jwt"
+	"github.com/kubecube-io/kubecube/pkg/authentication/authenticators/token"
+	"github.com/kubecube-io/kubecube/pkg/authorizer/rbac"
+	"github.com/kubecube-io/kubecube/pkg/clients"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/multicluster"
+	"github.com/kubecube-io/kubecube/pkg/quota/history"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+	"github.com/kubecube-io/kubecube/pkg/utils/env"
+	"github.com/kubecube-io/kubecube/pkg/utils/errcode"
+	"github.com/kubecube-io/kubecube/pkg/utils/response"
+)
+
+const (
+	subPath = "/history"
+
+	// wardenService is the service of warden api server in clusters
+	wardenService = "https:" + constants.Warden + ":7443"
+)
+
+func (h *handler) AddApisTo(root *gin.Engine) {
+	r := root.Group(constants.ApiPathRoot + subPath)
+	r.GET("/quota", h.getQuotaHistory)
+}
+
+// fetchFunc queries history of cluster from its warden on behalf of the
+// owner of token
+type fetchFunc func(ctx context.Context, cluster string, values url.Values, token string) (*history.Result, error)
+
+type handler struct {
+	rbac.Interface
+
+	clusters func() []string
+	fetch    fetchFunc
+	now      func() time.Time
+}
+
+func NewHandler() *handler {
+	h := new(handler)
+	h.Interface = rbac.NewDefaultResolver(constants.LocalCluster)
+	h.clusters = func() []string {
+		var names []string
+		for name := range multicluster.Interface().FuzzyCopy() {
+			names = append(names, name)
+		}
+		return names
+	}
+	h.fetch = fetchFromWarden
+	h.now = time.Now
+	return h
+}
+
+// getQuotaHistory queries trends of quotas and usage
+// @Summary Get quota history
+// @Description query hard and used of quotas and usage reported by metrics server over time, values are averaged in steps and summed by group. Platform admins see all tenants, tenant admins see their own tenant and project admins see their own project
+// @Tags history
+// @Param start query string false "RFC3339 start of range, 7 days before end by default"
+// @Param end query string false "RFC3339 end of range, now by default"
+// @Param step query string false "duration of step such as 10m and 1h, divides range into 200 steps by default"
+// @Param groupBy query string false "one of tenant, project and cluster, project by default"
+// @Param tenant query string false "only query tenant"
+// @Param project query string false "only query project"
+// @Param cluster query string false "only query cluster"
+// @Success 200 {object} history.Result
+// @Failure 400 {object} errcode.ErrorInfo
+// @Failure 403 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/history/quota [get]
+func (h *handler) getQuotaHistory(c *gin.Context) {
+	userName := c.GetString(constants.UserName)
+
+	opts, matcher, err := history.ParseQuery(c.Request.URL.Query(), h.now())
+	if err != nil {
+		response.FailReturn(c, errcode.ParamsInvalid(err))
+		return
+	}
+	if !history.CanQuery(h, userName, matcher) {
+		response.FailReturn(c, errcode.ForbiddenErr)
+		return
+	}
+
+	clusters := h.clusters()
+	if cluster := c.Query("cluster"); len(cluster) > 0 {
+		clusters = []string{cluster}
+	}
+	sort.Strings(clusters)
+
+	tk, _ := token.GetTokenFromReq(c.Request)
+	values := opts.Values(matcher)
+	result := history.NewResult(opts)
+	var lists [][]history.Series
+	for _, cluster := range clusters {
+		r, err := h.fetch(c.Request.Context(), cluster, values, tk)
+		if err != nil {
+			clog.Warn("query quota history of cluster %v failed: %v", cluster, err)
+			result.UnavailableClusters = append(result.UnavailableClusters, cluster)
+			continue
+		}
+		lists = append(lists, r.Series)
+	}
+	result.Series = history.MergeSeries(lists...)
+
+	response.SuccessReturn(c, result)
+}
+
+// fetchFromWarden queries warden through service proxy of cluster, token
+// is passed by cookie since authorization header is consumed by apiserver
+func fetchFromWarden(ctx context.Context, cluster string, values url.Values, tk string) (*history.Result, error) {
+	cli := clients.Interface().Kubernetes(cluster)
+	if cli == nil {
+		return nil, fmt.Errorf("cluster %v not found", cluster)
+	}
+	req := cli.ClientSet().CoreV1().RESTClient().Get().
+		Namespace(env.CubeNamespace()).
+		Resource("services").
+		Name(wardenService).
+		SubResource("proxy").
+		Suffix(history.WardenPath).
+		SetHeader("Cookie", fmt.Sprintf("%v=%v+%v", constants.AuthorizationHeader, jwt.BearerTokenPrefix, tk))
+	for k := range values {
+		req = req.Param(k, values.Get(k))
+	}
+	raw, err := req.DoRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := &history.Result{}
+	if err = json.Unmarshal(raw, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
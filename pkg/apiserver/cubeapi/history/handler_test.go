@@ -0,0 +1,137 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubecube-io/kubecube/pkg/apis"
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/authorizer/rbac"
+	"github.com/kubecube-io/kubecube/pkg/multicluster/client/fake"
+	"github.com/kubecube-io/kubecube/pkg/quota/history"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+func TestGetQuotaHistory(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	apis.AddToScheme(scheme)
+	cli := fake.NewFakeClients(&fake.Options{Scheme: scheme, Objs: []client.Object{
+		&userv1.User{ObjectMeta: metav1.ObjectMeta{Name: "admin"}, Status: userv1.UserStatus{PlatformAdmin: true}},
+		&userv1.User{ObjectMeta: metav1.ObjectMeta{Name: "carol"}},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "carol-" + constants.TenantAdmin, Namespace: constants.TenantNsPrefix + "tenant-1"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: constants.K8sGroupRBAC, Kind: constants.KindClusterRole, Name: constants.TenantAdmin},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "carol"}},
+		},
+		&userv1.User{ObjectMeta: metav1.ObjectMeta{Name: "erin"}},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "erin-" + constants.ProjectAdmin, Namespace: constants.ProjectNsPrefix + "project-1"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: constants.K8sGroupRBAC, Kind: constants.KindClusterRole, Name: constants.ProjectAdmin},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "erin"}},
+		},
+	}})
+
+	now := time.Date(2023, 3, 2, 0, 0, 0, 0, time.UTC)
+	var queried []url.Values
+	h := &handler{
+		Interface: &rbac.DefaultResolver{Cache: cli.Cache()},
+		clusters:  func() []string { return []string{"member-1", "broken", "pivot-cluster"} },
+		fetch: func(ctx context.Context, cluster string, values url.Values, token string) (*history.Result, error) {
+			if cluster == "broken" {
+				return nil, fmt.Errorf("unreachable")
+			}
+			queried = append(queried, values)
+			return &history.Result{Series: []history.Series{{
+				Tenant:  "tenant-1",
+				Project: "project-1",
+				Points:  []history.Point{{Time: now.Add(-time.Hour), Values: map[string]float64{"used/requests.cpu": 2}}},
+			}}}, nil
+		},
+		now: func() time.Time { return now },
+	}
+	requester := ""
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set(constants.UserName, requester)
+	})
+	h.AddApisTo(router)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, constants.ApiPathRoot+subPath+"/quota?"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	requester = "admin"
+	w := get("")
+	if w.Code != http.StatusOK {
+		t.Fatalf("want 200, got %v: %v", w.Code, w.Body.String())
+	}
+	result := &history.Result{}
+	json.Unmarshal(w.Body.Bytes(), result)
+	if len(result.Series) != 1 || result.Series[0].Points[0].Values["used/requests.cpu"] != 4 {
+		t.Errorf("series of clusters should be merged: %+v", result.Series)
+	}
+	if len(result.UnavailableClusters) != 1 || result.UnavailableClusters[0] != "broken" {
+		t.Errorf("unexpected unavailable clusters: %v", result.UnavailableClusters)
+	}
+	// clusters are queried with the same steps
+	if len(queried) != 2 || queried[0].Encode() != queried[1].Encode() || queried[0].Get("end") != now.Format(time.RFC3339) {
+		t.Errorf("unexpected queries: %v", queried)
+	}
+
+	requester = "carol"
+	if w = get(""); w.Code != http.StatusForbidden {
+		t.Errorf("tenant admin should not see all tenants, got %v", w.Code)
+	}
+	queried = nil
+	if w = get("tenant=tenant-1&cluster=member-1"); w.Code != http.StatusOK || len(queried) != 1 || queried[0].Get("tenant") != "tenant-1" {
+		t.Errorf("tenant admin should see own tenant, got %v %v", w.Code, queried)
+	}
+	if w = get("tenant=tenant-1&step=1s"); w.Code != http.StatusBadRequest {
+		t.Errorf("want 400 for invalid step, got %v", w.Code)
+	}
+
+	requester = "erin"
+	if w = get("tenant=tenant-1"); w.Code != http.StatusForbidden {
+		t.Errorf("project admin should not see whole tenant, got %v", w.Code)
+	}
+	if w = get("project=project-2"); w.Code != http.StatusForbidden {
+		t.Errorf("project admin should not see other projects, got %v", w.Code)
+	}
+	queried = nil
+	if w = get("project=project-1&cluster=member-1"); w.Code != http.StatusOK || len(queried) != 1 || queried[0].Get("project") != "project-1" {
+		t.Errorf("project admin should see own project, got %v %v", w.Code, queried)
+	}
+}
@@ -19,6 +19,7 @@ package key
 import (
 	"context"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	uuid "github.com/google/uuid"
@@ -31,6 +32,7 @@ import (
 	key "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
 	"github.com/kubecube-io/kubecube/pkg/authentication/authenticators/jwt"
 	"github.com/kubecube-io/kubecube/pkg/authentication/authenticators/token"
+	"github.com/kubecube-io/kubecube/pkg/authentication/session"
 	"github.com/kubecube-io/kubecube/pkg/clients"
 	"github.com/kubecube-io/kubecube/pkg/clog"
 	"github.com/kubecube-io/kubecube/pkg/utils/audit"
@@ -43,11 +45,20 @@ const (
 	UserLabel = "kubecube.io/user"
 )
 
+// CreateKeyParam is the optional params to create key
+type CreateKeyParam struct {
+	// ExpireTime is the time key expired at, never expired if empty
+	ExpireTime *metav1.Time `json:"expireTime,omitempty"`
+	// Scope limits the tokens issued by key, no limit if empty
+	Scope *key.KeyScope `json:"scope,omitempty"`
+}
+
 // CreateKey creates ak and sk
 // create ak & sk
 // @Summary create key
 // @Description create ak & sk keys
 // @Tags key
+// @Param param body CreateKeyParam false "expire time and scope of key"
 // @Success 200 {object} map[string]string "{"accessKey":"xxx","secretKey":"xxx"}"
 // @Failure 500 {object} errcode.ErrorInfo
 // @Router /api/v1/cube/key/create  [get]
@@ -60,18 +71,45 @@ func CreateKey(c *gin.Context) {
 	}
 	c = audit.SetAuditInfo(c, audit.CreateKey, userInfo.Username, userInfo)
 
+	// keys outlive impersonation, so they can not be created by impersonator
+	if len(c.GetString(constants.Impersonator)) > 0 {
+		response.FailReturn(c, errcode.ForbiddenErr)
+		return
+	}
+
+	// params are optional
+	param := CreateKeyParam{}
+	if c.Request.Body != nil && c.Request.ContentLength != 0 {
+		if err = c.ShouldBindJSON(&param); err != nil {
+			response.FailReturn(c, errcode.InvalidBodyFormat)
+			return
+		}
+	}
+
+	result, errInfo := CreateKeyForUser(c.Request.Context(), userInfo.Username, param, nil)
+	if errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+	response.SuccessReturn(c, result)
+}
+
+// CreateKeyForUser creates ak and sk for given user, the key will be
+// deleted with its owner if owner given.
+func CreateKeyForUser(ctx context.Context, user string, param CreateKeyParam, owner *metav1.OwnerReference) (map[string]string, *errcode.ErrorInfo) {
+	if param.ExpireTime != nil && !param.ExpireTime.After(time.Now()) {
+		return nil, errcode.InvalidParameterExpireTime
+	}
+
 	// max key num <= 5
 	localClient := clients.Interface().Kubernetes(constants.LocalCluster)
-	ctx := context.Background()
 	keyList := key.KeyList{}
-	err = localClient.Cache().List(ctx, &keyList, client.MatchingLabels{UserLabel: userInfo.Username})
+	err := localClient.Cache().List(ctx, &keyList, client.MatchingLabels{UserLabel: user})
 	if err != nil {
-		response.FailReturn(c, errcode.BadRequest(err))
-		return
+		return nil, errcode.BadRequest(err)
 	}
 	if len(keyList.Items) >= 5 {
-		response.FailReturn(c, errcode.MaxKeyErr)
-		return
+		return nil, errcode.MaxKeyErr
 	}
 
 	// create ak & sk
@@ -87,26 +125,28 @@ func CreateKey(c *gin.Context) {
 		ObjectMeta: metav1.ObjectMeta{
 			Name: accessKey,
 			Labels: map[string]string{
-				UserLabel: userInfo.Username,
+				UserLabel: user,
 			},
 		},
 		Spec: key.KeySpec{
-			SecretKey: secretKey,
-			User:      userInfo.Username,
+			SecretKey:  secretKey,
+			User:       user,
+			ExpireTime: param.ExpireTime,
+			Scope:      param.Scope,
 		},
 	}
+	if owner != nil {
+		keyInfo.OwnerReferences = []metav1.OwnerReference{*owner}
+	}
 	err = localClient.Direct().Create(ctx, &keyInfo)
 	if err != nil {
-		response.FailReturn(c, errcode.BadRequest(err))
-		return
+		return nil, errcode.BadRequest(err)
 	}
 
-	// return
-	result := map[string]string{
+	return map[string]string{
 		"accessKey": accessKey,
 		"secretKey": secretKey,
-	}
-	response.SuccessReturn(c, result)
+	}, nil
 }
 
 // DeleteKey delete ak and sk
@@ -148,6 +188,12 @@ func DeleteKey(c *gin.Context) {
 		return
 	}
 
+	// tokens issued by the key should not work anymore
+	err = session.NewManager(localClient).RevokeByKey(ctx, accessKey, session.ReasonKeyInvalid)
+	if err != nil {
+		clog.Warn("revoke sessions of key %v failed: %v", accessKey, err)
+	}
+
 	c = audit.SetAuditInfo(c, audit.DeleteKey, accessKey, keyInfo)
 
 	response.SuccessReturn(c, nil)
@@ -209,6 +255,10 @@ func GetTokenByKey(c *gin.Context) {
 		response.FailReturn(c, errcode.SecretNotMatchErr)
 		return
 	}
+	if !keyInfo.IsAvailable(time.Now()) {
+		response.FailReturn(c, errcode.KeyUnavailableErr)
+		return
+	}
 
 	// is user exist
 	user := key.User{}
@@ -223,14 +273,39 @@ func GetTokenByKey(c *gin.Context) {
 		return
 	}
 
-	// gen token
+	// gen token, every token issued by key belongs to a new session
 	authJwtImpl := jwt.GetAuthJwtImpl()
-	token, errInfo := authJwtImpl.GenerateToken(&v1beta1.UserInfo{Username: user.Name})
+	sessionID := uuid.NewString()
+	token, errInfo := authJwtImpl.GenerateScopedTokenForSession(&v1beta1.UserInfo{Username: user.Name}, sessionID, keyInfo.Spec.Scope)
 	if errInfo != nil {
 		clog.Info("gen token fail, %v", errInfo)
 		response.FailReturn(c, errcode.BadRequest(err))
 		return
 	}
+
+	cli := clients.Interface().Kubernetes(constants.LocalCluster)
+	s := &key.Session{}
+	s.Name = sessionID
+	s.Spec = key.SessionSpec{
+		User:      user.Name,
+		AccessKey: accessKey,
+		ClientIP:  c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+	// token of key is revoked by its session, so it must not be issued without session
+	if err = session.NewManager(cli).Create(ctx, s); err != nil {
+		clog.Warn("create session for key %v failed: %v", accessKey, err)
+		response.FailReturn(c, errcode.ServerErr)
+		return
+	}
+
+	// record the last time key used
+	keyInfo.Status.LastUsedTime = &metav1.Time{Time: time.Now()}
+	keyInfo.Status.LastUsedIP = c.ClientIP()
+	if err = localClient.Status().Update(ctx, &keyInfo); err != nil {
+		clog.Warn("update last used time of key %v failed: %v", accessKey, err)
+	}
+
 	result := map[string]string{
 		"token": token,
 	}
@@ -21,6 +21,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	. "github.com/onsi/ginkgo/v2"
@@ -185,4 +186,35 @@ var _ = Describe("Key", func() {
 		key.GetTokenByKey(c)
 		Expect(w.Code).To(Equal(http.StatusBadRequest))
 	})
+	Context("with scope", func() {
+		BeforeEach(func() {
+			userKey.Spec.Scope = &userv1.KeyScope{ReadOnly: true, Clusters: []string{"pivot-cluster"}}
+		})
+		It("test get scoped token by key", func() {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			u, _ := url.Parse("https://example.org/?accessKey=" + accessKey + "&secretKey=" + secretKey)
+			c.Request = &http.Request{URL: u, Header: http.Header{}}
+			key.GetTokenByKey(c)
+			Expect(w.Code).To(Equal(http.StatusOK))
+			var m map[string]string
+			Expect(json.Unmarshal(w.Body.Bytes(), &m)).To(BeNil())
+			claims, err := jwt.GetAuthJwtImpl().ParseClaims(m["token"])
+			Expect(err).To(BeNil())
+			Expect(claims.Scope).To(Equal(userKey.Spec.Scope))
+		})
+	})
+	Context("with expire time", func() {
+		BeforeEach(func() {
+			userKey.Spec.ExpireTime = &metav1.Time{Time: time.Now().Add(-time.Minute)}
+		})
+		It("test get token by expired key", func() {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			u, _ := url.Parse("https://example.org/?accessKey=" + accessKey + "&secretKey=" + secretKey)
+			c.Request = &http.Request{URL: u, Header: http.Header{}}
+			key.GetTokenByKey(c)
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
 })
@@ -0,0 +1,98 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quota
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
+	"github.com/kubecube-io/kubecube/pkg/clients"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	mgrclient "github.com/kubecube-io/kubecube/pkg/multicluster/client"
+	"github.com/kubecube-io/kubecube/pkg/quota/drift"
+	"github.com/kubecube-io/kubecube/pkg/utils/access"
+	"github.com/kubecube-io/kubecube/pkg/utils/audit"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+	"github.com/kubecube-io/kubecube/pkg/utils/errcode"
+	"github.com/kubecube-io/kubecube/pkg/utils/response"
+)
+
+const (
+	subPath = "/cuberesourcequotas"
+
+	resourceType = "cuberesourcequota"
+)
+
+func (h *handler) AddApisTo(root *gin.Engine) {
+	r := root.Group(constants.ApiPathRoot + subPath)
+	r.POST("recalculate", h.recalculate)
+}
+
+type handler struct {
+	mgrclient.Client
+
+	memberOf drift.MemberReaderFunc
+}
+
+func NewHandler() *handler {
+	h := new(handler)
+	h.Client = clients.Interface().Kubernetes(constants.LocalCluster)
+	h.memberOf = drift.MemberReader
+	return h
+}
+
+// recalculate recomputes used of cube resource quotas from child quotas
+// @Summary Recalculate cube resource quotas
+// @Description recompute used and sub resource quotas of cube resource quotas from the actual child quotas across all clusters and repair the drifted ones, only report drifts if dryRun
+// @Tags quota
+// @Param quotas query string false "names of cube resource quotas separated by ';', all if empty"
+// @Param dryRun query bool false "only report drifts"
+// @Success 200 {object} drift.Result
+// @Failure 403 {object} errcode.ErrorInfo
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/cuberesourcequotas/recalculate [post]
+func (h *handler) recalculate(c *gin.Context) {
+	dryRun, _ := strconv.ParseBool(c.Query("dryRun"))
+	var names []string
+	if qs := c.Query("quotas"); len(qs) > 0 {
+		names = strings.Split(qs, ";")
+	}
+
+	if !access.AllowAccess(constants.LocalCluster, c.Request, constants.UpdateVerb, &quotav1.CubeResourceQuota{}) {
+		response.FailReturn(c, errcode.ForbiddenErr)
+		return
+	}
+
+	detector := drift.NewDetector(h.Direct(), h.memberOf, nil)
+	result, err := detector.Check(c.Request.Context(), dryRun, names...)
+	if result == nil {
+		clog.Error("recalculate cube resource quotas failed: %v", err)
+		response.FailReturn(c, errcode.GetResourceError(resourceType))
+		return
+	}
+	if err != nil {
+		clog.Warn("recalculate cube resource quotas partially failed: %v", err)
+	}
+
+	if !dryRun {
+		c = audit.SetAuditInfo(c, audit.RecalculateQuota, strings.Join(names, ";"), nil)
+	}
+	response.SuccessReturn(c, result)
+}
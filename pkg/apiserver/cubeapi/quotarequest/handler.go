@@ -0,0 +1,560 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quotarequest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+
+	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
+	tenantv1 "github.com/kubecube-io/kubecube/pkg/apis/tenant/v1"
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/apiserver/cubeapi/approval"
+	"github.com/kubecube-io/kubecube/pkg/authorizer/rbac"
+	"github.com/kubecube-io/kubecube/pkg/clients"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	mgrclient "github.com/kubecube-io/kubecube/pkg/multicluster/client"
+	"github.com/kubecube-io/kubecube/pkg/quota"
+	"github.com/kubecube-io/kubecube/pkg/quota/cube"
+	"github.com/kubecube-io/kubecube/pkg/utils/audit"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+	"github.com/kubecube-io/kubecube/pkg/utils/errcode"
+	"github.com/kubecube-io/kubecube/pkg/utils/response"
+)
+
+const (
+	subPath = "/quotarequests"
+
+	resourceType = "quotarequest"
+)
+
+func (h *handler) AddApisTo(root *gin.Engine) {
+	r := root.Group(constants.ApiPathRoot + subPath)
+	r.POST("", h.createQuotaRequest)
+	r.GET("", h.listQuotaRequests)
+	r.GET("/:name", h.getQuotaRequest)
+	r.POST("/:name/approve", h.approveQuotaRequest)
+	r.POST("/:name/reject", h.rejectQuotaRequest)
+	r.POST("/:name/cancel", h.cancelQuotaRequest)
+}
+
+type result struct {
+	Total int                    `json:"total"`
+	Items []quotav1.QuotaRequest `json:"items"`
+}
+
+// CreateParam is the body to ask for changing hard of project quota
+type CreateParam struct {
+	Quota  string          `json:"quota"`
+	Hard   v1.ResourceList `json:"hard"`
+	Reason string          `json:"reason"`
+}
+
+// CommentParam is the body to decide quota request
+type CommentParam struct {
+	Comment string `json:"comment"`
+}
+
+type handler struct {
+	rbac.Interface
+	mgrclient.Client
+
+	now func() time.Time
+}
+
+func NewHandler() *handler {
+	h := new(handler)
+	h.Interface = rbac.NewDefaultResolver(constants.LocalCluster)
+	h.Client = clients.Interface().Kubernetes(constants.LocalCluster)
+	h.now = time.Now
+	return h
+}
+
+// createQuotaRequest asks for changing hard of project quota
+// @Summary Create quota request
+// @Description project admin asks for increasing or decreasing resources of project quota, the change is validated against free capacity of tenant quota and routed to tenant admins
+// @Tags quotarequest
+// @Param param body CreateParam true "change asked for"
+// @Success 200 {object} quotav1.QuotaRequest
+// @Failure 400 {object} errcode.ErrorInfo
+// @Failure 403 {object} errcode.ErrorInfo
+// @Failure 409 {object} errcode.ErrorInfo
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/quotarequests [post]
+func (h *handler) createQuotaRequest(c *gin.Context) {
+	ctx := c.Request.Context()
+	userName := c.GetString(constants.UserName)
+
+	param := &CreateParam{}
+	if err := c.ShouldBindJSON(param); err != nil {
+		response.FailReturn(c, errcode.InvalidBodyFormat)
+		return
+	}
+	if len(param.Quota) == 0 || len(param.Hard) == 0 || len(strings.TrimSpace(param.Reason)) == 0 {
+		response.FailReturn(c, errcode.ParamsInvalid(fmt.Errorf("quota, hard and reason are required")))
+		return
+	}
+
+	cubeQuota := &quotav1.CubeResourceQuota{}
+	if err := h.Direct().Get(ctx, types.NamespacedName{Name: param.Quota}, cubeQuota); err != nil {
+		response.FailReturn(c, errcode.ParamsInvalid(fmt.Errorf("get cube resource quota %v failed: %v", param.Quota, err)))
+		return
+	}
+	if cubeQuota.Spec.Target.Kind != quotav1.ProjectObj {
+		response.FailReturn(c, errcode.ParamsInvalid(fmt.Errorf("only quota of project can be requested")))
+		return
+	}
+	if err := checkHard(cubeQuota, param.Hard); err != nil {
+		response.FailReturn(c, errcode.ParamsInvalid(err))
+		return
+	}
+
+	project := &tenantv1.Project{}
+	if err := h.Cache().Get(ctx, types.NamespacedName{Name: cubeQuota.Spec.Target.Name}, project); err != nil {
+		response.FailReturn(c, errcode.ParamsInvalid(fmt.Errorf("get project %v failed: %v", cubeQuota.Spec.Target.Name, err)))
+		return
+	}
+	tenant := project.Labels[constants.TenantLabel]
+	if !h.isAdminOf(userName, project.Name, tenant) {
+		response.FailReturn(c, errcode.ForbiddenErr)
+		return
+	}
+
+	// validated up front, the change is validated again when approved
+	allowed, reason, err := cube.CheckHardChange(ctx, h.Direct(), cubeQuota, mergeHard(cubeQuota.Spec.Hard, param.Hard))
+	if err != nil {
+		clog.Error("check change of cube resource quota %v failed: %v", cubeQuota.Name, err)
+		response.FailReturn(c, errcode.GetResourceError("cuberesourcequota"))
+		return
+	}
+	if !allowed {
+		response.FailReturn(c, errcode.QuotaChangeDenied(reason))
+		return
+	}
+
+	list := &quotav1.QuotaRequestList{}
+	if err = h.Direct().List(ctx, list); err != nil {
+		clog.Error("list quota requests failed: %v", err)
+		response.FailReturn(c, errcode.GetResourceError(resourceType))
+		return
+	}
+	for _, r := range list.Items {
+		if r.GetPhase() == quotav1.QuotaRequestPending && r.Spec.Quota == param.Quota {
+			response.FailReturn(c, errcode.AlreadyExist(r.Name))
+			return
+		}
+	}
+
+	approvers := h.usersOf(constants.TenantAdmin, constants.TenantNsPrefix+tenant)
+	emails := make([]string, 0, len(approvers))
+	names := make([]string, 0, len(approvers))
+	for _, u := range approvers {
+		// requester can not approve own request
+		if u.Name == userName {
+			continue
+		}
+		names = append(names, u.Name)
+		if len(u.Spec.Email) > 0 {
+			emails = append(emails, u.Spec.Email)
+		}
+	}
+	sort.Strings(names)
+
+	r := &quotav1.QuotaRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   uuid.NewString(),
+			Labels: map[string]string{constants.TenantLabel: tenant, constants.ProjectLabel: project.Name},
+		},
+		Spec: quotav1.QuotaRequestSpec{
+			User:   userName,
+			Quota:  param.Quota,
+			Hard:   param.Hard,
+			Reason: param.Reason,
+		},
+		Status: quotav1.QuotaRequestStatus{
+			Phase:     quotav1.QuotaRequestPending,
+			Approvers: names,
+		},
+	}
+	if err = h.Direct().Create(ctx, r); err != nil {
+		clog.Error("create quota request of user %v failed: %v", userName, err)
+		response.FailReturn(c, errcode.CreateResourceError(resourceType))
+		return
+	}
+
+	clog.Info("user %v asks for changing quota %v to %v, approvers: %v", userName, r.Spec.Quota, r.Spec.Hard, names)
+	approval.Notify(emails, fmt.Sprintf("Quota request from %v", userName),
+		fmt.Sprintf("User %v asks for changing quota of project %v.\n\n%v\nReason: %v\n\nRequest: %v\n",
+			userName, project.Name, describeChange(cubeQuota.Spec.Hard, r.Spec.Hard), r.Spec.Reason, r.Name))
+
+	c = audit.SetAuditInfo(c, audit.CreateQuotaRequest, r.Name, param)
+	response.SuccessReturn(c, r)
+}
+
+// listQuotaRequests list quota requests visible to current user
+// @Summary List quota requests
+// @Description list quota requests filed by current user or routed to current user, platform admins see all
+// @Tags quotarequest
+// @Param phase query string false "phase of request: Pending, Approved, Rejected or Cancelled"
+// @Param quota query string false "name of cube resource quota"
+// @Success 200 {object} result
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/quotarequests [get]
+func (h *handler) listQuotaRequests(c *gin.Context) {
+	userName := c.GetString(constants.UserName)
+	phase := c.Query("phase")
+	quotaName := c.Query("quota")
+
+	list := &quotav1.QuotaRequestList{}
+	if err := h.Direct().List(c.Request.Context(), list); err != nil {
+		clog.Error("list quota requests failed: %v", err)
+		response.FailReturn(c, errcode.GetResourceError(resourceType))
+		return
+	}
+
+	admin := h.workflow().IsPlatformAdmin(userName)
+	items := make([]quotav1.QuotaRequest, 0)
+	for _, r := range list.Items {
+		if len(phase) > 0 && string(r.GetPhase()) != phase {
+			continue
+		}
+		if len(quotaName) > 0 && r.Spec.Quota != quotaName {
+			continue
+		}
+		if admin || r.Spec.User == userName || r.IsApprover(userName) {
+			items = append(items, r)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[j].CreationTimestamp.Before(&items[i].CreationTimestamp)
+	})
+
+	response.SuccessReturn(c, result{Total: len(items), Items: items})
+}
+
+// getQuotaRequest get quota request by name
+// @Summary Get quota request
+// @Description get quota request filed by current user or routed to current user
+// @Tags quotarequest
+// @Param name path string true "quota request name"
+// @Success 200 {object} quotav1.QuotaRequest
+// @Failure 403 {object} errcode.ErrorInfo
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/quotarequests/{name} [get]
+func (h *handler) getQuotaRequest(c *gin.Context) {
+	userName := c.GetString(constants.UserName)
+
+	w := h.workflow()
+	r, errInfo := w.Get(c.Request.Context(), c.Param("name"))
+	if errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+	if !w.CanView(r, userName) {
+		response.FailReturn(c, errcode.ForbiddenErr)
+		return
+	}
+
+	response.SuccessReturn(c, r)
+}
+
+// approveQuotaRequest approve quota request and apply the change
+// @Summary Approve quota request
+// @Description approver or platform admin approves the request, hard of quota is changed at once and recorded in history of quota
+// @Tags quotarequest
+// @Param name path string true "quota request name"
+// @Param param body CommentParam false "comment of approval"
+// @Success 200 {object} quotav1.QuotaRequest
+// @Failure 403 {object} errcode.ErrorInfo
+// @Failure 409 {object} errcode.ErrorInfo
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/quotarequests/{name}/approve [post]
+func (h *handler) approveQuotaRequest(c *gin.Context) {
+	h.decide(c, quotav1.QuotaRequestApproved)
+}
+
+// rejectQuotaRequest reject quota request
+// @Summary Reject quota request
+// @Description approver or platform admin rejects the request
+// @Tags quotarequest
+// @Param name path string true "quota request name"
+// @Param param body CommentParam false "comment of rejection"
+// @Success 200 {object} quotav1.QuotaRequest
+// @Failure 403 {object} errcode.ErrorInfo
+// @Failure 409 {object} errcode.ErrorInfo
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/quotarequests/{name}/reject [post]
+func (h *handler) rejectQuotaRequest(c *gin.Context) {
+	h.decide(c, quotav1.QuotaRequestRejected)
+}
+
+// cancelQuotaRequest cancel quota request
+// @Summary Cancel quota request
+// @Description requester cancels own pending request
+// @Tags quotarequest
+// @Param name path string true "quota request name"
+// @Param param body CommentParam false "comment of cancellation"
+// @Success 200 {object} quotav1.QuotaRequest
+// @Failure 403 {object} errcode.ErrorInfo
+// @Failure 409 {object} errcode.ErrorInfo
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/quotarequests/{name}/cancel [post]
+func (h *handler) cancelQuotaRequest(c *gin.Context) {
+	h.decide(c, quotav1.QuotaRequestCancelled)
+}
+
+// decide moves pending request to given phase, the change is applied
+// after the request is marked approved.
+func (h *handler) decide(c *gin.Context, phase quotav1.QuotaRequestPhase) {
+	userName := c.GetString(constants.UserName)
+
+	param := &CommentParam{}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(param); err != nil {
+			response.FailReturn(c, errcode.InvalidBodyFormat)
+			return
+		}
+	}
+
+	r, errInfo := h.workflow().Decide(c, string(phase), param.Comment, func(ctx context.Context, r *quotav1.QuotaRequest) *errcode.ErrorInfo {
+		return h.apply(ctx, r, userName)
+	})
+	if errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+
+	event := audit.ApproveQuotaRequest
+	switch phase {
+	case quotav1.QuotaRequestRejected:
+		event = audit.RejectQuotaRequest
+	case quotav1.QuotaRequestCancelled:
+		event = audit.CancelQuotaRequest
+	}
+	c = audit.SetAuditInfo(c, event, r.Name, param)
+	response.SuccessReturn(c, r)
+}
+
+// apply changes hard of quota as request asks and records the change in
+// history of quota. Free capacity of parent is checked again since it may
+// be taken after the request filed.
+func (h *handler) apply(ctx context.Context, r *quotav1.QuotaRequest, approver string) *errcode.ErrorInfo {
+	var (
+		from, to v1.ResourceList
+		errInfo  *errcode.ErrorInfo
+	)
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		errInfo = nil
+		cubeQuota := &quotav1.CubeResourceQuota{}
+		if err := h.Direct().Get(ctx, types.NamespacedName{Name: r.Spec.Quota}, cubeQuota); err != nil {
+			return err
+		}
+		hard := mergeHard(cubeQuota.Spec.Hard, r.Spec.Hard)
+		allowed, reason, err := cube.CheckHardChange(ctx, h.Direct(), cubeQuota, hard)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			errInfo = errcode.QuotaChangeDenied(reason)
+			return nil
+		}
+		from, to = v1.ResourceList{}, v1.ResourceList{}
+		for rs := range r.Spec.Hard {
+			if old, ok := cubeQuota.Spec.Hard[rs]; ok {
+				from[rs] = old
+			}
+			to[rs] = hard[rs]
+		}
+		cubeQuota.Spec.Hard = hard
+		return h.Direct().Update(ctx, cubeQuota)
+	})
+	if errInfo != nil {
+		return errInfo
+	}
+	if err != nil {
+		clog.Error("change hard of cube resource quota %v failed: %v", r.Spec.Quota, err)
+		return errcode.UpdateResourceError("cuberesourcequota")
+	}
+
+	record := quotav1.QuotaChangeRecord{
+		Request:   r.Name,
+		Requester: r.Spec.User,
+		Approver:  approver,
+		Reason:    r.Spec.Reason,
+		Time:      metav1.NewTime(h.now()),
+		From:      from,
+		To:        to,
+	}
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cubeQuota := &quotav1.CubeResourceQuota{}
+		if err := h.Direct().Get(ctx, types.NamespacedName{Name: r.Spec.Quota}, cubeQuota); err != nil {
+			return err
+		}
+		cubeQuota.Status.History = quota.AppendHistory(cubeQuota.Status.History, record)
+		return h.Direct().Status().Update(ctx, cubeQuota)
+	})
+	if err != nil {
+		// the change has been applied, lost history is not worth failing approval
+		clog.Error("record history of cube resource quota %v failed: %v", r.Spec.Quota, err)
+	}
+	return nil
+}
+
+// checkHard ensures resources asked for are supported and not negative
+func checkHard(cubeQuota *quotav1.CubeResourceQuota, hard v1.ResourceList) error {
+	for rs, q := range hard {
+		if !quota.IsSupported(rs) {
+			return fmt.Errorf("resource %v is not supported", rs)
+		}
+		if q.Sign() < 0 {
+			return fmt.Errorf("hard of resource %v must not be negative", rs)
+		}
+	}
+	if quota.ResourceListEqual(cubeQuota.Spec.Hard, mergeHard(cubeQuota.Spec.Hard, hard)) {
+		return fmt.Errorf("nothing changed")
+	}
+	return nil
+}
+
+// mergeHard returns hard with resources of change overridden
+func mergeHard(hard, change v1.ResourceList) v1.ResourceList {
+	merged := hard.DeepCopy()
+	if merged == nil {
+		merged = v1.ResourceList{}
+	}
+	for rs, q := range change {
+		merged[rs] = q.DeepCopy()
+	}
+	return merged
+}
+
+func describeChange(hard, change v1.ResourceList) string {
+	names := make([]string, 0, len(change))
+	for rs := range change {
+		names = append(names, string(rs))
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		rs := v1.ResourceName(name)
+		old, ok := hard[rs]
+		from := "unset"
+		if ok {
+			from = old.String()
+		}
+		to := change[rs]
+		fmt.Fprintf(&b, "%v: %v -> %v\n", rs, from, to.String())
+	}
+	return b.String()
+}
+
+// isAdminOf tells if user is admin of project, admin of tenant of project
+// or platform admin
+func (h *handler) isAdminOf(userName, project, tenant string) bool {
+	if h.workflow().IsPlatformAdmin(userName) {
+		return true
+	}
+	for _, u := range h.usersOf(constants.ProjectAdmin, constants.ProjectNsPrefix+project) {
+		if u.Name == userName {
+			return true
+		}
+	}
+	for _, u := range h.usersOf(constants.TenantAdmin, constants.TenantNsPrefix+tenant) {
+		if u.Name == userName {
+			return true
+		}
+	}
+	return false
+}
+
+// usersOf returns users bound to admin role in namespace
+func (h *handler) usersOf(role, namespace string) []*userv1.User {
+	users, err := h.UsersFor(rbacv1.RoleRef{APIGroup: constants.K8sGroupRBAC, Kind: constants.KindClusterRole, Name: role}, namespace)
+	if err != nil {
+		clog.Warn("resolve users of %v in namespace %v failed: %v", role, namespace, err)
+	}
+	return users
+}
+
+func (h *handler) workflow() *approval.Workflow[*quotav1.QuotaRequest] {
+	return &approval.Workflow[*quotav1.QuotaRequest]{
+		Adapter:  adapter{},
+		Resolver: h.Interface,
+		Client:   h.Client,
+		Now:      h.now,
+		Resource: resourceType,
+	}
+}
+
+// adapter lets quota requests go through approval workflow
+type adapter struct{}
+
+func (adapter) New() *quotav1.QuotaRequest {
+	return &quotav1.QuotaRequest{}
+}
+
+func (adapter) Phase(r *quotav1.QuotaRequest) string {
+	return string(r.GetPhase())
+}
+
+func (adapter) Requester(r *quotav1.QuotaRequest) string {
+	return r.Spec.User
+}
+
+func (adapter) Decider(r *quotav1.QuotaRequest) string {
+	return r.Status.DecidedBy
+}
+
+func (adapter) IsApprover(r *quotav1.QuotaRequest, user string) bool {
+	return r.IsApprover(user)
+}
+
+func (adapter) Record(r *quotav1.QuotaRequest, d *approval.Decision) {
+	r.Status.Phase = quotav1.QuotaRequestPhase(d.Phase)
+	r.Status.DecidedBy = d.Decider
+	r.Status.DecisionTime = d.Time.DeepCopy()
+	r.Status.Comment = d.Comment
+}
+
+func (adapter) Undo(r *quotav1.QuotaRequest, _ *approval.Decision) {
+	r.Status.Phase = quotav1.QuotaRequestPending
+	r.Status.DecidedBy = ""
+	r.Status.DecisionTime = nil
+	r.Status.Comment = ""
+}
+
+func (adapter) Decided(phase string) *errcode.ErrorInfo {
+	return errcode.QuotaRequestDecided(phase)
+}
+
+func (adapter) Notice(r *quotav1.QuotaRequest) (string, string) {
+	return fmt.Sprintf("Quota request %v", strings.ToLower(string(r.Status.Phase))),
+		fmt.Sprintf("Your request for changing quota %v is %v by %v.\n\nComment: %v\n\nRequest: %v\n",
+			r.Spec.Quota, strings.ToLower(string(r.Status.Phase)), r.Status.DecidedBy, r.Status.Comment, r.Name)
+}
@@ -0,0 +1,236 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quotarequest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubecube-io/kubecube/pkg/apis"
+	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
+	tenantv1 "github.com/kubecube-io/kubecube/pkg/apis/tenant/v1"
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/authorizer/rbac"
+	"github.com/kubecube-io/kubecube/pkg/multicluster/client/fake"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+func newTestHandler(t *testing.T) (*gin.Engine, *handler, *string) {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	apis.AddToScheme(scheme)
+
+	newUser := func(name string, admin bool) client.Object {
+		return &userv1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     userv1.UserStatus{PlatformAdmin: admin},
+		}
+	}
+	newBinding := func(user, role, namespace string) client.Object {
+		return &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: user + "-" + role, Namespace: namespace},
+			RoleRef:    rbacv1.RoleRef{APIGroup: constants.K8sGroupRBAC, Kind: constants.KindClusterRole, Name: role},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: user}},
+		}
+	}
+	newQuota := func(name, parent string, kind quotav1.TargetKind, target, hard, used string) client.Object {
+		return &quotav1.CubeResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: quotav1.CubeResourceQuotaSpec{
+				ParentQuota: parent,
+				Target:      quotav1.TargetObj{Kind: kind, Name: target},
+				Hard:        v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse(hard)},
+			},
+			Status: quotav1.CubeResourceQuotaStatus{
+				Hard: v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse(hard)},
+				Used: v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse(used)},
+			},
+		}
+	}
+	cli := fake.NewFakeClients(&fake.Options{Scheme: scheme, Objs: []client.Object{
+		newUser("alice", false), newUser("bob", false), newUser("carol", false), newUser("admin", true),
+		&tenantv1.Project{ObjectMeta: metav1.ObjectMeta{Name: "project-1", Labels: map[string]string{constants.TenantLabel: "tenant-1"}}},
+		newBinding("bob", constants.ProjectAdmin, constants.ProjectNsPrefix+"project-1"),
+		newBinding("carol", constants.TenantAdmin, constants.TenantNsPrefix+"tenant-1"),
+		newQuota("pivot-cluster.tenant-1", "", quotav1.TenantObj, "tenant-1", "10", "6"),
+		newQuota("pivot-cluster.tenant-1.project-1", "pivot-cluster.tenant-1", quotav1.ProjectObj, "project-1", "4", "1"),
+	}})
+
+	h := &handler{Interface: &rbac.DefaultResolver{Cache: cli.Cache()}, Client: cli, now: time.Now}
+	requester := new(string)
+	router := gin.New()
+	// stands in for auth middleware
+	router.Use(func(c *gin.Context) {
+		c.Set(constants.UserName, *requester)
+	})
+	h.AddApisTo(router)
+	return router, h, requester
+}
+
+func perform(router *gin.Engine, method, path string, body interface{}) *httptest.ResponseRecorder {
+	data, _ := json.Marshal(body)
+	req, _ := http.NewRequest(method, constants.ApiPathRoot+subPath+path, bytes.NewReader(data))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestApproveQuotaRequest(t *testing.T) {
+	router, h, requester := newTestHandler(t)
+	param := func(cpu string) CreateParam {
+		return CreateParam{
+			Quota:  "pivot-cluster.tenant-1.project-1",
+			Hard:   v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse(cpu)},
+			Reason: "release of project-1",
+		}
+	}
+
+	*requester = "alice"
+	if w := perform(router, http.MethodPost, "", param("6")); w.Code != http.StatusForbidden {
+		t.Fatalf("only admins can file quota request, got %v", w.Code)
+	}
+
+	*requester = "bob"
+	// tenant has 4 cpu free only
+	if w := perform(router, http.MethodPost, "", param("9")); w.Code != http.StatusConflict {
+		t.Fatalf("request exceeds free capacity of tenant should be rejected, got %v: %v", w.Code, w.Body.String())
+	}
+	w := perform(router, http.MethodPost, "", param("6"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("create quota request failed: %v", w.Body.String())
+	}
+	r := &quotav1.QuotaRequest{}
+	_ = json.Unmarshal(w.Body.Bytes(), r)
+	if len(r.Status.Approvers) != 1 || r.Status.Approvers[0] != "carol" {
+		t.Fatalf("tenant admin should be approver, got %v", r.Status.Approvers)
+	}
+	if w = perform(router, http.MethodPost, "", param("5")); w.Code != http.StatusConflict {
+		t.Fatalf("pending request of same quota should be rejected, got %v", w.Code)
+	}
+
+	if w = perform(router, http.MethodPost, "/"+r.Name+"/approve", nil); w.Code != http.StatusForbidden {
+		t.Fatalf("requester should not approve own request, got %v", w.Code)
+	}
+
+	*requester = "carol"
+	if w = perform(router, http.MethodPost, "/"+r.Name+"/approve", CommentParam{Comment: "ok"}); w.Code != http.StatusOK {
+		t.Fatalf("approve quota request failed: %v", w.Body.String())
+	}
+	if w = perform(router, http.MethodPost, "/"+r.Name+"/reject", nil); w.Code != http.StatusConflict {
+		t.Fatalf("decided request should not be decided again, got %v", w.Code)
+	}
+
+	q := &quotav1.CubeResourceQuota{}
+	if err := h.Direct().Get(context.Background(), types.NamespacedName{Name: "pivot-cluster.tenant-1.project-1"}, q); err != nil {
+		t.Fatal(err)
+	}
+	if cpu := q.Spec.Hard[v1.ResourceRequestsCPU]; cpu.Cmp(resource.MustParse("6")) != 0 {
+		t.Fatalf("hard should be changed to 6, got %v", cpu.String())
+	}
+	if len(q.Status.History) != 1 {
+		t.Fatalf("change should be recorded in history, got %+v", q.Status.History)
+	}
+	record := q.Status.History[0]
+	from := record.From[v1.ResourceRequestsCPU]
+	if record.Request != r.Name || record.Requester != "bob" || record.Approver != "carol" || from.Cmp(resource.MustParse("4")) != 0 {
+		t.Errorf("unexpected history record %+v", record)
+	}
+}
+
+func TestApproveRollbackWhenDenied(t *testing.T) {
+	router, h, requester := newTestHandler(t)
+
+	*requester = "bob"
+	w := perform(router, http.MethodPost, "", CreateParam{
+		Quota:  "pivot-cluster.tenant-1.project-1",
+		Hard:   v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("6")},
+		Reason: "release of project-1",
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("create quota request failed: %v", w.Body.String())
+	}
+	r := &quotav1.QuotaRequest{}
+	_ = json.Unmarshal(w.Body.Bytes(), r)
+
+	// free capacity of tenant is taken after the request filed
+	ctx := context.Background()
+	tq := &quotav1.CubeResourceQuota{}
+	if err := h.Direct().Get(ctx, types.NamespacedName{Name: "pivot-cluster.tenant-1"}, tq); err != nil {
+		t.Fatal(err)
+	}
+	tq.Status.Used = v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("9")}
+	if err := h.Direct().Status().Update(ctx, tq); err != nil {
+		t.Fatal(err)
+	}
+
+	*requester = "carol"
+	if w = perform(router, http.MethodPost, "/"+r.Name+"/approve", CommentParam{Comment: "ok"}); w.Code == http.StatusOK {
+		t.Fatalf("approval should fail when change is denied")
+	}
+	if err := h.Direct().Get(ctx, types.NamespacedName{Name: r.Name}, r); err != nil {
+		t.Fatal(err)
+	}
+	if r.GetPhase() != quotav1.QuotaRequestPending || len(r.Status.DecidedBy) > 0 || len(r.Status.Comment) > 0 {
+		t.Fatalf("request should be back to pending, got %+v", r.Status)
+	}
+}
+
+func TestCancelQuotaRequest(t *testing.T) {
+	router, _, requester := newTestHandler(t)
+
+	*requester = "bob"
+	w := perform(router, http.MethodPost, "", CreateParam{
+		Quota:  "pivot-cluster.tenant-1.project-1",
+		Hard:   v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("2")},
+		Reason: "project-1 shrinks",
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("create quota request failed: %v", w.Body.String())
+	}
+	r := &quotav1.QuotaRequest{}
+	_ = json.Unmarshal(w.Body.Bytes(), r)
+
+	*requester = "carol"
+	if w = perform(router, http.MethodPost, "/"+r.Name+"/cancel", nil); w.Code != http.StatusForbidden {
+		t.Fatalf("only requester can cancel request, got %v", w.Code)
+	}
+	*requester = "bob"
+	if w = perform(router, http.MethodPost, "/"+r.Name+"/cancel", nil); w.Code != http.StatusOK {
+		t.Fatalf("cancel quota request failed: %v", w.Body.String())
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), r)
+	if r.Status.Phase != quotav1.QuotaRequestCancelled || r.Status.DecidedBy != "bob" {
+		t.Errorf("unexpected status %+v", r.Status)
+	}
+}
@@ -32,6 +32,7 @@ import (
 	"github.com/kubecube-io/kubecube/pkg/apiserver/middlewares/audit"
 	"github.com/kubecube-io/kubecube/pkg/clients"
 	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/utils/access"
 	"github.com/kubecube-io/kubecube/pkg/utils/constants"
 	"github.com/kubecube-io/kubecube/pkg/utils/env"
 	"github.com/kubecube-io/kubecube/pkg/utils/errcode"
@@ -83,6 +84,17 @@ func (e *ExtendHandler) ExtendHandle(c *gin.Context) {
 	// get user info
 	username := c.GetString(constants.UserName)
 
+	// tokens issued by scoped key only allowed to access resources within scope
+	allowed, err := access.AllowScope(c.Request.Context(), access.ScopeFromContext(c), client.Cache(), cluster, namespace, httpMethod)
+	if err != nil {
+		response.FailReturn(c, errcode.BadRequest(err))
+		return
+	}
+	if !allowed {
+		response.FailReturn(c, errcode.ForbiddenErr)
+		return
+	}
+
 	extendCtx := ExtendContext{
 		Cluster:                  cluster,
 		Namespace:                namespace,
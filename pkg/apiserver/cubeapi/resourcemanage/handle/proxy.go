@@ -32,10 +32,13 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 
+	"github.com/kubecube-io/kubecube/pkg/authorizer/policy"
+	"github.com/kubecube-io/kubecube/pkg/authorizer/rbac"
 	"github.com/kubecube-io/kubecube/pkg/belongs"
 	"github.com/kubecube-io/kubecube/pkg/clog"
 	"github.com/kubecube-io/kubecube/pkg/conversion"
 	"github.com/kubecube-io/kubecube/pkg/multicluster"
+	"github.com/kubecube-io/kubecube/pkg/utils/access"
 	"github.com/kubecube-io/kubecube/pkg/utils/constants"
 	"github.com/kubecube-io/kubecube/pkg/utils/errcode"
 	"github.com/kubecube-io/kubecube/pkg/utils/filter"
@@ -154,6 +157,15 @@ func (h *ProxyHandler) ProxyHandle(c *gin.Context) {
 		response.FailReturn(c, errcode.BadRequest(err))
 		return
 	}
+	// impersonate groups of user so that bindings with Group subject take effect
+	c.Request.Header.Del(constants.ImpersonateGroupKey)
+	groups, err := rbac.GroupsOf(c.Request.Context(), internalCluster.Client.Cache(), username)
+	if err != nil {
+		clog.Warn("get groups of user %v failed: %v", username, err)
+	}
+	for _, g := range groups {
+		c.Request.Header.Add(constants.ImpersonateGroupKey, g)
+	}
 	transport, err := multicluster.Interface().GetTransport(cluster)
 	if err != nil {
 		response.FailReturn(c, errcode.BadRequest(err))
@@ -165,6 +177,23 @@ func (h *ProxyHandler) ProxyHandle(c *gin.Context) {
 		return
 	}
 
+	// access policies and decision service are consulted before body converted
+	attrs, err := policy.NewAttributes(c.Request, proxyUrl, cluster, username, groups)
+	if err != nil {
+		response.FailReturn(c, errcode.BadRequest(err))
+		return
+	}
+	decision, err := policy.NewEvaluator(internalCluster.Client.Cache()).Evaluate(c.Request.Context(), attrs)
+	if err != nil {
+		clog.Warn("evaluate policy of user %v failed: %v", username, err)
+		response.FailReturn(c, errcode.PolicyEvaluateError)
+		return
+	}
+	if !decision.Allowed {
+		response.FailReturn(c, errcode.DeniedByPolicy(decision.Reason))
+		return
+	}
+
 	needConvert, convertedObj, convertedUrl, err := h.tryVersionConvert(cluster, proxyUrl, c.Request)
 	if err != nil {
 		response.FailReturn(c, errcode.BadRequest(err))
@@ -179,6 +208,27 @@ func (h *ProxyHandler) ProxyHandle(c *gin.Context) {
 		return
 	}
 
+	// objects of list and watch responses not belong to user are dropped
+	objectFilter, err := belongs.NewObjectFilter(c.Request.Context(), internalCluster.Client, proxyUrl, username)
+	if err != nil {
+		response.FailReturn(c, errcode.BadRequest(err))
+		return
+	}
+	if objectFilter != nil {
+		belongs.AcceptJSON(c.Request.Header)
+	}
+
+	// tokens issued by scoped key only allowed to access resources within scope
+	allowed, err = access.AllowScope(c.Request.Context(), access.ScopeFromContext(c), internalCluster.Client.Cache(), cluster, access.NamespaceOfURL(proxyUrl), c.Request.Method)
+	if err != nil {
+		response.FailReturn(c, errcode.BadRequest(err))
+		return
+	}
+	if !allowed {
+		response.FailReturn(c, errcode.ForbiddenErr)
+		return
+	}
+
 	// create director
 	director := directerFunc(c, internalCluster, proxyUrl, username, convertedUrl, needConvert, convertedObj)
 
@@ -209,6 +259,7 @@ func (h *ProxyHandler) ProxyHandle(c *gin.Context) {
 	filter := ResponseFilter{
 		Condition:        condition,
 		ConverterContext: &converterContext,
+		ObjectFilter:     objectFilter,
 	}
 	needModifyResponse := needModifyResponse(proxyUrl, c)
 	// trim auth token here
@@ -216,6 +267,8 @@ func (h *ProxyHandler) ProxyHandle(c *gin.Context) {
 	requestProxy := &httputil.ReverseProxy{Director: director, Transport: transport, ModifyResponse: nil, ErrorHandler: errorHandler}
 	if needModifyResponse {
 		requestProxy.ModifyResponse = filter.filterResponse
+	} else if objectFilter != nil {
+		requestProxy.ModifyResponse = objectFilter.ModifyResponse
 	}
 	requestProxy.ServeHTTP(c.Writer, c.Request)
 }
@@ -19,14 +19,23 @@ package resourcemanage
 import (
 	"net/http"
 
+	"github.com/kubecube-io/kubecube/pkg/belongs"
 	"github.com/kubecube-io/kubecube/pkg/utils/filter"
 )
 
 type ResponseFilter struct {
 	Condition        *filter.Condition
 	ConverterContext *filter.ConverterContext
+	// ObjectFilter drops objects not belong to user before filter
+	// condition applied, nil if not needed
+	ObjectFilter *belongs.ObjectFilter
 }
 
 func (f *ResponseFilter) filterResponse(r *http.Response) error {
+	if f.ObjectFilter != nil {
+		if err := f.ObjectFilter.ModifyResponse(r); err != nil {
+			return err
+		}
+	}
 	return filter.NewFilter(f.ConverterContext).ModifyResponse(r, f.Condition)
 }
@@ -0,0 +1,296 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// attributes returns values of attribute by normalized path like "emails.value"
+type attributes func(path string) []string
+
+// filter is the parsed filter expression of RFC 7644 3.4.2.2, complex
+// attribute filter like emails[type eq "work"] is not supported.
+type filter interface {
+	match(attrs attributes) bool
+}
+
+type andFilter struct{ left, right filter }
+
+type orFilter struct{ left, right filter }
+
+type notFilter struct{ f filter }
+
+type compareFilter struct {
+	path  string
+	op    string
+	value string
+}
+
+func (f andFilter) match(attrs attributes) bool {
+	return f.left.match(attrs) && f.right.match(attrs)
+}
+
+func (f orFilter) match(attrs attributes) bool {
+	return f.left.match(attrs) || f.right.match(attrs)
+}
+
+func (f notFilter) match(attrs attributes) bool {
+	return !f.f.match(attrs)
+}
+
+// match compares case-insensitively, multi-valued attribute matches if
+// any of values matches.
+func (f compareFilter) match(attrs attributes) bool {
+	values := attrs(f.path)
+	if f.op == "pr" {
+		for _, v := range values {
+			if v != "" {
+				return true
+			}
+		}
+		return false
+	}
+	if f.op == "ne" {
+		for _, v := range values {
+			if strings.ToLower(v) == f.value {
+				return false
+			}
+		}
+		return true
+	}
+	for _, v := range values {
+		v = strings.ToLower(v)
+		var ok bool
+		switch f.op {
+		case "eq":
+			ok = v == f.value
+		case "co":
+			ok = strings.Contains(v, f.value)
+		case "sw":
+			ok = strings.HasPrefix(v, f.value)
+		case "ew":
+			ok = strings.HasSuffix(v, f.value)
+		case "gt":
+			ok = v > f.value
+		case "ge":
+			ok = v >= f.value
+		case "lt":
+			ok = v < f.value
+		case "le":
+			ok = v <= f.value
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+var compareOps = sets.New[string]("eq", "ne", "co", "sw", "ew", "gt", "ge", "lt", "le")
+
+type filterParser struct {
+	tokens []string
+	pos    int
+	schema string
+	known  sets.Set[string]
+}
+
+// parseFilter parses filter expression, attributes not in known are rejected
+func parseFilter(expr string, schema string, known sets.Set[string]) (filter, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens, schema: strings.ToLower(schema) + ":", known: known}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in filter", p.tokens[p.pos])
+	}
+	return f, nil
+}
+
+func (p *filterParser) next() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	return t
+}
+
+func (p *filterParser) peekKeyword(keyword string) bool {
+	return p.pos < len(p.tokens) && strings.EqualFold(p.tokens[p.pos], keyword)
+}
+
+func (p *filterParser) parseOr() (filter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("or") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orFilter{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filter, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("and") {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andFilter{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseUnary() (filter, error) {
+	if p.peekKeyword("not") {
+		p.pos++
+		if p.next() != "(" {
+			return nil, fmt.Errorf("expect ( after not")
+		}
+		f, err := p.parseGroup()
+		if err != nil {
+			return nil, err
+		}
+		return notFilter{f: f}, nil
+	}
+	if p.pos < len(p.tokens) && p.tokens[p.pos] == "(" {
+		p.pos++
+		return p.parseGroup()
+	}
+	return p.parseCompare()
+}
+
+func (p *filterParser) parseGroup() (filter, error) {
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.next() != ")" {
+		return nil, fmt.Errorf("missing ) in filter")
+	}
+	return f, nil
+}
+
+func (p *filterParser) parseCompare() (filter, error) {
+	path, err := p.normalizePath(p.next())
+	if err != nil {
+		return nil, err
+	}
+	op := strings.ToLower(p.next())
+	if op == "pr" {
+		return compareFilter{path: path, op: op}, nil
+	}
+	if !compareOps.Has(op) {
+		return nil, fmt.Errorf("unknown operator %q in filter", op)
+	}
+	value, err := parseValue(p.next())
+	if err != nil {
+		return nil, err
+	}
+	return compareFilter{path: path, op: op, value: strings.ToLower(value)}, nil
+}
+
+// normalizePath lowercases path, trims schema urn and completes sub
+// attribute "value" of multi-valued attribute.
+func (p *filterParser) normalizePath(path string) (string, error) {
+	if path == "" || path == "(" || path == ")" {
+		return "", fmt.Errorf("attribute path is required in filter")
+	}
+	normalized := strings.TrimPrefix(strings.ToLower(path), p.schema)
+	if p.known.Has(normalized + ".value") {
+		normalized += ".value"
+	}
+	if !p.known.Has(normalized) {
+		return "", fmt.Errorf("attribute %q is not supported in filter", path)
+	}
+	return normalized, nil
+}
+
+func parseValue(token string) (string, error) {
+	switch {
+	case token == "":
+		return "", fmt.Errorf("value is required in filter")
+	case strings.HasPrefix(token, `"`):
+		var s string
+		if err := json.Unmarshal([]byte(token), &s); err != nil {
+			return "", fmt.Errorf("invalid string %s in filter", token)
+		}
+		return s, nil
+	case token == "(" || token == ")":
+		return "", fmt.Errorf("unexpected %q in filter", token)
+	}
+	// true, false, null and numbers are compared by literal
+	return token, nil
+}
+
+func tokenize(expr string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(expr); {
+		switch c := expr[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '"':
+			j := i + 1
+			for ; j < len(expr) && expr[j] != '"'; j++ {
+				if expr[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(expr) {
+				return nil, fmt.Errorf("unterminated string in filter")
+			}
+			tokens = append(tokens, expr[i:j+1])
+			i = j + 1
+		case c == '[' || c == ']':
+			return nil, fmt.Errorf("complex attribute filter is not supported")
+		default:
+			j := i
+			for ; j < len(expr) && !strings.ContainsRune(" \t()\"[]", rune(expr[j])); j++ {
+			}
+			tokens = append(tokens, expr[i:j])
+			i = j
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty filter")
+	}
+	return tokens, nil
+}
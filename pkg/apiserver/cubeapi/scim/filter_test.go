@@ -0,0 +1,78 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scim
+
+import (
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	active := true
+	u := &User{
+		ID:          "alice",
+		UserName:    "alice",
+		DisplayName: "Alice Liddell",
+		Active:      &active,
+		Emails:      []MultiValued{{Value: "alice@example.com"}, {Value: "al@example.org"}},
+	}
+
+	tests := []struct {
+		filter string
+		match  bool
+	}{
+		{`userName eq "alice"`, true},
+		{`USERNAME Eq "ALICE"`, true},
+		{`urn:ietf:params:scim:schemas:core:2.0:User:userName eq "alice"`, true},
+		{`userName ne "alice"`, false},
+		{`displayName co "liddell"`, true},
+		{`displayName sw "bob"`, false},
+		{`emails ew "example.org"`, true},
+		{`emails.value eq "bob@example.com"`, false},
+		{`phoneNumbers pr`, false},
+		{`active eq true and userName sw "a"`, true},
+		{`userName eq "bob" or displayName sw "alice"`, true},
+		{`userName eq "bob" or userName eq "carol" and active eq true`, false},
+		{`not (userName eq "alice")`, false},
+		{`(userName eq "bob" or userName eq "alice") and active eq false`, false},
+		{`userName eq "a\"b"`, false},
+	}
+	for _, tt := range tests {
+		f, err := parseFilter(tt.filter, UserSchema, userFilterAttrs)
+		if err != nil {
+			t.Fatalf("parse filter %v failed: %v", tt.filter, err)
+		}
+		if got := f.match(userAttributes(u)); got != tt.match {
+			t.Errorf("filter %v: expect %v, got %v", tt.filter, tt.match, got)
+		}
+	}
+
+	for _, invalid := range []string{
+		``,
+		`userName`,
+		`userName eq`,
+		`title eq "boss"`,
+		`userName xx "alice"`,
+		`(userName eq "alice"`,
+		`userName eq "alice`,
+		`emails[type eq "work"]`,
+		`userName eq "alice" and`,
+	} {
+		if _, err := parseFilter(invalid, UserSchema, userFilterAttrs); err == nil {
+			t.Errorf("filter %q should be rejected", invalid)
+		}
+	}
+}
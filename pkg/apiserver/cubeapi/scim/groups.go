@@ -0,0 +1,362 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scim
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+const (
+	resourceTypeGroup = "Groups"
+
+	groupNamePrefix = "scim-"
+)
+
+var groupFilterAttrs = sets.New[string]("id", "displayname", "externalid", "members.value")
+
+// listGroups lists groups of scim source only, groups of other sources
+// are not visible to scim client.
+func (h *handler) listGroups(c *gin.Context) {
+	start, count, e := page(c)
+	if e != nil {
+		abort(c, e)
+		return
+	}
+	var f filter
+	if expr := c.Query("filter"); expr != "" {
+		var err error
+		if f, err = parseFilter(expr, GroupSchema, groupFilterAttrs); err != nil {
+			abort(c, badRequest(invalidFilter, err.Error()))
+			return
+		}
+	}
+
+	groups, e := h.listScimGroups(c)
+	if e != nil {
+		abort(c, e)
+		return
+	}
+	var resources []interface{}
+	for i := range groups {
+		g := toScimGroup(c, &groups[i])
+		if f != nil && !f.match(groupAttributes(g)) {
+			continue
+		}
+		resources = append(resources, g)
+	}
+	writeJSON(c, http.StatusOK, listResponse(resources, start, count))
+}
+
+func (h *handler) getGroup(c *gin.Context) {
+	group, e := h.getCubeGroup(c)
+	if e != nil {
+		abort(c, e)
+		return
+	}
+	g := toScimGroup(c, group)
+	writeResource(c, http.StatusOK, g, g.Meta)
+}
+
+// createGroup creates group with generated name, displayName must be unique
+func (h *handler) createGroup(c *gin.Context) {
+	s := &Group{}
+	if e := bindJSON(c, s); e != nil {
+		abort(c, e)
+		return
+	}
+	if e := h.checkScimGroup(c, "", s); e != nil {
+		abort(c, e)
+		return
+	}
+
+	group := &userv1.Group{}
+	group.Name = groupNamePrefix + uuid.New().String()
+	// groups are synced to member clusters for bindings of group
+	group.Annotations = map[string]string{constants.SyncAnnotation: constants.TrueStr}
+	group.Spec.Source = userv1.ScimGroup
+	applyScimGroup(group, s)
+
+	if err := h.Direct().Create(c.Request.Context(), group); err != nil {
+		if errors.IsAlreadyExists(err) {
+			abort(c, errConflict)
+			return
+		}
+		clog.Error("create group %v by scim failed: %v", group.Name, err)
+		abort(c, errInternal)
+		return
+	}
+	clog.Info("group %v created by scim", group.Name)
+
+	g := toScimGroup(c, group)
+	c.Header("Location", g.Meta.Location)
+	writeResource(c, http.StatusCreated, g, g.Meta)
+}
+
+func (h *handler) replaceGroup(c *gin.Context) {
+	s := &Group{}
+	if e := bindJSON(c, s); e != nil {
+		abort(c, e)
+		return
+	}
+	group, e := h.getCubeGroup(c)
+	if e != nil {
+		abort(c, e)
+		return
+	}
+	if e = checkVersion(c, version(group.ResourceVersion)); e != nil {
+		abort(c, e)
+		return
+	}
+	h.updateGroup(c, group, s)
+}
+
+func (h *handler) patchGroup(c *gin.Context) {
+	req := &PatchRequest{}
+	if e := bindJSON(c, req); e != nil {
+		abort(c, e)
+		return
+	}
+	if e := checkPatchRequest(req); e != nil {
+		abort(c, e)
+		return
+	}
+	group, e := h.getCubeGroup(c)
+	if e != nil {
+		abort(c, e)
+		return
+	}
+	if e = checkVersion(c, version(group.ResourceVersion)); e != nil {
+		abort(c, e)
+		return
+	}
+
+	s := toScimGroup(c, group)
+	for _, op := range req.Operations {
+		if e = patchScimGroup(s, op); e != nil {
+			abort(c, e)
+			return
+		}
+	}
+	h.updateGroup(c, group, s)
+}
+
+func (h *handler) updateGroup(c *gin.Context, group *userv1.Group, s *Group) {
+	if e := h.checkScimGroup(c, group.Name, s); e != nil {
+		abort(c, e)
+		return
+	}
+	applyScimGroup(group, s)
+
+	if err := h.Direct().Update(c.Request.Context(), group); err != nil {
+		if errors.IsConflict(err) {
+			abort(c, errPrecondition)
+			return
+		}
+		clog.Error("update group %v by scim failed: %v", group.Name, err)
+		abort(c, errInternal)
+		return
+	}
+
+	g := toScimGroup(c, group)
+	writeResource(c, http.StatusOK, g, g.Meta)
+}
+
+func (h *handler) deleteGroup(c *gin.Context) {
+	group, e := h.getCubeGroup(c)
+	if e != nil {
+		abort(c, e)
+		return
+	}
+	if e = checkVersion(c, version(group.ResourceVersion)); e != nil {
+		abort(c, e)
+		return
+	}
+	if err := h.Direct().Delete(c.Request.Context(), group); err != nil && !errors.IsNotFound(err) {
+		clog.Error("delete group %v by scim failed: %v", group.Name, err)
+		abort(c, errInternal)
+		return
+	}
+	clog.Info("group %v deleted by scim", group.Name)
+	c.Status(http.StatusNoContent)
+}
+
+func (h *handler) listScimGroups(c *gin.Context) ([]userv1.Group, *Error) {
+	groups := &userv1.GroupList{}
+	if err := h.Direct().List(c.Request.Context(), groups); err != nil {
+		clog.Error("list groups failed: %v", err)
+		return nil, errInternal
+	}
+	var items []userv1.Group
+	for _, g := range groups.Items {
+		if g.GetSource() == userv1.ScimGroup {
+			items = append(items, g)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+	return items, nil
+}
+
+// getCubeGroup returns group of path param, groups not of scim source are treated as not found
+func (h *handler) getCubeGroup(c *gin.Context) (*userv1.Group, *Error) {
+	group := &userv1.Group{}
+	err := h.Direct().Get(c.Request.Context(), types.NamespacedName{Name: c.Param("id")}, group)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, errNotFound
+		}
+		clog.Error("get group %v failed: %v", c.Param("id"), err)
+		return nil, errInternal
+	}
+	if group.GetSource() != userv1.ScimGroup {
+		return nil, errNotFound
+	}
+	return group, nil
+}
+
+// checkScimGroup checks displayName is unique and members are existing users
+func (h *handler) checkScimGroup(c *gin.Context, name string, s *Group) *Error {
+	if s.DisplayName == "" {
+		return badRequest(invalidValue, "displayName is required")
+	}
+	groups, e := h.listScimGroups(c)
+	if e != nil {
+		return e
+	}
+	for _, g := range groups {
+		if g.Name != name && strings.EqualFold(g.Spec.DisplayName, s.DisplayName) {
+			return errConflict
+		}
+	}
+
+	ctx := c.Request.Context()
+	for _, m := range s.Members {
+		user := &userv1.User{}
+		err := h.Direct().Get(ctx, types.NamespacedName{Name: m.Value}, user)
+		if errors.IsNotFound(err) || (err == nil && user.IsRobot()) {
+			return badRequest(invalidValue, fmt.Sprintf("member %q is not a user", m.Value))
+		}
+		if err != nil {
+			clog.Error("get user %v failed: %v", m.Value, err)
+			return errInternal
+		}
+	}
+	return nil
+}
+
+func toScimGroup(c *gin.Context, group *userv1.Group) *Group {
+	g := &Group{
+		Schemas:     []string{GroupSchema},
+		ID:          group.Name,
+		ExternalID:  group.Annotations[constants.ScimExternalIDAnnotation],
+		DisplayName: group.Spec.DisplayName,
+		Meta: &Meta{
+			ResourceType: "Group",
+			Created:      group.CreationTimestamp.UTC().Format(time.RFC3339),
+			Location:     location(c, resourceTypeGroup, group.Name),
+			Version:      version(group.ResourceVersion),
+		},
+	}
+	for _, m := range group.Spec.Members {
+		g.Members = append(g.Members, MultiValued{Value: m, Ref: location(c, resourceTypeUser, m)})
+	}
+	return g
+}
+
+func applyScimGroup(group *userv1.Group, s *Group) {
+	group.Spec.DisplayName = s.DisplayName
+	members := sets.New[string]()
+	for _, m := range s.Members {
+		members.Insert(m.Value)
+	}
+	group.Spec.Members = sets.List(members)
+	if s.ExternalID != "" {
+		if group.Annotations == nil {
+			group.Annotations = map[string]string{}
+		}
+		group.Annotations[constants.ScimExternalIDAnnotation] = s.ExternalID
+	} else {
+		delete(group.Annotations, constants.ScimExternalIDAnnotation)
+	}
+}
+
+func patchScimGroup(g *Group, op PatchOperation) *Error {
+	ops, e := splitValue(op)
+	if e != nil {
+		return e
+	}
+	for _, o := range ops {
+		pp, e := parsePatchPath(o.Path, GroupSchema)
+		if e != nil {
+			return e
+		}
+		switch pp.attr {
+		case "displayname":
+			if o.Op == opRemove {
+				return badRequest(mutability, "displayName can not be removed")
+			}
+			if g.DisplayName, e = unmarshalString(o.Value); e != nil {
+				return e
+			}
+		case "externalid":
+			g.ExternalID = ""
+			if o.Op != opRemove {
+				if g.ExternalID, e = unmarshalString(o.Value); e != nil {
+					return e
+				}
+			}
+		case "members":
+			if g.Members, e = patchMultiValued(g.Members, o.Op, pp, o.Value); e != nil {
+				return e
+			}
+		case "id", "meta":
+			return badRequest(mutability, fmt.Sprintf("%v is read only", pp.attr))
+		default:
+			return badRequest(invalidPath, fmt.Sprintf("unknown attribute %q", o.Path))
+		}
+	}
+	return nil
+}
+
+func groupAttributes(g *Group) attributes {
+	return func(path string) []string {
+		switch path {
+		case "id":
+			return []string{g.ID}
+		case "displayname":
+			return []string{g.DisplayName}
+		case "externalid":
+			return []string{g.ExternalID}
+		case "members.value":
+			return values(g.Members)
+		}
+		return nil
+	}
+}
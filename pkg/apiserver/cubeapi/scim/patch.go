@@ -0,0 +1,248 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	opAdd     = "add"
+	opReplace = "replace"
+	opRemove  = "remove"
+)
+
+// patchPath is the parsed path of patch operation like
+// members[value eq "alice"] or emails[type eq "work"].value
+type patchPath struct {
+	attr   string
+	filter filter
+	sub    string
+}
+
+// multiValuedAttrs are sub attributes can be used in filter of patch path
+var multiValuedAttrs = sets.New[string]("value", "display", "type", "primary")
+
+func parsePatchPath(path string, schema string) (*patchPath, *Error) {
+	p := strings.TrimSpace(path)
+	if len(p) > len(schema) && strings.EqualFold(p[:len(schema)+1], schema+":") {
+		p = p[len(schema)+1:]
+	}
+
+	pp := &patchPath{}
+	if i := strings.Index(p, "["); i >= 0 {
+		j := strings.LastIndex(p, "]")
+		if j < i {
+			return nil, badRequest(invalidPath, fmt.Sprintf("invalid path %q", path))
+		}
+		f, err := parseFilter(p[i+1:j], "", multiValuedAttrs)
+		if err != nil {
+			return nil, badRequest(invalidPath, err.Error())
+		}
+		pp.attr = strings.ToLower(p[:i])
+		pp.filter = f
+		pp.sub = strings.ToLower(strings.TrimPrefix(p[j+1:], "."))
+		return pp, nil
+	}
+	attr, sub, _ := strings.Cut(strings.ToLower(p), ".")
+	pp.attr, pp.sub = attr, sub
+	return pp, nil
+}
+
+// matchValue tells if item of multi-valued attribute matches filter of path
+func (pp *patchPath) matchValue(v MultiValued) bool {
+	if pp.filter == nil {
+		return true
+	}
+	return pp.filter.match(func(path string) []string {
+		switch path {
+		case "value":
+			return []string{v.Value}
+		case "display":
+			return []string{v.Display}
+		case "type":
+			return []string{v.Type}
+		case "primary":
+			return []string{fmt.Sprint(v.Primary)}
+		}
+		return nil
+	})
+}
+
+// normalizeOp lowercases op, some clients send "Replace" or "Add"
+func normalizeOp(op string) (string, *Error) {
+	switch o := strings.ToLower(op); o {
+	case opAdd, opReplace, opRemove:
+		return o, nil
+	}
+	return "", badRequest(invalidSyntax, fmt.Sprintf("unknown patch op %q", op))
+}
+
+// checkPatchRequest validates request and normalizes op of operations
+func checkPatchRequest(req *PatchRequest) *Error {
+	if len(req.Operations) == 0 {
+		return badRequest(invalidSyntax, "no patch operations")
+	}
+	for i := range req.Operations {
+		op, e := normalizeOp(req.Operations[i].Op)
+		if e != nil {
+			return e
+		}
+		req.Operations[i].Op = op
+		if op == opRemove && req.Operations[i].Path == "" {
+			return badRequest(noTarget, "path is required for remove operation")
+		}
+		if op != opRemove && len(req.Operations[i].Value) == 0 {
+			return badRequest(invalidValue, "value is required for add and replace operation")
+		}
+	}
+	return nil
+}
+
+// splitValue expands value of operation without path into operations on
+// each attribute of value.
+func splitValue(op PatchOperation) ([]PatchOperation, *Error) {
+	if op.Path != "" {
+		return []PatchOperation{op}, nil
+	}
+	values := map[string]json.RawMessage{}
+	if err := json.Unmarshal(op.Value, &values); err != nil {
+		return nil, badRequest(invalidValue, "value of operation without path must be an object")
+	}
+	ops := make([]PatchOperation, 0, len(values))
+	for path, value := range values {
+		ops = append(ops, PatchOperation{Op: op.Op, Path: path, Value: value})
+	}
+	return ops, nil
+}
+
+func jsonUnmarshal(raw json.RawMessage, obj interface{}) *Error {
+	if err := json.Unmarshal(raw, obj); err != nil {
+		return badRequest(invalidValue, fmt.Sprintf("invalid value %s", raw))
+	}
+	return nil
+}
+
+func unmarshalString(raw json.RawMessage) (string, *Error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return "", badRequest(invalidValue, fmt.Sprintf("expect string value but got %s", raw))
+	}
+	return s, nil
+}
+
+// unmarshalMultiValued accepts both single object and array of objects
+func unmarshalMultiValued(raw json.RawMessage) ([]MultiValued, *Error) {
+	var values []MultiValued
+	if err := json.Unmarshal(raw, &values); err == nil {
+		return values, nil
+	}
+	var v MultiValued
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, badRequest(invalidValue, fmt.Sprintf("invalid multi-valued attribute %s", raw))
+	}
+	return []MultiValued{v}, nil
+}
+
+// patchMultiValued applies operation to multi-valued attribute
+func patchMultiValued(values []MultiValued, op string, pp *patchPath, raw json.RawMessage) ([]MultiValued, *Error) {
+	// path targets sub attribute of filtered items, like emails[type eq "work"].value
+	if pp.sub != "" {
+		if pp.sub != "value" {
+			return values, nil
+		}
+		if op == opRemove {
+			return removeMultiValued(values, pp), nil
+		}
+		s, e := unmarshalString(raw)
+		if e != nil {
+			return nil, e
+		}
+		matched := false
+		for i := range values {
+			if pp.matchValue(values[i]) {
+				values[i].Value = s
+				matched = true
+			}
+		}
+		if !matched {
+			values = append(values, MultiValued{Value: s, Primary: len(values) == 0})
+		}
+		return values, nil
+	}
+
+	if op == opRemove {
+		if pp.filter == nil && len(raw) == 0 {
+			return nil, nil
+		}
+		if len(raw) > 0 {
+			removed, e := unmarshalMultiValued(raw)
+			if e != nil {
+				return nil, e
+			}
+			return removeValues(values, removed), nil
+		}
+		return removeMultiValued(values, pp), nil
+	}
+
+	added, e := unmarshalMultiValued(raw)
+	if e != nil {
+		return nil, e
+	}
+	if op == opReplace {
+		if pp.filter != nil {
+			values = removeMultiValued(values, pp)
+		} else {
+			values = nil
+		}
+	}
+	for _, v := range added {
+		values = append(removeValues(values, []MultiValued{v}), v)
+	}
+	return values, nil
+}
+
+func removeMultiValued(values []MultiValued, pp *patchPath) []MultiValued {
+	var kept []MultiValued
+	for _, v := range values {
+		if !pp.matchValue(v) {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+func removeValues(values []MultiValued, removed []MultiValued) []MultiValued {
+	var kept []MultiValued
+	for _, v := range values {
+		keep := true
+		for _, r := range removed {
+			if strings.EqualFold(v.Value, r.Value) {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
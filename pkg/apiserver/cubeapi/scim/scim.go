@@ -0,0 +1,263 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scim
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/kubecube-io/kubecube/pkg/clients"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	mgrclient "github.com/kubecube-io/kubecube/pkg/multicluster/client"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+	"github.com/kubecube-io/kubecube/pkg/utils/env"
+)
+
+const (
+	subPath = "/scim/v2"
+
+	// TokenSecret holds the bearer token of scim client with key TokenSecretKey,
+	// scim endpoint rejects all requests if it does not exist.
+	TokenSecret    = "kubecube-scim"
+	TokenSecretKey = "token"
+
+	contentType = "application/scim+json"
+
+	defaultCount = 100
+	maxCount     = 1000
+)
+
+type handler struct {
+	mgrclient.Client
+}
+
+func NewHandler() *handler {
+	h := new(handler)
+	h.Client = clients.Interface().Kubernetes(constants.LocalCluster)
+	return h
+}
+
+// AddApisTo registers scim 2.0 endpoint, which is authenticated by its own
+// bearer token rather than token of kubecube.
+func (h *handler) AddApisTo(root *gin.Engine) {
+	r := root.Group(constants.ApiPathRoot+subPath, h.authenticate)
+	r.GET("/ServiceProviderConfig", serviceProviderConfig)
+	r.GET("/ResourceTypes", resourceTypes)
+	r.GET("/Schemas", schemas)
+
+	r.GET("/Users", h.listUsers)
+	r.POST("/Users", h.createUser)
+	r.GET("/Users/:id", h.getUser)
+	r.PUT("/Users/:id", h.replaceUser)
+	r.PATCH("/Users/:id", h.patchUser)
+	r.DELETE("/Users/:id", h.deleteUser)
+
+	r.GET("/Groups", h.listGroups)
+	r.POST("/Groups", h.createGroup)
+	r.GET("/Groups/:id", h.getGroup)
+	r.PUT("/Groups/:id", h.replaceGroup)
+	r.PATCH("/Groups/:id", h.patchGroup)
+	r.DELETE("/Groups/:id", h.deleteGroup)
+}
+
+// authenticate compares bearer token with the one in secret in constant time
+func (h *handler) authenticate(c *gin.Context) {
+	token := strings.TrimSpace(strings.TrimPrefix(c.GetHeader(constants.AuthorizationHeader), "Bearer "))
+	if token == "" {
+		abort(c, errUnauthorized)
+		return
+	}
+
+	secret := &corev1.Secret{}
+	err := h.Direct().Get(c.Request.Context(), types.NamespacedName{Name: TokenSecret, Namespace: env.CubeNamespace()}, secret)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			clog.Error("get scim token secret failed: %v", err)
+		}
+		abort(c, errUnauthorized)
+		return
+	}
+	expected := secret.Data[TokenSecretKey]
+	if len(expected) == 0 || subtle.ConstantTimeCompare(expected, []byte(token)) != 1 {
+		clog.Warn("invalid scim token from %v", c.ClientIP())
+		abort(c, errUnauthorized)
+		return
+	}
+	c.Next()
+}
+
+func writeJSON(c *gin.Context, status int, obj interface{}) {
+	c.Header("Content-Type", contentType)
+	c.JSON(status, obj)
+}
+
+func writeResource(c *gin.Context, status int, obj interface{}, meta *Meta) {
+	if meta != nil && meta.Version != "" {
+		c.Header("ETag", meta.Version)
+	}
+	writeJSON(c, status, obj)
+}
+
+func abort(c *gin.Context, e *Error) {
+	c.Header("Content-Type", contentType)
+	status, _ := strconv.Atoi(e.Status)
+	c.AbortWithStatusJSON(status, e)
+}
+
+// bindJSON accepts both application/json and application/scim+json
+func bindJSON(c *gin.Context, obj interface{}) *Error {
+	if err := json.NewDecoder(c.Request.Body).Decode(obj); err != nil {
+		return badRequest(invalidSyntax, fmt.Sprintf("invalid request body: %v", err))
+	}
+	return nil
+}
+
+// checkVersion checks If-Match header against current version of resource
+func checkVersion(c *gin.Context, version string) *Error {
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" || ifMatch == "*" || ifMatch == version {
+		return nil
+	}
+	return errPrecondition
+}
+
+func version(resourceVersion string) string {
+	return fmt.Sprintf("W/%q", resourceVersion)
+}
+
+func location(c *gin.Context, resourceType string, id string) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return fmt.Sprintf("%s://%s%s%s/%s/%s", scheme, c.Request.Host, constants.ApiPathRoot, subPath, resourceType, id)
+}
+
+// page parses 1-based startIndex and count of list request
+func page(c *gin.Context) (int, int, *Error) {
+	start, count := 1, defaultCount
+	var err error
+	if s := c.Query("startIndex"); s != "" {
+		if start, err = strconv.Atoi(s); err != nil {
+			return 0, 0, badRequest(invalidValue, "invalid startIndex")
+		}
+		if start < 1 {
+			start = 1
+		}
+	}
+	if s := c.Query("count"); s != "" {
+		if count, err = strconv.Atoi(s); err != nil {
+			return 0, 0, badRequest(invalidValue, "invalid count")
+		}
+		if count < 0 {
+			count = 0
+		}
+		if count > maxCount {
+			count = maxCount
+		}
+	}
+	return start, count, nil
+}
+
+func listResponse(resources []interface{}, start int, count int) *ListResponse {
+	total := len(resources)
+	from := start - 1
+	if from > total {
+		from = total
+	}
+	to := from + count
+	if to > total {
+		to = total
+	}
+	return &ListResponse{
+		Schemas:      []string{ListResponseSchema},
+		TotalResults: total,
+		StartIndex:   start,
+		ItemsPerPage: to - from,
+		Resources:    append([]interface{}{}, resources[from:to]...),
+	}
+}
+
+func serviceProviderConfig(c *gin.Context) {
+	writeJSON(c, http.StatusOK, gin.H{
+		"schemas":        []string{ServiceProviderConfigSchema},
+		"patch":          gin.H{"supported": true},
+		"bulk":           gin.H{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         gin.H{"supported": true, "maxResults": maxCount},
+		"changePassword": gin.H{"supported": true},
+		"sort":           gin.H{"supported": false},
+		"etag":           gin.H{"supported": true},
+		"authenticationSchemes": []gin.H{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer Token",
+			"description": "Bearer token stored in secret " + TokenSecret,
+		}},
+	})
+}
+
+func resourceTypes(c *gin.Context) {
+	items := []interface{}{
+		gin.H{"schemas": []string{ResourceTypeSchema}, "id": "User", "name": "User", "endpoint": "/Users", "schema": UserSchema},
+		gin.H{"schemas": []string{ResourceTypeSchema}, "id": "Group", "name": "Group", "endpoint": "/Groups", "schema": GroupSchema},
+	}
+	writeJSON(c, http.StatusOK, listResponse(items, 1, len(items)))
+}
+
+func schemas(c *gin.Context) {
+	attr := func(name string, typ string, multi bool, required bool, mutability string) gin.H {
+		return gin.H{"name": name, "type": typ, "multiValued": multi, "required": required, "mutability": mutability}
+	}
+	items := []interface{}{
+		gin.H{
+			"schemas": []string{SchemaSchema},
+			"id":      UserSchema,
+			"name":    "User",
+			"attributes": []gin.H{
+				attr("userName", "string", false, true, "immutable"),
+				attr("name", "complex", false, false, "readWrite"),
+				attr("displayName", "string", false, false, "readWrite"),
+				attr("active", "boolean", false, false, "readWrite"),
+				attr("password", "string", false, false, "writeOnly"),
+				attr("emails", "complex", true, false, "readWrite"),
+				attr("phoneNumbers", "complex", true, false, "readWrite"),
+				attr("groups", "complex", true, false, "readOnly"),
+			},
+		},
+		gin.H{
+			"schemas": []string{SchemaSchema},
+			"id":      GroupSchema,
+			"name":    "Group",
+			"attributes": []gin.H{
+				attr("displayName", "string", false, true, "readWrite"),
+				attr("members", "complex", true, false, "readWrite"),
+			},
+		},
+	}
+	writeJSON(c, http.StatusOK, listResponse(items, 1, len(items)))
+}
@@ -0,0 +1,296 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubecube-io/kubecube/pkg/apis"
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/multicluster/client/fake"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+	"github.com/kubecube-io/kubecube/pkg/utils/env"
+)
+
+const testToken = "scim-token"
+
+func newTestRouter(t *testing.T) (*gin.Engine, *handler) {
+	gin.SetMode(gin.TestMode)
+	scheme := runtime.NewScheme()
+	apis.AddToScheme(scheme)
+	corev1.AddToScheme(scheme)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: TokenSecret, Namespace: env.CubeNamespace()},
+		Data:       map[string][]byte{TokenSecretKey: []byte(testToken)},
+	}
+	robot := &userv1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "robot-ci"},
+		Spec:       userv1.UserSpec{AccountType: userv1.RobotAccount},
+	}
+	ldapGroup := &userv1.Group{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev"},
+		Spec:       userv1.GroupSpec{Source: userv1.LdapGroup},
+	}
+	admin := &userv1.User{ObjectMeta: metav1.ObjectMeta{Name: constants.AdminUser}}
+	root := &userv1.User{ObjectMeta: metav1.ObjectMeta{Name: "root"}, Status: userv1.UserStatus{PlatformAdmin: true}}
+	h := &handler{Client: fake.NewFakeClients(&fake.Options{Scheme: scheme, Objs: []client.Object{secret, robot, ldapGroup, admin, root}})}
+	router := gin.New()
+	h.AddApisTo(router)
+	return router, h
+}
+
+func do(t *testing.T, router *gin.Engine, method string, path string, body string, out interface{}) int {
+	req := httptest.NewRequest(method, constants.ApiPathRoot+subPath+path, strings.NewReader(body))
+	req.Header.Set(constants.AuthorizationHeader, "Bearer "+testToken)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if out != nil && w.Body.Len() > 0 {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatalf("%v %v: unmarshal response %s failed: %v", method, path, w.Body.String(), err)
+		}
+	}
+	return w.Code
+}
+
+func TestAuthenticate(t *testing.T) {
+	router, _ := newTestRouter(t)
+	for _, token := range []string{"", "Bearer wrong"} {
+		req := httptest.NewRequest(http.MethodGet, constants.ApiPathRoot+subPath+"/Users", nil)
+		req.Header.Set(constants.AuthorizationHeader, token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("token %q should be rejected, got %v", token, w.Code)
+		}
+	}
+	if code := do(t, router, http.MethodGet, "/ServiceProviderConfig", "", nil); code != http.StatusOK {
+		t.Fatalf("expect 200, got %v", code)
+	}
+}
+
+func TestUsers(t *testing.T) {
+	router, h := newTestRouter(t)
+	ctx := context.Background()
+
+	u := &User{}
+	code := do(t, router, http.MethodPost, "/Users", `{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"userName": "alice",
+		"externalId": "00u1",
+		"name": {"formatted": "Alice Liddell"},
+		"emails": [{"value": "al@example.org"}, {"value": "alice@example.com", "primary": true}],
+		"active": true
+	}`, u)
+	if code != http.StatusCreated || u.ID != "alice" || u.DisplayName != "Alice Liddell" {
+		t.Fatalf("create user: %v %+v", code, u)
+	}
+	if code = do(t, router, http.MethodPost, "/Users", `{"userName": "alice"}`, nil); code != http.StatusConflict {
+		t.Fatalf("duplicated user should be conflict, got %v", code)
+	}
+	if code = do(t, router, http.MethodPost, "/Users", `{"userName": "Bob@example.com"}`, nil); code != http.StatusBadRequest {
+		t.Fatalf("invalid userName should be rejected, got %v", code)
+	}
+
+	user := &userv1.User{}
+	if err := h.Direct().Get(ctx, types.NamespacedName{Name: "alice"}, user); err != nil {
+		t.Fatal(err)
+	}
+	if user.Spec.Email != "alice@example.com" || user.Spec.Password == "" || user.Annotations[constants.ScimExternalIDAnnotation] != "00u1" {
+		t.Fatalf("unexpected user %+v", user)
+	}
+
+	list := &ListResponse{}
+	do(t, router, http.MethodGet, `/Users?filter=userName+eq+"alice"`, "", list)
+	if list.TotalResults != 1 {
+		t.Fatalf("expect 1 user, got %+v", list)
+	}
+	// robot accounts are invisible
+	do(t, router, http.MethodGet, "/Users?startIndex=1&count=10", "", list)
+	if list.TotalResults != 3 || list.ItemsPerPage != 3 {
+		t.Fatalf("expect only human users, got %+v", list)
+	}
+	if code = do(t, router, http.MethodGet, "/Users/robot-ci", "", nil); code != http.StatusNotFound {
+		t.Fatalf("robot should not be found, got %v", code)
+	}
+	if code = do(t, router, http.MethodGet, `/Users?filter=title+eq+"x"`, "", nil); code != http.StatusBadRequest {
+		t.Fatalf("unsupported filter should be rejected, got %v", code)
+	}
+
+	code = do(t, router, http.MethodPatch, "/Users/alice", `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [
+			{"op": "Replace", "path": "active", "value": "False"},
+			{"op": "replace", "path": "emails[type eq \"work\"].value", "value": "liddell@example.com"},
+			{"op": "add", "value": {"displayName": "Alice L", "title": "engineer"}}
+		]
+	}`, u)
+	if code != http.StatusOK || *u.Active || u.DisplayName != "Alice L" {
+		t.Fatalf("patch user: %v %+v", code, u)
+	}
+	if err := h.Direct().Get(ctx, types.NamespacedName{Name: "alice"}, user); err != nil {
+		t.Fatal(err)
+	}
+	if user.Spec.State != userv1.ForbiddenState || user.Spec.Email != "liddell@example.com" {
+		t.Fatalf("unexpected user after patch %+v", user.Spec)
+	}
+	if user.Annotations[constants.TokensRevokedAtAnnotation] == "" {
+		t.Fatalf("sessions of deactivated user should be revoked")
+	}
+
+	// state is kept when active is absent
+	code = do(t, router, http.MethodPut, "/Users/alice", `{"userName": "alice", "displayName": "Alice"}`, nil)
+	if code != http.StatusOK {
+		t.Fatalf("replace user: %v", code)
+	}
+	if err := h.Direct().Get(ctx, types.NamespacedName{Name: "alice"}, user); err != nil {
+		t.Fatal(err)
+	}
+	if user.Spec.State != userv1.ForbiddenState {
+		t.Fatalf("forbidden user should not be enabled without active, got %v", user.Spec.State)
+	}
+
+	// password must meet the policy
+	if code = do(t, router, http.MethodPost, "/Users", `{"userName": "carol", "password": "old"}`, nil); code != http.StatusBadRequest {
+		t.Fatalf("weak password should be rejected, got %v", code)
+	}
+
+	// sessions are revoked when password is changed
+	if code = do(t, router, http.MethodPost, "/Users", `{"userName": "carol", "password": "0ld-passw0rd"}`, nil); code != http.StatusCreated {
+		t.Fatalf("create user carol: %v", code)
+	}
+	code = do(t, router, http.MethodPatch, "/Users/carol", `{"Operations": [{"op": "replace", "path": "password", "value": "0ld-passw0rd"}]}`, nil)
+	if code != http.StatusOK {
+		t.Fatalf("patch password: %v", code)
+	}
+	carol := &userv1.User{}
+	if err := h.Direct().Get(ctx, types.NamespacedName{Name: "carol"}, carol); err != nil {
+		t.Fatal(err)
+	}
+	if carol.Annotations[constants.TokensRevokedAtAnnotation] != "" {
+		t.Fatalf("sessions should not be revoked if password is not changed")
+	}
+	code = do(t, router, http.MethodPatch, "/Users/carol", `{"Operations": [{"op": "replace", "path": "password", "value": "new"}]}`, nil)
+	if code != http.StatusBadRequest {
+		t.Fatalf("weak password should be rejected, got %v", code)
+	}
+	code = do(t, router, http.MethodPatch, "/Users/carol", `{"Operations": [{"op": "replace", "path": "password", "value": "n3w-passw0rd"}]}`, nil)
+	if code != http.StatusOK {
+		t.Fatalf("patch password: %v", code)
+	}
+	if err := h.Direct().Get(ctx, types.NamespacedName{Name: "carol"}, carol); err != nil {
+		t.Fatal(err)
+	}
+	if carol.Annotations[constants.TokensRevokedAtAnnotation] == "" {
+		t.Fatalf("sessions should be revoked if password is changed")
+	}
+
+	code = do(t, router, http.MethodPatch, "/Users/alice", `{"Operations": [{"op": "replace", "path": "userName", "value": "bob"}]}`, nil)
+	if code != http.StatusBadRequest {
+		t.Fatalf("userName should be immutable, got %v", code)
+	}
+
+	// platform admins can not be taken over by scim
+	for _, name := range []string{constants.AdminUser, "root"} {
+		code = do(t, router, http.MethodPatch, "/Users/"+name, `{"Operations": [{"op": "replace", "path": "password", "value": "n3w-passw0rd"}]}`, nil)
+		if code != http.StatusForbidden {
+			t.Fatalf("password of %v should not be changed, got %v", name, code)
+		}
+		if code = do(t, router, http.MethodPut, "/Users/"+name, `{"userName": "`+name+`", "active": false}`, nil); code != http.StatusForbidden {
+			t.Fatalf("%v should not be replaced, got %v", name, code)
+		}
+		if code = do(t, router, http.MethodDelete, "/Users/"+name, "", nil); code != http.StatusForbidden {
+			t.Fatalf("%v should not be deleted, got %v", name, code)
+		}
+	}
+
+	if code = do(t, router, http.MethodDelete, "/Users/alice", "", nil); code != http.StatusNoContent {
+		t.Fatalf("delete user: %v", code)
+	}
+	if code = do(t, router, http.MethodGet, "/Users/alice", "", nil); code != http.StatusNotFound {
+		t.Fatalf("deleted user should not be found, got %v", code)
+	}
+}
+
+func TestGroups(t *testing.T) {
+	router, h := newTestRouter(t)
+	ctx := context.Background()
+	for _, name := range []string{"alice", "bob"} {
+		if code := do(t, router, http.MethodPost, "/Users", `{"userName": "`+name+`"}`, nil); code != http.StatusCreated {
+			t.Fatalf("create user %v: %v", name, code)
+		}
+	}
+
+	g := &Group{}
+	code := do(t, router, http.MethodPost, "/Groups", `{"displayName": "Dev", "members": [{"value": "alice"}]}`, g)
+	if code != http.StatusCreated || len(g.Members) != 1 {
+		t.Fatalf("create group: %v %+v", code, g)
+	}
+	if code = do(t, router, http.MethodPost, "/Groups", `{"displayName": "dev"}`, nil); code != http.StatusConflict {
+		t.Fatalf("duplicated displayName should be conflict, got %v", code)
+	}
+	if code = do(t, router, http.MethodPost, "/Groups", `{"displayName": "ops", "members": [{"value": "robot-ci"}]}`, nil); code != http.StatusBadRequest {
+		t.Fatalf("robot should not be member, got %v", code)
+	}
+
+	// groups of other sources are invisible
+	list := &ListResponse{}
+	do(t, router, http.MethodGet, "/Groups", "", list)
+	if list.TotalResults != 1 {
+		t.Fatalf("expect only scim groups, got %+v", list)
+	}
+	if code = do(t, router, http.MethodGet, "/Groups/dev", "", nil); code != http.StatusNotFound {
+		t.Fatalf("ldap group should not be found, got %v", code)
+	}
+
+	code = do(t, router, http.MethodPatch, "/Groups/"+g.ID, `{"Operations": [
+		{"op": "add", "path": "members", "value": [{"value": "bob"}]},
+		{"op": "remove", "path": "members[value eq \"alice\"]"}
+	]}`, g)
+	if code != http.StatusOK || len(g.Members) != 1 || g.Members[0].Value != "bob" {
+		t.Fatalf("patch group: %v %+v", code, g)
+	}
+	group := &userv1.Group{}
+	if err := h.Direct().Get(ctx, types.NamespacedName{Name: g.ID}, group); err != nil {
+		t.Fatal(err)
+	}
+	if group.GetSource() != userv1.ScimGroup || len(group.Spec.Members) != 1 || group.Spec.Members[0] != "bob" || group.Annotations[constants.SyncAnnotation] != constants.TrueStr {
+		t.Fatalf("unexpected group %+v", group.Spec)
+	}
+
+	u := &User{}
+	do(t, router, http.MethodGet, "/Users/bob", "", u)
+	if len(u.Groups) != 1 || u.Groups[0].Value != g.ID {
+		t.Fatalf("groups of user should be listed, got %+v", u.Groups)
+	}
+
+	if code = do(t, router, http.MethodDelete, "/Groups/"+g.ID, "", nil); code != http.StatusNoContent {
+		t.Fatalf("delete group: %v", code)
+	}
+}
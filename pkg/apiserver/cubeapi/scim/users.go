@@ -0,0 +1,482 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scim
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	cubeuser "github.com/kubecube-io/kubecube/pkg/apiserver/cubeapi/user"
+	"github.com/kubecube-io/kubecube/pkg/authentication/session"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+	"github.com/kubecube-io/kubecube/pkg/utils/md5util"
+)
+
+const resourceTypeUser = "Users"
+
+var userFilterAttrs = sets.New[string]("id", "username", "displayname", "name.formatted", "externalid", "active", "emails.value", "phonenumbers.value")
+
+// listUsers lists human accounts, robot accounts are not visible to scim
+func (h *handler) listUsers(c *gin.Context) {
+	start, count, e := page(c)
+	if e != nil {
+		abort(c, e)
+		return
+	}
+	var f filter
+	if expr := c.Query("filter"); expr != "" {
+		var err error
+		if f, err = parseFilter(expr, UserSchema, userFilterAttrs); err != nil {
+			abort(c, badRequest(invalidFilter, err.Error()))
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	users := &userv1.UserList{}
+	if err := h.Direct().List(ctx, users); err != nil {
+		clog.Error("list users failed: %v", err)
+		abort(c, errInternal)
+		return
+	}
+	groups := &userv1.GroupList{}
+	if err := h.Direct().List(ctx, groups); err != nil {
+		clog.Error("list groups failed: %v", err)
+		abort(c, errInternal)
+		return
+	}
+
+	sort.Slice(users.Items, func(i, j int) bool { return users.Items[i].Name < users.Items[j].Name })
+	var resources []interface{}
+	for i := range users.Items {
+		if users.Items[i].IsRobot() {
+			continue
+		}
+		u := toScimUser(c, &users.Items[i], groups.Items)
+		if f != nil && !f.match(userAttributes(u)) {
+			continue
+		}
+		resources = append(resources, u)
+	}
+	writeJSON(c, http.StatusOK, listResponse(resources, start, count))
+}
+
+func (h *handler) getUser(c *gin.Context) {
+	user, e := h.getCubeUser(c)
+	if e != nil {
+		abort(c, e)
+		return
+	}
+	u, e := h.scimUserOf(c, user)
+	if e != nil {
+		abort(c, e)
+		return
+	}
+	writeResource(c, http.StatusOK, u, u.Meta)
+}
+
+// createUser creates user with userName as name, so userName must be a
+// valid name of kubernetes object.
+func (h *handler) createUser(c *gin.Context) {
+	s := &User{}
+	if e := bindJSON(c, s); e != nil {
+		abort(c, e)
+		return
+	}
+	if s.UserName == "" {
+		abort(c, badRequest(invalidValue, "userName is required"))
+		return
+	}
+	if errs := validation.IsDNS1123Subdomain(s.UserName); len(errs) > 0 {
+		abort(c, badRequest(invalidValue, fmt.Sprintf("invalid userName %q: %v", s.UserName, strings.Join(errs, ","))))
+		return
+	}
+
+	if s.Password != "" && !cubeuser.CheckPwd(s.Password) {
+		abort(c, errPassword)
+		return
+	}
+
+	user := &userv1.User{}
+	user.Name = s.UserName
+	user.Spec.LoginType = userv1.NormalLogin
+	user.Spec.AccountType = userv1.HumanAccount
+	user.Spec.State = userv1.NormalState
+	if s.Password == "" {
+		// users provisioned without password login by identity provider only
+		user.Spec.Password = md5util.GetMD5Salt(uuid.New().String())
+	}
+	applyScimUser(user, s)
+
+	if err := h.Direct().Create(c.Request.Context(), user); err != nil {
+		if errors.IsAlreadyExists(err) {
+			abort(c, errConflict)
+			return
+		}
+		clog.Error("create user %v by scim failed: %v", user.Name, err)
+		abort(c, errInternal)
+		return
+	}
+	clog.Info("user %v created by scim", user.Name)
+
+	u, e := h.scimUserOf(c, user)
+	if e != nil {
+		abort(c, e)
+		return
+	}
+	c.Header("Location", u.Meta.Location)
+	writeResource(c, http.StatusCreated, u, u.Meta)
+}
+
+func (h *handler) replaceUser(c *gin.Context) {
+	s := &User{}
+	if e := bindJSON(c, s); e != nil {
+		abort(c, e)
+		return
+	}
+	user, e := h.getCubeUser(c)
+	if e != nil {
+		abort(c, e)
+		return
+	}
+	if e = checkVersion(c, version(user.ResourceVersion)); e != nil {
+		abort(c, e)
+		return
+	}
+	if s.UserName != "" && s.UserName != user.Name {
+		abort(c, badRequest(mutability, "userName can not be changed"))
+		return
+	}
+	h.updateUser(c, user, s)
+}
+
+func (h *handler) patchUser(c *gin.Context) {
+	req := &PatchRequest{}
+	if e := bindJSON(c, req); e != nil {
+		abort(c, e)
+		return
+	}
+	if e := checkPatchRequest(req); e != nil {
+		abort(c, e)
+		return
+	}
+	user, e := h.getCubeUser(c)
+	if e != nil {
+		abort(c, e)
+		return
+	}
+	if e = checkVersion(c, version(user.ResourceVersion)); e != nil {
+		abort(c, e)
+		return
+	}
+
+	s := toScimUser(c, user, nil)
+	for _, op := range req.Operations {
+		if e = patchScimUser(s, op); e != nil {
+			abort(c, e)
+			return
+		}
+	}
+	h.updateUser(c, user, s)
+}
+
+// updateUser applies scim user to user, sessions are revoked if user is
+// deactivated or password of user is changed
+func (h *handler) updateUser(c *gin.Context, user *userv1.User, s *User) {
+	if isProtected(user) {
+		abort(c, errProtected)
+		return
+	}
+	if s.Password != "" && !cubeuser.CheckPwd(s.Password) {
+		abort(c, errPassword)
+		return
+	}
+	deactivated := user.Spec.State != userv1.ForbiddenState && s.Active != nil && !*s.Active
+	passwordChanged := s.Password != "" && md5util.GetMD5Salt(s.Password) != user.Spec.Password
+	applyScimUser(user, s)
+
+	ctx := c.Request.Context()
+	if err := h.Direct().Update(ctx, user); err != nil {
+		if errors.IsConflict(err) {
+			abort(c, errPrecondition)
+			return
+		}
+		clog.Error("update user %v by scim failed: %v", user.Name, err)
+		abort(c, errInternal)
+		return
+	}
+	switch {
+	case deactivated:
+		clog.Info("user %v deactivated by scim", user.Name)
+		if err := session.NewManager(h.Client).RevokeAll(ctx, user.Name, "deactivated by scim"); err != nil {
+			clog.Error("revoke sessions of user %v failed: %v", user.Name, err)
+		}
+	case passwordChanged:
+		clog.Info("password of user %v changed by scim", user.Name)
+		if err := session.NewManager(h.Client).RevokeAll(ctx, user.Name, session.ReasonPasswordReset); err != nil {
+			clog.Error("revoke sessions of user %v failed: %v", user.Name, err)
+		}
+	}
+
+	u, e := h.scimUserOf(c, user)
+	if e != nil {
+		abort(c, e)
+		return
+	}
+	writeResource(c, http.StatusOK, u, u.Meta)
+}
+
+func (h *handler) deleteUser(c *gin.Context) {
+	user, e := h.getCubeUser(c)
+	if e != nil {
+		abort(c, e)
+		return
+	}
+	if e = checkVersion(c, version(user.ResourceVersion)); e != nil {
+		abort(c, e)
+		return
+	}
+	if isProtected(user) {
+		abort(c, errProtected)
+		return
+	}
+	ctx := c.Request.Context()
+	if err := h.Direct().Delete(ctx, user); err != nil && !errors.IsNotFound(err) {
+		clog.Error("delete user %v by scim failed: %v", user.Name, err)
+		abort(c, errInternal)
+		return
+	}
+	clog.Info("user %v deleted by scim", user.Name)
+	c.Status(http.StatusNoContent)
+}
+
+// getCubeUser returns user of path param, robot accounts are treated as not found
+func (h *handler) getCubeUser(c *gin.Context) (*userv1.User, *Error) {
+	user := &userv1.User{}
+	err := h.Direct().Get(c.Request.Context(), types.NamespacedName{Name: c.Param("id")}, user)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, errNotFound
+		}
+		clog.Error("get user %v failed: %v", c.Param("id"), err)
+		return nil, errInternal
+	}
+	if user.IsRobot() {
+		return nil, errNotFound
+	}
+	return user, nil
+}
+
+// isProtected tells if user can not be changed by scim, the token of scim
+// must not be able to take over platform admins
+func isProtected(user *userv1.User) bool {
+	return user.Name == constants.AdminUser || userv1.IsPlatformAdmin(user)
+}
+
+func (h *handler) scimUserOf(c *gin.Context, user *userv1.User) (*User, *Error) {
+	groups := &userv1.GroupList{}
+	if err := h.Direct().List(c.Request.Context(), groups); err != nil {
+		clog.Error("list groups failed: %v", err)
+		return nil, errInternal
+	}
+	return toScimUser(c, user, groups.Items), nil
+}
+
+func toScimUser(c *gin.Context, user *userv1.User, groups []userv1.Group) *User {
+	active := user.Spec.State != userv1.ForbiddenState
+	u := &User{
+		Schemas:     []string{UserSchema},
+		ID:          user.Name,
+		ExternalID:  user.Annotations[constants.ScimExternalIDAnnotation],
+		UserName:    user.Name,
+		DisplayName: user.Spec.DisplayName,
+		Active:      &active,
+		Meta: &Meta{
+			ResourceType: "User",
+			Created:      user.CreationTimestamp.UTC().Format(time.RFC3339),
+			Location:     location(c, resourceTypeUser, user.Name),
+			Version:      version(user.ResourceVersion),
+		},
+	}
+	if user.Spec.DisplayName != "" {
+		u.Name = &Name{Formatted: user.Spec.DisplayName}
+	}
+	if user.Spec.Email != "" {
+		u.Emails = []MultiValued{{Value: user.Spec.Email, Type: "work", Primary: true}}
+	}
+	if user.Spec.Phone != "" {
+		u.PhoneNumbers = []MultiValued{{Value: user.Spec.Phone, Type: "work", Primary: true}}
+	}
+	for _, g := range groups {
+		if g.HasMember(user.Name) {
+			u.Groups = append(u.Groups, MultiValued{Value: g.Name, Display: g.Spec.DisplayName, Ref: location(c, resourceTypeGroup, g.Name)})
+		}
+	}
+	return u
+}
+
+// applyScimUser sets attributes of scim user to user, password is only
+// changed when given.
+func applyScimUser(user *userv1.User, s *User) {
+	user.Spec.DisplayName = s.DisplayName
+	if user.Spec.DisplayName == "" && s.Name != nil {
+		user.Spec.DisplayName = s.Name.Formatted
+	}
+	user.Spec.Email = primaryValue(s.Emails)
+	user.Spec.Phone = primaryValue(s.PhoneNumbers)
+	// state is kept if active is absent
+	if s.Active != nil {
+		user.Spec.State = userv1.NormalState
+		if !*s.Active {
+			user.Spec.State = userv1.ForbiddenState
+		}
+	}
+	if s.Password != "" {
+		user.Spec.Password = md5util.GetMD5Salt(s.Password)
+	}
+	if s.ExternalID != "" {
+		if user.Annotations == nil {
+			user.Annotations = map[string]string{}
+		}
+		user.Annotations[constants.ScimExternalIDAnnotation] = s.ExternalID
+	} else {
+		delete(user.Annotations, constants.ScimExternalIDAnnotation)
+	}
+}
+
+// patchScimUser applies patch operation to scim user, attributes can not be
+// stored in user like title or addresses are ignored.
+func patchScimUser(u *User, op PatchOperation) *Error {
+	ops, e := splitValue(op)
+	if e != nil {
+		return e
+	}
+	for _, o := range ops {
+		pp, e := parsePatchPath(o.Path, UserSchema)
+		if e != nil {
+			return e
+		}
+		if e = patchUserAttr(u, o, pp); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+func patchUserAttr(u *User, op PatchOperation, pp *patchPath) *Error {
+	var e *Error
+	switch pp.attr {
+	case "username":
+		if op.Op == opRemove {
+			return badRequest(mutability, "userName can not be removed")
+		}
+		var name string
+		if name, e = unmarshalString(op.Value); e != nil {
+			return e
+		}
+		if name != u.UserName {
+			return badRequest(mutability, "userName can not be changed")
+		}
+	case "active":
+		if op.Op == opRemove {
+			return badRequest(mutability, "active can not be removed")
+		}
+		active, ok := parseBool(op.Value)
+		if !ok {
+			return badRequest(invalidValue, fmt.Sprintf("invalid active %s", op.Value))
+		}
+		u.Active = &active
+	case "displayname":
+		u.DisplayName = ""
+		if op.Op != opRemove {
+			u.DisplayName, e = unmarshalString(op.Value)
+		}
+	case "name":
+		u.Name = nil
+		if op.Op == opRemove {
+			if pp.sub == "" || pp.sub == "formatted" {
+				u.DisplayName = ""
+			}
+			return nil
+		}
+		var formatted string
+		if pp.sub == "formatted" {
+			formatted, e = unmarshalString(op.Value)
+		} else if pp.sub == "" {
+			name := &Name{}
+			if err := jsonUnmarshal(op.Value, name); err != nil {
+				return err
+			}
+			formatted = name.Formatted
+		}
+		// displayName takes precedence over name.formatted
+		if formatted != "" {
+			u.DisplayName = formatted
+		}
+	case "externalid":
+		u.ExternalID = ""
+		if op.Op != opRemove {
+			u.ExternalID, e = unmarshalString(op.Value)
+		}
+	case "password":
+		if op.Op == opRemove {
+			return badRequest(mutability, "password can not be removed")
+		}
+		u.Password, e = unmarshalString(op.Value)
+	case "emails":
+		u.Emails, e = patchMultiValued(u.Emails, op.Op, pp, op.Value)
+	case "phonenumbers":
+		u.PhoneNumbers, e = patchMultiValued(u.PhoneNumbers, op.Op, pp, op.Value)
+	case "groups", "id", "meta":
+		return badRequest(mutability, fmt.Sprintf("%v is read only", pp.attr))
+	}
+	return e
+}
+
+func userAttributes(u *User) attributes {
+	return func(path string) []string {
+		switch path {
+		case "id":
+			return []string{u.ID}
+		case "username":
+			return []string{u.UserName}
+		case "displayname", "name.formatted":
+			return []string{u.DisplayName}
+		case "externalid":
+			return []string{u.ExternalID}
+		case "active":
+			return []string{fmt.Sprint(u.Active != nil && *u.Active)}
+		case "emails.value":
+			return values(u.Emails)
+		case "phonenumbers.value":
+			return values(u.PhoneNumbers)
+		}
+		return nil
+	}
+}
@@ -22,13 +22,15 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"k8s.io/api/authentication/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	v1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
 	"github.com/kubecube-io/kubecube/pkg/authentication/authenticators/jwt"
+	"github.com/kubecube-io/kubecube/pkg/authentication/group"
 	"github.com/kubecube-io/kubecube/pkg/authentication/identityprovider/github"
 	"github.com/kubecube-io/kubecube/pkg/authentication/identityprovider/ldap"
+	"github.com/kubecube-io/kubecube/pkg/authentication/ldapsync"
+	"github.com/kubecube-io/kubecube/pkg/clients"
 	"github.com/kubecube-io/kubecube/pkg/clog"
 	"github.com/kubecube-io/kubecube/pkg/utils/constants"
 	"github.com/kubecube-io/kubecube/pkg/utils/errcode"
@@ -109,7 +111,7 @@ func Login(c *gin.Context) {
 
 	// generate token and return
 	authJwtImpl := jwt.GetAuthJwtImpl()
-	token, err := authJwtImpl.GenerateToken(&v1beta1.UserInfo{Username: name})
+	token, err := generateSessionToken(c, user, name, loginType)
 	if err != nil {
 		clog.Warn(err.Error())
 		response.FailReturn(c, errcode.AuthenticateError)
@@ -174,6 +176,12 @@ func GitHubLogin(c *gin.Context) {
 		user = userFind
 	}
 
+	// membership of github groups follows organizations and teams, failure should not block login
+	cli := clients.Interface().Kubernetes(constants.LocalCluster)
+	if err = group.SyncMembership(c.Request.Context(), cli, v1.GitHubGroup, user.Name, userInfo.GetGroups()); err != nil {
+		clog.Warn("sync github groups of user %v failed: %v", user.Name, err)
+	}
+
 	// update user login information
 	user.Status.LastLoginIP = c.ClientIP()
 	user.Status.LastLoginTime = &metav1.Time{Time: time.Now()}
@@ -185,7 +193,7 @@ func GitHubLogin(c *gin.Context) {
 
 	// generate token and return
 	authJwtImpl := jwt.GetAuthJwtImpl()
-	token, errInfo := authJwtImpl.GenerateToken(&v1beta1.UserInfo{Username: userName})
+	token, errInfo := generateSessionToken(c, user, userName, v1.GitHubLogin)
 	bearerToken := jwt.BearerTokenPrefix + " " + token
 	if errInfo != nil {
 		response.FailReturn(c, errcode.AuthenticateError)
@@ -204,7 +212,8 @@ func normalLogin(c *gin.Context, name string, password string) (*v1.User, *errco
 	if respInfo != nil {
 		return nil, respInfo
 	}
-	if user == nil {
+	// robot accounts can only login by key
+	if user == nil || user.IsRobot() {
 		return nil, errcode.AuthenticateError
 	}
 	if user.Spec.Password != md5util.GetMD5Salt(password) {
@@ -224,13 +233,14 @@ func ldapLogin(c *gin.Context, name string, password string) (*v1.User, *errcode
 	if respInfo != nil {
 		return nil, respInfo
 	}
-	if user != nil && user.Spec.State == v1.ForbiddenState {
+	// user forbidden by ldap sync is enabled again once found in directory
+	if user != nil && user.Spec.State == v1.ForbiddenState && user.Annotations[constants.LdapSyncForbiddenAnnotation] != constants.TrueStr {
 		return nil, errcode.UserIsDisabled
 	}
 
 	// ldap login
 	ldapProvider := ldap.GetProvider()
-	_, err := ldapProvider.Authenticate(name, password)
+	identity, err := ldapProvider.Authenticate(name, password)
 	if err != nil {
 		return nil, errcode.AuthenticateError
 	}
@@ -248,5 +258,16 @@ func ldapLogin(c *gin.Context, name string, password string) (*v1.User, *errcode
 			return nil, respInfo
 		}
 	}
+
+	// membership of ldap groups and mapped roles follow ldap server, failure should not block login
+	cli := clients.Interface().Kubernetes(constants.LocalCluster)
+	if dirUser, ok := ldap.AsDirectoryUser(identity); ok {
+		if err = ldapsync.SyncUser(c.Request.Context(), cli, user, dirUser); err != nil {
+			clog.Warn("sync ldap role mappings of user %v failed: %v", user.Name, err)
+		}
+	}
+	if err = group.SyncMembership(c.Request.Context(), cli, v1.LdapGroup, user.Name, identity.GetGroups()); err != nil {
+		clog.Warn("sync ldap groups of user %v failed: %v", user.Name, err)
+	}
 	return user, nil
 }
@@ -0,0 +1,264 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package user
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/clients"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/utils/access"
+	"github.com/kubecube-io/kubecube/pkg/utils/audit"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+	"github.com/kubecube-io/kubecube/pkg/utils/errcode"
+	"github.com/kubecube-io/kubecube/pkg/utils/response"
+)
+
+const resourceTypeGroup = "group"
+
+type GroupList struct {
+	Total int            `json:"total"`
+	Items []userv1.Group `json:"items"`
+}
+
+// CreateGroup create group
+// @Summary create group
+// @Description create group of users, members of group inherit scope bindings of group
+// @Tags group
+// @Param group body userv1.Group true "group information"
+// @Success 200 {object} userv1.Group
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/groups [post]
+func CreateGroup(c *gin.Context) {
+	group := &userv1.Group{}
+	if err := c.ShouldBindJSON(group); err != nil {
+		clog.Error("parse create group body error: %s", err)
+		response.FailReturn(c, errcode.InvalidBodyFormat)
+		return
+	}
+	if !regexp.MustCompile(dnsNamePattern).MatchString(group.Name) {
+		response.FailReturn(c, errcode.InvalidParameterName)
+		return
+	}
+	if errInfo := checkGroupSpec(&group.Spec); errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+	if !access.AllowAccess(constants.LocalCluster, c.Request, constants.CreateVerb, group) {
+		response.FailReturn(c, errcode.ForbiddenErr)
+		return
+	}
+
+	newGroup := &userv1.Group{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: group.Name,
+			// groups should be synced to member clusters for warden to render bindings
+			Annotations: map[string]string{constants.SyncAnnotation: constants.TrueStr},
+		},
+		Spec: group.Spec,
+	}
+	err := clients.Interface().Kubernetes(constants.LocalCluster).Direct().Create(c.Request.Context(), newGroup)
+	if err != nil {
+		if errors.IsAlreadyExists(err) {
+			response.FailReturn(c, errcode.AlreadyExist(group.Name))
+			return
+		}
+		clog.Error("create group %v error: %v", group.Name, err)
+		response.FailReturn(c, errcode.CreateResourceError(resourceTypeGroup))
+		return
+	}
+
+	c = audit.SetAuditInfo(c, audit.CreateGroup, newGroup.Name, newGroup.Spec)
+	response.SuccessReturn(c, newGroup)
+}
+
+// ListGroups list groups
+// @Summary list groups
+// @Description list groups, filter by member if given
+// @Tags group
+// @Param member query string false "user name of member"
+// @Success 200 {object} GroupList
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/groups [get]
+func ListGroups(c *gin.Context) {
+	member := c.Query("member")
+
+	groupList := &userv1.GroupList{}
+	err := clients.Interface().Kubernetes(constants.LocalCluster).Cache().List(c.Request.Context(), groupList)
+	if err != nil {
+		clog.Error("list groups error: %v", err)
+		response.FailReturn(c, errcode.GetResourceError(resourceTypeGroup))
+		return
+	}
+
+	// everyone can see groups of self
+	if !access.IsSelf(c.Request, member) && !access.AllowAccess(constants.LocalCluster, c.Request, constants.ListVerb, &userv1.Group{}) {
+		response.FailReturn(c, errcode.ForbiddenErr)
+		return
+	}
+
+	res := GroupList{Items: []userv1.Group{}}
+	for _, g := range groupList.Items {
+		if len(member) > 0 && !g.HasMember(member) {
+			continue
+		}
+		res.Items = append(res.Items, g)
+	}
+	res.Total = len(res.Items)
+
+	response.SuccessReturn(c, res)
+}
+
+// GetGroup get group
+// @Summary get group
+// @Description get group by name
+// @Tags group
+// @Param group path string true "group name"
+// @Success 200 {object} userv1.Group
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/groups/{group} [get]
+func GetGroup(c *gin.Context) {
+	group, errInfo := getGroupForAccess(c, constants.GetVerb)
+	if errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+	response.SuccessReturn(c, group)
+}
+
+// UpdateGroup update group
+// @Summary update group
+// @Description update display name, members and scope bindings of group, members of groups from identity provider are synced and can not be modified
+// @Tags group
+// @Param group path string true "group name"
+// @Param spec body userv1.GroupSpec true "group spec"
+// @Success 200 {object} response.SuccessInfo
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/groups/{group} [put]
+func UpdateGroup(c *gin.Context) {
+	spec := &userv1.GroupSpec{}
+	if err := c.ShouldBindJSON(spec); err != nil {
+		clog.Error("parse update group body error: %s", err)
+		response.FailReturn(c, errcode.InvalidBodyFormat)
+		return
+	}
+	if errInfo := checkGroupSpec(spec); errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+
+	group, errInfo := getGroupForAccess(c, constants.UpdateVerb)
+	if errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+
+	// source of group can not be changed
+	if spec.Source != "" && spec.Source != group.GetSource() {
+		response.FailReturn(c, errcode.ParamsInvalid(fmt.Errorf("source of group can not be changed")))
+		return
+	}
+	members := spec.Members
+	if group.GetSource() != userv1.StaticGroup {
+		members = group.Spec.Members
+	}
+	group.Spec.DisplayName = spec.DisplayName
+	group.Spec.Description = spec.Description
+	group.Spec.ExternalName = spec.ExternalName
+	group.Spec.Members = members
+	group.Spec.ScopeBindings = spec.ScopeBindings
+
+	err := clients.Interface().Kubernetes(constants.LocalCluster).Direct().Update(c.Request.Context(), group)
+	if err != nil {
+		clog.Error("update group %v error: %v", group.Name, err)
+		response.FailReturn(c, errcode.UpdateResourceError(resourceTypeGroup))
+		return
+	}
+
+	c = audit.SetAuditInfo(c, audit.UpdateGroup, group.Name, spec)
+	response.SuccessReturn(c, nil)
+}
+
+// DeleteGroup delete group
+// @Summary delete group
+// @Description delete group, members lose the scope bindings of group
+// @Tags group
+// @Param group path string true "group name"
+// @Success 200 {object} response.SuccessInfo
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/groups/{group} [delete]
+func DeleteGroup(c *gin.Context) {
+	group, errInfo := getGroupForAccess(c, constants.DeleteVerb)
+	if errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+
+	err := clients.Interface().Kubernetes(constants.LocalCluster).Direct().Delete(c.Request.Context(), group)
+	if err != nil && !errors.IsNotFound(err) {
+		clog.Error("delete group %v error: %v", group.Name, err)
+		response.FailReturn(c, errcode.DealError(err))
+		return
+	}
+
+	c = audit.SetAuditInfo(c, audit.DeleteGroup, group.Name, nil)
+	response.SuccessReturn(c, nil)
+}
+
+// getGroupForAccess returns group specified by path param if request user can
+// access it with given verb.
+func getGroupForAccess(c *gin.Context, verb string) (*userv1.Group, *errcode.ErrorInfo) {
+	group := &userv1.Group{}
+	err := clients.Interface().Kubernetes(constants.LocalCluster).Direct().Get(c.Request.Context(), types.NamespacedName{Name: c.Param("group")}, group)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, errcode.NotFoundErr
+		}
+		clog.Error("get group %v error: %v", c.Param("group"), err)
+		return nil, errcode.GetResourceError(resourceTypeGroup)
+	}
+	if !access.AllowAccess(constants.LocalCluster, c.Request, verb, group) {
+		return nil, errcode.ForbiddenErr
+	}
+	return group, nil
+}
+
+func checkGroupSpec(spec *userv1.GroupSpec) *errcode.ErrorInfo {
+	switch spec.Source {
+	case "", userv1.StaticGroup, userv1.LdapGroup, userv1.OidcGroup, userv1.GitHubGroup, userv1.SamlGroup, userv1.ScimGroup:
+	default:
+		return errcode.ParamsInvalid(fmt.Errorf("unknown source of group: %v", spec.Source))
+	}
+	for _, b := range spec.ScopeBindings {
+		switch b.ScopeType {
+		case userv1.TenantScope, userv1.ProjectScope, userv1.PlatformScope:
+		default:
+			return errcode.ParamsInvalid(fmt.Errorf("unknown scope type of binding: %v", b.ScopeType))
+		}
+		if len(b.ScopeName) == 0 || len(b.Role) == 0 {
+			return errcode.ParamsInvalid(fmt.Errorf("scope name and role of binding are required"))
+		}
+	}
+	return nil
+}
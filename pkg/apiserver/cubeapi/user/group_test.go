@@ -0,0 +1,137 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package user_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubecube-io/kubecube/pkg/apis"
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/apiserver/cubeapi/user"
+	"github.com/kubecube-io/kubecube/pkg/clients"
+	"github.com/kubecube-io/kubecube/pkg/multicluster"
+	"github.com/kubecube-io/kubecube/pkg/multicluster/client/fake"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+var _ = Describe("Group", func() {
+
+	var (
+		ldapGroup *userv1.Group
+		router    *gin.Engine
+	)
+
+	BeforeEach(func() {
+		ldapGroup = &userv1.Group{
+			ObjectMeta: metav1.ObjectMeta{Name: "dev"},
+			Spec: userv1.GroupSpec{
+				Source:  userv1.LdapGroup,
+				Members: []string{"user-1"},
+			},
+		}
+	})
+
+	JustBeforeEach(func() {
+		scheme := runtime.NewScheme()
+		apis.AddToScheme(scheme)
+		corev1.AddToScheme(scheme)
+		opts := &fake.Options{
+			Scheme:               scheme,
+			Objs:                 []client.Object{ldapGroup},
+			ClientSetRuntimeObjs: []runtime.Object{},
+			Lists:                []client.ObjectList{},
+		}
+		multicluster.InitFakeMultiClusterMgrWithOpts(opts)
+		clients.InitCubeClientSetWithOpts(nil)
+
+		router = gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Set(constants.UserName, "admin")
+		})
+		router.POST("/api/v1/cube/groups", user.CreateGroup)
+		router.GET("/api/v1/cube/groups", user.ListGroups)
+		router.PUT("/api/v1/cube/groups/:group", user.UpdateGroup)
+	})
+
+	It("create static group", func() {
+		body, _ := json.Marshal(userv1.Group{
+			ObjectMeta: metav1.ObjectMeta{Name: "ops"},
+			Spec: userv1.GroupSpec{
+				Members:       []string{"user-1", "user-2"},
+				ScopeBindings: []userv1.ScopeBinding{{ScopeType: userv1.TenantScope, ScopeName: "tenant-1", Role: constants.TenantAdmin}},
+			},
+		})
+		w := performRequest(router, http.MethodPost, "/api/v1/cube/groups", body)
+		Expect(w.Code).To(Equal(http.StatusOK))
+
+		group := &userv1.Group{}
+		err := clients.Interface().Kubernetes(constants.LocalCluster).Direct().Get(context.Background(), client.ObjectKey{Name: "ops"}, group)
+		Expect(err).To(BeNil())
+		Expect(group.Annotations[constants.SyncAnnotation]).To(Equal(constants.TrueStr))
+		Expect(group.HasMember("user-2")).To(BeTrue())
+
+		w = performRequest(router, http.MethodGet, "/api/v1/cube/groups?member=user-2", nil)
+		Expect(w.Code).To(Equal(http.StatusOK))
+		list := &user.GroupList{}
+		Expect(json.Unmarshal(w.Body.Bytes(), list)).To(BeNil())
+		Expect(list.Total).To(Equal(1))
+		Expect(list.Items[0].Name).To(Equal("ops"))
+	})
+
+	It("reject binding with unknown scope", func() {
+		body, _ := json.Marshal(userv1.Group{
+			ObjectMeta: metav1.ObjectMeta{Name: "ops"},
+			Spec: userv1.GroupSpec{
+				ScopeBindings: []userv1.ScopeBinding{{ScopeType: "cluster", ScopeName: "pivot", Role: constants.TenantAdmin}},
+			},
+		})
+		w := performRequest(router, http.MethodPost, "/api/v1/cube/groups", body)
+		Expect(w.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("keep members of ldap group on update", func() {
+		body, _ := json.Marshal(userv1.GroupSpec{
+			Source:        userv1.LdapGroup,
+			Members:       []string{"user-3"},
+			ScopeBindings: []userv1.ScopeBinding{{ScopeType: userv1.ProjectScope, ScopeName: "project-1", Role: constants.ProjectAdmin}},
+		})
+		w := performRequest(router, http.MethodPut, "/api/v1/cube/groups/dev", body)
+		Expect(w.Code).To(Equal(http.StatusOK))
+
+		group := &userv1.Group{}
+		err := clients.Interface().Kubernetes(constants.LocalCluster).Direct().Get(context.Background(), client.ObjectKey{Name: "dev"}, group)
+		Expect(err).To(BeNil())
+		Expect(group.Spec.Members).To(Equal([]string{"user-1"}))
+		Expect(group.Spec.ScopeBindings).To(HaveLen(1))
+	})
+
+	It("reject changing source of group", func() {
+		body, _ := json.Marshal(userv1.GroupSpec{Source: userv1.StaticGroup})
+		w := performRequest(router, http.MethodPut, "/api/v1/cube/groups/dev", body)
+		Expect(w.Code).To(Equal(http.StatusBadRequest))
+	})
+})
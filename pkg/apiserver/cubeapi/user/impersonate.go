@@ -0,0 +1,175 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package user
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"k8s.io/api/authentication/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/authentication/authenticators/jwt"
+	"github.com/kubecube-io/kubecube/pkg/authentication/session"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/utils/audit"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+	"github.com/kubecube-io/kubecube/pkg/utils/errcode"
+	"github.com/kubecube-io/kubecube/pkg/utils/response"
+)
+
+const (
+	defaultImpersonateMinutes = 30
+	maxImpersonateMinutes     = 60
+)
+
+type ImpersonateRequest struct {
+	// User is the name of user to act as
+	User string `json:"user"`
+	// Minutes is how long the impersonation lasts, default 30 and at most 60
+	Minutes int `json:"minutes"`
+	// Reason tells why the user is impersonated, it is recorded by audit
+	Reason string `json:"reason"`
+}
+
+type ImpersonateResult struct {
+	Session    string      `json:"session"`
+	Token      string      `json:"token"`
+	ExpireTime metav1.Time `json:"expireTime"`
+}
+
+// StartImpersonate start to act as given user
+// @Summary start impersonation
+// @Description platform admin gets a token acts as given user for a limited time, platform admins can not be impersonated
+// @Tags user
+// @Param impersonateRequest body ImpersonateRequest true "impersonation request"
+// @Success 200 {object} ImpersonateResult
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/user/impersonate  [post]
+func StartImpersonate(c *gin.Context) {
+	req := &ImpersonateRequest{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		response.FailReturn(c, errcode.InvalidBodyFormat)
+		return
+	}
+	if len(req.User) == 0 || len(strings.TrimSpace(req.Reason)) == 0 {
+		response.FailReturn(c, errcode.ParamsInvalid(fmt.Errorf("user and reason are required")))
+		return
+	}
+	if req.Minutes == 0 {
+		req.Minutes = defaultImpersonateMinutes
+	}
+	if req.Minutes < 0 || req.Minutes > maxImpersonateMinutes {
+		response.FailReturn(c, errcode.ParamsInvalid(fmt.Errorf("minutes must be between 1 and %d", maxImpersonateMinutes)))
+		return
+	}
+
+	// impersonation can not be nested
+	if len(c.GetString(constants.Impersonator)) > 0 {
+		response.FailReturn(c, errcode.ForbiddenErr)
+		return
+	}
+	requester, errInfo := GetUserByName(c, c.GetString(constants.UserName))
+	if errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+	if requester == nil || !userv1.IsPlatformAdmin(requester) {
+		response.FailReturn(c, errcode.ForbiddenErr)
+		return
+	}
+
+	target, errInfo := GetUserByName(c, req.User)
+	if errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+	if target == nil {
+		response.FailReturn(c, errcode.UserNotExist)
+		return
+	}
+	if target.Spec.State == userv1.ForbiddenState {
+		response.FailReturn(c, errcode.UserIsDisabled)
+		return
+	}
+	if userv1.IsPlatformAdmin(target) {
+		response.FailReturn(c, errcode.ImpersonateAdmin)
+		return
+	}
+
+	sessionID := uuid.NewString()
+	expireTime := metav1.NewTime(time.Now().Add(time.Duration(req.Minutes) * time.Minute))
+	token, err := jwt.GetAuthJwtImpl().GenerateImpersonationToken(&v1beta1.UserInfo{Username: target.Name}, requester.Name, sessionID, expireTime.Time)
+	if err != nil {
+		clog.Warn(err.Error())
+		response.FailReturn(c, errcode.AuthenticateError)
+		return
+	}
+
+	s := &userv1.Session{}
+	s.Name = sessionID
+	s.Spec = userv1.SessionSpec{
+		User:         target.Name,
+		Impersonator: requester.Name,
+		ExpireTime:   &expireTime,
+		ClientIP:     c.ClientIP(),
+		UserAgent:    c.Request.UserAgent(),
+	}
+	// unlike login, the session must be recorded so that the impersonation can be ended
+	if err = sessionManager().Create(c.Request.Context(), s); err != nil {
+		clog.Error("create impersonation session of %v for user %v failed: %v", requester.Name, target.Name, err)
+		response.FailReturn(c, errcode.CreateResourceError(resourceTypeSession))
+		return
+	}
+
+	clog.Info("platform admin %v starts to impersonate user %v until %v: %v", requester.Name, target.Name, expireTime, req.Reason)
+	c = audit.SetAuditInfo(c, audit.StartImpersonate, target.Name, nil)
+	response.SuccessReturn(c, ImpersonateResult{
+		Session:    sessionID,
+		Token:      jwt.BearerTokenPrefix + " " + token,
+		ExpireTime: expireTime,
+	})
+}
+
+// EndImpersonate end the impersonation of current token
+// @Summary end impersonation
+// @Description revoke the impersonation session of current token
+// @Tags user
+// @Success 200 {object} response.SuccessInfo
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/user/impersonate  [delete]
+func EndImpersonate(c *gin.Context) {
+	if len(c.GetString(constants.Impersonator)) == 0 {
+		response.FailReturn(c, errcode.NotImpersonating)
+		return
+	}
+	sessionID := c.GetString(constants.SessionID)
+	err := sessionManager().Revoke(c.Request.Context(), sessionID, session.ReasonImpersonateEnd)
+	if err != nil && !errors.IsNotFound(err) {
+		clog.Error("revoke impersonation session %v failed: %v", sessionID, err)
+		response.FailReturn(c, errcode.UpdateResourceError(resourceTypeSession))
+		return
+	}
+
+	c = audit.SetAuditInfo(c, audit.EndImpersonate, c.GetString(constants.UserName), nil)
+	response.SuccessReturn(c, nil)
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package user_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubecube-io/kubecube/pkg/apis"
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/apiserver/cubeapi/user"
+	"github.com/kubecube-io/kubecube/pkg/authentication/authenticators/jwt"
+	"github.com/kubecube-io/kubecube/pkg/clients"
+	"github.com/kubecube-io/kubecube/pkg/multicluster"
+	"github.com/kubecube-io/kubecube/pkg/multicluster/client/fake"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+var _ = Describe("Impersonate", func() {
+
+	var (
+		router       *gin.Engine
+		requester    string
+		impersonator string
+		sessionID    string
+	)
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		apis.AddToScheme(scheme)
+		newUser := func(name string, admin bool) client.Object {
+			return &userv1.User{
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+				Status:     userv1.UserStatus{PlatformAdmin: admin},
+			}
+		}
+		multicluster.InitFakeMultiClusterMgrWithOpts(&fake.Options{Scheme: scheme, Objs: []client.Object{
+			newUser("admin", true), newUser("admin2", true), newUser("alice", false),
+		}})
+		clients.InitCubeClientSetWithOpts(nil)
+
+		requester, impersonator, sessionID = "admin", "", ""
+		router = gin.New()
+		// stands in for auth middleware
+		router.Use(func(c *gin.Context) {
+			c.Set(constants.UserName, requester)
+			c.Set(constants.SessionID, sessionID)
+			if len(impersonator) > 0 {
+				c.Set(constants.Impersonator, impersonator)
+			}
+		})
+		router.POST("/api/v1/cube/user/impersonate", user.StartImpersonate)
+		router.DELETE("/api/v1/cube/user/impersonate", user.EndImpersonate)
+	})
+
+	start := func(target string, minutes int) (int, *user.ImpersonateResult) {
+		body, _ := json.Marshal(user.ImpersonateRequest{User: target, Minutes: minutes, Reason: "debug permission"})
+		w := performRequest(router, http.MethodPost, "/api/v1/cube/user/impersonate", body)
+		result := &user.ImpersonateResult{}
+		_ = json.Unmarshal(w.Body.Bytes(), result)
+		return w.Code, result
+	}
+
+	getSession := func(name string) *userv1.Session {
+		s := &userv1.Session{}
+		err := clients.Interface().Kubernetes(constants.LocalCluster).Direct().Get(context.Background(), types.NamespacedName{Name: name}, s)
+		Expect(err).NotTo(HaveOccurred())
+		return s
+	}
+
+	It("impersonates user by time limited token", func() {
+		code, result := start("alice", 10)
+		Expect(code).To(Equal(http.StatusOK))
+
+		claims, err := jwt.GetAuthJwtImpl().ParseClaims(strings.TrimPrefix(result.Token, jwt.BearerTokenPrefix+" "))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(claims.UserInfo.Username).To(Equal("alice"))
+		Expect(claims.Impersonator).To(Equal("admin"))
+		Expect(claims.Id).To(Equal(result.Session))
+		Expect(claims.ExpiresAt).To(Equal(result.ExpireTime.Unix()))
+
+		s := getSession(result.Session)
+		Expect(s.Spec.User).To(Equal("alice"))
+		Expect(s.Spec.Impersonator).To(Equal("admin"))
+		Expect(s.Spec.ExpireTime.Unix()).To(Equal(result.ExpireTime.Unix()))
+
+		// acts as alice to end the impersonation
+		requester, impersonator, sessionID = "alice", "admin", result.Session
+		Expect(performRequest(router, http.MethodDelete, "/api/v1/cube/user/impersonate", nil).Code).To(Equal(http.StatusOK))
+		Expect(getSession(result.Session).Spec.Revoked).To(BeTrue())
+	})
+
+	It("disallows impersonating platform admin", func() {
+		code, _ := start("admin2", 10)
+		Expect(code).To(Equal(http.StatusForbidden))
+	})
+
+	It("is allowed for platform admin only", func() {
+		requester = "alice"
+		code, _ := start("admin", 10)
+		Expect(code).To(Equal(http.StatusForbidden))
+
+		// impersonation can not be nested
+		requester, impersonator = "alice", "admin"
+		code, _ = start("alice", 10)
+		Expect(code).To(Equal(http.StatusForbidden))
+	})
+
+	It("limits duration of impersonation", func() {
+		code, _ := start("alice", 24*60)
+		Expect(code).To(Equal(http.StatusBadRequest))
+		code, _ = start("nobody", 10)
+		Expect(code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("ends impersonation only", func() {
+		Expect(performRequest(router, http.MethodDelete, "/api/v1/cube/user/impersonate", nil).Code).To(Equal(http.StatusBadRequest))
+	})
+})
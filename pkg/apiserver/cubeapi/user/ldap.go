@@ -0,0 +1,71 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package user
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/authentication/identityprovider/ldap"
+	"github.com/kubecube-io/kubecube/pkg/authentication/ldapsync"
+	"github.com/kubecube-io/kubecube/pkg/clients"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/utils/access"
+	"github.com/kubecube-io/kubecube/pkg/utils/audit"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+	"github.com/kubecube-io/kubecube/pkg/utils/errcode"
+	"github.com/kubecube-io/kubecube/pkg/utils/response"
+)
+
+// SyncLdap sync ldap users with directory
+// @Summary sync ldap users
+// @Description sync scope bindings of ldap users by role mappings and forbid users removed from directory, only preview changes if dryRun
+// @Tags user
+// @Param dryRun query bool false "only preview changes"
+// @Success 200 {object} ldapsync.Result
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/user/ldap/sync [post]
+func SyncLdap(c *gin.Context) {
+	if !ldap.IsLdapOpen() {
+		response.FailReturn(c, errcode.LdapNotEnabled)
+		return
+	}
+	dryRun, _ := strconv.ParseBool(c.Query("dryRun"))
+
+	if !access.AllowAccess(constants.LocalCluster, c.Request, constants.UpdateVerb, &userv1.User{}) {
+		response.FailReturn(c, errcode.ForbiddenErr)
+		return
+	}
+
+	syncer := ldapsync.NewSyncer(clients.Interface().Kubernetes(constants.LocalCluster), ldap.GetProvider())
+	result, err := syncer.Sync(c.Request.Context(), dryRun)
+	if result == nil {
+		clog.Error("sync ldap directory failed: %v", err)
+		response.FailReturn(c, errcode.LdapConnectError)
+		return
+	}
+	if err != nil {
+		clog.Warn("sync ldap directory partially failed: %v", err)
+	}
+
+	if !dryRun {
+		c = audit.SetAuditInfo(c, audit.SyncLdap, "ldap", nil)
+	}
+	response.SuccessReturn(c, result)
+}
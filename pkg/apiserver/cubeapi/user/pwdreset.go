@@ -0,0 +1,128 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package user
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kubecube-io/kubecube/pkg/authentication/passwordreset"
+	"github.com/kubecube-io/kubecube/pkg/authentication/session"
+	"github.com/kubecube-io/kubecube/pkg/clients"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/notification"
+	"github.com/kubecube-io/kubecube/pkg/utils/audit"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+	"github.com/kubecube-io/kubecube/pkg/utils/errcode"
+	"github.com/kubecube-io/kubecube/pkg/utils/response"
+)
+
+// requestTimeout bounds the background work of sending reset mail
+const requestTimeout = time.Minute
+
+type PwdResetRequest struct {
+	// Account is name or email of user
+	Account string `json:"account,omitempty"`
+}
+
+type PwdResetConfirm struct {
+	UserName    string `json:"userName,omitempty"`
+	Token       string `json:"token,omitempty"`
+	NewPassword string `json:"newPassword,omitempty"`
+}
+
+func pwdResetManager() *passwordreset.Manager {
+	return passwordreset.NewManager(clients.Interface().Kubernetes(constants.LocalCluster), notification.GetSender())
+}
+
+// RequestPwdReset mails a one-time link to reset password
+// @Summary request password reset
+// @Description mail a one-time link to reset password to user found by name or email, the response is same whether user exists or not
+// @Tags user
+// @Param request body PwdResetRequest true "name or email of user"
+// @Success 200 {object} response.SuccessInfo
+// @Failure 400 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/user/pwd/reset-request [post]
+func RequestPwdReset(c *gin.Context) {
+	if !passwordreset.IsEnable() {
+		response.FailReturn(c, errcode.PwdResetNotEnabled)
+		return
+	}
+	req := &PwdResetRequest{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		response.FailReturn(c, errcode.InvalidBodyFormat)
+		return
+	}
+	if len(req.Account) == 0 {
+		response.FailReturn(c, errcode.MissingParamUserName)
+		return
+	}
+
+	// mail is sent in background so that response time tells nothing about user
+	mgr := pwdResetManager()
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+		if err := mgr.Request(ctx, req.Account); err != nil {
+			clog.Warn("request password reset failed: %v", err)
+		}
+	}()
+	response.SuccessReturn(c, nil)
+}
+
+// ConfirmPwdReset sets new password by token of reset link
+// @Summary reset password
+// @Description set new password by one-time token of reset link, all sessions of user are revoked
+// @Tags user
+// @Param confirm body PwdResetConfirm true "user name, token and new password"
+// @Success 200 {object} response.SuccessInfo
+// @Failure 400 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/user/pwd/reset [post]
+func ConfirmPwdReset(c *gin.Context) {
+	if !passwordreset.IsEnable() {
+		response.FailReturn(c, errcode.PwdResetNotEnabled)
+		return
+	}
+	req := &PwdResetConfirm{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		response.FailReturn(c, errcode.InvalidBodyFormat)
+		return
+	}
+	if !CheckPwd(req.NewPassword) {
+		response.FailReturn(c, errcode.InvalidParameterPassword)
+		return
+	}
+
+	user, err := pwdResetManager().Confirm(c.Request.Context(), req.UserName, req.Token, req.NewPassword)
+	if err != nil {
+		if errors.Is(err, passwordreset.ErrInvalidToken) {
+			response.FailReturn(c, errcode.InvalidPwdResetToken)
+			return
+		}
+		clog.Error("reset password of user %v failed: %v", req.UserName, err)
+		response.FailReturn(c, errcode.UpdateResourceError(resourceTypeUser))
+		return
+	}
+	revokeUserSessions(c, user.Name, session.ReasonPasswordReset)
+
+	c.Set(constants.UserName, user.Name)
+	c = audit.SetAuditInfo(c, audit.ResetPassword, user.Name, nil)
+	response.SuccessReturn(c, nil)
+}
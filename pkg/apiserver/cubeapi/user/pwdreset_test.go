@@ -0,0 +1,136 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package user_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubecube-io/kubecube/pkg/apis"
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/apiserver/cubeapi/user"
+	"github.com/kubecube-io/kubecube/pkg/authentication/passwordreset"
+	"github.com/kubecube-io/kubecube/pkg/clients"
+	"github.com/kubecube-io/kubecube/pkg/multicluster"
+	"github.com/kubecube-io/kubecube/pkg/multicluster/client/fake"
+	"github.com/kubecube-io/kubecube/pkg/notification"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+	"github.com/kubecube-io/kubecube/pkg/utils/md5util"
+)
+
+type recordSender struct {
+	sync.Mutex
+	messages []*notification.Message
+}
+
+func (s *recordSender) Send(_ context.Context, msg *notification.Message) error {
+	s.Lock()
+	defer s.Unlock()
+	s.messages = append(s.messages, msg)
+	return nil
+}
+
+func (s *recordSender) Messages() []*notification.Message {
+	s.Lock()
+	defer s.Unlock()
+	return append([]*notification.Message{}, s.messages...)
+}
+
+var _ = Describe("PwdReset", func() {
+
+	var (
+		router *gin.Engine
+		sender *recordSender
+	)
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		apis.AddToScheme(scheme)
+		alice := &userv1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: "alice"},
+			Spec:       userv1.UserSpec{Email: "alice@example.com", LoginType: userv1.NormalLogin, Password: md5util.GetMD5Salt("old")},
+		}
+		multicluster.InitFakeMultiClusterMgrWithOpts(&fake.Options{Scheme: scheme, Objs: []client.Object{alice}})
+		clients.InitCubeClientSetWithOpts(nil)
+
+		sender = &recordSender{}
+		notification.SetSender(sender)
+		passwordreset.Config.ResetURL = "https://kubecube.example.com/reset"
+
+		router = gin.New()
+		router.POST("/api/v1/cube/user/pwd/reset-request", user.RequestPwdReset)
+		router.POST("/api/v1/cube/user/pwd/reset", user.ConfirmPwdReset)
+	})
+
+	AfterEach(func() {
+		notification.SetSender(nil)
+		passwordreset.Config.ResetURL = ""
+	})
+
+	request := func(account string) int {
+		body, _ := json.Marshal(user.PwdResetRequest{Account: account})
+		return performRequest(router, http.MethodPost, "/api/v1/cube/user/pwd/reset-request", body).Code
+	}
+	confirm := func(name, token, password string) int {
+		body, _ := json.Marshal(user.PwdResetConfirm{UserName: name, Token: token, NewPassword: password})
+		return performRequest(router, http.MethodPost, "/api/v1/cube/user/pwd/reset", body).Code
+	}
+
+	It("reset password by mailed link", func() {
+		Expect(request("alice")).To(Equal(http.StatusOK))
+		Eventually(sender.Messages).Should(HaveLen(1))
+
+		link := regexp.MustCompile(`https://\S+`).FindString(sender.Messages()[0].Body)
+		u, err := url.Parse(link)
+		Expect(err).NotTo(HaveOccurred())
+		token := u.Query().Get("token")
+
+		// password policy is enforced before token is used
+		Expect(confirm("alice", token, "weak")).To(Equal(http.StatusBadRequest))
+		Expect(confirm("alice", token, "new-password1")).To(Equal(http.StatusOK))
+		Expect(confirm("alice", token, "new-password2")).To(Equal(http.StatusBadRequest))
+
+		alice := &userv1.User{}
+		err = clients.Interface().Kubernetes(constants.LocalCluster).Direct().Get(context.Background(), types.NamespacedName{Name: "alice"}, alice)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(alice.Spec.Password).To(Equal(md5util.GetMD5Salt("new-password1")))
+	})
+
+	It("responds same whether user exists or not", func() {
+		Expect(request("nobody")).To(Equal(http.StatusOK))
+		Expect(request("nobody@example.com")).To(Equal(http.StatusOK))
+		Consistently(sender.Messages).Should(BeEmpty())
+		Expect(confirm("nobody", "token", "new-password1")).To(Equal(confirm("alice", "token", "new-password1")))
+	})
+
+	It("is disabled without reset url", func() {
+		passwordreset.Config.ResetURL = ""
+		Expect(request("alice")).To(Equal(http.StatusBadRequest))
+	})
+})
@@ -0,0 +1,540 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package user
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	tenantv1 "github.com/kubecube-io/kubecube/pkg/apis/tenant/v1"
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/apiserver/cubeapi/key"
+	"github.com/kubecube-io/kubecube/pkg/authentication/session"
+	"github.com/kubecube-io/kubecube/pkg/authorizer/rbac"
+	"github.com/kubecube-io/kubecube/pkg/clients"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/utils/audit"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+	"github.com/kubecube-io/kubecube/pkg/utils/errcode"
+	"github.com/kubecube-io/kubecube/pkg/utils/response"
+)
+
+const (
+	robotUserNamePrefix = "robot-"
+	dnsNamePattern      = `^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	resourceTypeRobot   = "robot"
+)
+
+// RobotParam is the body to create or update robot account
+type RobotParam struct {
+	// Name is the name of robot, the robot account name will be prefixed with robot-
+	Name        string `json:"name,omitempty"`
+	DisplayName string `json:"displayName,omitempty"`
+	// Owner is the tenant or project robot belongs to, can not be modified
+	Owner *userv1.AccountOwner `json:"owner,omitempty"`
+	// State is used to forbid robot account
+	State         userv1.UserState      `json:"state,omitempty"`
+	ScopeBindings []userv1.ScopeBinding `json:"scopeBindings,omitempty"`
+}
+
+type RobotList struct {
+	Total int        `json:"total"`
+	Items []UserItem `json:"items"`
+}
+
+// CreateRobot create robot account
+// @Summary create robot
+// @Description create robot account owned by tenant or project for CI systems
+// @Tags robot
+// @Param robot body RobotParam true "robot information"
+// @Success 200 {object} UserItem
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/robots [post]
+func CreateRobot(c *gin.Context) {
+	param := &RobotParam{}
+	if err := c.ShouldBindJSON(param); err != nil {
+		clog.Error("parse create robot body error: %s", err)
+		response.FailReturn(c, errcode.InvalidBodyFormat)
+		return
+	}
+	if len(param.Name) == 0 {
+		response.FailReturn(c, errcode.MissingParamUserName)
+		return
+	}
+	if !regexp.MustCompile(dnsNamePattern).MatchString(param.Name) {
+		response.FailReturn(c, errcode.InvalidParameterName)
+		return
+	}
+	if param.Owner == nil {
+		response.FailReturn(c, errcode.ParamsMissing("owner"))
+		return
+	}
+
+	ownerRef, errInfo := allowManageRobots(c, param.Owner)
+	if errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+	if errInfo = checkRobotBindings(c, param.Owner, param.ScopeBindings); errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+
+	name := robotUserNamePrefix + param.Name
+	found, errInfo := GetUserByName(c, name)
+	if errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+	if found != nil {
+		response.FailReturn(c, errcode.UserNameDuplicated(name))
+		return
+	}
+
+	// robot will be deleted with its owner
+	robot := &userv1.User{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			OwnerReferences: []metav1.OwnerReference{*ownerRef},
+		},
+		Spec: userv1.UserSpec{
+			DisplayName:   param.DisplayName,
+			State:         userv1.NormalState,
+			ScopeBindings: param.ScopeBindings,
+			AccountType:   userv1.RobotAccount,
+			Owner:         param.Owner,
+		},
+	}
+	if errInfo = CreateUserImpl(c, robot); errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+
+	c = audit.SetAuditInfo(c, audit.CreateRobot, robot.Name, param)
+	response.SuccessReturn(c, UserItem{Name: robot.Name, Spec: robot.Spec})
+}
+
+// ListRobots list robot accounts of tenant or project
+// @Summary list robots
+// @Description list robot accounts owned by specified tenant or project
+// @Tags robot
+// @Param tenant query string false "tenant name"
+// @Param project query string false "project name"
+// @Success 200 {object} RobotList
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/robots [get]
+func ListRobots(c *gin.Context) {
+	owner := &userv1.AccountOwner{ScopeType: userv1.TenantScope, ScopeName: c.Query("tenant")}
+	if project := c.Query("project"); len(project) > 0 {
+		owner = &userv1.AccountOwner{ScopeType: userv1.ProjectScope, ScopeName: project}
+	}
+	if len(owner.ScopeName) == 0 {
+		response.FailReturn(c, errcode.ParamsMissing("tenant or project"))
+		return
+	}
+
+	if _, errInfo := allowManageRobots(c, owner); errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+
+	userList := &userv1.UserList{}
+	err := clients.Interface().Kubernetes(constants.LocalCluster).Cache().List(c.Request.Context(), userList)
+	if err != nil {
+		clog.Error("list users from k8s error: %s", err)
+		response.FailReturn(c, errcode.GetResourceError(resourceTypeRobot))
+		return
+	}
+
+	res := RobotList{Items: []UserItem{}}
+	for _, u := range userList.Items {
+		if !u.IsRobot() || u.Spec.Owner == nil || *u.Spec.Owner != *owner {
+			continue
+		}
+		res.Items = append(res.Items, UserItem{Name: u.Name, Spec: u.Spec, Status: u.Status})
+	}
+	res.Total = len(res.Items)
+
+	response.SuccessReturn(c, res)
+}
+
+// UpdateRobot update robot account
+// @Summary update robot
+// @Description update display name, state and scope bindings of robot account
+// @Tags robot
+// @Param robot path string true "robot account name"
+// @Param param body RobotParam true "robot information"
+// @Success 200 {object} response.SuccessInfo
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/robots/{robot} [put]
+func UpdateRobot(c *gin.Context) {
+	param := &RobotParam{}
+	if err := c.ShouldBindJSON(param); err != nil {
+		clog.Error("parse update robot body error: %s", err)
+		response.FailReturn(c, errcode.InvalidBodyFormat)
+		return
+	}
+
+	robot, errInfo := getRobotForManage(c)
+	if errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+	if errInfo = checkRobotBindings(c, robot.Spec.Owner, param.ScopeBindings); errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+
+	originState := robot.Spec.State
+	if len(param.DisplayName) > 0 {
+		robot.Spec.DisplayName = param.DisplayName
+	}
+	if param.State == userv1.NormalState || param.State == userv1.ForbiddenState {
+		robot.Spec.State = param.State
+	}
+	if param.ScopeBindings != nil {
+		robot.Spec.ScopeBindings = param.ScopeBindings
+	}
+
+	if errInfo = UpdateUserSpecImpl(c, robot); errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+	if robot.Spec.State == userv1.ForbiddenState && originState != userv1.ForbiddenState {
+		revokeUserSessions(c, robot.Name, session.ReasonUserForbidden)
+	}
+
+	c = audit.SetAuditInfo(c, audit.UpdateRobot, robot.Name, param)
+	response.SuccessReturn(c, nil)
+}
+
+// DeleteRobot delete robot account
+// @Summary delete robot
+// @Description delete robot account and its keys
+// @Tags robot
+// @Param robot path string true "robot account name"
+// @Success 200 {object} response.SuccessInfo
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/robots/{robot} [delete]
+func DeleteRobot(c *gin.Context) {
+	robot, errInfo := getRobotForManage(c)
+	if errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+
+	// tokens of robot should not work anymore, keys are deleted by gc
+	revokeUserSessions(c, robot.Name, session.ReasonRevoked)
+
+	err := clients.Interface().Kubernetes(constants.LocalCluster).Direct().Delete(c.Request.Context(), robot)
+	if err != nil && !errors.IsNotFound(err) {
+		clog.Error("delete robot %v error: %s", robot.Name, err)
+		response.FailReturn(c, errcode.DealError(err))
+		return
+	}
+
+	c = audit.SetAuditInfo(c, audit.DeleteRobot, robot.Name, nil)
+	response.SuccessReturn(c, nil)
+}
+
+// CreateRobotKey create ak and sk for robot account
+// @Summary create robot key
+// @Description create ak & sk for robot account
+// @Tags robot
+// @Param robot path string true "robot account name"
+// @Param param body key.CreateKeyParam false "expire time and scope of key"
+// @Success 200 {object} map[string]string "{"accessKey":"xxx","secretKey":"xxx"}"
+// @Failure 403 {object} errcode.ErrorInfo
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/robots/{robot}/keys [post]
+func CreateRobotKey(c *gin.Context) {
+	// keys outlive impersonation, so they can not be created by impersonator
+	if len(c.GetString(constants.Impersonator)) > 0 {
+		response.FailReturn(c, errcode.ForbiddenErr)
+		return
+	}
+
+	param := key.CreateKeyParam{}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&param); err != nil {
+			response.FailReturn(c, errcode.InvalidBodyFormat)
+			return
+		}
+	}
+
+	robot, errInfo := getRobotForManage(c)
+	if errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+
+	// keys will be deleted with robot
+	owner := metav1.NewControllerRef(robot, userv1.GroupVersion.WithKind("User"))
+	result, errInfo := key.CreateKeyForUser(c.Request.Context(), robot.Name, param, owner)
+	if errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+
+	c = audit.SetAuditInfo(c, audit.CreateRobotKey, robot.Name, param)
+	response.SuccessReturn(c, result)
+}
+
+// ListRobotKeys list keys of robot account
+// @Summary list robot keys
+// @Description list keys of robot account
+// @Tags robot
+// @Param robot path string true "robot account name"
+// @Success 200 {object} v1.KeyList
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/robots/{robot}/keys [get]
+func ListRobotKeys(c *gin.Context) {
+	robot, errInfo := getRobotForManage(c)
+	if errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+
+	keyList := userv1.KeyList{}
+	err := clients.Interface().Kubernetes(constants.LocalCluster).Cache().List(c.Request.Context(), &keyList, client.MatchingLabels{key.UserLabel: robot.Name})
+	if err != nil {
+		response.FailReturn(c, errcode.BadRequest(err))
+		return
+	}
+
+	response.SuccessReturn(c, keyList)
+}
+
+// DeleteRobotKey delete key of robot account
+// @Summary delete robot key
+// @Description delete key of robot account, tokens issued by the key are revoked
+// @Tags robot
+// @Param robot path string true "robot account name"
+// @Param key path string true "access key"
+// @Success 200 {object} response.SuccessInfo
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/robots/{robot}/keys/{key} [delete]
+func DeleteRobotKey(c *gin.Context) {
+	robot, errInfo := getRobotForManage(c)
+	if errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+
+	ctx := c.Request.Context()
+	cli := clients.Interface().Kubernetes(constants.LocalCluster)
+	accessKey := c.Param("key")
+
+	keyInfo := &userv1.Key{}
+	err := cli.Cache().Get(ctx, types.NamespacedName{Name: accessKey}, keyInfo)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			response.FailReturn(c, errcode.KeyNotExistErr)
+			return
+		}
+		response.FailReturn(c, errcode.BadRequest(err))
+		return
+	}
+	if keyInfo.Spec.User != robot.Name {
+		response.FailReturn(c, errcode.NotMatchErr)
+		return
+	}
+
+	err = cli.Direct().Delete(ctx, keyInfo)
+	if err != nil && !errors.IsNotFound(err) {
+		response.FailReturn(c, errcode.BadRequest(err))
+		return
+	}
+	if err = session.NewManager(cli).RevokeByKey(ctx, accessKey, session.ReasonKeyInvalid); err != nil {
+		clog.Warn("revoke sessions of key %v failed: %v", accessKey, err)
+	}
+
+	c = audit.SetAuditInfo(c, audit.DeleteRobotKey, accessKey, nil)
+	response.SuccessReturn(c, nil)
+}
+
+// getRobotForManage returns the robot specified by path param
+// if request user can manage it
+func getRobotForManage(c *gin.Context) (*userv1.User, *errcode.ErrorInfo) {
+	robot, errInfo := GetUserByName(c, c.Param("robot"))
+	if errInfo != nil {
+		return nil, errInfo
+	}
+	if robot == nil || !robot.IsRobot() || robot.Spec.Owner == nil {
+		return nil, errcode.UserNotExist
+	}
+	if _, errInfo = allowManageRobots(c, robot.Spec.Owner); errInfo != nil {
+		return nil, errInfo
+	}
+	return robot, nil
+}
+
+// allowManageRobots checks if request user is admin of the owner of
+// robots, returns the owner reference of robots if allowed.
+func allowManageRobots(c *gin.Context, owner *userv1.AccountOwner) (*metav1.OwnerReference, *errcode.ErrorInfo) {
+	ctx := c.Request.Context()
+	cli := clients.Interface().Kubernetes(constants.LocalCluster).Cache()
+
+	requester, errInfo := GetUserByName(c, c.GetString(constants.UserName))
+	if errInfo != nil {
+		return nil, errInfo
+	}
+	if requester == nil {
+		return nil, errcode.ForbiddenErr
+	}
+
+	var (
+		ownerObj client.Object
+		kind     string
+		resolver = rbac.NewDefaultResolver(constants.LocalCluster)
+		allowed  = userv1.IsPlatformAdmin(requester)
+	)
+	switch owner.ScopeType {
+	case userv1.TenantScope:
+		tenant := &tenantv1.Tenant{}
+		if err := cli.Get(ctx, types.NamespacedName{Name: owner.ScopeName}, tenant); err != nil {
+			return nil, ownerGetError(err, owner)
+		}
+		allowed = allowed || hasScopeRole(resolver, requester.Name, userv1.TenantScope, tenant.Name, constants.TenantAdmin)
+		ownerObj, kind = tenant, "Tenant"
+	case userv1.ProjectScope:
+		project := &tenantv1.Project{}
+		if err := cli.Get(ctx, types.NamespacedName{Name: owner.ScopeName}, project); err != nil {
+			return nil, ownerGetError(err, owner)
+		}
+		allowed = allowed || hasScopeRole(resolver, requester.Name, userv1.ProjectScope, project.Name, constants.ProjectAdmin) ||
+			hasScopeRole(resolver, requester.Name, userv1.TenantScope, project.Labels[constants.TenantLabel], constants.TenantAdmin)
+		ownerObj, kind = project, "Project"
+	default:
+		return nil, errcode.ParamsInvalid(fmt.Errorf("owner of robot must be tenant or project"))
+	}
+
+	if !allowed {
+		return nil, errcode.ForbiddenErr
+	}
+
+	ownerRef := metav1.NewControllerRef(ownerObj, tenantv1.GroupVersion.WithKind(kind))
+	// the owner can be deleted before robots
+	ownerRef.BlockOwnerDeletion = nil
+	return ownerRef, nil
+}
+
+// checkRobotBindings ensures robot can only be bound within its owner, and
+// roles of robot are no higher than roles of request user in the scopes
+func checkRobotBindings(c *gin.Context, owner *userv1.AccountOwner, bindings []userv1.ScopeBinding) *errcode.ErrorInfo {
+	cli := clients.Interface().Kubernetes(constants.LocalCluster).Cache()
+	requester, errInfo := GetUserByName(c, c.GetString(constants.UserName))
+	if errInfo != nil {
+		return errInfo
+	}
+	if requester == nil {
+		return errcode.ForbiddenErr
+	}
+	resolver := rbac.NewDefaultResolver(constants.LocalCluster)
+
+	for _, b := range bindings {
+		if len(b.ScopeName) == 0 || len(b.Role) == 0 {
+			return errcode.ParamsInvalid(fmt.Errorf("scope name and role of binding are required"))
+		}
+		var tenant string
+		switch b.ScopeType {
+		case userv1.TenantScope:
+			tenant = b.ScopeName
+		case userv1.ProjectScope:
+			project := &tenantv1.Project{}
+			if err := cli.Get(c.Request.Context(), types.NamespacedName{Name: b.ScopeName}, project); err != nil {
+				return ownerGetError(err, &userv1.AccountOwner{ScopeType: b.ScopeType, ScopeName: b.ScopeName})
+			}
+			tenant = project.Labels[constants.TenantLabel]
+		}
+
+		// tenant robot can be bound to projects of the tenant
+		inOwner := (b.ScopeType == owner.ScopeType && b.ScopeName == owner.ScopeName) ||
+			(owner.ScopeType == userv1.TenantScope && b.ScopeType == userv1.ProjectScope && tenant == owner.ScopeName)
+		if !inOwner {
+			return errcode.ParamsInvalid(fmt.Errorf("robot of %v %v can not be bound to %v %v", owner.ScopeType, owner.ScopeName, b.ScopeType, b.ScopeName))
+		}
+
+		if !userv1.IsPlatformAdmin(requester) && !canGrantRole(resolver, requester.Name, b, tenant) {
+			return errcode.ForbiddenErr
+		}
+	}
+	return nil
+}
+
+// roleRanks orders built-in roles, the higher role covers the lower ones
+var roleRanks = map[string]int{
+	constants.Reviewer:     1,
+	constants.ProjectAdmin: 2,
+	constants.TenantAdmin:  3,
+}
+
+// canGrantRole tells if user can grant role of binding to robot, that is
+// the role is no higher than role of user in the scope. Roles other than
+// built-in ones can only be granted by users bound to them in the scope.
+func canGrantRole(resolver rbac.Interface, user string, b userv1.ScopeBinding, tenant string) bool {
+	rank, ok := roleRanks[b.Role]
+	if !ok {
+		return hasScopeRole(resolver, user, b.ScopeType, b.ScopeName, b.Role)
+	}
+	if hasScopeRole(resolver, user, userv1.TenantScope, tenant, constants.TenantAdmin) {
+		return true
+	}
+	for role, r := range roleRanks {
+		if r >= rank && hasScopeRole(resolver, user, b.ScopeType, b.ScopeName, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasScopeRole tells if user is bound to role in scope, bindings of groups
+// of user are resolved too
+func hasScopeRole(resolver rbac.Interface, user string, scopeType userv1.BindingScopeType, scopeName, role string) bool {
+	if len(scopeName) == 0 {
+		return false
+	}
+	namespace := constants.TenantNsPrefix + scopeName
+	if scopeType == userv1.ProjectScope {
+		namespace = constants.ProjectNsPrefix + scopeName
+	}
+	users, err := resolver.UsersFor(rbacv1.RoleRef{APIGroup: constants.K8sGroupRBAC, Kind: constants.KindClusterRole, Name: role}, namespace)
+	if err != nil {
+		clog.Warn("resolve users of %v in namespace %v failed: %v", role, namespace, err)
+	}
+	for _, u := range users {
+		if u.Name == user {
+			return true
+		}
+	}
+	return false
+}
+
+func ownerGetError(err error, owner *userv1.AccountOwner) *errcode.ErrorInfo {
+	if errors.IsNotFound(err) {
+		return errcode.ParamsInvalid(fmt.Errorf("%v %v not found", owner.ScopeType, owner.ScopeName))
+	}
+	clog.Error("get %v %v failed: %v", owner.ScopeType, owner.ScopeName, err)
+	return errcode.BadRequest(err)
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package user_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubecube-io/kubecube/pkg/apis"
+	tenantv1 "github.com/kubecube-io/kubecube/pkg/apis/tenant/v1"
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/apiserver/cubeapi/user"
+	"github.com/kubecube-io/kubecube/pkg/clients"
+	"github.com/kubecube-io/kubecube/pkg/multicluster"
+	"github.com/kubecube-io/kubecube/pkg/multicluster/client/fake"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+var _ = Describe("Robot", func() {
+
+	var (
+		tenantAdmin  *userv1.User
+		projectAdmin *userv1.User
+		devs         *userv1.Group
+		bindings     []client.Object
+		tenant1      *tenantv1.Tenant
+		project1     *tenantv1.Project
+		project2     *tenantv1.Project
+		requester    string
+		impersonator string
+		router       *gin.Engine
+	)
+
+	roleBinding := func(namespace, role string, subject rbacv1.Subject) *rbacv1.RoleBinding {
+		return &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: subject.Name + "-" + role, Namespace: namespace},
+			RoleRef:    rbacv1.RoleRef{APIGroup: constants.K8sGroupRBAC, Kind: constants.KindClusterRole, Name: role},
+			Subjects:   []rbacv1.Subject{subject},
+		}
+	}
+
+	BeforeEach(func() {
+		tenantAdmin = &userv1.User{ObjectMeta: metav1.ObjectMeta{Name: "tenant-admin-user"}}
+		projectAdmin = &userv1.User{ObjectMeta: metav1.ObjectMeta{Name: "project-admin-user"}}
+		// project admin is bound by group
+		devs = &userv1.Group{
+			ObjectMeta: metav1.ObjectMeta{Name: "devs"},
+			Spec:       userv1.GroupSpec{Members: []string{"project-admin-user"}},
+		}
+		bindings = []client.Object{
+			roleBinding(constants.TenantNsPrefix+"tenant-1", constants.TenantAdmin, rbacv1.Subject{Kind: rbacv1.UserKind, Name: "tenant-admin-user"}),
+			roleBinding(constants.ProjectNsPrefix+"project-1", constants.ProjectAdmin, rbacv1.Subject{Kind: rbacv1.GroupKind, Name: "devs"}),
+		}
+		requester = "tenant-admin-user"
+		impersonator = ""
+		tenant1 = &tenantv1.Tenant{ObjectMeta: metav1.ObjectMeta{Name: "tenant-1"}}
+		project1 = &tenantv1.Project{ObjectMeta: metav1.ObjectMeta{Name: "project-1", Labels: map[string]string{constants.TenantLabel: "tenant-1"}}}
+		project2 = &tenantv1.Project{ObjectMeta: metav1.ObjectMeta{Name: "project-2", Labels: map[string]string{constants.TenantLabel: "tenant-2"}}}
+	})
+
+	JustBeforeEach(func() {
+		scheme := runtime.NewScheme()
+		apis.AddToScheme(scheme)
+		corev1.AddToScheme(scheme)
+		rbacv1.AddToScheme(scheme)
+		opts := &fake.Options{
+			Scheme:               scheme,
+			Objs:                 append([]client.Object{tenantAdmin, projectAdmin, devs, tenant1, project1, project2}, bindings...),
+			ClientSetRuntimeObjs: []runtime.Object{},
+			Lists:                []client.ObjectList{},
+		}
+		multicluster.InitFakeMultiClusterMgrWithOpts(opts)
+		clients.InitCubeClientSetWithOpts(nil)
+
+		router = gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Set(constants.UserName, requester)
+			if len(impersonator) > 0 {
+				c.Set(constants.Impersonator, impersonator)
+			}
+		})
+		router.POST("/api/v1/cube/robots", user.CreateRobot)
+		router.POST("/api/v1/cube/robots/:robot/keys", user.CreateRobotKey)
+		router.GET("/api/v1/cube/robots", user.ListRobots)
+		router.POST("/api/v1/cube/login", user.Login)
+	})
+
+	createRobot := func(param user.RobotParam) int {
+		body, _ := json.Marshal(param)
+		return performRequest(router, http.MethodPost, "/api/v1/cube/robots", body).Code
+	}
+
+	It("create robot owned by tenant", func() {
+		code := createRobot(user.RobotParam{
+			Name:          "ci",
+			Owner:         &userv1.AccountOwner{ScopeType: userv1.TenantScope, ScopeName: "tenant-1"},
+			ScopeBindings: []userv1.ScopeBinding{{ScopeType: userv1.ProjectScope, ScopeName: "project-1", Role: constants.ProjectAdmin}},
+		})
+		Expect(code).To(Equal(http.StatusOK))
+
+		robot := &userv1.User{}
+		err := clients.Interface().Kubernetes(constants.LocalCluster).Direct().Get(context.Background(), client.ObjectKey{Name: "robot-ci"}, robot)
+		Expect(err).To(BeNil())
+		Expect(robot.IsRobot()).To(BeTrue())
+		Expect(robot.OwnerReferences).To(HaveLen(1))
+		Expect(robot.OwnerReferences[0].Name).To(Equal("tenant-1"))
+
+		w := performRequest(router, http.MethodGet, "/api/v1/cube/robots?tenant=tenant-1", nil)
+		Expect(w.Code).To(Equal(http.StatusOK))
+		list := &user.RobotList{}
+		Expect(json.Unmarshal(w.Body.Bytes(), list)).To(BeNil())
+		Expect(list.Total).To(Equal(1))
+	})
+
+	It("reject binding out of owner", func() {
+		code := createRobot(user.RobotParam{
+			Name:          "ci",
+			Owner:         &userv1.AccountOwner{ScopeType: userv1.TenantScope, ScopeName: "tenant-1"},
+			ScopeBindings: []userv1.ScopeBinding{{ScopeType: userv1.ProjectScope, ScopeName: "project-2", Role: constants.ProjectAdmin}},
+		})
+		Expect(code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("reject robot of other tenant", func() {
+		code := createRobot(user.RobotParam{
+			Name:  "ci",
+			Owner: &userv1.AccountOwner{ScopeType: userv1.ProjectScope, ScopeName: "project-2"},
+		})
+		Expect(code).To(Equal(http.StatusForbidden))
+	})
+
+	It("project admin by group manages robots of project", func() {
+		requester = "project-admin-user"
+		code := createRobot(user.RobotParam{
+			Name:          "ci",
+			Owner:         &userv1.AccountOwner{ScopeType: userv1.ProjectScope, ScopeName: "project-1"},
+			ScopeBindings: []userv1.ScopeBinding{{ScopeType: userv1.ProjectScope, ScopeName: "project-1", Role: constants.Reviewer}},
+		})
+		Expect(code).To(Equal(http.StatusOK))
+	})
+
+	It("reject role higher than request user", func() {
+		requester = "project-admin-user"
+		code := createRobot(user.RobotParam{
+			Name:          "ci",
+			Owner:         &userv1.AccountOwner{ScopeType: userv1.ProjectScope, ScopeName: "project-1"},
+			ScopeBindings: []userv1.ScopeBinding{{ScopeType: userv1.ProjectScope, ScopeName: "project-1", Role: constants.TenantAdmin}},
+		})
+		Expect(code).To(Equal(http.StatusForbidden))
+	})
+
+	It("reject robot key created by impersonator", func() {
+		Expect(createRobot(user.RobotParam{
+			Name:  "ci",
+			Owner: &userv1.AccountOwner{ScopeType: userv1.TenantScope, ScopeName: "tenant-1"},
+		})).To(Equal(http.StatusOK))
+
+		impersonator = "admin"
+		w := performRequest(router, http.MethodPost, "/api/v1/cube/robots/robot-ci/keys", nil)
+		Expect(w.Code).To(Equal(http.StatusForbidden))
+
+		keys := &userv1.KeyList{}
+		Expect(clients.Interface().Kubernetes(constants.LocalCluster).Direct().List(context.Background(), keys)).To(BeNil())
+		Expect(keys.Items).To(BeEmpty())
+	})
+
+	It("robot can not login by password", func() {
+		Expect(createRobot(user.RobotParam{
+			Name:  "ci",
+			Owner: &userv1.AccountOwner{ScopeType: userv1.TenantScope, ScopeName: "tenant-1"},
+		})).To(Equal(http.StatusOK))
+
+		loginBytes, _ := json.Marshal(user.LoginInfo{Name: "robot-ci", Password: "", LoginType: "normal"})
+		w := performRequest(router, http.MethodPost, "/api/v1/cube/login", loginBytes)
+		Expect(w.Code).NotTo(Equal(http.StatusOK))
+	})
+})
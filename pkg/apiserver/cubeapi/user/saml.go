@@ -0,0 +1,222 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package user
+
+import (
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/authentication/authenticators/jwt"
+	"github.com/kubecube-io/kubecube/pkg/authentication/group"
+	"github.com/kubecube-io/kubecube/pkg/authentication/identityprovider"
+	"github.com/kubecube-io/kubecube/pkg/authentication/identityprovider/saml"
+	"github.com/kubecube-io/kubecube/pkg/clients"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+	"github.com/kubecube-io/kubecube/pkg/utils/errcode"
+	"github.com/kubecube-io/kubecube/pkg/utils/md5util"
+	"github.com/kubecube-io/kubecube/pkg/utils/response"
+)
+
+const (
+	samlUserNamePrefix = "saml-"
+
+	// samlRequestCookie keeps id of AuthnRequest until IdP posts response back
+	samlRequestCookie = "saml-request-id"
+	samlRequestMaxAge = 5 * 60
+)
+
+// SamlMetadata get metadata of saml service provider
+// @Summary saml sp metadata
+// @Description get metadata of kubecube as saml service provider, which should be registered into IdP
+// @Tags user
+// @Success 200 {string} string "metadata xml"
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/saml/metadata [get]
+func SamlMetadata(c *gin.Context) {
+	provider, errInfo := getSamlProvider(c)
+	if errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+	metadata, err := provider.Metadata()
+	if err != nil {
+		clog.Error("generate saml metadata failed: %v", err)
+		response.FailReturn(c, errcode.SamlProviderError)
+		return
+	}
+	c.Data(http.StatusOK, "application/samlmetadata+xml", metadata)
+}
+
+// SamlLogin redirect to saml IdP
+// @Summary saml login
+// @Description redirect browser to saml IdP with signed AuthnRequest
+// @Tags user
+// @Param redirect query string false "path redirected to after login"
+// @Success 302
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/saml/login [get]
+func SamlLogin(c *gin.Context) {
+	provider, errInfo := getSamlProvider(c)
+	if errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+	redirectURL, requestID, err := provider.AuthnRequestURL(safeRedirectPath(c.Query("redirect")))
+	if err != nil {
+		clog.Error("make saml authn request failed: %v", err)
+		response.FailReturn(c, errcode.SamlProviderError)
+		return
+	}
+
+	// IdP posts response back cross site, the cookie must be SameSite=None
+	c.SetSameSite(http.SameSiteNoneMode)
+	c.SetCookie(samlRequestCookie, requestID, samlRequestMaxAge, saml.AcsPath, "", true, true)
+	c.Redirect(http.StatusFound, redirectURL)
+}
+
+// SamlAcs saml assertion consumer service
+// @Summary saml acs
+// @Description validate saml response posted by IdP, create or update user and issue token
+// @Tags user
+// @Param SAMLResponse formData string true "saml response"
+// @Param RelayState formData string false "path redirected to after login"
+// @Success 302
+// @Failure 401 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/saml/acs [post]
+func SamlAcs(c *gin.Context) {
+	provider, errInfo := getSamlProvider(c)
+	if errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+
+	var requestIDs []string
+	if requestID, err := c.Cookie(samlRequestCookie); err == nil && requestID != "" {
+		requestIDs = append(requestIDs, requestID)
+	}
+	// request id can be used only once
+	c.SetSameSite(http.SameSiteNoneMode)
+	c.SetCookie(samlRequestCookie, "", -1, saml.AcsPath, "", true, true)
+
+	identity, err := provider.Authenticate(c.Request, requestIDs)
+	if err != nil {
+		clog.Warn("saml authenticate failed: %v", err)
+		response.FailReturn(c, errcode.AuthenticateError)
+		return
+	}
+	clog.Info("user %s auth success by saml", identity.GetUserName())
+
+	token, errInfo := samlSignIn(c, identity)
+	if errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+	bearerToken := jwt.BearerTokenPrefix + " " + token
+	c.SetSameSite(http.SameSiteDefaultMode)
+	c.SetCookie(constants.AuthorizationHeader, bearerToken, int(jwt.GetAuthJwtImpl().TokenExpireDuration), "/", "", false, true)
+
+	c.Redirect(http.StatusFound, safeRedirectPath(c.PostForm("RelayState")))
+}
+
+// samlSignIn logs in the local user mapped from identity and issues token for it.
+// Token must be signed for the local user rather than the name asserted by IdP,
+// otherwise an IdP asserting "admin" would act as local admin.
+func samlSignIn(c *gin.Context, identity identityprovider.Identity) (string, *errcode.ErrorInfo) {
+	user, errInfo := samlLogin(c, identity)
+	if errInfo != nil {
+		return "", errInfo
+	}
+
+	c.Set(constants.UserName, user.Name)
+	user.Status.LastLoginIP = c.ClientIP()
+	user.Status.LastLoginTime = &metav1.Time{Time: time.Now()}
+	if errInfo = UpdateUserStatusImpl(c, user); errInfo != nil {
+		return "", errInfo
+	}
+
+	token, err := generateSessionToken(c, user, user.Name, v1.SAMLLogin)
+	if err != nil {
+		clog.Warn(err.Error())
+		return "", errcode.AuthenticateError
+	}
+	return token, nil
+}
+
+// samlLogin creates user at first login, or updates email of user by assertion
+func samlLogin(c *gin.Context, identity identityprovider.Identity) (*v1.User, *errcode.ErrorInfo) {
+	name := samlUserNamePrefix + hex.EncodeToString([]byte(identity.GetUserName()))
+	user, errInfo := GetUserByName(c, name)
+	if errInfo != nil {
+		return nil, errInfo
+	}
+	if user != nil && user.Spec.State == v1.ForbiddenState {
+		return nil, errcode.UserIsDisabled
+	}
+
+	cli := clients.Interface().Kubernetes(constants.LocalCluster)
+	if user == nil {
+		user = &v1.User{}
+		user.Name = name
+		user.Spec.DisplayName = identity.GetUserName()
+		user.Spec.Email = identity.GetUserEmail()
+		user.Spec.LoginType = v1.SAMLLogin
+		user.Spec.Password = md5util.GetMD5Salt(uuid.New().String())
+		user.Labels = map[string]string{"name": identity.GetUserName()}
+		if errInfo = CreateUserImpl(c, user); errInfo != nil {
+			return nil, errInfo
+		}
+	} else if email := identity.GetUserEmail(); email != "" && email != user.Spec.Email {
+		user.Spec.Email = email
+		if err := cli.Direct().Update(c.Request.Context(), user); err != nil {
+			clog.Warn("update email of user %v failed: %v", user.Name, err)
+		}
+	}
+
+	// membership of saml groups follows assertion, failure should not block login
+	if err := group.SyncMembership(c.Request.Context(), cli, v1.SamlGroup, user.Name, identity.GetGroups()); err != nil {
+		clog.Warn("sync saml groups of user %v failed: %v", user.Name, err)
+	}
+	return user, nil
+}
+
+func getSamlProvider(c *gin.Context) (*saml.Provider, *errcode.ErrorInfo) {
+	if !saml.IsSamlOpen() {
+		return nil, errcode.SamlNotEnabled
+	}
+	provider, err := saml.GetProvider(c.Request.Context())
+	if err != nil {
+		clog.Error("init saml provider failed: %v", err)
+		return nil, errcode.SamlProviderError
+	}
+	return provider, nil
+}
+
+// safeRedirectPath only allows redirecting to path of kubecube itself
+func safeRedirectPath(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.Contains(path, "\\") {
+		return "/"
+	}
+	return path
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package user
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubecube-io/kubecube/pkg/apis"
+	v1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/authentication/authenticators/jwt"
+	"github.com/kubecube-io/kubecube/pkg/clients"
+	"github.com/kubecube-io/kubecube/pkg/multicluster"
+	"github.com/kubecube-io/kubecube/pkg/multicluster/client/fake"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+type fakeSamlIdentity struct {
+	name string
+}
+
+func (f *fakeSamlIdentity) GetUserName() string        { return f.name }
+func (f *fakeSamlIdentity) GetUserEmail() string       { return f.name + "@example.com" }
+func (f *fakeSamlIdentity) GetAccountId() string       { return "" }
+func (f *fakeSamlIdentity) GetGroup() string           { return "" }
+func (f *fakeSamlIdentity) GetGroups() []string        { return nil }
+func (f *fakeSamlIdentity) GetRespHeader() http.Header { return nil }
+
+func TestSamlSignIn(t *testing.T) {
+	scheme := runtime.NewScheme()
+	apis.AddToScheme(scheme)
+	multicluster.InitFakeMultiClusterMgrWithOpts(&fake.Options{Scheme: scheme, Objs: []client.Object{
+		&v1.User{ObjectMeta: metav1.ObjectMeta{Name: "admin"}, Status: v1.UserStatus{PlatformAdmin: true}},
+	}})
+	clients.InitCubeClientSetWithOpts(nil)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/cube/saml/acs", nil)
+
+	// IdP asserts name of local admin
+	token, errInfo := samlSignIn(c, &fakeSamlIdentity{name: "admin"})
+	if errInfo != nil {
+		t.Fatalf("sign in failed: %v", errInfo)
+	}
+
+	claims, err := jwt.GetAuthJwtImpl().ParseClaims(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := &v1.User{}
+	err = clients.Interface().Kubernetes(constants.LocalCluster).Direct().Get(context.Background(), types.NamespacedName{Name: samlUserNamePrefix + "61646d696e"}, created)
+	if err != nil {
+		t.Fatalf("saml user not created: %v", err)
+	}
+	if claims.UserInfo.Username != created.Name {
+		t.Errorf("token issued for %q, want %q", claims.UserInfo.Username, created.Name)
+	}
+
+	s := &v1.Session{}
+	err = clients.Interface().Kubernetes(constants.LocalCluster).Direct().Get(context.Background(), types.NamespacedName{Name: claims.Id}, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Spec.User != created.Name {
+		t.Errorf("session owned by %q, want %q", s.Spec.User, created.Name)
+	}
+}
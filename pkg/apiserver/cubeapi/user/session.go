@@ -0,0 +1,218 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package user
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"k8s.io/api/authentication/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/authentication/authenticators/jwt"
+	"github.com/kubecube-io/kubecube/pkg/authentication/session"
+	"github.com/kubecube-io/kubecube/pkg/clients"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/utils/access"
+	"github.com/kubecube-io/kubecube/pkg/utils/audit"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+	"github.com/kubecube-io/kubecube/pkg/utils/errcode"
+	"github.com/kubecube-io/kubecube/pkg/utils/response"
+)
+
+const resourceTypeSession = "session"
+
+type SessionList struct {
+	Total int              `json:"total"`
+	Items []userv1.Session `json:"items"`
+}
+
+func sessionManager() session.Manager {
+	return session.NewManager(clients.Interface().Kubernetes(constants.LocalCluster))
+}
+
+// generateSessionToken opens a new session for user and returns the
+// token belongs to it.
+func generateSessionToken(c *gin.Context, user *userv1.User, tokenUser string, loginType userv1.LoginType) (string, error) {
+	sessionID := uuid.NewString()
+	token, err := jwt.GetAuthJwtImpl().GenerateTokenForSession(&v1beta1.UserInfo{Username: tokenUser}, sessionID)
+	if err != nil {
+		return "", err
+	}
+
+	s := &userv1.Session{}
+	s.Name = sessionID
+	s.Spec = userv1.SessionSpec{
+		User:      user.Name,
+		LoginType: loginType,
+		ClientIP:  c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+	// the token still works without session, so failed to record session should not block login
+	if err = sessionManager().Create(c.Request.Context(), s); err != nil {
+		clog.Warn("create session for user %v failed: %v", user.Name, err)
+	}
+
+	return token, nil
+}
+
+// revokeUserSessions revokes all sessions of user, failures are logged only
+// because the change of user already took effect.
+func revokeUserSessions(c *gin.Context, user string, reason string) {
+	if err := sessionManager().RevokeAll(c.Request.Context(), user, reason); err != nil {
+		clog.Error("revoke sessions of user %v failed: %v", user, err)
+	}
+}
+
+// allowManageSessions checks if request user can manage sessions of given user
+func allowManageSessions(c *gin.Context, user string) *errcode.ErrorInfo {
+	if access.IsSelf(c.Request, user) {
+		return nil
+	}
+	originUser, errInfo := GetUserByName(c, user)
+	if errInfo != nil {
+		return errInfo
+	}
+	if originUser == nil {
+		return errcode.UserNotExist
+	}
+	if !access.AllowAccess(constants.LocalCluster, c.Request, constants.UpdateVerb, originUser) {
+		return errcode.ForbiddenErr
+	}
+	return nil
+}
+
+// Logout logout current session
+// @Summary logout
+// @Description revoke the session of current token and clear cookie
+// @Tags user
+// @Success 200 {object} response.SuccessInfo
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/logout  [post]
+func Logout(c *gin.Context) {
+	userName := c.GetString(constants.UserName)
+	sessionID := c.GetString(constants.SessionID)
+
+	if len(sessionID) > 0 {
+		err := sessionManager().Revoke(c.Request.Context(), sessionID, session.ReasonLogout)
+		if err != nil && !errors.IsNotFound(err) {
+			clog.Error("revoke session %v of user %v failed: %v", sessionID, userName, err)
+			response.FailReturn(c, errcode.UpdateResourceError(resourceTypeSession))
+			return
+		}
+	}
+
+	c.SetCookie(constants.AuthorizationHeader, "", -1, "/", "", false, true)
+	c = audit.SetAuditInfo(c, audit.Logout, sessionID, nil)
+	response.SuccessReturn(c, nil)
+}
+
+// ListSessions list active sessions of user
+// @Summary list sessions
+// @Description list sessions of user which are neither revoked nor expired
+// @Tags user
+// @Param user query string false "user name, default to current user"
+// @Success 200 {object} SessionList
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/user/sessions  [get]
+func ListSessions(c *gin.Context) {
+	user := c.Query("user")
+	if len(user) == 0 {
+		user = c.GetString(constants.UserName)
+	}
+
+	if errInfo := allowManageSessions(c, user); errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+
+	sessions, err := sessionManager().ListActive(c.Request.Context(), user)
+	if err != nil {
+		clog.Error("list sessions of user %v failed: %v", user, err)
+		response.FailReturn(c, errcode.GetResourceError(resourceTypeSession))
+		return
+	}
+
+	response.SuccessReturn(c, SessionList{Total: len(sessions), Items: sessions})
+}
+
+// RevokeSession revoke the specified session
+// @Summary revoke session
+// @Description revoke the specified session, tokens of the session will be rejected
+// @Tags user
+// @Param session path string true "session name"
+// @Success 200 {object} response.SuccessInfo
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/user/sessions/{session}  [delete]
+func RevokeSession(c *gin.Context) {
+	name := c.Param("session")
+	sessions := sessionManager()
+
+	s, err := sessions.Get(c.Request.Context(), name)
+	if err != nil {
+		clog.Error("get session %v failed: %v", name, err)
+		response.FailReturn(c, errcode.GetResourceError(resourceTypeSession))
+		return
+	}
+	if s == nil {
+		response.FailReturn(c, errcode.NotFoundErr)
+		return
+	}
+
+	if errInfo := allowManageSessions(c, s.Spec.User); errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+
+	if err = sessions.Revoke(c.Request.Context(), name, session.ReasonRevoked); err != nil {
+		clog.Error("revoke session %v failed: %v", name, err)
+		response.FailReturn(c, errcode.UpdateResourceError(resourceTypeSession))
+		return
+	}
+
+	c = audit.SetAuditInfo(c, audit.RevokeSession, name, nil)
+	response.SuccessReturn(c, nil)
+}
+
+// RevokeSessions revoke all sessions of user
+// @Summary revoke sessions
+// @Description revoke all sessions of user, all tokens issued to user before will be rejected
+// @Tags user
+// @Param user query string false "user name, default to current user"
+// @Success 200 {object} response.SuccessInfo
+// @Failure 500 {object} errcode.ErrorInfo
+// @Router /api/v1/cube/user/sessions  [delete]
+func RevokeSessions(c *gin.Context) {
+	user := c.Query("user")
+	if len(user) == 0 {
+		user = c.GetString(constants.UserName)
+	}
+
+	if errInfo := allowManageSessions(c, user); errInfo != nil {
+		response.FailReturn(c, errInfo)
+		return
+	}
+
+	if err := sessionManager().RevokeAll(c.Request.Context(), user, session.ReasonRevoked); err != nil {
+		clog.Error("revoke sessions of user %v failed: %v", user, err)
+		response.FailReturn(c, errcode.UpdateResourceError(resourceTypeSession))
+		return
+	}
+
+	c = audit.SetAuditInfo(c, audit.RevokeSession, user, nil)
+	response.SuccessReturn(c, nil)
+}
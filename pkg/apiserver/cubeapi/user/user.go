@@ -31,6 +31,7 @@ import (
 	"k8s.io/api/authentication/v1beta1"
 	v1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -38,6 +39,7 @@ import (
 	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
 	proxy "github.com/kubecube-io/kubecube/pkg/apiserver/cubeapi/resourcemanage/handle"
 	"github.com/kubecube-io/kubecube/pkg/authentication/authenticators/jwt"
+	"github.com/kubecube-io/kubecube/pkg/authentication/session"
 	"github.com/kubecube-io/kubecube/pkg/clients"
 	"github.com/kubecube-io/kubecube/pkg/clog"
 	"github.com/kubecube-io/kubecube/pkg/multicluster"
@@ -153,6 +155,11 @@ func UpdateUser(c *gin.Context) {
 		response.FailReturn(c, errcode.UserNotExist)
 		return
 	}
+	// robot accounts are managed by robot api
+	if originUser.IsRobot() {
+		response.FailReturn(c, errcode.ForbiddenErr)
+		return
+	}
 
 	// if user want to update the other people`s info,need to check permission
 	if !access.IsSelf(c.Request, name) {
@@ -162,6 +169,9 @@ func UpdateUser(c *gin.Context) {
 		}
 	}
 
+	// CheckUpdateParam modifies origin user in place
+	originState, originPassword := originUser.Spec.State, originUser.Spec.Password
+
 	//check param
 	user, errInfo := CheckUpdateParam(newUser, originUser)
 	if errInfo != nil {
@@ -175,6 +185,14 @@ func UpdateUser(c *gin.Context) {
 		response.FailReturn(c, errcode.UpdateResourceError(resourceTypeUser))
 		return
 	}
+
+	// tokens issued before should not work anymore once user forbidden or password changed
+	if user.Spec.State == userv1.ForbiddenState && originState != userv1.ForbiddenState {
+		revokeUserSessions(c, user.Name, session.ReasonUserForbidden)
+	} else if user.Spec.Password != originPassword {
+		revokeUserSessions(c, user.Name, session.ReasonPasswordReset)
+	}
+
 	c = audit.SetAuditInfo(c, audit.UpdateUser, user.Name, user)
 	response.SuccessReturn(c, nil)
 	return
@@ -205,6 +223,7 @@ func UpdateUserStatusImpl(c *gin.Context, newUser *userv1.User) *errcode.ErrorIn
 // @Description fuzzy query user by name or displayName
 // @Tags user
 // @Param	query	query	string  false  "keyword for query"
+// @Param	accountType	query	string  false  "human or robot, default to human"
 // @Param	pageSize	query	int	false "page size"
 // @Param	pageNum		query	int	false	"page num"
 // @Success 200 {object} UserList
@@ -229,8 +248,12 @@ func ListUsers(c *gin.Context) {
 
 	// fuzzy query
 	query := c.Query("query")
+	accountType := userv1.AccountType(c.DefaultQuery("accountType", string(userv1.HumanAccount)))
 	var filterList = &userv1.UserList{}
 	for _, user := range allUserList.Items {
+		if user.IsRobot() != (accountType == userv1.RobotAccount) {
+			continue
+		}
 		if query == "" || strings.Contains(user.Spec.DisplayName, query) || strings.Contains(user.Name, query) {
 			var userResp userv1.User
 			userResp.Spec = user.Spec
@@ -312,7 +335,7 @@ func CheckAndCompleteCreateParam(c *gin.Context) (*userv1.User, *errcode.ErrorIn
 	if password == "" {
 		return user, errcode.MissingParamPassword
 	}
-	matched := checkPwd(password)
+	matched := CheckPwd(password)
 	if !matched {
 		return user, errcode.InvalidParameterPassword
 	}
@@ -382,7 +405,7 @@ func CheckUpdateParam(newUser *userv1.User, originUser *userv1.User) (*userv1.Us
 	// check password
 	newPassword := strings.TrimSpace(newUser.Spec.Password)
 	if newPassword != "" {
-		if !checkPwd(newPassword) {
+		if !CheckPwd(newPassword) {
 			return originUser, errcode.InvalidParameterPassword
 		}
 		originUser.Spec.Password = md5util.GetMD5Salt(newPassword)
@@ -560,7 +583,8 @@ func GetKubeConfig(c *gin.Context) {
 		return
 	}
 
-	if !access.IsSelf(c.Request, user) {
+	// kubeconfig outlives impersonation, so it can not be fetched by impersonator
+	if !access.IsSelf(c.Request, user) || len(c.GetString(constants.Impersonator)) > 0 {
 		response.FailReturn(c, errcode.ForbiddenErr)
 		return
 	}
@@ -616,14 +640,27 @@ func GetMembersByNS(c *gin.Context) {
 		return
 	}
 
-	res := getMembers(roleBindingList)
+	// members of groups bound in namespace are members too
+	groupList := userv1.GroupList{}
+	err = cli.Cache().List(ctx, &groupList)
+	if err != nil && !meta.IsNoMatchError(err) {
+		response.FailReturn(c, errcode.BadRequest(err))
+		return
+	}
+
+	res := getMembers(roleBindingList, groupList.Items)
 
 	clog.Debug("%v has members: %v", ns, res)
 
 	response.SuccessReturn(c, res)
 }
 
-func getMembers(roleBindingList v1.RoleBindingList) []string {
+func getMembers(roleBindingList v1.RoleBindingList, groups []userv1.Group) []string {
+	groupMembers := make(map[string][]string, len(groups))
+	for _, g := range groups {
+		groupMembers[g.Name] = g.Spec.Members
+	}
+
 	membersSet := sets.NewString()
 	for _, rb := range roleBindingList.Items {
 		// match roleBinding witch has specified annotation
@@ -640,6 +677,9 @@ func getMembers(roleBindingList v1.RoleBindingList) []string {
 					if s.Kind == "User" {
 						membersSet.Insert(s.Name)
 					}
+					if s.Kind == "Group" {
+						membersSet.Insert(groupMembers[s.Name]...)
+					}
 				}
 			}
 		}
@@ -649,10 +689,11 @@ func getMembers(roleBindingList v1.RoleBindingList) []string {
 }
 
 /**
- * the length of password is between 6~18
- * include at least two types of letters, numbers and special symbols
+ * CheckPwd tells if password meets the policy: the length of password is
+ * between 8~20 and includes at least two types of letters, numbers and
+ * special symbols
  */
-func checkPwd(pwd string) bool {
+func CheckPwd(pwd string) bool {
 	var (
 		isLetter  = false
 		isNumber  = false
@@ -725,13 +766,17 @@ func UpdatePwd(c *gin.Context) {
 		response.FailReturn(c, errInfo)
 		return
 	}
+	if user == nil || user.IsRobot() {
+		response.FailReturn(c, errcode.ForbiddenErr)
+		return
+	}
 	// check original password
 	if user.Spec.Password != oldPwdMd5 {
 		response.FailReturn(c, errcode.PasswordWrong)
 		return
 	}
 	// check new password
-	if !checkPwd(newPwd) {
+	if !CheckPwd(newPwd) {
 		response.FailReturn(c, errcode.InvalidParameterPassword)
 		return
 	}
@@ -742,6 +787,7 @@ func UpdatePwd(c *gin.Context) {
 		response.FailReturn(c, errcode.UpdateResourceError(resourceTypeUser))
 		return
 	}
+	revokeUserSessions(c, user.Name, session.ReasonPasswordReset)
 	response.SuccessReturn(c, nil)
 	return
 }
@@ -40,6 +40,7 @@ import (
 	"github.com/kubecube-io/kubecube/pkg/clog"
 	"github.com/kubecube-io/kubecube/pkg/multicluster"
 	"github.com/kubecube-io/kubecube/pkg/multicluster/client"
+	"github.com/kubecube-io/kubecube/pkg/utils/access"
 	"github.com/kubecube-io/kubecube/pkg/utils/constants"
 	"github.com/kubecube-io/kubecube/pkg/utils/errcode"
 	"github.com/kubecube-io/kubecube/pkg/utils/international"
@@ -78,6 +79,20 @@ func (h *Handler) Deploy(c *gin.Context) {
 		return
 	}
 
+	// tokens issued by scoped key only deploy resources within scope
+	scope := access.ScopeFromContext(c)
+	for _, obj := range objs.Objects {
+		allowed, err := access.AllowScope(c.Request.Context(), scope, cluster.Client.Cache(), clusterName, obj.GetNamespace(), http.MethodPost)
+		if err != nil {
+			response.FailReturn(c, errcode.BadRequest(err))
+			return
+		}
+		if !allowed {
+			response.FailReturn(c, errcode.ForbiddenErr)
+			return
+		}
+	}
+
 	var errs []error
 
 	for _, obj := range objs.Objects {
@@ -218,6 +218,39 @@ func (h *Handler) SendEvent(c *gin.Context, event *Event, options *Options) {
 	sendEvent(event)
 }
 
+// ReportEvent sends event which does not come from http request, such as
+// changes made by controllers. The event is reported as by KubeCube itself.
+func ReportEvent(event *Event) {
+	if !env.AuditIsEnable() || event == nil {
+		return
+	}
+	if event.EventTime == 0 {
+		event.EventTime = time.Now().UnixNano() / int64(time.Millisecond)
+	}
+	if len(event.EventVersion) == 0 {
+		event.EventVersion = "V1"
+	}
+	if len(event.UserAgent) == 0 {
+		event.UserAgent = constants.KubeCube
+	}
+	if len(event.EventType) == 0 {
+		event.EventType = constants.EventTypeUserWrite
+	}
+	if len(event.RequestId) == 0 {
+		event.RequestId = uuid.New().String()
+	}
+	if len(event.EventSource) == 0 {
+		event.EventSource = env.AuditEventSource()
+	}
+	if event.UserIdentity == nil {
+		event.UserIdentity = &UserIdentity{AccountId: constants.KubeCube, RealAccountId: constants.KubeCube}
+	}
+	if event.ResponseStatus == 0 {
+		event.ResponseStatus = http.StatusOK
+	}
+	go sendEvent(event)
+}
+
 func sendEvent(e *Event) {
 	clog.Debug("[audit] send event to audit service")
 	jsonstr, err := json.Marshal(e)
@@ -337,20 +370,23 @@ func getUserIdentity(c *gin.Context) *UserIdentity {
 	accountId := c.GetString(constants.EventAccountId)
 	if len(accountId) > 0 {
 		userIdentity.AccountId = accountId
-		return userIdentity
-	} else {
-		userName := c.GetString(constants.UserName)
-		if len(userName) > 0 {
-			userIdentity.AccountId = userName
-			return userIdentity
+	} else if userName := c.GetString(constants.UserName); len(userName) > 0 {
+		userIdentity.AccountId = userName
+	}
+	userIdentity.RealAccountId = c.GetString(constants.Impersonator)
+
+	if len(userIdentity.AccountId) == 0 {
+		claims, err := token.GetClaimsFromReq(c.Request)
+		if err != nil {
+			return nil
 		}
+		userIdentity.AccountId = claims.UserInfo.Username
+		userIdentity.RealAccountId = claims.Impersonator
 	}
 
-	userInfo, err := token.GetUserFromReq(c.Request)
-	if err != nil {
-		return nil
+	if len(userIdentity.RealAccountId) == 0 {
+		userIdentity.RealAccountId = userIdentity.AccountId
 	}
-	userIdentity.AccountId = userInfo.Username
 	return userIdentity
 }
 
@@ -59,7 +59,7 @@ func TestSendEvent(t *testing.T) {
 		RequestMethod:   http.MethodPost,
 		ResponseStatus:  http.StatusOK,
 		Url:             "/api/v1/cube/login",
-		UserIdentity:    &UserIdentity{"admin"},
+		UserIdentity:    &UserIdentity{AccountId: "admin", RealAccountId: "admin"},
 		UserAgent:       "HTTP",
 		EventType:       constants.EventTypeUserWrite,
 		RequestId:       uuid.New().String(),
@@ -68,6 +68,23 @@ func TestSendEvent(t *testing.T) {
 	sendEvent(e)
 }
 
+func TestGetUserIdentity(t *testing.T) {
+	var got *UserIdentity
+	router := gin.New()
+	router.POST("/api/v1/cube/user", func(c *gin.Context) {
+		c.Set(constants.UserName, "user1")
+		got = getUserIdentity(c)
+		c.Set(constants.Impersonator, "admin")
+		if identity := getUserIdentity(c); identity.AccountId != "user1" || identity.RealAccountId != "admin" {
+			t.Errorf("impersonated identity should carry both users, got %+v", identity)
+		}
+	})
+	_ = performRequest(router, http.MethodPost, "/api/v1/cube/user", nil)
+	if got == nil || got.AccountId != "user1" || got.RealAccountId != "user1" {
+		t.Fatalf("real identity should be the user itself, got %+v", got)
+	}
+}
+
 func TestGetEventName(t *testing.T) {
 	file, err := os.Getwd()
 	if err != nil {
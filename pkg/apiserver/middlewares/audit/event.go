@@ -40,7 +40,11 @@ type Event struct {
 }
 
 type UserIdentity struct {
+	// AccountId is the effective user the request acts as
 	AccountId string
+	// RealAccountId is the user who really sends the request, it differs
+	// from AccountId when a platform admin impersonates the user.
+	RealAccountId string
 }
 
 type Resource struct {
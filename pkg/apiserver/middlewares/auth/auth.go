@@ -20,13 +20,18 @@ import (
 	"net/http"
 	"net/url"
 	"regexp"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"k8s.io/api/authentication/v1beta1"
 
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
 	"github.com/kubecube-io/kubecube/pkg/authentication/authenticators/jwt"
 	"github.com/kubecube-io/kubecube/pkg/authentication/authenticators/token"
+	"github.com/kubecube-io/kubecube/pkg/authentication/group"
 	"github.com/kubecube-io/kubecube/pkg/authentication/identityprovider/generic"
+	"github.com/kubecube-io/kubecube/pkg/authentication/session"
+	"github.com/kubecube-io/kubecube/pkg/clients"
 	"github.com/kubecube-io/kubecube/pkg/clog"
 	"github.com/kubecube-io/kubecube/pkg/utils/constants"
 	"github.com/kubecube-io/kubecube/pkg/utils/errcode"
@@ -34,14 +39,40 @@ import (
 )
 
 var AuthWhiteList = map[string]string{
-	constants.ApiPathRoot + "/login":                http.MethodPost,
-	constants.ApiPathRoot + "/audit":                http.MethodPost,
-	constants.ApiPathRoot + "/key/token":            http.MethodGet,
-	constants.ApiPathRoot + "/authorization/access": http.MethodPost,
-	constants.ApiPathRoot + "/oauth/redirect":       http.MethodGet,
-	constants.ApiPathRoot + "/user/pwd":             http.MethodPut,
-	constants.ApiPathRoot + "/user/valid/:username": http.MethodGet,
-	constants.ApiPathRoot + "/clusters/register":    http.MethodPost,
+	constants.ApiPathRoot + "/login":                  http.MethodPost,
+	constants.ApiPathRoot + "/audit":                  http.MethodPost,
+	constants.ApiPathRoot + "/key/token":              http.MethodGet,
+	constants.ApiPathRoot + "/authorization/access":   http.MethodPost,
+	constants.ApiPathRoot + "/oauth/redirect":         http.MethodGet,
+	constants.ApiPathRoot + "/saml/metadata":          http.MethodGet,
+	constants.ApiPathRoot + "/saml/login":             http.MethodGet,
+	constants.ApiPathRoot + "/saml/acs":               http.MethodPost,
+	constants.ApiPathRoot + "/user/pwd":               http.MethodPut,
+	constants.ApiPathRoot + "/user/pwd/reset":         http.MethodPost,
+	constants.ApiPathRoot + "/user/pwd/reset-request": http.MethodPost,
+	constants.ApiPathRoot + "/user/valid/:username":   http.MethodGet,
+	constants.ApiPathRoot + "/clusters/register":      http.MethodPost,
+}
+
+// ScopeDenyList holds path prefixes of apis which manage identities and
+// permissions, tokens limited by scope can not call them at all, or they
+// could escape their scope by granting themselves more.
+var ScopeDenyList = []string{
+	constants.ApiPathRoot + "/user",
+	constants.ApiPathRoot + "/key",
+	constants.ApiPathRoot + "/robots",
+	constants.ApiPathRoot + "/groups",
+	constants.ApiPathRoot + "/authorization/bindings",
+	constants.ApiPathRoot + "/authorization/authitems",
+}
+
+// ScopeWriteAllowList holds path prefixes of apis which check the scope of
+// token against the cluster and namespace they change. Tokens limited by
+// scope can only write through them, so write apis added later are denied
+// to scoped tokens by default.
+var ScopeWriteAllowList = []string{
+	constants.ApiPathRoot + "/proxy/clusters",
+	constants.ApiPathRoot + "/extend/clusters",
 }
 
 func WithinWhiteList(url *url.URL, method string, whiteList map[string]string) bool {
@@ -80,19 +111,82 @@ func defaultAuth(c *gin.Context, authJwtImpl *jwt.AuthJwt) {
 		return
 	}
 
-	user, newToken, err := authJwtImpl.RefreshToken(userToken)
+	claims, err := authJwtImpl.ParseClaims(userToken)
 	if err != nil {
 		clog.Warn(err.Error())
 		response.FailReturn(c, errcode.AuthenticateError)
 		return
 	}
 
+	sessions := session.NewManager(clients.Interface().Kubernetes(constants.LocalCluster))
+	revoked, err := sessions.IsRevoked(c.Request.Context(), claims)
+	if err != nil {
+		clog.Warn("check session of user %v failed: %v", claims.UserInfo.Username, err)
+		response.FailReturn(c, errcode.AuthenticateError)
+		return
+	}
+	if revoked {
+		clog.Debug("token of user %v with session %v was revoked", claims.UserInfo.Username, claims.Id)
+		response.FailReturn(c, errcode.AuthenticateError)
+		return
+	}
+
+	if !allowScope(c.Request, claims.Scope) {
+		clog.Debug("request %v %v of user %v is out of token scope", c.Request.Method, c.Request.URL.Path, claims.UserInfo.Username)
+		response.FailReturn(c, errcode.ForbiddenErr)
+		return
+	}
+
+	newToken, err := authJwtImpl.RefreshClaims(claims)
+	if err != nil {
+		clog.Warn(err.Error())
+		response.FailReturn(c, errcode.AuthenticateError)
+		return
+	}
+
+	user := claims.UserInfo
 	v := jwt.BearerTokenPrefix + " " + newToken
 
 	c.Request.Header.Set(constants.AuthorizationHeader, v)
 	c.Request.Header.Set(constants.ImpersonateUserKey, user.Username)
 	c.SetCookie(constants.AuthorizationHeader, v, int(authJwtImpl.TokenExpireDuration), "/", "", false, true)
 	c.Set(constants.UserName, user.Username)
+	c.Set(constants.SessionID, claims.Id)
+	if claims.Scope != nil {
+		c.Set(constants.TokenScope, claims.Scope)
+	}
+	// the request acts as the impersonated user, the impersonator is kept for audit
+	if len(claims.Impersonator) > 0 {
+		c.Set(constants.Impersonator, claims.Impersonator)
+	}
+
+	if err = sessions.Touch(c.Request.Context(), claims.Id); err != nil {
+		clog.Warn("refresh active time of session %v failed: %v", claims.Id, err)
+	}
+}
+
+// allowScope tells if request is allowed for token with scope, finer checks
+// of clusters and namespaces are done where the target of request is known.
+func allowScope(req *http.Request, scope *userv1.KeyScope) bool {
+	if scope == nil {
+		return true
+	}
+	if hasPathPrefix(req.URL.Path, ScopeDenyList) {
+		return false
+	}
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		return true
+	}
+	return !scope.ReadOnly && hasPathPrefix(req.URL.Path, ScopeWriteAllowList)
+}
+
+func hasPathPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
 }
 
 func genericAuth(c *gin.Context, authJwtImpl *jwt.AuthJwt) {
@@ -123,4 +217,12 @@ func genericAuth(c *gin.Context, authJwtImpl *jwt.AuthJwt) {
 	}
 	c.Set(constants.UserName, user.GetUserName())
 	c.Set(constants.EventAccountId, user.GetAccountId())
+
+	// membership of oidc groups follows groups claim, failure should not block request
+	if groups := user.GetGroups(); groups != nil {
+		cli := clients.Interface().Kubernetes(constants.LocalCluster)
+		if err = group.SyncMembership(c.Request.Context(), cli, userv1.OidcGroup, user.GetUserName(), groups); err != nil {
+			clog.Warn("sync oidc groups of user %v failed: %v", user.GetUserName(), err)
+		}
+	}
 }
@@ -0,0 +1,84 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+)
+
+func TestAllowScope(t *testing.T) {
+	readOnly := &userv1.KeyScope{ReadOnly: true}
+	tenantOnly := &userv1.KeyScope{Tenants: []string{"tenant-1"}}
+
+	tests := []struct {
+		name   string
+		scope  *userv1.KeyScope
+		method string
+		path   string
+		want   bool
+	}{
+		{"no scope", nil, http.MethodPost, "/api/v1/cube/authorization/bindings", true},
+		{"read only get", readOnly, http.MethodGet, "/api/v1/cube/quota/tenant-1", true},
+		{"read only post", readOnly, http.MethodPost, "/api/v1/cube/accessrequests", false},
+		{"read only put", readOnly, http.MethodPut, "/api/v1/cube/quotarequests/r1/approve", false},
+		{"scoped create key", tenantOnly, http.MethodPost, "/api/v1/cube/key/create", false},
+		{"scoped delete key", tenantOnly, http.MethodDelete, "/api/v1/cube/key", false},
+		{"scoped update user", tenantOnly, http.MethodPut, "/api/v1/cube/user/alice", false},
+		{"scoped get kubeconfig", tenantOnly, http.MethodGet, "/api/v1/cube/user/kubeconfigs", false},
+		{"scoped create robot", tenantOnly, http.MethodPost, "/api/v1/cube/robots", false},
+		{"scoped update group", tenantOnly, http.MethodPut, "/api/v1/cube/groups/dev", false},
+		{"scoped create binding", tenantOnly, http.MethodPost, "/api/v1/cube/authorization/bindings", false},
+		{"scoped set auth items", tenantOnly, http.MethodPost, "/api/v1/cube/authorization/authitems", false},
+		{"scoped approve access request", tenantOnly, http.MethodPost, "/api/v1/cube/accessrequests/r1/approve", false},
+		{"scoped approve quota request", tenantOnly, http.MethodPost, "/api/v1/cube/quotarequests/r1/approve", false},
+		{"scoped recalculate quota", tenantOnly, http.MethodPost, "/api/v1/cube/quota/recalculate", false},
+		{"scoped get quota requests", tenantOnly, http.MethodGet, "/api/v1/cube/quotarequests", true},
+		{"scoped proxy", tenantOnly, http.MethodPost, "/api/v1/cube/proxy/clusters/pivot-cluster/api/v1/namespaces/ns/pods", true},
+		{"scoped extend", tenantOnly, http.MethodDelete, "/api/v1/cube/extend/clusters/pivot-cluster/namespaces/ns/deployments/d1", true},
+		{"scoped yaml deploy", tenantOnly, http.MethodPost, "/api/v1/cube/extend/clusters/pivot-cluster/yaml/deploy", true},
+		{"read only proxy", readOnly, http.MethodPost, "/api/v1/cube/proxy/clusters/pivot-cluster/api/v1/namespaces/ns/pods", false},
+		{"prefix of other api", tenantOnly, http.MethodGet, "/api/v1/cube/users-like", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if got := allowScope(req, tt.scope); got != tt.want {
+				t.Errorf("allowScope() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllowScopeDenyList(t *testing.T) {
+	scopes := []*userv1.KeyScope{{ReadOnly: true}, {Tenants: []string{"tenant-1"}}}
+	methods := []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete}
+	for _, prefix := range ScopeDenyList {
+		for _, path := range []string{prefix, prefix + "/any"} {
+			for _, method := range methods {
+				for _, scope := range scopes {
+					if allowScope(httptest.NewRequest(method, path, nil), scope) {
+						t.Errorf("scoped token %+v should be rejected on %v %v", scope, method, path)
+					}
+				}
+			}
+		}
+	}
+}
@@ -21,8 +21,10 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
 	"k8s.io/api/authentication/v1beta1"
 
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
 	"github.com/kubecube-io/kubecube/pkg/authentication"
 	"github.com/kubecube-io/kubecube/pkg/clog"
 	"github.com/kubecube-io/kubecube/pkg/utils/constants"
@@ -44,6 +46,10 @@ type AuthJwt struct {
 
 type Claims struct {
 	UserInfo v1beta1.UserInfo
+	// Scope limits what the token can access, no limit if nil
+	Scope *userv1.KeyScope `json:"scope,omitempty"`
+	// Impersonator is the real user acts as UserInfo, empty if not impersonated
+	Impersonator string `json:"impersonator,omitempty"`
 	jwt.StandardClaims
 }
 
@@ -63,24 +69,66 @@ func (a *AuthJwt) GenerateToken(user *v1beta1.UserInfo) (string, error) {
 }
 
 func (a *AuthJwt) GenerateTokenWithExpired(user *v1beta1.UserInfo, expireDuration int64) (string, error) {
-	var tokenExpireDuration int64 = constants.DefaultTokenExpireDuration
+	return a.signClaims(newClaims(user, uuid.NewString()), expireDuration)
+}
+
+// GenerateTokenForSession generates token carries given session id as jti,
+// the session id will be kept when the token refreshed.
+func (a *AuthJwt) GenerateTokenForSession(user *v1beta1.UserInfo, sessionID string) (string, error) {
+	return a.signClaims(newClaims(user, sessionID), 0)
+}
+
+// GenerateScopedTokenForSession generates token like GenerateTokenForSession
+// but limited by given scope.
+func (a *AuthJwt) GenerateScopedTokenForSession(user *v1beta1.UserInfo, sessionID string, scope *userv1.KeyScope) (string, error) {
+	claims := newClaims(user, sessionID)
+	claims.Scope = scope
+	return a.signClaims(claims, 0)
+}
+
+// GenerateImpersonationToken generates token of session that impersonator
+// acts as given user, the token expires at given time and never be extended.
+func (a *AuthJwt) GenerateImpersonationToken(user *v1beta1.UserInfo, impersonator string, sessionID string, expireAt time.Time) (string, error) {
+	claims := newClaims(user, sessionID)
+	claims.Impersonator = impersonator
+	claims.ExpiresAt = expireAt.Unix()
+	return a.sign(claims)
+}
+
+// ExpireDuration returns the seconds of token expired after issued.
+func (a *AuthJwt) ExpireDuration() int64 {
 	if a.TokenExpireDuration > 0 {
-		tokenExpireDuration = a.TokenExpireDuration
-	}
-	if expireDuration > 0 {
-		tokenExpireDuration = expireDuration
+		return a.TokenExpireDuration
 	}
+	return constants.DefaultTokenExpireDuration
+}
 
-	claims := Claims{
+func newClaims(user *v1beta1.UserInfo, id string) *Claims {
+	return &Claims{
 		UserInfo: v1beta1.UserInfo{
 			Username: user.Username,
 			Groups:   []string{constants.KubeCube},
 		},
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Unix() + tokenExpireDuration,
-			Issuer:    a.JwtIssuer,
+			Id:       id,
+			IssuedAt: time.Now().Unix(),
 		},
 	}
+}
+
+func (a *AuthJwt) signClaims(claims *Claims, expireDuration int64) (string, error) {
+	tokenExpireDuration := a.ExpireDuration()
+	if expireDuration > 0 {
+		tokenExpireDuration = expireDuration
+	}
+
+	claims.ExpiresAt = time.Now().Unix() + tokenExpireDuration
+	return a.sign(claims)
+}
+
+func (a *AuthJwt) sign(claims *Claims) (string, error) {
+	claims.Issuer = a.JwtIssuer
+
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	signedToken, signErr := token.SignedString([]byte(a.JwtSecret))
 	if signErr != nil {
@@ -91,6 +139,15 @@ func (a *AuthJwt) GenerateTokenWithExpired(user *v1beta1.UserInfo, expireDuratio
 }
 
 func (a *AuthJwt) Authentication(token string) (user *v1beta1.UserInfo, err error) {
+	claims, err := a.ParseClaims(token)
+	if err != nil {
+		return nil, err
+	}
+	return &claims.UserInfo, nil
+}
+
+// ParseClaims verifies the token and returns the claims it carried.
+func (a *AuthJwt) ParseClaims(token string) (*Claims, error) {
 	claims := &Claims{}
 
 	// Empty bearer tokens aren't valid
@@ -105,21 +162,32 @@ func (a *AuthJwt) Authentication(token string) (user *v1beta1.UserInfo, err erro
 		return nil, fmt.Errorf("parse token error, jwt secret: %v, token: %v, error: %v", a.JwtSecret, token, parseErr)
 	}
 	if claims, ok := newToken.Claims.(*Claims); ok && newToken.Valid {
-		return &claims.UserInfo, nil
+		return claims, nil
 	}
 	return nil, fmt.Errorf("invaild token")
 }
 
 func (a *AuthJwt) RefreshToken(token string) (*v1beta1.UserInfo, string, error) {
-	userInfo, err := a.Authentication(token)
+	claims, err := a.ParseClaims(token)
 	if err != nil {
 		return nil, "", err
 	}
 
-	newToken, err := a.GenerateToken(userInfo)
+	newToken, err := a.RefreshClaims(claims)
 	if err != nil {
 		return nil, "", err
 	}
 
-	return userInfo, newToken, nil
+	return &claims.UserInfo, newToken, nil
+}
+
+// RefreshClaims signs a new token for the verified claims, the jti, issued
+// time and scope are kept so that the refreshed token belongs to same session.
+// The expire time of impersonation token is kept too.
+func (a *AuthJwt) RefreshClaims(claims *Claims) (string, error) {
+	refreshed := *claims
+	if len(refreshed.Impersonator) > 0 {
+		return a.sign(&refreshed)
+	}
+	return a.signClaims(&refreshed, 0)
 }
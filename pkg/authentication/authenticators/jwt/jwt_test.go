@@ -18,6 +18,7 @@ package jwt
 
 import (
 	"testing"
+	"time"
 
 	"k8s.io/api/authentication/v1beta1"
 )
@@ -61,3 +62,59 @@ func TestRefreshToken(t *testing.T) {
 	}
 
 }
+
+func TestRefreshTokenForSession(t *testing.T) {
+
+	user1 := &v1beta1.UserInfo{Username: "test"}
+	token, err := GetAuthJwtImpl().GenerateTokenForSession(user1, "session-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := GetAuthJwtImpl().ParseClaims(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newToken, err := GetAuthJwtImpl().RefreshClaims(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newClaims, err := GetAuthJwtImpl().ParseClaims(newToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newClaims.Id != "session-1" || newClaims.IssuedAt != claims.IssuedAt {
+		t.Fail()
+	}
+
+}
+
+func TestRefreshImpersonationToken(t *testing.T) {
+
+	user1 := &v1beta1.UserInfo{Username: "test"}
+	expireAt := time.Now().Add(10 * time.Minute)
+	token, err := GetAuthJwtImpl().GenerateImpersonationToken(user1, "admin", "session-1", expireAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := GetAuthJwtImpl().ParseClaims(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserInfo.Username != "test" || claims.Impersonator != "admin" {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+
+	newToken, err := GetAuthJwtImpl().RefreshClaims(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newClaims, err := GetAuthJwtImpl().ParseClaims(newToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newClaims.Impersonator != "admin" || newClaims.ExpiresAt != expireAt.Unix() {
+		t.Fatalf("impersonation token should not be extended, got %+v", newClaims)
+	}
+
+}
@@ -60,15 +60,20 @@ func GetTokenFromReq(req *http.Request) (string, error) {
 }
 
 func GetUserFromReq(req *http.Request) (*v1beta1.UserInfo, error) {
-	token, err := GetTokenFromReq(req)
+	claims, err := GetClaimsFromReq(req)
 	if err != nil {
 		return nil, err
 	}
 
-	userInfo, err := jwt.GetAuthJwtImpl().Authentication(token)
+	return &claims.UserInfo, nil
+}
+
+// GetClaimsFromReq verifies the bearer token of request and returns its claims.
+func GetClaimsFromReq(req *http.Request) (*jwt.Claims, error) {
+	token, err := GetTokenFromReq(req)
 	if err != nil {
 		return nil, err
 	}
 
-	return userInfo, nil
+	return jwt.GetAuthJwtImpl().ParseClaims(token)
 }
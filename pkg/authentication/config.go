@@ -21,6 +21,7 @@ type Config struct {
 	LdapConfig
 	GenericConfig
 	GitHubConfig
+	SAMLConfig
 }
 
 func (c *Config) Validate() []error {
@@ -43,6 +44,8 @@ type LdapConfig struct {
 	LdapAdminUserAccount string `yaml:"ldapAdminUserAccount, omitempty"`
 	LdapAdminPassword    string `yaml:"ldapAdminPassword, omitempty"`
 	LdapIsEnable         bool   `yaml:"ldapIsEnable, omitempty"`
+	// LdapSyncPageSize is the page size of searching users in directory sync
+	LdapSyncPageSize int `yaml:"ldapSyncPageSize, omitempty"`
 }
 
 type GenericConfig struct {
@@ -55,6 +58,9 @@ type GenericConfig struct {
 	CACert              string
 	TLSCert             string
 	TLSKey              string
+	// GroupsClaim is the claim in response of generic auth that carries
+	// groups of user in oidc provider, groups are not synced if absent
+	GroupsClaim string
 }
 
 type GitHubConfig struct {
@@ -62,3 +68,23 @@ type GitHubConfig struct {
 	ClientID       string
 	ClientSecret   string
 }
+
+type SAMLConfig struct {
+	SAMLIsEnable bool
+	// EntityID of service provider, metadata url is used if empty
+	EntityID string
+	// RootURL is the external url of kubecube which IdP redirects browsers to
+	RootURL string
+	// IdPMetadataURL and IdPMetadataFile are where metadata of IdP comes from, url takes precedence
+	IdPMetadataURL  string
+	IdPMetadataFile string
+	// SPCert and SPKey are used to sign AuthnRequests and decrypt assertions
+	SPCert string
+	SPKey  string
+	// UsernameAttribute is the attribute of assertion used as username, NameID is used if empty
+	UsernameAttribute string
+	EmailAttribute    string
+	GroupsAttribute   string
+	// AllowIDPInitiated allows login initiated from IdP without AuthnRequest
+	AllowIDPInitiated bool
+}
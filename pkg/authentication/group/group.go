@@ -0,0 +1,87 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package group
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/retry"
+
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	mgrclient "github.com/kubecube-io/kubecube/pkg/multicluster/client"
+)
+
+// SyncMembership keeps membership of user in groups of given source consistent
+// with identity provider: user is added to the groups whose external name in
+// externalGroups, and removed from the other groups of source.
+func SyncMembership(ctx context.Context, cli mgrclient.Client, source userv1.GroupSource, user string, externalGroups []string) error {
+	groupList := &userv1.GroupList{}
+	err := cli.Cache().List(ctx, groupList)
+	if err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil
+		}
+		return err
+	}
+
+	want := sets.New[string](externalGroups...)
+	for _, g := range groupList.Items {
+		if g.GetSource() != source {
+			continue
+		}
+		isMember := want.Has(g.GetExternalName())
+		if isMember == g.HasMember(user) {
+			continue
+		}
+		if err = setMember(ctx, cli, g.Name, user, isMember); err != nil {
+			return err
+		}
+		clog.Info("sync membership of user %v in group %v: %v", user, g.Name, isMember)
+	}
+
+	return nil
+}
+
+// setMember adds user to or removes user from group
+func setMember(ctx context.Context, cli mgrclient.Client, group string, user string, isMember bool) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		g := &userv1.Group{}
+		err := cli.Direct().Get(ctx, types.NamespacedName{Name: group}, g)
+		if err != nil {
+			return err
+		}
+		if isMember == g.HasMember(user) {
+			return nil
+		}
+		if isMember {
+			g.Spec.Members = append(g.Spec.Members, user)
+		} else {
+			members := make([]string, 0, len(g.Spec.Members))
+			for _, m := range g.Spec.Members {
+				if m != user {
+					members = append(members, m)
+				}
+			}
+			g.Spec.Members = members
+		}
+		return cli.Direct().Update(ctx, g)
+	})
+}
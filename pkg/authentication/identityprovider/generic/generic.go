@@ -54,6 +54,8 @@ type GenericIdentity struct {
 	Username  string
 	Header    http.Header
 	AccountId string
+	// Groups are nil if groups claim is absent in response
+	Groups []string
 }
 
 func (g *GenericIdentity) GetRespHeader() http.Header {
@@ -73,6 +75,10 @@ func (g *GenericIdentity) GetGroup() string {
 	return ""
 }
 
+func (g *GenericIdentity) GetGroups() []string {
+	return g.Groups
+}
+
 func (g *GenericIdentity) GetAccountId() string {
 	return g.AccountId
 }
@@ -163,11 +169,40 @@ func (h *HeaderProvider) Authenticate(headers map[string][]string) (identityprov
 		}
 		accountId = n
 	}
+	groups, err := groupsOf(respMap)
+	if err != nil {
+		return nil, err
+	}
 	respHeader := resp.Header
 
 	return &GenericIdentity{
 		Username:  name,
 		Header:    respHeader,
 		AccountId: accountId,
+		Groups:    groups,
 	}, nil
 }
+
+// groupsOf reads groups claim from response, nil if claim is absent
+func groupsOf(respMap map[string]interface{}) ([]string, error) {
+	if len(Config.GroupsClaim) == 0 {
+		return nil, nil
+	}
+	claim, ok := respMap[Config.GroupsClaim]
+	if !ok || claim == nil {
+		return nil, nil
+	}
+	values, ok := claim.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("claim %v is not array type", Config.GroupsClaim)
+	}
+	groups := make([]string, 0, len(values))
+	for _, v := range values {
+		g, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("claim %v is not string array type", Config.GroupsClaim)
+		}
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
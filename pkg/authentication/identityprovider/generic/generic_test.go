@@ -0,0 +1,58 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generic
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestAuthenticateGroups(t *testing.T) {
+	body := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	Config.GroupsClaim = "groups"
+	defer func() { Config.GroupsClaim = "" }()
+	h := &HeaderProvider{URL: server.URL, Method: http.MethodGet, Client: server.Client()}
+
+	tests := []struct {
+		body    string
+		groups  []string
+		wantErr bool
+	}{
+		{`{"name": "alice", "groups": ["dev", "ops"]}`, []string{"dev", "ops"}, false},
+		{`{"name": "alice", "groups": []}`, []string{}, false},
+		{`{"name": "alice"}`, nil, false},
+		{`{"name": "alice", "groups": "dev"}`, nil, true},
+		{`{"name": "alice", "groups": [1]}`, nil, true},
+	}
+	for _, tt := range tests {
+		body = tt.body
+		identity, err := h.Authenticate(http.Header{})
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%v: want error %v, got %v", tt.body, tt.wantErr, err)
+		}
+		if err == nil && !reflect.DeepEqual(identity.GetGroups(), tt.groups) {
+			t.Errorf("%v: want groups %#v, got %#v", tt.body, tt.groups, identity.GetGroups())
+		}
+	}
+}
@@ -29,7 +29,11 @@ import (
 	"github.com/kubecube-io/kubecube/pkg/clog"
 )
 
-const githubUserUrl = "https://api.github.com/user"
+const (
+	githubUserUrl  = "https://api.github.com/user"
+	githubOrgsUrl  = "https://api.github.com/user/orgs?per_page=100"
+	githubTeamsUrl = "https://api.github.com/user/teams?per_page=100"
+)
 
 type githubProvider struct {
 	ClientID       string `json:"clientID" yaml:"clientID"`
@@ -76,6 +80,18 @@ type githubIdentity struct {
 	CreatedAt         time.Time `json:"created_at"`
 	UpdatedAt         time.Time `json:"updated_at"`
 	TwitterUsername   string    `json:"twitter_username"`
+
+	// groups are organizations and teams of user
+	groups []string
+}
+
+type githubOrg struct {
+	Login string `json:"login"`
+}
+
+type githubTeam struct {
+	Slug         string    `json:"slug"`
+	Organization githubOrg `json:"organization"`
 }
 
 func (g githubIdentity) GetRespHeader() http.Header {
@@ -90,6 +106,11 @@ func (g githubIdentity) GetGroup() string {
 	return ""
 }
 
+// GetGroups returns logins of organizations and org/team-slug of teams of user
+func (g githubIdentity) GetGroups() []string {
+	return g.groups
+}
+
 func (g githubIdentity) GetUserEmail() string {
 	return g.Email
 }
@@ -178,5 +199,55 @@ func (g *githubProvider) IdentityExchange(code string) (identityprovider.Identit
 		return nil, err
 	}
 
+	identity.groups, err = groupsOf(client, t.AccessToken)
+	if err != nil {
+		clog.Error("get groups of github user %v error: %v", identity.Login, err)
+		return nil, err
+	}
+
 	return identity, nil
 }
+
+// groupsOf returns organizations and teams user belongs to. Teams are only
+// visible with read:org scope, so user has no teams if they are forbidden.
+func groupsOf(client *http.Client, accessToken string) ([]string, error) {
+	var orgs []githubOrg
+	if _, err := getJSON(client, githubOrgsUrl, accessToken, &orgs); err != nil {
+		return nil, err
+	}
+	groups := make([]string, 0, len(orgs))
+	for _, org := range orgs {
+		groups = append(groups, org.Login)
+	}
+
+	var teams []githubTeam
+	code, err := getJSON(client, githubTeamsUrl, accessToken, &teams)
+	if err != nil && code != http.StatusForbidden && code != http.StatusNotFound {
+		return nil, err
+	}
+	for _, team := range teams {
+		groups = append(groups, team.Organization.Login+"/"+team.Slug)
+	}
+	return groups, nil
+}
+
+// getJSON gets url by token and decodes response into out, status code of
+// response is returned
+func getJSON(client *http.Client, url, accessToken string, out interface{}) (int, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("accept", "application/json")
+	req.Header.Set("Authorization", "token "+accessToken)
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, fmt.Errorf("get %v response code is %v", url, resp.StatusCode)
+	}
+	return resp.StatusCode, json.NewDecoder(resp.Body).Decode(out)
+}
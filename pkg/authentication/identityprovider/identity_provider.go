@@ -23,5 +23,7 @@ type Identity interface {
 	GetUserEmail() string
 	GetAccountId() string
 	GetGroup() string
+	// GetGroups returns names of groups user belongs to in identity provider
+	GetGroups() []string
 	GetRespHeader() http.Header
 }
@@ -0,0 +1,87 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ldap
+
+import (
+	"github.com/go-ldap/ldap"
+
+	"github.com/kubecube-io/kubecube/pkg/authentication/identityprovider"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+)
+
+const defaultSyncPageSize = 500
+
+// DirectoryUser is the user entry found in ldap directory
+type DirectoryUser struct {
+	// LoginName is the value of login name attribute of user
+	LoginName string
+	// DN is the distinguished name of user entry
+	DN string
+	// GroupDNs are the DNs of groups which user is member of
+	GroupDNs []string
+}
+
+// AsDirectoryUser returns the directory user of identity authenticated by ldap
+func AsDirectoryUser(identity identityprovider.Identity) (DirectoryUser, bool) {
+	l, ok := identity.(*ldapIdentity)
+	if !ok {
+		return DirectoryUser{}, false
+	}
+	return DirectoryUser{LoginName: l.Username, DN: l.DN, GroupDNs: l.GroupDNs}, true
+}
+
+// SearchUsers returns all users under base DN, the search is paged so that
+// size limit of large directories will not be exceeded.
+func (l ldapProvider) SearchUsers() ([]DirectoryUser, error) {
+	conn, err := l.newConn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	pageSize := l.LdapSyncPageSize
+	if pageSize <= 0 {
+		pageSize = defaultSyncPageSize
+	}
+
+	result, err := conn.SearchWithPaging(&ldap.SearchRequest{
+		BaseDN:       l.LdapBaseDN,
+		Scope:        ldap.ScopeWholeSubtree,
+		DerefAliases: ldap.NeverDerefAliases,
+		Filter:       l.userFilter("*"),
+		Attributes:   []string{l.LdapLoginNameConfig, ldapAttributeMemberOf},
+	}, uint32(pageSize))
+	if err != nil {
+		clog.Error("search users in ldap err: %v", err)
+		return nil, err
+	}
+
+	users := make([]DirectoryUser, 0, len(result.Entries))
+	for _, entry := range result.Entries {
+		loginName := entry.GetAttributeValue(l.LdapLoginNameConfig)
+		if loginName == "" {
+			continue
+		}
+		users = append(users, DirectoryUser{
+			LoginName: loginName,
+			DN:        entry.DN,
+			GroupDNs:  entry.GetAttributeValues(ldapAttributeMemberOf),
+		})
+	}
+
+	return users, nil
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ldap
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-ldap/ldap"
+	ber "gopkg.in/asn1-ber.v1"
+)
+
+const (
+	appBindRequest      = 0
+	appBindResponse     = 1
+	appSearchRequest    = 3
+	appSearchResultItem = 4
+	appSearchResultDone = 5
+)
+
+type entry struct {
+	dn    string
+	attrs map[string][]string
+}
+
+// fakeServer is a minimal ldap server which accepts any bind and serves
+// entries of search by paging control, pages records the number of pages served.
+type fakeServer struct {
+	listener net.Listener
+	entries  []entry
+	pages    int32
+}
+
+func newFakeServer(t *testing.T, entries []entry) *fakeServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeServer{listener: l, entries: entries}
+	go s.serve()
+	t.Cleanup(func() { l.Close() })
+	return s
+}
+
+func (s *fakeServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeServer) handle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		msgID := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+		switch op.Tag {
+		case appBindRequest:
+			conn.Write(response(msgID, appBindResponse, nil).Bytes())
+		case appSearchRequest:
+			for _, p := range s.search(msgID, packet) {
+				conn.Write(p.Bytes())
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (s *fakeServer) search(msgID int64, packet *ber.Packet) []*ber.Packet {
+	var paging *ldap.ControlPaging
+	if len(packet.Children) == 3 {
+		for _, child := range packet.Children[2].Children {
+			if c, err := ldap.DecodeControl(child); err == nil {
+				if p, ok := c.(*ldap.ControlPaging); ok {
+					paging = p
+				}
+			}
+		}
+	}
+	if paging == nil {
+		return []*ber.Packet{response(msgID, appSearchResultDone, nil)}
+	}
+
+	offset := 0
+	if len(paging.Cookie) > 0 {
+		offset, _ = strconv.Atoi(string(paging.Cookie))
+	}
+	end := offset + int(paging.PagingSize)
+	if end > len(s.entries) {
+		end = len(s.entries)
+	}
+	atomic.AddInt32(&s.pages, 1)
+
+	var packets []*ber.Packet
+	for _, e := range s.entries[offset:end] {
+		packets = append(packets, searchEntry(msgID, e))
+	}
+	next := ldap.NewControlPaging(paging.PagingSize)
+	if end < len(s.entries) {
+		next.SetCookie([]byte(strconv.Itoa(end)))
+	}
+	controls := ber.Encode(ber.ClassContext, ber.TypeConstructed, 0, nil, "Controls")
+	controls.AppendChild(next.Encode())
+	return append(packets, response(msgID, appSearchResultDone, controls))
+}
+
+func envelope(msgID int64, op *ber.Packet) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, msgID, "MessageID"))
+	packet.AppendChild(op)
+	return packet
+}
+
+func response(msgID int64, tag ber.Tag, controls *ber.Packet) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Response")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, 0, "Result Code"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Error Message"))
+	packet := envelope(msgID, op)
+	if controls != nil {
+		packet.AppendChild(controls)
+	}
+	return packet
+}
+
+func searchEntry(msgID int64, e entry) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, appSearchResultItem, nil, "Search Result Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.dn, "DN"))
+	attrs := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for name, values := range e.attrs {
+		attr := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+		attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+		vals := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, v := range values {
+			vals.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "Value"))
+		}
+		attr.AppendChild(vals)
+		attrs.AppendChild(attr)
+	}
+	op.AppendChild(attrs)
+	return envelope(msgID, op)
+}
+
+func TestSearchUsersPaged(t *testing.T) {
+	var entries []entry
+	for i := 0; i < 5; i++ {
+		name := fmt.Sprintf("user-%d", i)
+		entries = append(entries, entry{
+			dn: fmt.Sprintf("uid=%s,ou=dev,dc=example,dc=com", name),
+			attrs: map[string][]string{
+				"uid":                 {name},
+				ldapAttributeMemberOf: {"cn=dev,ou=groups,dc=example,dc=com"},
+			},
+		})
+	}
+	// entry without login name is not a user
+	entries = append(entries, entry{dn: "ou=dev,dc=example,dc=com", attrs: map[string][]string{}})
+
+	s := newFakeServer(t, entries)
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	provider := ldapProvider{
+		LdapServer:           host,
+		LdapPort:             port,
+		LdapBaseDN:           "dc=example,dc=com",
+		LdapAdminUserAccount: "cn=admin,dc=example,dc=com",
+		LdapAdminPassword:    "admin",
+		LdapLoginNameConfig:  "uid",
+		LdapSyncPageSize:     2,
+	}
+
+	users, err := provider.SearchUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pages := atomic.LoadInt32(&s.pages); pages != 3 {
+		t.Fatalf("expect 3 pages searched, got %v", pages)
+	}
+	if len(users) != 5 {
+		t.Fatalf("expect 5 users, got %+v", users)
+	}
+	for i, u := range users {
+		if u.LoginName != fmt.Sprintf("user-%d", i) || u.DN != entries[i].dn || len(u.GroupDNs) != 1 {
+			t.Fatalf("unexpected user %+v", u)
+		}
+	}
+}
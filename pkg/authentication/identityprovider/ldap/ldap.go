@@ -19,6 +19,7 @@ package ldap
 import (
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/go-ldap/ldap"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -31,6 +32,7 @@ import (
 const (
 	ldapAttributeObjectClass    = "objectClass"
 	ldapAttributeObjectCategory = "objectCategory"
+	ldapAttributeMemberOf       = "memberOf"
 )
 
 var Config = authentication.LdapConfig{}
@@ -48,10 +50,14 @@ type ldapProvider struct {
 	LdapBaseDN           string `json:"ldapBaseDN,omitempty"`
 	LdapAdminUserAccount string `json:"ldapAdminUserAccount,omitempty"`
 	LdapAdminPassword    string `json:"ldapAdminPassword,omitempty"`
+	LdapSyncPageSize     int    `json:"ldapSyncPageSize,omitempty"`
 }
 
 type ldapIdentity struct {
 	Username string
+	Groups   []string
+	DN       string
+	GroupDNs []string
 }
 
 func (l *ldapIdentity) GetRespHeader() http.Header {
@@ -66,6 +72,10 @@ func (l *ldapIdentity) GetGroup() string {
 	return ""
 }
 
+func (l *ldapIdentity) GetGroups() []string {
+	return l.Groups
+}
+
 func (l *ldapIdentity) GetUserEmail() string {
 	return ""
 }
@@ -83,7 +93,8 @@ func GetProvider() ldapProvider {
 		LdapPort:             Config.LdapPort,
 		LdapBaseDN:           Config.LdapBaseDN,
 		LdapAdminUserAccount: Config.LdapAdminUserAccount,
-		LdapAdminPassword:    Config.LdapAdminPassword}
+		LdapAdminPassword:    Config.LdapAdminPassword,
+		LdapSyncPageSize:     Config.LdapSyncPageSize}
 }
 
 func (l *ldapIdentity) GetUserID() string {
@@ -104,17 +115,7 @@ func (l ldapProvider) Authenticate(username string, password string) (identitypr
 	}
 
 	// request to ldap server with user name
-	filter := "("
-	if l.LdapObjectCategory != "" || l.LdapObjectClass != "" {
-		filter += "&"
-	}
-	if l.LdapObjectCategory != "" {
-		filter = fmt.Sprintf("(%s=%s)", ldapAttributeObjectCategory, l.LdapObjectCategory)
-	}
-	if l.LdapObjectClass != "" {
-		filter += fmt.Sprintf("(%s=%s)", ldapAttributeObjectClass, l.LdapObjectClass)
-	}
-	filter += fmt.Sprintf("(%s=%s))", l.LdapLoginNameConfig, username)
+	filter := l.userFilter(ldap.EscapeFilter(username))
 	result, err := conn.Search(&ldap.SearchRequest{
 		BaseDN:       l.LdapBaseDN,
 		Scope:        ldap.ScopeWholeSubtree,
@@ -123,6 +124,8 @@ func (l ldapProvider) Authenticate(username string, password string) (identitypr
 		TimeLimit:    0,
 		TypesOnly:    false,
 		Filter:       filter,
+		// memberOf is operational attribute in some servers, ask for it explicitly
+		Attributes: []string{"*", ldapAttributeMemberOf},
 	})
 	if err != nil {
 		clog.Error("search ldap err: %v", err)
@@ -148,11 +151,47 @@ func (l ldapProvider) Authenticate(username string, password string) (identitypr
 
 	defer conn.Close()
 
+	groupDNs := entry.GetAttributeValues(ldapAttributeMemberOf)
 	return &ldapIdentity{
 		Username: username,
+		Groups:   GroupNames(groupDNs),
+		DN:       entry.DN,
+		GroupDNs: groupDNs,
 	}, nil
 }
 
+// userFilter returns the filter to search users by login name, login name
+// may be "*" to match all users.
+func (l *ldapProvider) userFilter(loginName string) string {
+	filter := ""
+	if l.LdapObjectCategory != "" {
+		filter += fmt.Sprintf("(%s=%s)", ldapAttributeObjectCategory, l.LdapObjectCategory)
+	}
+	if l.LdapObjectClass != "" {
+		filter += fmt.Sprintf("(%s=%s)", ldapAttributeObjectClass, l.LdapObjectClass)
+	}
+	filter += fmt.Sprintf("(%s=%s)", l.LdapLoginNameConfig, loginName)
+	return "(&" + filter + ")"
+}
+
+// GroupNames returns the common names of groups by their DNs
+func GroupNames(groupDNs []string) []string {
+	groups := make([]string, 0)
+	for _, dn := range groupDNs {
+		parsed, err := ldap.ParseDN(dn)
+		if err != nil || len(parsed.RDNs) == 0 {
+			clog.Warn("parse group dn %v failed: %v", dn, err)
+			continue
+		}
+		for _, attr := range parsed.RDNs[0].Attributes {
+			if strings.EqualFold(attr.Type, "cn") {
+				groups = append(groups, attr.Value)
+			}
+		}
+	}
+	return groups
+}
+
 func (l *ldapProvider) newConn() (*ldap.Conn, error) {
 	var host = l.LdapServer
 	if l.LdapPort != "" {
@@ -0,0 +1,132 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ldap
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-ldap/ldap"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/utils/env"
+)
+
+const (
+	configMapName = "kubecube-auth-config"
+
+	// roleMappingsKey is the key of role mappings in auth config
+	roleMappingsKey = "ldapRoleMappings"
+)
+
+// RoleMapping maps users of ldap group or under ldap DN to role of scope.
+// The scope bindings appear in mappings are managed by directory sync.
+type RoleMapping struct {
+	// Group is the common name of ldap group
+	Group string `json:"group,omitempty"`
+	// DN matches the group of such DN, or the users under such DN
+	DN string `json:"dn,omitempty"`
+
+	ScopeType userv1.BindingScopeType `json:"scopeType"`
+	ScopeName string                  `json:"scopeName"`
+	Role      string                  `json:"role"`
+}
+
+func (m RoleMapping) ScopeBinding() userv1.ScopeBinding {
+	return userv1.ScopeBinding{ScopeType: m.ScopeType, ScopeName: m.ScopeName, Role: m.Role}
+}
+
+// Matches tells if the directory user should be granted by mapping
+func (m RoleMapping) Matches(user DirectoryUser) bool {
+	if m.Group != "" {
+		for _, g := range GroupNames(user.GroupDNs) {
+			if strings.EqualFold(g, m.Group) {
+				return true
+			}
+		}
+	}
+	if m.DN != "" {
+		dn, err := ldap.ParseDN(m.DN)
+		if err != nil {
+			return false
+		}
+		for _, groupDN := range user.GroupDNs {
+			if parsed, err := ldap.ParseDN(groupDN); err == nil && dn.Equal(parsed) {
+				return true
+			}
+		}
+		if parsed, err := ldap.ParseDN(user.DN); err == nil && dn.AncestorOf(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m RoleMapping) validate() error {
+	if m.Group == "" && m.DN == "" {
+		return fmt.Errorf("one of group and dn of ldap role mapping is required")
+	}
+	if m.DN != "" {
+		if _, err := ldap.ParseDN(m.DN); err != nil {
+			return fmt.Errorf("invalid dn %q of ldap role mapping: %v", m.DN, err)
+		}
+	}
+	switch m.ScopeType {
+	case userv1.TenantScope, userv1.ProjectScope, userv1.PlatformScope:
+	default:
+		return fmt.Errorf("unknown scope type %q of ldap role mapping", m.ScopeType)
+	}
+	if m.ScopeName == "" || m.Role == "" {
+		return fmt.Errorf("scope name and role of ldap role mapping are required")
+	}
+	return nil
+}
+
+// GetRoleMappings reads role mappings from auth config, returns empty
+// mappings if not configured.
+func GetRoleMappings(ctx context.Context, cli client.Reader) ([]RoleMapping, error) {
+	cm := &v1.ConfigMap{}
+	err := cli.Get(ctx, client.ObjectKey{Name: configMapName, Namespace: env.CubeNamespace()}, cm)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	data := cm.Data[roleMappingsKey]
+	if data == "" {
+		return nil, nil
+	}
+
+	mappings := []RoleMapping{}
+	if err = yaml.Unmarshal([]byte(data), &mappings); err != nil {
+		return nil, fmt.Errorf("parse ldap role mappings failed: %v", err)
+	}
+	for _, m := range mappings {
+		if err = m.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return mappings, nil
+}
@@ -0,0 +1,256 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package saml
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/crewjam/saml"
+	"github.com/crewjam/saml/samlsp"
+
+	"github.com/kubecube-io/kubecube/pkg/authentication"
+	"github.com/kubecube-io/kubecube/pkg/authentication/identityprovider"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+const (
+	MetadataPath = constants.ApiPathRoot + "/saml/metadata"
+	LoginPath    = constants.ApiPathRoot + "/saml/login"
+	AcsPath      = constants.ApiPathRoot + "/saml/acs"
+)
+
+var (
+	Config = authentication.SAMLConfig{}
+
+	lock     sync.Mutex
+	provider *Provider
+)
+
+func IsSamlOpen() bool {
+	return Config.SAMLIsEnable
+}
+
+// Provider is the SAML 2.0 service provider of kubecube
+type Provider struct {
+	sp *saml.ServiceProvider
+
+	usernameAttribute string
+	emailAttribute    string
+	groupsAttribute   string
+}
+
+type samlIdentity struct {
+	Username string
+	Email    string
+	Groups   []string
+}
+
+func (s *samlIdentity) GetRespHeader() http.Header {
+	return nil
+}
+
+func (s *samlIdentity) GetUserName() string {
+	return s.Username
+}
+
+func (s *samlIdentity) GetGroup() string {
+	return ""
+}
+
+func (s *samlIdentity) GetGroups() []string {
+	return s.Groups
+}
+
+func (s *samlIdentity) GetUserEmail() string {
+	return s.Email
+}
+
+func (s *samlIdentity) GetAccountId() string {
+	return ""
+}
+
+// GetProvider returns the service provider built from Config, the metadata
+// of IdP is fetched at the first time and cached once succeed.
+func GetProvider(ctx context.Context) (*Provider, error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if provider != nil {
+		return provider, nil
+	}
+	p, err := NewProvider(ctx, Config)
+	if err != nil {
+		return nil, err
+	}
+	provider = p
+	return provider, nil
+}
+
+// NewProvider builds service provider by config
+func NewProvider(ctx context.Context, cfg authentication.SAMLConfig) (*Provider, error) {
+	rootURL, err := url.Parse(cfg.RootURL)
+	if err != nil || rootURL.Host == "" {
+		return nil, fmt.Errorf("invalid saml root url %q", cfg.RootURL)
+	}
+
+	keyPair, err := tls.LoadX509KeyPair(cfg.SPCert, cfg.SPKey)
+	if err != nil {
+		return nil, fmt.Errorf("load saml sp key pair failed: %v", err)
+	}
+	key, ok := keyPair.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("saml sp key must be rsa private key")
+	}
+	cert, err := x509.ParseCertificate(keyPair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("parse saml sp cert failed: %v", err)
+	}
+
+	idpMetadata, err := loadIdPMetadata(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	sp := &saml.ServiceProvider{
+		EntityID:          cfg.EntityID,
+		Key:               key,
+		Certificate:       cert,
+		MetadataURL:       *rootURL.ResolveReference(&url.URL{Path: MetadataPath}),
+		AcsURL:            *rootURL.ResolveReference(&url.URL{Path: AcsPath}),
+		IDPMetadata:       idpMetadata,
+		AllowIDPInitiated: cfg.AllowIDPInitiated,
+		// AuthnRequests are always signed
+		SignatureMethod: "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256",
+	}
+
+	return &Provider{
+		sp:                sp,
+		usernameAttribute: cfg.UsernameAttribute,
+		emailAttribute:    cfg.EmailAttribute,
+		groupsAttribute:   cfg.GroupsAttribute,
+	}, nil
+}
+
+func loadIdPMetadata(ctx context.Context, cfg authentication.SAMLConfig) (*saml.EntityDescriptor, error) {
+	if cfg.IdPMetadataURL != "" {
+		metadataURL, err := url.Parse(cfg.IdPMetadataURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid saml idp metadata url %q", cfg.IdPMetadataURL)
+		}
+		metadata, err := samlsp.FetchMetadata(ctx, http.DefaultClient, *metadataURL)
+		if err != nil {
+			return nil, fmt.Errorf("fetch saml idp metadata failed: %v", err)
+		}
+		return metadata, nil
+	}
+	if cfg.IdPMetadataFile != "" {
+		data, err := os.ReadFile(cfg.IdPMetadataFile)
+		if err != nil {
+			return nil, fmt.Errorf("read saml idp metadata failed: %v", err)
+		}
+		return samlsp.ParseMetadata(data)
+	}
+	return nil, errors.New("one of saml idp metadata url and file is required")
+}
+
+// Metadata returns the metadata xml of service provider
+func (p *Provider) Metadata() ([]byte, error) {
+	return xml.MarshalIndent(p.sp.Metadata(), "", "  ")
+}
+
+// AuthnRequestURL returns the url redirects browser to IdP with signed
+// AuthnRequest, the id of request should be kept to validate response.
+func (p *Provider) AuthnRequestURL(relayState string) (string, string, error) {
+	req, err := p.sp.MakeAuthenticationRequest(p.sp.GetSSOBindingLocation(saml.HTTPRedirectBinding), saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		return "", "", err
+	}
+	u, err := req.Redirect(url.QueryEscape(relayState), p.sp)
+	if err != nil {
+		return "", "", err
+	}
+	return u.String(), req.ID, nil
+}
+
+// Authenticate validates the SAML response posted to ACS, both of signature,
+// audience, recipient and validity period of assertion are checked.
+func (p *Provider) Authenticate(r *http.Request, requestIDs []string) (identityprovider.Identity, error) {
+	assertion, err := p.sp.ParseResponse(r, requestIDs)
+	if err != nil {
+		var invalidErr *saml.InvalidResponseError
+		if errors.As(err, &invalidErr) {
+			clog.Warn("invalid saml response: %v", invalidErr.PrivateErr)
+		}
+		return nil, err
+	}
+
+	// the audience restriction is optional in SAML, but we require it to
+	// make sure the assertion is issued to us
+	if assertion.Conditions == nil || len(assertion.Conditions.AudienceRestrictions) == 0 {
+		return nil, errors.New("saml assertion has no audience restriction")
+	}
+
+	return p.identityOf(assertion)
+}
+
+func (p *Provider) identityOf(assertion *saml.Assertion) (*samlIdentity, error) {
+	identity := &samlIdentity{}
+	if p.usernameAttribute == "" {
+		if assertion.Subject == nil || assertion.Subject.NameID == nil {
+			return nil, errors.New("saml assertion has no name id")
+		}
+		identity.Username = assertion.Subject.NameID.Value
+	}
+
+	for _, statement := range assertion.AttributeStatements {
+		for _, attr := range statement.Attributes {
+			if len(attr.Values) == 0 {
+				continue
+			}
+			switch {
+			case p.usernameAttribute != "" && matchAttribute(attr, p.usernameAttribute):
+				identity.Username = attr.Values[0].Value
+			case p.emailAttribute != "" && matchAttribute(attr, p.emailAttribute):
+				identity.Email = attr.Values[0].Value
+			case p.groupsAttribute != "" && matchAttribute(attr, p.groupsAttribute):
+				for _, v := range attr.Values {
+					identity.Groups = append(identity.Groups, v.Value)
+				}
+			}
+		}
+	}
+
+	if identity.Username == "" {
+		return nil, fmt.Errorf("saml assertion has no username attribute %q", p.usernameAttribute)
+	}
+	return identity, nil
+}
+
+func matchAttribute(attr saml.Attribute, name string) bool {
+	return attr.Name == name || attr.FriendlyName == name
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package saml
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crewjam/saml"
+
+	"github.com/kubecube-io/kubecube/pkg/authentication"
+)
+
+const idpMetadata = `<EntityDescriptor xmlns="urn:oasis:names:tc:SAML:2.0:metadata" entityID="https://idp.example.com/metadata">
+  <IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://idp.example.com/sso"/>
+  </IDPSSODescriptor>
+</EntityDescriptor>`
+
+func newTestProvider(t *testing.T) *Provider {
+	dir := t.TempDir()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "kubecube"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		"tls.crt":      pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		"tls.key":      pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		"metadata.xml": []byte(idpMetadata),
+	}
+	for name, data := range files {
+		if err = os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p, err := NewProvider(context.Background(), authentication.SAMLConfig{
+		RootURL:         "https://kubecube.example.com",
+		IdPMetadataFile: filepath.Join(dir, "metadata.xml"),
+		SPCert:          filepath.Join(dir, "tls.crt"),
+		SPKey:           filepath.Join(dir, "tls.key"),
+		EmailAttribute:  "email",
+		GroupsAttribute: "groups",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestMetadata(t *testing.T) {
+	metadata, err := newTestProvider(t).Metadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`entityID="https://kubecube.example.com/api/v1/cube/saml/metadata"`,
+		`AuthnRequestsSigned="true"`,
+		`Location="https://kubecube.example.com/api/v1/cube/saml/acs"`,
+	} {
+		if !strings.Contains(string(metadata), want) {
+			t.Fatalf("metadata should contain %v:\n%s", want, metadata)
+		}
+	}
+}
+
+func TestAuthnRequestURL(t *testing.T) {
+	redirectURL, requestID, err := newTestProvider(t).AuthnRequestURL("/dashboard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(redirectURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Host != "idp.example.com" || requestID == "" {
+		t.Fatalf("unexpected authn request %v, id %v", redirectURL, requestID)
+	}
+	query := u.Query()
+	if query.Get("SAMLRequest") == "" || query.Get("Signature") == "" || query.Get("SigAlg") == "" {
+		t.Fatalf("authn request should be signed: %v", redirectURL)
+	}
+	if query.Get("RelayState") != "/dashboard" {
+		t.Fatalf("unexpected relay state %v", query.Get("RelayState"))
+	}
+}
+
+func TestIdentityOf(t *testing.T) {
+	p := newTestProvider(t)
+	assertion := &saml.Assertion{
+		Subject: &saml.Subject{NameID: &saml.NameID{Value: "alice"}},
+		AttributeStatements: []saml.AttributeStatement{{
+			Attributes: []saml.Attribute{
+				{Name: "email", Values: []saml.AttributeValue{{Value: "alice@example.com"}}},
+				{FriendlyName: "groups", Name: "urn:oid:1.3.6.1.4.1.5923.1.5.1.1", Values: []saml.AttributeValue{{Value: "dev"}, {Value: "ops"}}},
+			},
+		}},
+	}
+
+	identity, err := p.identityOf(assertion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.GetUserName() != "alice" || identity.GetUserEmail() != "alice@example.com" || len(identity.GetGroups()) != 2 {
+		t.Fatalf("unexpected identity %+v", identity)
+	}
+
+	p.usernameAttribute = "uid"
+	if _, err = p.identityOf(assertion); err == nil {
+		t.Fatal("assertion without username attribute should be rejected")
+	}
+}
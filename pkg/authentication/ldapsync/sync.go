@@ -0,0 +1,274 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ldapsync
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/retry"
+
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/authentication/group"
+	"github.com/kubecube-io/kubecube/pkg/authentication/identityprovider/ldap"
+	"github.com/kubecube-io/kubecube/pkg/authentication/session"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	mgrclient "github.com/kubecube-io/kubecube/pkg/multicluster/client"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+// Directory is where users are searched from, it is ldap server in
+// production and can be faked in tests.
+type Directory interface {
+	SearchUsers() ([]ldap.DirectoryUser, error)
+}
+
+// UserChange is the change made to an ldap user by sync
+type UserChange struct {
+	User      string `json:"user"`
+	LoginName string `json:"loginName"`
+
+	AddBindings    []userv1.ScopeBinding `json:"addBindings,omitempty"`
+	RemoveBindings []userv1.ScopeBinding `json:"removeBindings,omitempty"`
+
+	// Forbid is true if user is removed from directory
+	Forbid bool `json:"forbid,omitempty"`
+	// Enable is true if user forbidden by sync comes back to directory
+	Enable bool `json:"enable,omitempty"`
+}
+
+func (c *UserChange) isEmpty() bool {
+	return len(c.AddBindings) == 0 && len(c.RemoveBindings) == 0 && !c.Forbid && !c.Enable
+}
+
+// Result is the result of sync, changes are not applied if DryRun is true
+type Result struct {
+	DryRun  bool         `json:"dryRun"`
+	Total   int          `json:"total"`
+	Changes []UserChange `json:"changes"`
+}
+
+// Syncer reconciles ldap users with directory: scope bindings appear in role
+// mappings follow the groups and DNs of users, and users removed from directory
+// are forbidden.
+type Syncer struct {
+	cli mgrclient.Client
+	dir Directory
+}
+
+func NewSyncer(cli mgrclient.Client, dir Directory) *Syncer {
+	return &Syncer{cli: cli, dir: dir}
+}
+
+// Sync computes the changes of ldap users and applies them unless dryRun.
+// Failures of single user do not stop the sync, all of them are returned
+// together with the result.
+func (s *Syncer) Sync(ctx context.Context, dryRun bool) (*Result, error) {
+	mappings, err := ldap.GetRoleMappings(ctx, s.cli.Cache())
+	if err != nil {
+		return nil, err
+	}
+
+	dirUsers, err := s.dir.SearchUsers()
+	if err != nil {
+		return nil, err
+	}
+	// an empty directory is more likely caused by wrong config than removing
+	// everyone, refuse to forbid all users in that case
+	if len(dirUsers) == 0 {
+		return nil, fmt.Errorf("no user found in ldap directory")
+	}
+	byLoginName := make(map[string]ldap.DirectoryUser, len(dirUsers))
+	for _, u := range dirUsers {
+		byLoginName[u.LoginName] = u
+	}
+
+	users := &userv1.UserList{}
+	if err = s.cli.Cache().List(ctx, users); err != nil {
+		return nil, err
+	}
+
+	result := &Result{DryRun: dryRun, Changes: []UserChange{}}
+	var errs []error
+	for i := range users.Items {
+		user := &users.Items[i]
+		loginName := user.Labels["name"]
+		if user.Spec.LoginType != userv1.LDAPLogin || loginName == "" {
+			continue
+		}
+
+		dirUser, found := byLoginName[loginName]
+		change := diff(user, dirUser, found, mappings)
+		if !dryRun {
+			if err = s.apply(ctx, user.Name, change); err != nil {
+				errs = append(errs, fmt.Errorf("sync ldap user %v failed: %v", user.Name, err))
+				continue
+			}
+			if err = group.SyncMembership(ctx, s.cli, userv1.LdapGroup, user.Name, ldap.GroupNames(dirUser.GroupDNs)); err != nil {
+				errs = append(errs, fmt.Errorf("sync ldap groups of user %v failed: %v", user.Name, err))
+			}
+		}
+		if !change.isEmpty() {
+			result.Changes = append(result.Changes, *change)
+		}
+	}
+	result.Total = len(result.Changes)
+
+	return result, utilerrors.NewAggregate(errs)
+}
+
+// SyncUser applies role mappings to the user just authenticated by directory,
+// the user forbidden by sync is enabled again.
+func SyncUser(ctx context.Context, cli mgrclient.Client, user *userv1.User, dirUser ldap.DirectoryUser) error {
+	mappings, err := ldap.GetRoleMappings(ctx, cli.Cache())
+	if err != nil {
+		return err
+	}
+	s := &Syncer{cli: cli}
+	return s.apply(ctx, user.Name, diff(user, dirUser, true, mappings))
+}
+
+// syncedBindings returns the keys of scope bindings added to user by sync,
+// bindings granted by hand are never removed by sync even if they are the
+// same as mapped ones
+func syncedBindings(user *userv1.User) sets.Set[string] {
+	synced := sets.New[string]()
+	if v := user.Annotations[constants.LdapSyncBindingsAnnotation]; len(v) > 0 {
+		synced.Insert(strings.Split(v, ",")...)
+	}
+	return synced
+}
+
+// diff computes the change of user, the user missing in directory is
+// forbidden and loses all bindings added by sync.
+func diff(user *userv1.User, dirUser ldap.DirectoryUser, found bool, mappings []ldap.RoleMapping) *UserChange {
+	change := &UserChange{User: user.Name, LoginName: user.Labels["name"]}
+	synced := syncedBindings(user)
+
+	want := sets.New[string]()
+	if found {
+		for _, m := range mappings {
+			b := m.ScopeBinding()
+			if m.Matches(dirUser) && !want.Has(bindingKey(b)) {
+				want.Insert(bindingKey(b))
+				if !hasBinding(user.Spec.ScopeBindings, b) {
+					change.AddBindings = append(change.AddBindings, b)
+				}
+			}
+		}
+	}
+	for _, b := range user.Spec.ScopeBindings {
+		if synced.Has(bindingKey(b)) && !want.Has(bindingKey(b)) {
+			change.RemoveBindings = append(change.RemoveBindings, b)
+		}
+	}
+
+	forbiddenBySync := user.Annotations[constants.LdapSyncForbiddenAnnotation] == constants.TrueStr
+	switch {
+	case !found && user.Spec.State != userv1.ForbiddenState:
+		change.Forbid = true
+	case found && user.Spec.State == userv1.ForbiddenState && forbiddenBySync:
+		// only enable users forbidden by sync, users forbidden by hand are kept
+		change.Enable = true
+	}
+
+	return change
+}
+
+func (s *Syncer) apply(ctx context.Context, name string, change *UserChange) error {
+	if change.isEmpty() {
+		return nil
+	}
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		user := &userv1.User{}
+		if err := s.cli.Direct().Get(ctx, types.NamespacedName{Name: name}, user); err != nil {
+			return err
+		}
+
+		synced := syncedBindings(user)
+		remove := sets.New[string]()
+		for _, b := range change.RemoveBindings {
+			remove.Insert(bindingKey(b))
+		}
+		bindings := make([]userv1.ScopeBinding, 0, len(user.Spec.ScopeBindings)+len(change.AddBindings))
+		for _, b := range user.Spec.ScopeBindings {
+			if !remove.Has(bindingKey(b)) {
+				bindings = append(bindings, b)
+			}
+		}
+		synced.Delete(sets.List(remove)...)
+		for _, b := range change.AddBindings {
+			// binding granted by hand meanwhile is not taken over by sync
+			if !hasBinding(bindings, b) {
+				bindings = append(bindings, b)
+				synced.Insert(bindingKey(b))
+			}
+		}
+		user.Spec.ScopeBindings = bindings
+		if user.Annotations == nil {
+			user.Annotations = make(map[string]string)
+		}
+		if synced.Len() > 0 {
+			user.Annotations[constants.LdapSyncBindingsAnnotation] = strings.Join(sets.List(synced), ",")
+		} else {
+			delete(user.Annotations, constants.LdapSyncBindingsAnnotation)
+		}
+
+		if change.Forbid {
+			user.Spec.State = userv1.ForbiddenState
+			user.Annotations[constants.LdapSyncForbiddenAnnotation] = constants.TrueStr
+		}
+		if change.Enable {
+			user.Spec.State = userv1.NormalState
+			delete(user.Annotations, constants.LdapSyncForbiddenAnnotation)
+		}
+
+		return s.cli.Direct().Update(ctx, user)
+	})
+	if err != nil {
+		return err
+	}
+	clog.Info("ldap sync user %v: add bindings %v, remove bindings %v, forbid %v, enable %v",
+		name, change.AddBindings, change.RemoveBindings, change.Forbid, change.Enable)
+
+	// tokens of user removed from directory should not work anymore
+	if change.Forbid {
+		if err = session.NewManager(s.cli).RevokeAll(ctx, name, session.ReasonUserForbidden); err != nil {
+			clog.Warn("revoke sessions of user %v failed: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+func bindingKey(b userv1.ScopeBinding) string {
+	return fmt.Sprintf("%v/%v/%v", b.ScopeType, b.ScopeName, b.Role)
+}
+
+func hasBinding(bindings []userv1.ScopeBinding, b userv1.ScopeBinding) bool {
+	for _, binding := range bindings {
+		if bindingKey(binding) == bindingKey(b) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ldapsync
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubecube-io/kubecube/pkg/apis"
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/authentication/identityprovider/ldap"
+	mgrclient "github.com/kubecube-io/kubecube/pkg/multicluster/client"
+	"github.com/kubecube-io/kubecube/pkg/multicluster/client/fake"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+	"github.com/kubecube-io/kubecube/pkg/utils/env"
+)
+
+const mappings = `
+- group: dev
+  scopeType: project
+  scopeName: project-1
+  role: project-admin
+- dn: ou=ops,dc=example,dc=com
+  scopeType: tenant
+  scopeName: tenant-1
+  role: tenant-admin
+`
+
+type fakeDirectory []ldap.DirectoryUser
+
+func (d fakeDirectory) SearchUsers() ([]ldap.DirectoryUser, error) {
+	return d, nil
+}
+
+func ldapUser(name string, state userv1.UserState, bindings ...userv1.ScopeBinding) *userv1.User {
+	return &userv1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "ldap-" + name, Labels: map[string]string{"name": name}},
+		Spec:       userv1.UserSpec{LoginType: userv1.LDAPLogin, State: state, ScopeBindings: bindings},
+	}
+}
+
+func newFakeClient(objs ...client.Object) mgrclient.Client {
+	scheme := runtime.NewScheme()
+	apis.AddToScheme(scheme)
+	corev1.AddToScheme(scheme)
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "kubecube-auth-config", Namespace: env.CubeNamespace()},
+		Data:       map[string]string{"ldapRoleMappings": mappings},
+	}
+	return fake.NewFakeClients(&fake.Options{Scheme: scheme, Objs: append(objs, cm)})
+}
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	manual := userv1.ScopeBinding{ScopeType: userv1.ProjectScope, ScopeName: "project-2", Role: constants.Reviewer}
+	managed := userv1.ScopeBinding{ScopeType: userv1.TenantScope, ScopeName: "tenant-1", Role: constants.TenantAdmin}
+	mapped := userv1.ScopeBinding{ScopeType: userv1.ProjectScope, ScopeName: "project-1", Role: constants.ProjectAdmin}
+
+	alice := ldapUser("alice", userv1.NormalState, manual, managed)
+	alice.Annotations = map[string]string{constants.LdapSyncBindingsAnnotation: bindingKey(managed)}
+	cli := newFakeClient(
+		// joined dev group and left ops ou
+		alice,
+		// removed from directory
+		ldapUser("bob", userv1.NormalState),
+		// under ops ou, forbidden by hand
+		ldapUser("carol", userv1.ForbiddenState),
+		// not in dev group but granted the same binding by hand
+		ldapUser("dave", userv1.NormalState, mapped),
+	)
+	dir := fakeDirectory{
+		{LoginName: "alice", DN: "uid=alice,ou=dev,dc=example,dc=com", GroupDNs: []string{"cn=dev,ou=groups,dc=example,dc=com"}},
+		{LoginName: "carol", DN: "uid=carol,ou=ops,dc=example,dc=com"},
+		{LoginName: "dave", DN: "uid=dave,ou=qa,dc=example,dc=com"},
+	}
+	syncer := NewSyncer(cli, dir)
+
+	preview, err := syncer.Sync(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.Total != 3 {
+		t.Fatalf("expect 3 users changed, got %+v", preview.Changes)
+	}
+	alice = &userv1.User{}
+	if err = cli.Direct().Get(ctx, types.NamespacedName{Name: "ldap-alice"}, alice); err != nil {
+		t.Fatal(err)
+	}
+	if len(alice.Spec.ScopeBindings) != 2 || alice.Spec.ScopeBindings[1] != managed {
+		t.Fatalf("dry run should not change user: %+v", alice.Spec.ScopeBindings)
+	}
+
+	if _, err = syncer.Sync(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = cli.Direct().Get(ctx, types.NamespacedName{Name: "ldap-alice"}, alice); err != nil {
+		t.Fatal(err)
+	}
+	want := []userv1.ScopeBinding{manual, mapped}
+	if len(alice.Spec.ScopeBindings) != 2 || alice.Spec.ScopeBindings[0] != want[0] || alice.Spec.ScopeBindings[1] != want[1] {
+		t.Fatalf("expect bindings %v, got %v", want, alice.Spec.ScopeBindings)
+	}
+	if alice.Annotations[constants.LdapSyncBindingsAnnotation] != bindingKey(mapped) {
+		t.Fatalf("expect binding added by sync recorded, got %v", alice.Annotations)
+	}
+
+	dave := &userv1.User{}
+	if err = cli.Direct().Get(ctx, types.NamespacedName{Name: "ldap-dave"}, dave); err != nil {
+		t.Fatal(err)
+	}
+	if len(dave.Spec.ScopeBindings) != 1 || dave.Spec.ScopeBindings[0] != mapped {
+		t.Fatalf("binding granted by hand should be kept: %+v", dave.Spec.ScopeBindings)
+	}
+
+	bob := &userv1.User{}
+	if err = cli.Direct().Get(ctx, types.NamespacedName{Name: "ldap-bob"}, bob); err != nil {
+		t.Fatal(err)
+	}
+	if bob.Spec.State != userv1.ForbiddenState || bob.Annotations[constants.LdapSyncForbiddenAnnotation] != constants.TrueStr {
+		t.Fatalf("user removed from directory should be forbidden by sync")
+	}
+
+	carol := &userv1.User{}
+	if err = cli.Direct().Get(ctx, types.NamespacedName{Name: "ldap-carol"}, carol); err != nil {
+		t.Fatal(err)
+	}
+	if carol.Spec.State != userv1.ForbiddenState || len(carol.Spec.ScopeBindings) != 1 {
+		t.Fatalf("user forbidden by hand should be kept forbidden and granted by dn: %+v", carol.Spec)
+	}
+
+	// bob comes back to directory
+	syncer = NewSyncer(cli, append(dir, ldap.DirectoryUser{LoginName: "bob", DN: "uid=bob,ou=dev,dc=example,dc=com"}))
+	if _, err = syncer.Sync(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	if err = cli.Direct().Get(ctx, types.NamespacedName{Name: "ldap-bob"}, bob); err != nil {
+		t.Fatal(err)
+	}
+	if bob.Spec.State != userv1.NormalState {
+		t.Fatalf("user forbidden by sync should be enabled once back to directory")
+	}
+}
+
+func TestSyncEmptyDirectory(t *testing.T) {
+	cli := newFakeClient(ldapUser("alice", userv1.NormalState))
+	if _, err := NewSyncer(cli, fakeDirectory{}).Sync(context.Background(), false); err == nil {
+		t.Fatal("sync should refuse empty directory")
+	}
+}
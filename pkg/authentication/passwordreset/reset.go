@@ -0,0 +1,270 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package passwordreset
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	mgrclient "github.com/kubecube-io/kubecube/pkg/multicluster/client"
+	"github.com/kubecube-io/kubecube/pkg/notification"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+	"github.com/kubecube-io/kubecube/pkg/utils/md5util"
+)
+
+const (
+	defaultTokenTTL = 30 * time.Minute
+
+	// resendInterval limits how often reset mail is sent to a user
+	resendInterval = time.Minute
+)
+
+var (
+	Config = ResetConfig{}
+
+	// ErrInvalidToken is returned for all failures of confirming, so
+	// that caller can not tell whether user exists.
+	ErrInvalidToken = errors.New("password reset token is invalid or expired")
+)
+
+type ResetConfig struct {
+	// ResetURL is the page of front end to set new password, user and
+	// token are appended as query.
+	ResetURL        string `yaml:"resetURL,omitempty"`
+	TokenTTLMinutes int    `yaml:"tokenTTLMinutes,omitempty"`
+}
+
+func IsEnable() bool {
+	return Config.ResetURL != "" && notification.GetSender() != nil
+}
+
+type Manager struct {
+	cli      mgrclient.Client
+	sender   notification.Sender
+	resetURL string
+	ttl      time.Duration
+	now      func() time.Time
+}
+
+func NewManager(cli mgrclient.Client, sender notification.Sender) *Manager {
+	ttl := time.Duration(Config.TokenTTLMinutes) * time.Minute
+	if ttl <= 0 {
+		ttl = defaultTokenTTL
+	}
+	return &Manager{cli: cli, sender: sender, resetURL: Config.ResetURL, ttl: ttl, now: time.Now}
+}
+
+// Request issues a reset token and mails the link to the user found by name
+// or email. Nothing happens if the user can not reset password by mail, the
+// error returned is for logging only and must not be exposed.
+func (m *Manager) Request(ctx context.Context, account string) error {
+	user, err := m.findUser(ctx, account)
+	if err != nil || user == nil {
+		return err
+	}
+	if !canReset(user) {
+		clog.Debug("user %v can not reset password by mail", user.Name)
+		return nil
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+	now := m.now()
+	resent := false
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		u := &userv1.User{}
+		if err := m.cli.Direct().Get(ctx, types.NamespacedName{Name: user.Name}, u); err != nil {
+			return err
+		}
+		if expire, ok := expireTime(u); ok && now.Before(expire.Add(-m.ttl).Add(resendInterval)) {
+			resent = true
+			return nil
+		}
+		if u.Annotations == nil {
+			u.Annotations = map[string]string{}
+		}
+		u.Annotations[constants.PasswordResetTokenAnnotation] = hashToken(token)
+		u.Annotations[constants.PasswordResetExpireAnnotation] = strconv.FormatInt(now.Add(m.ttl).Unix(), 10)
+		return m.cli.Direct().Update(ctx, u)
+	})
+	if err != nil {
+		return err
+	}
+	if resent {
+		clog.Info("password reset of user %v was requested recently, skip", user.Name)
+		return nil
+	}
+
+	if err = m.sender.Send(ctx, m.message(user, token)); err != nil {
+		return fmt.Errorf("send password reset mail to user %v failed: %v", user.Name, err)
+	}
+	clog.Info("password reset mail sent to user %v", user.Name)
+	return nil
+}
+
+// Confirm sets new password if token matches, token is cleared once used.
+// The password policy should be checked by caller.
+func (m *Manager) Confirm(ctx context.Context, name string, token string, password string) (*userv1.User, error) {
+	if name == "" || token == "" {
+		return nil, ErrInvalidToken
+	}
+	user := &userv1.User{}
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := m.cli.Direct().Get(ctx, types.NamespacedName{Name: name}, user); err != nil {
+			if apierrors.IsNotFound(err) {
+				return ErrInvalidToken
+			}
+			return err
+		}
+		if !canReset(user) || !m.tokenValid(user, token) {
+			return ErrInvalidToken
+		}
+		user.Spec.Password = md5util.GetMD5Salt(password)
+		delete(user.Annotations, constants.PasswordResetTokenAnnotation)
+		delete(user.Annotations, constants.PasswordResetExpireAnnotation)
+		// concurrent confirming with same token gets conflict and finds token used
+		return m.cli.Direct().Update(ctx, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (m *Manager) tokenValid(user *userv1.User, token string) bool {
+	expire, ok := expireTime(user)
+	if !ok || !m.now().Before(expire) {
+		return false
+	}
+	hashed := user.Annotations[constants.PasswordResetTokenAnnotation]
+	return subtle.ConstantTimeCompare([]byte(hashed), []byte(hashToken(token))) == 1
+}
+
+// findUser finds user by name first, then by email if email is unique
+func (m *Manager) findUser(ctx context.Context, account string) (*userv1.User, error) {
+	account = strings.TrimSpace(account)
+	if account == "" {
+		return nil, nil
+	}
+	user := &userv1.User{}
+	err := m.cli.Direct().Get(ctx, types.NamespacedName{Name: account}, user)
+	if err == nil {
+		return user, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	if !strings.Contains(account, "@") {
+		return nil, nil
+	}
+
+	users := &userv1.UserList{}
+	if err = m.cli.Direct().List(ctx, users); err != nil {
+		return nil, err
+	}
+	var found *userv1.User
+	for i := range users.Items {
+		if strings.EqualFold(users.Items[i].Spec.Email, account) {
+			if found != nil {
+				clog.Warn("email %v is used by more than one user, skip password reset", account)
+				return nil, nil
+			}
+			found = &users.Items[i]
+		}
+	}
+	return found, nil
+}
+
+func (m *Manager) message(user *userv1.User, token string) *notification.Message {
+	query := url.Values{"user": {user.Name}, "token": {token}}
+	link := m.resetURL + "?" + query.Encode()
+	if strings.Contains(m.resetURL, "?") {
+		link = m.resetURL + "&" + query.Encode()
+	}
+	displayName := user.Spec.DisplayName
+	if displayName == "" {
+		displayName = user.Name
+	}
+	body := fmt.Sprintf(`Hi %s,
+
+A password reset was requested for your KubeCube account %s.
+Open the link below within %d minutes to set a new password:
+
+%s
+
+The link can be used only once. If you did not request it, ignore this mail
+and your password stays unchanged.
+`, displayName, user.Name, int(m.ttl.Minutes()), link)
+
+	return &notification.Message{
+		To:      []string{user.Spec.Email},
+		Subject: "Reset your KubeCube password",
+		Body:    body,
+	}
+}
+
+// canReset tells if password of user is managed by kubecube and can be mailed
+func canReset(user *userv1.User) bool {
+	if user.IsRobot() || user.Spec.State == userv1.ForbiddenState || user.Spec.Email == "" {
+		return false
+	}
+	return user.Spec.LoginType == "" || user.Spec.LoginType == userv1.NormalLogin
+}
+
+func expireTime(user *userv1.User) (time.Time, bool) {
+	v, ok := user.Annotations[constants.PasswordResetExpireAnnotation]
+	if !ok {
+		return time.Time{}, false
+	}
+	unix, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(unix, 0), true
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken only sha256 of token is stored, the token itself is in mail only
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package passwordreset
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubecube-io/kubecube/pkg/apis"
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/multicluster/client/fake"
+	"github.com/kubecube-io/kubecube/pkg/notification"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+	"github.com/kubecube-io/kubecube/pkg/utils/md5util"
+)
+
+type fakeSender struct {
+	messages []*notification.Message
+}
+
+func (s *fakeSender) Send(_ context.Context, msg *notification.Message) error {
+	s.messages = append(s.messages, msg)
+	return nil
+}
+
+var linkPattern = regexp.MustCompile(`https://\S+`)
+
+func newTestManager(t *testing.T) (*Manager, *fakeSender, *time.Time) {
+	scheme := runtime.NewScheme()
+	apis.AddToScheme(scheme)
+	user := func(name string, email string, loginType userv1.LoginType) client.Object {
+		return &userv1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       userv1.UserSpec{Email: email, LoginType: loginType, Password: md5util.GetMD5Salt("old")},
+		}
+	}
+	cli := fake.NewFakeClients(&fake.Options{Scheme: scheme, Objs: []client.Object{
+		user("alice", "alice@example.com", userv1.NormalLogin),
+		user("ldap-bob", "bob@example.com", userv1.LDAPLogin),
+		user("carol", "shared@example.com", userv1.NormalLogin),
+		user("dave", "shared@example.com", userv1.NormalLogin),
+	}})
+
+	sender := &fakeSender{}
+	now := time.Now()
+	m := &Manager{cli: cli, sender: sender, resetURL: "https://kubecube.example.com/reset", ttl: defaultTokenTTL}
+	m.now = func() time.Time { return now }
+	return m, sender, &now
+}
+
+func tokenOf(t *testing.T, msg *notification.Message) string {
+	u, err := url.Parse(linkPattern.FindString(msg.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Query().Get("token")
+}
+
+func TestResetPassword(t *testing.T) {
+	ctx := context.Background()
+	m, sender, now := newTestManager(t)
+
+	// request by email
+	if err := m.Request(ctx, "Alice@Example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if len(sender.messages) != 1 || sender.messages[0].To[0] != "alice@example.com" {
+		t.Fatalf("expect reset mail to alice, got %+v", sender.messages)
+	}
+	token := tokenOf(t, sender.messages[0])
+
+	alice := &userv1.User{}
+	if err := m.cli.Direct().Get(ctx, types.NamespacedName{Name: "alice"}, alice); err != nil {
+		t.Fatal(err)
+	}
+	if alice.Annotations[constants.PasswordResetTokenAnnotation] == token {
+		t.Fatal("token should not be stored in plain text")
+	}
+
+	// mail is not resent within interval
+	if err := m.Request(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if len(sender.messages) != 1 {
+		t.Fatalf("reset mail should not be resent within interval")
+	}
+
+	if _, err := m.Confirm(ctx, "alice", "wrong", "new-password1"); err != ErrInvalidToken {
+		t.Fatalf("wrong token should be rejected, got %v", err)
+	}
+	if _, err := m.Confirm(ctx, "nobody", token, "new-password1"); err != ErrInvalidToken {
+		t.Fatalf("unknown user should be rejected as invalid token, got %v", err)
+	}
+	user, err := m.Confirm(ctx, "alice", token, "new-password1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Spec.Password != md5util.GetMD5Salt("new-password1") {
+		t.Fatal("password should be changed")
+	}
+	if _, err = m.Confirm(ctx, "alice", token, "new-password2"); err != ErrInvalidToken {
+		t.Fatalf("token should be used only once, got %v", err)
+	}
+
+	// token expires
+	*now = now.Add(2 * resendInterval)
+	if err = m.Request(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	token = tokenOf(t, sender.messages[1])
+	*now = now.Add(defaultTokenTTL)
+	if _, err = m.Confirm(ctx, "alice", token, "new-password3"); err != ErrInvalidToken {
+		t.Fatalf("expired token should be rejected, got %v", err)
+	}
+}
+
+func TestRequestIgnored(t *testing.T) {
+	ctx := context.Background()
+	m, sender, _ := newTestManager(t)
+
+	for _, account := range []string{"", "nobody", "nobody@example.com", "ldap-bob", "shared@example.com"} {
+		if err := m.Request(ctx, account); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(sender.messages) != 0 {
+		t.Fatalf("no mail should be sent, got %+v", sender.messages)
+	}
+}
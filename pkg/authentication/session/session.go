@@ -0,0 +1,317 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/authentication/authenticators/jwt"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	mgrclient "github.com/kubecube-io/kubecube/pkg/multicluster/client"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+// touchInterval limits how often the last active time of session updated
+const touchInterval = time.Minute
+
+const (
+	ReasonLogout        = "logout"
+	ReasonRevoked       = "revoked"
+	ReasonUserForbidden = "user forbidden"
+	ReasonPasswordReset = "password changed"
+	ReasonKeyInvalid    = "key invalid"
+
+	ReasonImpersonateEnd = "impersonation ended"
+)
+
+// Manager manages server-side sessions of users. Both of cube
+// and warden consult it to tell if a token was revoked.
+type Manager interface {
+	// Create records a new session once user login succeed.
+	Create(ctx context.Context, session *userv1.Session) error
+
+	// Get returns the session by name, nil returned if not found.
+	Get(ctx context.Context, name string) (*userv1.Session, error)
+
+	// ListActive returns sessions of user which are neither revoked nor expired,
+	// including the sessions user impersonates others by.
+	ListActive(ctx context.Context, user string) ([]userv1.Session, error)
+
+	// Revoke revokes a single session.
+	Revoke(ctx context.Context, name string, reason string) error
+
+	// RevokeAll revokes all sessions of user, tokens of user issued
+	// before now are revoked even if they do not belong to any session.
+	// Sessions the user impersonates others by are revoked as well.
+	RevokeAll(ctx context.Context, user string, reason string) error
+
+	// RevokeByKey revokes all sessions opened by given access key.
+	RevokeByKey(ctx context.Context, accessKey string, reason string) error
+
+	// Touch refreshes the last active time of session.
+	Touch(ctx context.Context, name string) error
+
+	// IsRevoked tells if the token carries given claims can not be used anymore.
+	IsRevoked(ctx context.Context, claims *jwt.Claims) (bool, error)
+}
+
+type manager struct {
+	cli mgrclient.Client
+}
+
+// NewManager returns a Manager stores sessions as Session objects
+// in the cluster of given client.
+func NewManager(cli mgrclient.Client) Manager {
+	return &manager{cli: cli}
+}
+
+// IsActive tells if the session is neither revoked nor expired.
+func IsActive(session *userv1.Session, now time.Time) bool {
+	if session.Spec.Revoked {
+		return false
+	}
+	return now.Before(ExpireTime(session))
+}
+
+// ExpireTime returns the time the last token of session expires at.
+func ExpireTime(session *userv1.Session) time.Time {
+	lastActive := session.CreationTimestamp.Time
+	if session.Status.LastActiveTime != nil && session.Status.LastActiveTime.After(lastActive) {
+		lastActive = session.Status.LastActiveTime.Time
+	}
+	expireTime := lastActive.Add(time.Duration(jwt.GetAuthJwtImpl().ExpireDuration()) * time.Second)
+	if session.Spec.ExpireTime != nil && session.Spec.ExpireTime.Before(&metav1.Time{Time: expireTime}) {
+		return session.Spec.ExpireTime.Time
+	}
+	return expireTime
+}
+
+func (m *manager) Create(ctx context.Context, session *userv1.Session) error {
+	if session.Annotations == nil {
+		session.Annotations = make(map[string]string)
+	}
+	// sessions should be synced to member clusters for warden to consult
+	session.Annotations[constants.SyncAnnotation] = constants.TrueStr
+	return m.cli.Direct().Create(ctx, session)
+}
+
+func (m *manager) Get(ctx context.Context, name string) (*userv1.Session, error) {
+	session := &userv1.Session{}
+	err := m.cli.Cache().Get(ctx, types.NamespacedName{Name: name}, session)
+	if err != nil {
+		if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return session, nil
+}
+
+func (m *manager) ListActive(ctx context.Context, user string) ([]userv1.Session, error) {
+	sessions, err := m.list(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	res := make([]userv1.Session, 0, len(sessions))
+	for _, s := range sessions {
+		if IsActive(&s, now) {
+			res = append(res, s)
+		}
+	}
+	return res, nil
+}
+
+func (m *manager) list(ctx context.Context, user string) ([]userv1.Session, error) {
+	sessionList := &userv1.SessionList{}
+	err := m.cli.Cache().List(ctx, sessionList)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]userv1.Session, 0)
+	for _, s := range sessionList.Items {
+		if s.Spec.User == user || s.Spec.Impersonator == user {
+			res = append(res, s)
+		}
+	}
+	return res, nil
+}
+
+func (m *manager) Revoke(ctx context.Context, name string, reason string) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		session := &userv1.Session{}
+		err := m.cli.Direct().Get(ctx, types.NamespacedName{Name: name}, session)
+		if err != nil {
+			return err
+		}
+		if session.Spec.Revoked {
+			return nil
+		}
+		session.Spec.Revoked = true
+		session.Spec.RevokedReason = reason
+		return m.cli.Direct().Update(ctx, session)
+	})
+}
+
+func (m *manager) RevokeAll(ctx context.Context, user string, reason string) error {
+	// mark the revoked time on user so that tokens without session are revoked too
+	u := &userv1.User{}
+	err := m.cli.Direct().Get(ctx, types.NamespacedName{Name: user}, u)
+	if err != nil {
+		return err
+	}
+	patch := client.MergeFrom(u.DeepCopy())
+	if u.Annotations == nil {
+		u.Annotations = make(map[string]string)
+	}
+	u.Annotations[constants.TokensRevokedAtAnnotation] = strconv.FormatInt(time.Now().Unix(), 10)
+	err = m.cli.Direct().Patch(ctx, u, patch)
+	if err != nil {
+		return err
+	}
+
+	sessions, err := m.list(ctx, user)
+	if err != nil {
+		return err
+	}
+	for _, s := range sessions {
+		if s.Spec.Revoked {
+			continue
+		}
+		err = m.Revoke(ctx, s.Name, reason)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	clog.Info("all sessions of user %v revoked: %v", user, reason)
+
+	return nil
+}
+
+func (m *manager) RevokeByKey(ctx context.Context, accessKey string, reason string) error {
+	sessionList := &userv1.SessionList{}
+	err := m.cli.Cache().List(ctx, sessionList)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range sessionList.Items {
+		if s.Spec.AccessKey != accessKey || s.Spec.Revoked {
+			continue
+		}
+		err = m.Revoke(ctx, s.Name, reason)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *manager) Touch(ctx context.Context, name string) error {
+	if len(name) == 0 {
+		return nil
+	}
+
+	session, err := m.Get(ctx, name)
+	if err != nil || session == nil {
+		return err
+	}
+
+	now := time.Now()
+	if session.Status.LastActiveTime != nil && now.Sub(session.Status.LastActiveTime.Time) < touchInterval {
+		return nil
+	}
+
+	session.Status.LastActiveTime = &metav1.Time{Time: now}
+	return m.cli.Direct().Status().Update(ctx, session)
+}
+
+func (m *manager) IsRevoked(ctx context.Context, claims *jwt.Claims) (bool, error) {
+	inSession := len(claims.Id) > 0
+	revoked, err := m.userRevoked(ctx, claims.UserInfo.Username, claims.IssuedAt, inSession)
+	if err != nil || revoked {
+		return revoked, err
+	}
+	// impersonation ends once the impersonator is forbidden or logged out everywhere
+	if len(claims.Impersonator) > 0 {
+		revoked, err = m.userRevoked(ctx, claims.Impersonator, claims.IssuedAt, inSession)
+		if err != nil || revoked {
+			return revoked, err
+		}
+	}
+
+	// tokens without jti do not belong to any session
+	if !inSession {
+		return false, nil
+	}
+
+	session, err := m.Get(ctx, claims.Id)
+	if err != nil {
+		return false, err
+	}
+	if session == nil {
+		return false, nil
+	}
+
+	if session.Spec.ExpireTime != nil && !time.Now().Before(session.Spec.ExpireTime.Time) {
+		return true, nil
+	}
+
+	return session.Spec.Revoked, nil
+}
+
+// userRevoked tells if tokens of user issued at given time are revoked.
+// Issued time is in seconds, so tokens issued in the same second as the
+// revocation are revoked as well unless they belong to a session, sessions
+// opened before the revocation are revoked one by one and the session
+// opened right after it is kept.
+func (m *manager) userRevoked(ctx context.Context, name string, issuedAt int64, inSession bool) (bool, error) {
+	user := &userv1.User{}
+	err := m.cli.Cache().Get(ctx, types.NamespacedName{Name: name}, user)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if user.Spec.State == userv1.ForbiddenState {
+		return true, nil
+	}
+	if v, ok := user.Annotations[constants.TokensRevokedAtAnnotation]; ok {
+		revokedAt, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			clog.Warn("parse annotation %v of user %v failed: %v", constants.TokensRevokedAtAnnotation, user.Name, err)
+		} else if issuedAt < revokedAt || (issuedAt == revokedAt && !inSession) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,237 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"k8s.io/api/authentication/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubecube-io/kubecube/pkg/apis"
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/authentication/authenticators/jwt"
+	"github.com/kubecube-io/kubecube/pkg/multicluster/client/fake"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+func newFakeManager(objs ...client.Object) Manager {
+	scheme := runtime.NewScheme()
+	apis.AddToScheme(scheme)
+	return NewManager(fake.NewFakeClients(&fake.Options{Scheme: scheme, Objs: objs}))
+}
+
+func claimsOf(t *testing.T, user string, sessionID string) *jwt.Claims {
+	token, err := jwt.GetAuthJwtImpl().GenerateTokenForSession(&v1beta1.UserInfo{Username: user}, sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := jwt.GetAuthJwtImpl().ParseClaims(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return claims
+}
+
+func TestRevoke(t *testing.T) {
+	ctx := context.Background()
+	user := &userv1.User{ObjectMeta: metav1.ObjectMeta{Name: "test"}}
+	m := newFakeManager(user)
+
+	for _, name := range []string{"s1", "s2"} {
+		s := &userv1.Session{ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.Now()}, Spec: userv1.SessionSpec{User: "test"}}
+		if err := m.Create(ctx, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	revoked, err := m.IsRevoked(ctx, claimsOf(t, "test", "s1"))
+	if err != nil || revoked {
+		t.Fatalf("session s1 should not be revoked: %v", err)
+	}
+
+	if err = m.Revoke(ctx, "s1", ReasonLogout); err != nil {
+		t.Fatal(err)
+	}
+	revoked, err = m.IsRevoked(ctx, claimsOf(t, "test", "s1"))
+	if err != nil || !revoked {
+		t.Fatalf("session s1 should be revoked: %v", err)
+	}
+	revoked, err = m.IsRevoked(ctx, claimsOf(t, "test", "s2"))
+	if err != nil || revoked {
+		t.Fatalf("session s2 should not be revoked: %v", err)
+	}
+
+	sessions, err := m.ListActive(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].Name != "s2" {
+		t.Fatalf("expect only session s2 active, got %v", sessions)
+	}
+}
+
+func TestRevokeAll(t *testing.T) {
+	ctx := context.Background()
+	user := &userv1.User{ObjectMeta: metav1.ObjectMeta{Name: "test"}}
+	m := newFakeManager(user)
+
+	s := &userv1.Session{ObjectMeta: metav1.ObjectMeta{Name: "s1"}, Spec: userv1.SessionSpec{User: "test"}}
+	if err := m.Create(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+
+	// token issued before revoking and without any session
+	claims := claimsOf(t, "test", "")
+	claims.IssuedAt = time.Now().Add(-time.Minute).Unix()
+
+	if err := m.RevokeAll(ctx, "test", ReasonPasswordReset); err != nil {
+		t.Fatal(err)
+	}
+
+	revoked, err := m.IsRevoked(ctx, claims)
+	if err != nil || !revoked {
+		t.Fatalf("tokens issued before should be revoked: %v", err)
+	}
+
+	got, err := m.Get(ctx, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Spec.Revoked || got.Spec.RevokedReason != ReasonPasswordReset {
+		t.Fatalf("session s1 should be revoked, got %v", got.Spec)
+	}
+
+	revoked, err = m.IsRevoked(ctx, claimsOf(t, "other", ""))
+	if err != nil || revoked {
+		t.Fatalf("tokens of other user should not be revoked: %v", err)
+	}
+}
+
+func TestRevokeAllSameSecond(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	apis.AddToScheme(scheme)
+	cli := fake.NewFakeClients(&fake.Options{Scheme: scheme, Objs: []client.Object{&userv1.User{ObjectMeta: metav1.ObjectMeta{Name: "test"}}}})
+	m := NewManager(cli)
+
+	if err := m.RevokeAll(ctx, "test", ReasonPasswordReset); err != nil {
+		t.Fatal(err)
+	}
+	user := &userv1.User{}
+	if err := cli.Direct().Get(ctx, types.NamespacedName{Name: "test"}, user); err != nil {
+		t.Fatal(err)
+	}
+	revokedAt, err := strconv.ParseInt(user.Annotations[constants.TokensRevokedAtAnnotation], 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// token without session issued in the same second as revoking
+	claims := claimsOf(t, "test", "")
+	claims.IssuedAt = revokedAt
+	revoked, err := m.IsRevoked(ctx, claims)
+	if err != nil || !revoked {
+		t.Fatalf("tokens issued in the same second should be revoked: %v", err)
+	}
+
+	// session opened right after revoking is kept
+	s := &userv1.Session{ObjectMeta: metav1.ObjectMeta{Name: "s1"}, Spec: userv1.SessionSpec{User: "test"}}
+	if err = m.Create(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	claims = claimsOf(t, "test", "s1")
+	claims.IssuedAt = revokedAt
+	revoked, err = m.IsRevoked(ctx, claims)
+	if err != nil || revoked {
+		t.Fatalf("session opened after revoking should not be revoked: %v", err)
+	}
+}
+
+func TestIsActive(t *testing.T) {
+	now := time.Now()
+	expire := time.Duration(jwt.GetAuthJwtImpl().ExpireDuration()) * time.Second
+
+	s := &userv1.Session{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.Time{Time: now.Add(-2 * expire)}}}
+	if IsActive(s, now) {
+		t.Fatal("session without activity should be expired")
+	}
+
+	s.Status.LastActiveTime = &metav1.Time{Time: now.Add(-expire / 2)}
+	if !IsActive(s, now) {
+		t.Fatal("session active recently should not be expired")
+	}
+
+	s.Spec.ExpireTime = &metav1.Time{Time: now.Add(-time.Second)}
+	if IsActive(s, now) {
+		t.Fatal("session should not be active after hard expire time")
+	}
+	s.Spec.ExpireTime = nil
+
+	s.Spec.Revoked = true
+	if IsActive(s, now) {
+		t.Fatal("revoked session should not be active")
+	}
+}
+
+func TestImpersonationRevoked(t *testing.T) {
+	ctx := context.Background()
+	admin := &userv1.User{ObjectMeta: metav1.ObjectMeta{Name: "admin"}}
+	user := &userv1.User{ObjectMeta: metav1.ObjectMeta{Name: "test"}}
+	m := newFakeManager(admin, user)
+
+	s := &userv1.Session{ObjectMeta: metav1.ObjectMeta{Name: "s1"}, Spec: userv1.SessionSpec{User: "test", Impersonator: "admin"}}
+	if err := m.Create(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	token, err := jwt.GetAuthJwtImpl().GenerateImpersonationToken(&v1beta1.UserInfo{Username: "test"}, "admin", "s1", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := jwt.GetAuthJwtImpl().ParseClaims(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims.IssuedAt = time.Now().Add(-time.Minute).Unix()
+
+	revoked, err := m.IsRevoked(ctx, claims)
+	if err != nil || revoked {
+		t.Fatalf("impersonation should not be revoked: %v", err)
+	}
+
+	// revoking sessions of impersonator ends impersonation
+	if err = m.RevokeAll(ctx, "admin", ReasonRevoked); err != nil {
+		t.Fatal(err)
+	}
+	revoked, err = m.IsRevoked(ctx, claims)
+	if err != nil || !revoked {
+		t.Fatalf("impersonation should be revoked with impersonator: %v", err)
+	}
+	got, err := m.Get(ctx, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Spec.Revoked {
+		t.Fatal("impersonation session should be revoked")
+	}
+}
@@ -229,3 +229,82 @@ func RoleAuthMapping(roleAuths *RoleAuthBody, cmData map[string]string) *rbacv1.
 
 	return &rbacv1.ClusterRole{ObjectMeta: v1.ObjectMeta{Name: roleAuths.ClusterRoleName}, Rules: policyRules}
 }
+
+// ResourceChange is the change of verb on resource of auth item.
+type ResourceChange struct {
+	AuthItem string        `json:"authItem"`
+	Resource string        `json:"resource"`
+	Before   VerbRepresent `json:"before"`
+	After    VerbRepresent `json:"after"`
+	Gained   VerbRepresent `json:"gained"`
+	Lost     VerbRepresent `json:"lost"`
+}
+
+// DiffRoleAuth compares the resources of auth items mapped by ClusterRoleMapping
+// in verbose, resources without change are omitted.
+func DiffRoleAuth(before, after *RoleAuthBody) []ResourceChange {
+	resourcesOf := func(body *RoleAuthBody) map[string]map[string]VerbRepresent {
+		res := make(map[string]map[string]VerbRepresent)
+		if body == nil {
+			return res
+		}
+		for k, item := range body.AuthItems {
+			res[k] = item.Resources
+		}
+		return res
+	}
+	b, a := resourcesOf(before), resourcesOf(after)
+
+	items := sets.StringKeySet(b).Union(sets.StringKeySet(a))
+	changes := make([]ResourceChange, 0)
+	for _, item := range items.List() {
+		resources := sets.StringKeySet(b[item]).Union(sets.StringKeySet(a[item]))
+		for _, resource := range resources.List() {
+			beforeVerb, afterVerb := normalizeVerb(b[item][resource]), normalizeVerb(a[item][resource])
+			if beforeVerb == afterVerb {
+				continue
+			}
+			gained, lost := verbsDelta(beforeVerb, afterVerb)
+			changes = append(changes, ResourceChange{
+				AuthItem: item,
+				Resource: resource,
+				Before:   beforeVerb,
+				After:    afterVerb,
+				Gained:   gained,
+				Lost:     lost,
+			})
+		}
+	}
+	return changes
+}
+
+func normalizeVerb(v VerbRepresent) VerbRepresent {
+	if v == "" {
+		return Null
+	}
+	return v
+}
+
+// verbsDelta returns the verbs gained and lost from v1 to v2.
+func verbsDelta(v1, v2 VerbRepresent) (VerbRepresent, VerbRepresent) {
+	expand := func(v VerbRepresent) sets.String {
+		switch v {
+		case All:
+			return sets.NewString(string(Read), string(Write))
+		case Read, Write:
+			return sets.NewString(string(v))
+		}
+		return sets.NewString()
+	}
+	fold := func(s sets.String) VerbRepresent {
+		switch s.Len() {
+		case 0:
+			return Null
+		case 1:
+			return VerbRepresent(s.List()[0])
+		}
+		return All
+	}
+	s1, s2 := expand(v1), expand(v2)
+	return fold(s2.Difference(s1)), fold(s1.Difference(s2))
+}
@@ -346,3 +346,31 @@ func TestClusterRoleMapping(t *testing.T) {
 //		})
 //	}
 //}
+
+func TestDiffRoleAuth(t *testing.T) {
+	before := ClusterRoleMapping(&rbacv1.ClusterRole{
+		Rules: []rbacv1.PolicyRule{
+			{Resources: []string{"deployments", "pods"}, Verbs: []string{"get", "list", "watch"}},
+			{Resources: []string{"services"}, Verbs: []string{"get", "list", "watch", "create", "delete", "patch", "update", "deletecollection"}},
+		},
+	}, cmData, true)
+	after := ClusterRoleMapping(&rbacv1.ClusterRole{
+		Rules: []rbacv1.PolicyRule{
+			{Resources: []string{"deployments", "pods"}, Verbs: []string{"get", "list", "watch", "create", "delete", "patch", "update", "deletecollection"}},
+			{Resources: []string{"services"}, Verbs: []string{"create", "delete", "patch", "update", "deletecollection"}},
+		},
+	}, cmData, true)
+
+	want := []ResourceChange{
+		{AuthItem: "deployment.manage", Resource: "deployments", Before: Read, After: All, Gained: Write, Lost: Null},
+		{AuthItem: "deployment.manage", Resource: "pods", Before: Read, After: All, Gained: Write, Lost: Null},
+		{AuthItem: "services.manage", Resource: "services", Before: All, After: Write, Gained: Null, Lost: Read},
+	}
+	if got := DiffRoleAuth(before, after); !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffRoleAuth() = %v, want %v", got, want)
+	}
+
+	if got := DiffRoleAuth(before, before); len(got) != 0 {
+		t.Errorf("DiffRoleAuth() of same role = %v, want empty", got)
+	}
+}
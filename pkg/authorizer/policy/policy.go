@@ -0,0 +1,201 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/utils/path"
+)
+
+var Config = PolicyConfig{}
+
+type PolicyConfig struct {
+	// WebhookURL is the external decision service consulted after
+	// access policies, disabled if empty.
+	WebhookURL string `yaml:"webhookURL,omitempty"`
+	// WebhookCACert is the ca file to verify decision service, system
+	// roots are used if empty.
+	WebhookCACert string `yaml:"webhookCACert,omitempty"`
+	// WebhookTimeoutSeconds is the timeout of calling decision service.
+	WebhookTimeoutSeconds int `yaml:"webhookTimeoutSeconds,omitempty"`
+	// WebhookFailOpen allows requests when decision service is unavailable,
+	// requests are denied by default.
+	WebhookFailOpen bool `yaml:"webhookFailOpen,omitempty"`
+}
+
+// Attributes describes the request going to be proxied to k8s
+type Attributes struct {
+	User        string   `json:"user"`
+	Groups      []string `json:"groups,omitempty"`
+	Cluster     string   `json:"cluster"`
+	Verb        string   `json:"verb"`
+	APIGroup    string   `json:"apiGroup"`
+	APIVersion  string   `json:"apiVersion"`
+	Resource    string   `json:"resource,omitempty"`
+	Subresource string   `json:"subresource,omitempty"`
+	Namespace   string   `json:"namespace,omitempty"`
+	Name        string   `json:"name,omitempty"`
+	// Path is set for requests not of resources like /version
+	Path string `json:"path,omitempty"`
+	// Object is the decoded body of create, update and patch requests,
+	// it is the patch itself for patch requests.
+	Object map[string]interface{} `json:"object,omitempty"`
+
+	// undecodable is true if request has body which is not an object in
+	// json or yaml, like json patch or protobuf.
+	undecodable bool
+}
+
+// IsResourceRequest tells if the request is to k8s resources
+func (a *Attributes) IsResourceRequest() bool {
+	return len(a.Resource) > 0
+}
+
+// Decision is the result of evaluating a request
+type Decision struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+var allowed = &Decision{Allowed: true}
+
+// Evaluator decides if a request is allowed beyond rbac
+type Evaluator interface {
+	Evaluate(ctx context.Context, a *Attributes) (*Decision, error)
+}
+
+// Evaluators denies request if any of evaluators denies
+type Evaluators []Evaluator
+
+func (es Evaluators) Evaluate(ctx context.Context, a *Attributes) (*Decision, error) {
+	for _, e := range es {
+		d, err := e.Evaluate(ctx, a)
+		if err != nil {
+			return nil, err
+		}
+		if !d.Allowed {
+			return d, nil
+		}
+	}
+	return allowed, nil
+}
+
+// NewEvaluator returns the evaluator consults access policies read from
+// cli and the decision service if configured.
+func NewEvaluator(cli client.Reader) Evaluator {
+	es := Evaluators{NewRuleEvaluator(cli)}
+	if w := GetWebhook(); w != nil {
+		es = append(es, w)
+	}
+	return es
+}
+
+// NewAttributes builds attributes of request to k8sPath, body of request
+// is read and set back for later use.
+func NewAttributes(r *http.Request, k8sPath, cluster, user string, groups []string) (*Attributes, error) {
+	a := &Attributes{User: user, Groups: groups, Cluster: cluster}
+
+	ss := strings.Split(strings.Trim(k8sPath, "/"), "/")
+	watch, _ := strconv.ParseBool(r.URL.Query().Get("watch"))
+	// legacy watch path like /api/v1/watch/namespaces
+	idx := 2
+	if strings.HasPrefix(k8sPath, "/apis/") {
+		idx = 3
+	}
+	if len(ss) > idx && ss[idx] == "watch" {
+		watch = true
+		ss = append(ss[:idx], ss[idx+1:]...)
+		k8sPath = "/" + strings.Join(ss, "/")
+	}
+
+	ri, err := path.Parse(k8sPath)
+	if err != nil {
+		a.Path = k8sPath
+		a.Verb = strings.ToLower(r.Method)
+		return a, nil
+	}
+	a.APIGroup, a.APIVersion, a.Resource = ri.Gvr.Group, ri.Gvr.Version, ri.Gvr.Resource
+	a.Namespace, a.Name = ri.Namespace, ri.Name
+	// namespaces are named by itself
+	if a.Resource == "namespaces" && len(a.Name) > 0 {
+		a.Namespace = a.Name
+	}
+	if len(a.Name) > 0 {
+		for i, s := range ss {
+			if s == a.Name && i > 0 && ss[i-1] == a.Resource && i+1 < len(ss) {
+				a.Subresource = ss[i+1]
+				break
+			}
+		}
+	}
+	a.Verb = verbOf(r.Method, len(a.Name) > 0, watch)
+
+	if r.Body == nil || (r.Method != http.MethodPost && r.Method != http.MethodPut && r.Method != http.MethodPatch) {
+		return a, nil
+	}
+	data, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	if len(data) > 0 {
+		// yaml decoder accepts json too, like body of server side apply
+		if err = yaml.Unmarshal(data, &a.Object); err != nil || a.Object == nil {
+			clog.Debug("decode body of %v failed: %v", k8sPath, err)
+			a.Object, a.undecodable = nil, true
+		}
+	}
+
+	return a, nil
+}
+
+func verbOf(method string, named, watch bool) string {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		if watch {
+			return "watch"
+		}
+		if named {
+			return "get"
+		}
+		return "list"
+	case http.MethodPost:
+		return "create"
+	case http.MethodPut:
+		return "update"
+	case http.MethodPatch:
+		return "patch"
+	case http.MethodDelete:
+		if named {
+			return "delete"
+		}
+		return "deletecollection"
+	default:
+		return strings.ToLower(method)
+	}
+}
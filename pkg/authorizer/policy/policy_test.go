@@ -0,0 +1,250 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+)
+
+func TestNewAttributes(t *testing.T) {
+	tests := []struct {
+		method string
+		url    string
+		want   Attributes
+	}{
+		{http.MethodGet, "/api/v1/namespaces/ns-1/pods", Attributes{Verb: "list", APIVersion: "v1", Resource: "pods", Namespace: "ns-1"}},
+		{http.MethodGet, "/api/v1/watch/namespaces/ns-1/pods", Attributes{Verb: "watch", APIVersion: "v1", Resource: "pods", Namespace: "ns-1"}},
+		{http.MethodGet, "/apis/apps/v1/deployments?watch=true", Attributes{Verb: "watch", APIGroup: "apps", APIVersion: "v1", Resource: "deployments"}},
+		{http.MethodPost, "/api/v1/namespaces/ns-1/pods/pod-1/exec", Attributes{Verb: "create", APIVersion: "v1", Resource: "pods", Subresource: "exec", Namespace: "ns-1", Name: "pod-1"}},
+		{http.MethodDelete, "/api/v1/namespaces/ns-1", Attributes{Verb: "delete", APIVersion: "v1", Resource: "namespaces", Namespace: "ns-1", Name: "ns-1"}},
+		{http.MethodGet, "/version", Attributes{Verb: "get", Path: "/version"}},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.url, nil)
+		a, err := NewAttributes(r, r.URL.Path, "c1", "alice", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tt.want.User, tt.want.Cluster = "alice", "c1"
+		if a.Verb != tt.want.Verb || a.APIGroup != tt.want.APIGroup || a.APIVersion != tt.want.APIVersion || a.Resource != tt.want.Resource ||
+			a.Subresource != tt.want.Subresource || a.Namespace != tt.want.Namespace || a.Name != tt.want.Name || a.Path != tt.want.Path {
+			t.Errorf("%v %v: want %+v, got %+v", tt.method, tt.url, tt.want, *a)
+		}
+	}
+
+	body := `{"kind":"Pod","spec":{"containers":[{"image":"nginx:latest"}]}}`
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/namespaces/ns-1/pods", strings.NewReader(body))
+	a, err := NewAttributes(r, r.URL.Path, "c1", "alice", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Object["kind"] != "Pod" {
+		t.Errorf("body should be decoded, got %v", a.Object)
+	}
+	data, _ := io.ReadAll(r.Body)
+	if string(data) != body {
+		t.Errorf("body should be set back, got %s", data)
+	}
+
+	// body of server side apply is yaml
+	body = "kind: Pod\nspec:\n  containers:\n  - image: nginx:latest\n"
+	r = httptest.NewRequest(http.MethodPatch, "/api/v1/namespaces/ns-1/pods/pod-1", strings.NewReader(body))
+	a, err = NewAttributes(r, r.URL.Path, "c1", "alice", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Object["kind"] != "Pod" || a.undecodable {
+		t.Errorf("yaml body should be decoded, got %v", a.Object)
+	}
+
+	// json patch is not an object
+	body = `[{"op":"replace","path":"/spec/containers/0/image","value":"nginx:latest"}]`
+	r = httptest.NewRequest(http.MethodPatch, "/api/v1/namespaces/ns-1/pods/pod-1", strings.NewReader(body))
+	a, err = NewAttributes(r, r.URL.Path, "c1", "alice", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Object != nil || !a.undecodable {
+		t.Errorf("json patch should be undecodable, got %v", a.Object)
+	}
+}
+
+func TestRuleEvaluator(t *testing.T) {
+	scheme := runtime.NewScheme()
+	userv1.AddToScheme(scheme)
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&userv1.AccessPolicy{ObjectMeta: metav1.ObjectMeta{Name: "no-latest"}, Spec: userv1.AccessPolicySpec{
+			Clusters: []string{"prod"},
+			Rules: []userv1.AccessPolicyRule{{
+				Verbs:      []string{"create", "update"},
+				APIGroups:  []string{"", "apps"},
+				Resources:  []string{"pods", "deployments"},
+				Conditions: []userv1.ObjectCondition{{Path: "{..image}", Operator: userv1.ConditionMatches, Values: []string{":latest$"}}},
+				Message:    "latest image is not allowed",
+			}},
+		}},
+		&userv1.AccessPolicy{ObjectMeta: metav1.ObjectMeta{Name: "on-call"}, Spec: userv1.AccessPolicySpec{
+			Rules: []userv1.AccessPolicyRule{{
+				Verbs:        []string{"delete"},
+				APIGroups:    []string{"*"},
+				Resources:    []string{"*"},
+				ExemptGroups: []string{"on-call"},
+				TimeWindow:   &userv1.TimeWindow{Start: "09:00", End: "17:00", Weekdays: []string{"Mon", "Tue", "Wed", "Thu", "Fri"}},
+			}},
+		}},
+	).Build()
+
+	e := NewRuleEvaluator(cli)
+	// Monday
+	e.now = func() time.Time { return time.Date(2023, 5, 8, 10, 0, 0, 0, time.UTC) }
+
+	pod := func(image string) map[string]interface{} {
+		return map[string]interface{}{"spec": map[string]interface{}{"containers": []interface{}{map[string]interface{}{"image": image}}}}
+	}
+	deploy := map[string]interface{}{"spec": map[string]interface{}{"template": pod("nginx:latest")}}
+
+	tests := []struct {
+		name string
+		a    *Attributes
+		want bool
+	}{
+		{"latest pod denied", &Attributes{Cluster: "prod", Verb: "create", APIVersion: "v1", Resource: "pods", Object: pod("nginx:latest")}, false},
+		{"latest deployment denied", &Attributes{Cluster: "prod", Verb: "update", APIGroup: "apps", APIVersion: "v1", Resource: "deployments", Object: deploy}, false},
+		{"tagged pod allowed", &Attributes{Cluster: "prod", Verb: "create", APIVersion: "v1", Resource: "pods", Object: pod("nginx:1.25")}, true},
+		{"other cluster allowed", &Attributes{Cluster: "dev", Verb: "create", APIVersion: "v1", Resource: "pods", Object: pod("nginx:latest")}, true},
+		{"delete in window denied", &Attributes{User: "alice", Verb: "delete", APIVersion: "v1", Resource: "pods", Name: "pod-1"}, false},
+		{"on-call delete allowed", &Attributes{User: "bob", Groups: []string{"on-call"}, Verb: "delete", APIVersion: "v1", Resource: "pods", Name: "pod-1"}, true},
+		{"non resource allowed", &Attributes{Verb: "delete", Path: "/version"}, true},
+		{"undecodable body denied", &Attributes{Cluster: "prod", Verb: "update", APIGroup: "apps", APIVersion: "v1", Resource: "deployments", undecodable: true}, false},
+	}
+	for _, tt := range tests {
+		d, err := e.Evaluate(context.Background(), tt.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Allowed != tt.want {
+			t.Errorf("%v: want %v, got %+v", tt.name, tt.want, d)
+		}
+	}
+
+	// out of window on Monday evening and at weekend
+	for _, now := range []time.Time{time.Date(2023, 5, 8, 18, 0, 0, 0, time.UTC), time.Date(2023, 5, 6, 10, 0, 0, 0, time.UTC)} {
+		e.now = func() time.Time { return now }
+		d, err := e.Evaluate(context.Background(), &Attributes{User: "alice", Verb: "delete", APIVersion: "v1", Resource: "pods", Name: "pod-1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !d.Allowed {
+			t.Errorf("delete at %v should be allowed", now)
+		}
+	}
+}
+
+func TestRuleEvaluatorFailClosed(t *testing.T) {
+	scheme := runtime.NewScheme()
+	userv1.AddToScheme(scheme)
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&userv1.AccessPolicy{ObjectMeta: metav1.ObjectMeta{Name: "broken"}, Spec: userv1.AccessPolicySpec{
+			Rules: []userv1.AccessPolicyRule{{
+				Verbs:      []string{"create"},
+				APIGroups:  []string{""},
+				Resources:  []string{"pods"},
+				Conditions: []userv1.ObjectCondition{{Path: "{..image}", Operator: userv1.ConditionMatches, Values: []string{"(latest"}}},
+			}},
+		}},
+	).Build()
+
+	d, err := NewRuleEvaluator(cli).Evaluate(context.Background(), &Attributes{Verb: "create", APIVersion: "v1", Resource: "pods",
+		Object: map[string]interface{}{"spec": map[string]interface{}{}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Allowed {
+		t.Errorf("request matches invalid rule should be denied")
+	}
+
+	// cluster without crd of access policy has no policies
+	noCRD := fake.NewClientBuilder().WithScheme(scheme).WithInterceptorFuncs(interceptor.Funcs{
+		List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+			return &meta.NoKindMatchError{GroupKind: userv1.GroupVersion.WithKind("AccessPolicy").GroupKind()}
+		},
+	}).Build()
+	d, err = NewRuleEvaluator(noCRD).Evaluate(context.Background(),
+		&Attributes{Verb: "create", APIVersion: "v1", Resource: "pods"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Allowed {
+		t.Errorf("request should be allowed without crd of access policy")
+	}
+}
+
+func TestWebhookEvaluator(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a := &Attributes{}
+		json.NewDecoder(r.Body).Decode(a)
+		d := Decision{Allowed: a.Verb != "delete"}
+		if !d.Allowed {
+			d.Reason = "delete is not allowed"
+		}
+		json.NewEncoder(w).Encode(d)
+	}))
+	defer srv.Close()
+
+	e := &WebhookEvaluator{URL: srv.URL, Client: srv.Client()}
+	d, err := e.Evaluate(context.Background(), &Attributes{Verb: "delete"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Allowed || d.Reason != "delete is not allowed" {
+		t.Errorf("delete should be denied, got %+v", d)
+	}
+
+	// decision service unavailable
+	e = &WebhookEvaluator{URL: srv.URL + "/404", Client: &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})}}
+	if _, err = e.Evaluate(context.Background(), &Attributes{Verb: "get"}); err == nil {
+		t.Errorf("error should be returned if not fail open")
+	}
+	e.FailOpen = true
+	if d, err = e.Evaluate(context.Background(), &Attributes{Verb: "get"}); err != nil || !d.Allowed {
+		t.Errorf("request should be allowed if fail open, got %+v %v", d, err)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -0,0 +1,245 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/jsonpath"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/authorizer/rbac/helper"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+)
+
+// RuleEvaluator evaluates requests with AccessPolicy
+type RuleEvaluator struct {
+	cli client.Reader
+	now func() time.Time
+}
+
+func NewRuleEvaluator(cli client.Reader) *RuleEvaluator {
+	return &RuleEvaluator{cli: cli, now: time.Now}
+}
+
+func (e *RuleEvaluator) Evaluate(ctx context.Context, a *Attributes) (*Decision, error) {
+	// access policies only limit requests of resources
+	if !a.IsResourceRequest() {
+		return allowed, nil
+	}
+
+	policies := &userv1.AccessPolicyList{}
+	if err := e.cli.List(ctx, policies); err != nil {
+		// member cluster may not have crd of access policy
+		if meta.IsNoMatchError(err) {
+			return allowed, nil
+		}
+		return nil, err
+	}
+
+	now := e.now()
+	for _, p := range policies.Items {
+		if len(p.Spec.Clusters) > 0 && !sets.NewString(p.Spec.Clusters...).Has(a.Cluster) {
+			continue
+		}
+		for i := range p.Spec.Rules {
+			rule := &p.Spec.Rules[i]
+			matched, err := ruleMatches(rule, a, now)
+			if err != nil {
+				// broken rule fails closed, or a typo would silently lift the limit
+				clog.Warn("evaluate rule %v of access policy %v failed: %v", i, p.Name, err)
+				return &Decision{Allowed: false, Reason: fmt.Sprintf("rule %v of access policy %v is invalid: %v", i, p.Name, err)}, nil
+			}
+			if matched {
+				reason := rule.Message
+				if len(reason) == 0 {
+					reason = fmt.Sprintf("denied by access policy %v", p.Name)
+				}
+				return &Decision{Allowed: false, Reason: reason}, nil
+			}
+		}
+	}
+
+	return allowed, nil
+}
+
+func ruleMatches(rule *userv1.AccessPolicyRule, a *Attributes, now time.Time) (bool, error) {
+	r := &rbacv1.PolicyRule{Verbs: rule.Verbs, APIGroups: rule.APIGroups, Resources: rule.Resources}
+	resource := a.Resource
+	if len(a.Subresource) > 0 {
+		resource = a.Resource + "/" + a.Subresource
+	}
+	if !helper.VerbMatches(r, a.Verb) || !helper.APIGroupMatches(r, a.APIGroup) || !helper.ResourceMatches(r, resource, a.Subresource) {
+		return false, nil
+	}
+
+	if len(rule.Namespaces) > 0 && !sets.NewString(rule.Namespaces...).Has(a.Namespace) {
+		return false, nil
+	}
+
+	if sets.NewString(rule.ExemptUsers...).Has(a.User) || sets.NewString(rule.ExemptGroups...).HasAny(a.Groups...) {
+		return false, nil
+	}
+
+	if rule.TimeWindow != nil {
+		within, err := withinWindow(rule.TimeWindow, now)
+		if err != nil || !within {
+			return false, err
+		}
+	}
+
+	if len(rule.Conditions) > 0 && a.Object == nil {
+		// body can not be checked against conditions is denied
+		return a.undecodable, nil
+	}
+	for _, c := range rule.Conditions {
+		met, err := conditionMet(c, a.Object)
+		if err != nil || !met {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func withinWindow(w *userv1.TimeWindow, now time.Time) (bool, error) {
+	loc := time.UTC
+	if len(w.TimeZone) > 0 {
+		l, err := time.LoadLocation(w.TimeZone)
+		if err != nil {
+			return false, err
+		}
+		loc = l
+	}
+	now = now.In(loc)
+
+	start, err := time.Parse("15:04", w.Start)
+	if err != nil {
+		return false, err
+	}
+	end, err := time.Parse("15:04", w.End)
+	if err != nil {
+		return false, err
+	}
+
+	// weekday of window crosses midnight is the day it starts
+	clock := now.Hour()*60 + now.Minute()
+	startClock, endClock := start.Hour()*60+start.Minute(), end.Hour()*60+end.Minute()
+	day := now.Weekday()
+	var within bool
+	if startClock <= endClock {
+		within = clock >= startClock && clock < endClock
+	} else {
+		within = clock >= startClock || clock < endClock
+		if clock < endClock {
+			day = (day + 6) % 7
+		}
+	}
+	if !within {
+		return false, nil
+	}
+
+	if len(w.Weekdays) == 0 {
+		return true, nil
+	}
+	for _, d := range w.Weekdays {
+		if strings.EqualFold(d, day.String()[:3]) || strings.EqualFold(d, day.String()) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func conditionMet(c userv1.ObjectCondition, obj map[string]interface{}) (bool, error) {
+	values, err := fieldValues(c.Path, obj)
+	if err != nil {
+		return false, err
+	}
+
+	switch c.Operator {
+	case userv1.ConditionExists:
+		return len(values) > 0, nil
+	case userv1.ConditionNotExists:
+		return len(values) == 0, nil
+	case userv1.ConditionIn, userv1.ConditionNotIn:
+		in := sets.NewString(c.Values...)
+		for _, v := range values {
+			if in.Has(v) == (c.Operator == userv1.ConditionIn) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case userv1.ConditionMatches, userv1.ConditionNotMatches:
+		regexps := make([]*regexp.Regexp, 0, len(c.Values))
+		for _, v := range c.Values {
+			re, err := regexp.Compile(v)
+			if err != nil {
+				return false, err
+			}
+			regexps = append(regexps, re)
+		}
+		for _, v := range values {
+			matched := false
+			for _, re := range regexps {
+				if re.MatchString(v) {
+					matched = true
+					break
+				}
+			}
+			if matched == (c.Operator == userv1.ConditionMatches) {
+				return true, nil
+			}
+		}
+		return false, nil
+	default:
+		return false, fmt.Errorf("unknown operator %v", c.Operator)
+	}
+}
+
+// fieldValues returns the values found by jsonpath in obj
+func fieldValues(path string, obj map[string]interface{}) ([]string, error) {
+	if !strings.HasPrefix(path, "{") {
+		path = "{" + path + "}"
+	}
+	j := jsonpath.New("condition").AllowMissingKeys(true)
+	if err := j.Parse(path); err != nil {
+		return nil, err
+	}
+	results, err := j.FindResults(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	var values []string
+	for _, result := range results {
+		for _, v := range result {
+			if !v.IsValid() || !v.CanInterface() || v.Interface() == nil {
+				continue
+			}
+			values = append(values, fmt.Sprint(v.Interface()))
+		}
+	}
+	return values, nil
+}
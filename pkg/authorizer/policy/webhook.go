@@ -0,0 +1,116 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/utils/ctls"
+)
+
+const defaultWebhookTimeout = 5 * time.Second
+
+var (
+	once    sync.Once
+	webhook *WebhookEvaluator
+)
+
+// WebhookEvaluator posts attributes of request to external decision service
+// as json and expects a Decision back.
+type WebhookEvaluator struct {
+	URL      string
+	FailOpen bool
+	Client   *http.Client
+}
+
+// GetWebhook returns the evaluator of decision service configured, nil
+// if not configured.
+func GetWebhook() *WebhookEvaluator {
+	once.Do(func() {
+		if len(Config.WebhookURL) == 0 {
+			return
+		}
+		timeout := defaultWebhookTimeout
+		if Config.WebhookTimeoutSeconds > 0 {
+			timeout = time.Duration(Config.WebhookTimeoutSeconds) * time.Second
+		}
+		webhook = &WebhookEvaluator{
+			URL:      Config.WebhookURL,
+			FailOpen: Config.WebhookFailOpen,
+			Client:   &http.Client{Timeout: timeout, Transport: ctls.DefaultTransport()},
+		}
+		if len(Config.WebhookCACert) == 0 {
+			return
+		}
+		tr, err := ctls.MakeTlsTransportByFile(Config.WebhookCACert)
+		if err != nil {
+			clog.Warn("make tls transport of decision service failed, use default: %v", err)
+			return
+		}
+		webhook.Client.Transport = tr
+	})
+	return webhook
+}
+
+func (e *WebhookEvaluator) Evaluate(ctx context.Context, a *Attributes) (*Decision, error) {
+	d, err := e.evaluate(ctx, a)
+	if err != nil && e.FailOpen {
+		clog.Warn("call decision service failed, request of %v allowed: %v", a.User, err)
+		return allowed, nil
+	}
+	return d, err
+}
+
+func (e *WebhookEvaluator) evaluate(ctx context.Context, a *Attributes) (*Decision, error) {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("decision service responded %v: %s", resp.StatusCode, body)
+	}
+
+	d := &Decision{}
+	if err = json.Unmarshal(body, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
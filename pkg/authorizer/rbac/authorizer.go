@@ -136,6 +136,8 @@ func (r *DefaultResolver) GetRoleReferenceRules(roleRef rbacv1.RoleRef, bindingN
 }
 
 func (r *DefaultResolver) VisitRulesFor(user user.Info, namespace string, visitor func(source fmt.Stringer, rule *rbacv1.PolicyRule, err error) bool) {
+	user = r.withGroups(user)
+
 	if clusterRoleBindings, err := r.ListClusterRoleBindings(); err != nil {
 		if !visitor(nil, nil, err) {
 			return
@@ -218,6 +220,7 @@ func User2UserInfo(u string) user.Info {
 }
 
 func (r *DefaultResolver) User2UserRole(user user.Info) []string {
+	user = r.withGroups(user)
 	roles := make([]string, 0)
 	clusterRoleBindings, err := r.ListClusterRoleBindings()
 	if err != nil {
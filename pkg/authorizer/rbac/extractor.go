@@ -33,6 +33,9 @@ type RoleExtractor interface {
 	GetUser(name string) (userv1.User, error)
 	ListUser() ([]userv1.User, error)
 
+	GetGroup(name string) (userv1.Group, error)
+	ListGroup() ([]userv1.Group, error)
+
 	GetRole(namespace, name string) (rbacv1.Role, error)
 	ListRoleBindings(namespace string) ([]rbacv1.RoleBinding, error)
 
@@ -74,6 +77,25 @@ func (r *DefaultResolver) ListUser() ([]userv1.User, error) {
 	return ul.Items, err
 }
 
+func (r *DefaultResolver) GetGroup(name string) (userv1.Group, error) {
+	key := types.NamespacedName{
+		Name: name,
+	}
+	group := userv1.Group{}
+	err := r.Get(context.Background(), key, &group)
+
+	return group, err
+}
+
+func (r *DefaultResolver) ListGroup() ([]userv1.Group, error) {
+	gl := userv1.GroupList{}
+	err := r.List(context.Background(), &gl)
+	if err != nil {
+		return nil, err
+	}
+	return gl.Items, err
+}
+
 func (r *DefaultResolver) GetRole(namespace, name string) (rbacv1.Role, error) {
 	key := types.NamespacedName{
 		Name:      name,
@@ -17,23 +17,29 @@ limitations under the License.
 package rbac
 
 import (
+	"context"
 	"fmt"
 
-	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apiserver/pkg/authentication/user"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/clog"
 )
 
 type RoleResolver interface {
 	// RolesFor get all of roles and cluster roles bind to user, with non empty
 	// namespace will match both Role and ClusterRole, otherwise only clusterRole
-	// will be matched.
+	// will be matched. Roles bind to groups of user are included.
 	RolesFor(user user.Info, namespace string) ([]*rbacv1.Role, []*rbacv1.ClusterRole, error)
 
 	// UsersFor get all of users bind to role reference, if Role with namespace,
 	// will match RoleBindings and ClusterRoleBindings, otherwise only Cluster
-	// will be matched.
+	// will be matched. Members of bound groups are included.
 	UsersFor(role rbacv1.RoleRef, namespace string) ([]*userv1.User, error)
 
 	// VisitRulesFor invokes visitor() with each rule that applies to a given user in a given namespace,
@@ -104,12 +110,7 @@ func (r *DefaultResolver) matchClusterRoleBindingsForUser(role rbacv1.RoleRef, v
 				continue
 			}
 			for _, subject := range clusterRoleBinding.Subjects {
-				u, err := r.GetUser(subject.Name)
-				if err != nil {
-					visitor(nil, nil, nil, err)
-					continue
-				}
-				visitor(nil, nil, &u, nil)
+				r.visitUsersOfSubject(subject, visitor)
 			}
 		}
 	}
@@ -125,18 +126,44 @@ func (r *DefaultResolver) matchRoleBindingsForUser(role rbacv1.RoleRef, namespac
 					continue
 				}
 				for _, subject := range roleBinding.Subjects {
-					u, err := r.GetUser(subject.Name)
-					if err != nil {
-						visitor(nil, nil, nil, err)
-						continue
-					}
-					visitor(nil, nil, &u, nil)
+					r.visitUsersOfSubject(subject, visitor)
 				}
 			}
 		}
 	}
 }
 
+// visitUsersOfSubject visits users of subject, members are visited
+// for Group subject.
+func (r *DefaultResolver) visitUsersOfSubject(subject rbacv1.Subject, visitor visitor) {
+	if subject.Kind != rbacv1.GroupKind {
+		u, err := r.GetUser(subject.Name)
+		if err != nil {
+			visitor(nil, nil, nil, err)
+			return
+		}
+		visitor(nil, nil, &u, nil)
+		return
+	}
+
+	group, err := r.GetGroup(subject.Name)
+	if err != nil {
+		visitor(nil, nil, nil, err)
+		return
+	}
+	for _, member := range group.Spec.Members {
+		u, err := r.GetUser(member)
+		if err != nil {
+			// members of external group may not login yet
+			if !errors.IsNotFound(err) {
+				visitor(nil, nil, nil, err)
+			}
+			continue
+		}
+		visitor(nil, nil, &u, nil)
+	}
+}
+
 func (r *DefaultResolver) VisitUsersFor(role rbacv1.RoleRef, namespace string, visitor visitor) {
 	switch role.Kind {
 	case "ClusterRole":
@@ -152,6 +179,8 @@ func (r *DefaultResolver) VisitUsersFor(role rbacv1.RoleRef, namespace string, v
 }
 
 func (r *DefaultResolver) VisitRolesFor(user user.Info, namespace string, visitor visitor) {
+	user = r.withGroups(user)
+
 	if clusterRoleBindings, err := r.ListClusterRoleBindings(); err != nil {
 		visitor(nil, nil, nil, err)
 	} else {
@@ -213,14 +242,59 @@ func appliesTo(user user.Info, bindingSubjects []rbacv1.Subject, namespace strin
 	return 0, false
 }
 
-// appliesToUser only support user kind now
+// withGroups returns user info carries the groups user belongs to,
+// so that bindings with Group subject can be applied to user.
+func (r *DefaultResolver) withGroups(u user.Info) user.Info {
+	groups, err := GroupsOf(context.Background(), r.Cache, u.GetName())
+	if err != nil {
+		clog.Warn("get groups of user %v failed: %v", u.GetName(), err)
+		return u
+	}
+	if len(groups) == 0 {
+		return u
+	}
+
+	return &user.DefaultInfo{
+		Name:   u.GetName(),
+		UID:    u.GetUID(),
+		Groups: append(append([]string{}, u.GetGroups()...), groups...),
+		Extra:  u.GetExtra(),
+	}
+}
+
+// GroupsOf returns names of groups which user is member of.
+func GroupsOf(ctx context.Context, cli client.Reader, user string) ([]string, error) {
+	groupList := &userv1.GroupList{}
+	err := cli.List(ctx, groupList)
+	if err != nil {
+		// groups are optional, Group CRD may not be installed
+		if meta.IsNoMatchError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var groups []string
+	for _, g := range groupList.Items {
+		if g.HasMember(user) {
+			groups = append(groups, g.Name)
+		}
+	}
+	return groups, nil
+}
+
+// appliesToUser support user and group kind now
 func appliesToUser(user user.Info, subject rbacv1.Subject, namespace string) bool {
 	switch subject.Kind {
 	case rbacv1.UserKind:
 		return user.GetName() == subject.Name
 
 	case rbacv1.GroupKind:
-		// group not used here
+		for _, g := range user.GetGroups() {
+			if g == subject.Name {
+				return true
+			}
+		}
 		return false
 
 	case rbacv1.ServiceAccountKind:
@@ -0,0 +1,133 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	"fmt"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+)
+
+// AccessSubjects are the subjects allowed to perform an action,
+// members of bound groups are included in users.
+type AccessSubjects struct {
+	Users           []string `json:"users"`
+	Groups          []string `json:"groups"`
+	ServiceAccounts []string `json:"serviceAccounts"`
+}
+
+// AccessReason names the binding and the rule which allow an action
+type AccessReason struct {
+	Binding string            `json:"binding"`
+	Rule    rbacv1.PolicyRule `json:"rule"`
+}
+
+// WhoCan returns the subjects allowed to perform the action of attributes,
+// the user of attributes is ignored. Only ClusterRoleBindings are matched
+// if namespace of attributes is empty.
+func (r *DefaultResolver) WhoCan(a authorizer.Attributes) (*AccessSubjects, error) {
+	users, groups, sas := sets.New[string](), sets.New[string](), sets.New[string]()
+	var errs []error
+
+	collect := func(roleRef rbacv1.RoleRef, namespace string, subjects []rbacv1.Subject) {
+		rules, err := r.GetRoleReferenceRules(roleRef, namespace)
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+		allowed := false
+		for i := range rules {
+			if RuleAllows(a, &rules[i]) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return
+		}
+		for _, s := range subjects {
+			switch s.Kind {
+			case rbacv1.UserKind:
+				users.Insert(s.Name)
+			case rbacv1.GroupKind:
+				groups.Insert(s.Name)
+				group, err := r.GetGroup(s.Name)
+				if err != nil {
+					// group of kubernetes is not a Group of kubecube
+					if !errors.IsNotFound(err) {
+						errs = append(errs, err)
+					}
+					continue
+				}
+				users.Insert(group.Spec.Members...)
+			case rbacv1.ServiceAccountKind:
+				ns := s.Namespace
+				if len(ns) == 0 {
+					ns = namespace
+				}
+				sas.Insert(ns + "/" + s.Name)
+			}
+		}
+	}
+
+	clusterRoleBindings, err := r.ListClusterRoleBindings()
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range clusterRoleBindings {
+		collect(b.RoleRef, "", b.Subjects)
+	}
+
+	if ns := a.GetNamespace(); len(ns) > 0 {
+		roleBindings, err := r.ListRoleBindings(ns)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range roleBindings {
+			collect(b.RoleRef, ns, b.Subjects)
+		}
+	}
+
+	return &AccessSubjects{
+		Users:           sets.List(users),
+		Groups:          sets.List(groups),
+		ServiceAccounts: sets.List(sas),
+	}, utilerrors.NewAggregate(errs)
+}
+
+// WhyCan explains the decision of Authorize by every binding and rule
+// allow the action, the action is not allowed if no reason returned.
+func (r *DefaultResolver) WhyCan(a authorizer.Attributes) ([]AccessReason, error) {
+	var (
+		reasons []AccessReason
+		errs    []error
+	)
+	r.VisitRulesFor(a.GetUser(), a.GetNamespace(), func(source fmt.Stringer, rule *rbacv1.PolicyRule, err error) bool {
+		if rule != nil && RuleAllows(a, rule) {
+			reasons = append(reasons, AccessReason{Binding: source.String(), Rule: *rule})
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+		return true
+	})
+	return reasons, utilerrors.NewAggregate(errs)
+}
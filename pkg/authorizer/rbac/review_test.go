@@ -0,0 +1,111 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/multicluster/client/fake"
+)
+
+func newReviewResolver() *DefaultResolver {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	userv1.AddToScheme(scheme)
+
+	rules := func(verbs ...string) []rbacv1.PolicyRule {
+		return []rbacv1.PolicyRule{{Verbs: verbs, APIGroups: []string{""}, Resources: []string{"pods"}}}
+	}
+	cli := fake.NewFakeClients(&fake.Options{Scheme: scheme, Objs: []client.Object{
+		&userv1.User{ObjectMeta: metav1.ObjectMeta{Name: "alice"}},
+		&userv1.User{ObjectMeta: metav1.ObjectMeta{Name: "bob"}},
+		&userv1.Group{ObjectMeta: metav1.ObjectMeta{Name: "dev"}, Spec: userv1.GroupSpec{Members: []string{"bob"}}},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "viewer"}, Rules: rules("get", "list")},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "editor"}, Rules: rules("*")},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "alice-viewer"},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "viewer"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "alice"}},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "dev-editor", Namespace: "ns-1"},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "editor"},
+			Subjects: []rbacv1.Subject{
+				{Kind: rbacv1.GroupKind, Name: "dev"},
+				{Kind: rbacv1.ServiceAccountKind, Name: "deployer"},
+			},
+		},
+	}})
+	return &DefaultResolver{Cache: cli.Cache()}
+}
+
+func TestWhoCan(t *testing.T) {
+	r := newReviewResolver()
+
+	subjects, err := r.WhoCan(&authorizer.AttributesRecord{Verb: "delete", Namespace: "ns-1", Resource: "pods", ResourceRequest: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &AccessSubjects{Users: []string{"bob"}, Groups: []string{"dev"}, ServiceAccounts: []string{"ns-1/deployer"}}
+	if !reflect.DeepEqual(subjects, want) {
+		t.Fatalf("want %+v, got %+v", want, subjects)
+	}
+
+	subjects, err = r.WhoCan(&authorizer.AttributesRecord{Verb: "get", Resource: "pods", ResourceRequest: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(subjects.Users, []string{"alice"}) || len(subjects.Groups) != 0 {
+		t.Fatalf("only cluster role bindings should be matched without namespace, got %+v", subjects)
+	}
+}
+
+func TestWhyCan(t *testing.T) {
+	r := newReviewResolver()
+
+	reasons, err := r.WhyCan(&authorizer.AttributesRecord{
+		User: &user.DefaultInfo{Name: "bob"}, Verb: "get", Namespace: "ns-1", Resource: "pods", ResourceRequest: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reasons) != 1 || !strings.Contains(reasons[0].Binding, `RoleBinding "dev-editor/ns-1"`) ||
+		!strings.Contains(reasons[0].Binding, `Group "dev"`) || reasons[0].Rule.Verbs[0] != "*" {
+		t.Fatalf("get should be allowed by group binding, got %+v", reasons)
+	}
+
+	reasons, err = r.WhyCan(&authorizer.AttributesRecord{
+		User: &user.DefaultInfo{Name: "alice"}, Verb: "delete", Namespace: "ns-1", Resource: "pods", ResourceRequest: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reasons) != 0 {
+		t.Fatalf("delete should not be allowed, got %+v", reasons)
+	}
+}
@@ -19,6 +19,8 @@ package belongs
 import (
 	"strings"
 
+	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
+	tenantv1 "github.com/kubecube-io/kubecube/pkg/apis/tenant/v1"
 	v1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
 	"github.com/kubecube-io/kubecube/pkg/utils/constants"
 	"k8s.io/apimachinery/pkg/api/meta"
@@ -32,6 +34,9 @@ type JudgementFunc func(user *v1.User, obj runtime.Object) (bool, error)
 var resourcesHandlers = map[schema.GroupVersionResource]JudgementFunc{
 	{Version: "v1", Resource: constants.ResourceNamespaces}: namespaceJudgement,
 	{Version: "v1", Resource: constants.ResourceNode}:       nodeJudgment,
+	tenantv1.GroupVersion.WithResource("tenants"):           tenantJudgement,
+	tenantv1.GroupVersion.WithResource("projects"):          projectJudgement,
+	quotav1.GroupVersion.WithResource("cuberesourcequota"):  tenantResourceJudgement,
 }
 
 func RegisterDeterminer(gvr schema.GroupVersionResource, fn JudgementFunc) {
@@ -102,3 +107,61 @@ func namespaceJudgement(user *v1.User, obj runtime.Object) (bool, error) {
 
 	return false, nil
 }
+
+func tenantJudgement(user *v1.User, obj runtime.Object) (bool, error) {
+	if v1.IsPlatformAdmin(user) {
+		return true, nil
+	}
+
+	meatObj, err := meta.Accessor(obj)
+	if err != nil {
+		return false, err
+	}
+
+	return v1.BelongsToTenant(user, meatObj.GetName()), nil
+}
+
+func projectJudgement(user *v1.User, obj runtime.Object) (bool, error) {
+	if v1.IsPlatformAdmin(user) {
+		return true, nil
+	}
+
+	meatObj, err := meta.Accessor(obj)
+	if err != nil {
+		return false, err
+	}
+
+	if v1.BelongsToProject(user, meatObj.GetName()) {
+		return true, nil
+	}
+
+	return tenantResourceJudgement(user, obj)
+}
+
+// tenantResourceJudgement judges resources labeled with tenant or project they relate with
+func tenantResourceJudgement(user *v1.User, obj runtime.Object) (bool, error) {
+	if v1.IsPlatformAdmin(user) {
+		return true, nil
+	}
+
+	meatObj, err := meta.Accessor(obj)
+	if err != nil {
+		return false, err
+	}
+
+	if meatObj.GetLabels() == nil {
+		return false, nil
+	}
+
+	tenant, ok := meatObj.GetLabels()[constants.TenantLabel]
+	if ok && v1.BelongsToTenant(user, tenant) {
+		return true, nil
+	}
+
+	project, ok := meatObj.GetLabels()[constants.ProjectLabel]
+	if ok && v1.BelongsToProject(user, project) {
+		return true, nil
+	}
+
+	return false, nil
+}
@@ -22,6 +22,7 @@ import (
 
 	"github.com/kubecube-io/kubecube/pkg/multicluster/client"
 	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
 
 	v1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
@@ -29,14 +30,17 @@ import (
 	"github.com/kubecube-io/kubecube/pkg/utils/path"
 )
 
+// RelationshipDetermine tells if the object requested belongs to user, list
+// and watch requests are allowed here and their responses are filtered by
+// ObjectFilter instead.
 func RelationshipDetermine(ctx context.Context, cli client.Client, k8sPath string, userName string) (bool, error) {
-	ri, err := path.Parse(k8sPath)
+	ri, err := path.Parse(trimWatch(k8sPath))
 	if err != nil {
 		return true, fmt.Errorf("parse request url %v failed %v", k8sPath, err)
 	}
 
 	if len(ri.Name) == 0 {
-		return true, fmt.Errorf("list request %v will be pass through", k8sPath)
+		return true, nil
 	}
 
 	determiner := GetDeterminer(ri.Gvr)
@@ -61,6 +65,17 @@ func RelationshipDetermine(ctx context.Context, cli client.Client, k8sPath strin
 			}
 			return determiner(user, obj)
 		}
+		gvk, err := cli.RESTMapper().KindFor(ri.Gvr)
+		if err != nil {
+			return true, err
+		}
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(gvk)
+		err = cli.Direct().Get(ctx, types.NamespacedName{Namespace: ri.Namespace, Name: ri.Name}, obj)
+		if err != nil {
+			return true, err
+		}
+		return determiner(user, obj)
 	}
 	return true, nil
 }
@@ -0,0 +1,283 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package belongs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	v1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/multicluster/client"
+	"github.com/kubecube-io/kubecube/pkg/utils/path"
+)
+
+// ObjectFilter drops objects of list and watch responses which not belong
+// to user by the determiner registered for the resource.
+type ObjectFilter struct {
+	user       *v1.User
+	determiner JudgementFunc
+}
+
+// NewObjectFilter returns the filter for list or watch request of k8sPath,
+// nil is returned if the request needs no filter.
+func NewObjectFilter(ctx context.Context, cli client.Client, k8sPath string, userName string) (*ObjectFilter, error) {
+	ri, err := path.Parse(trimWatch(k8sPath))
+	// paths not of resources like discovery need no filter
+	if err != nil || len(ri.Name) > 0 {
+		return nil, nil
+	}
+
+	determiner := GetDeterminer(ri.Gvr)
+	if determiner == nil {
+		return nil, nil
+	}
+	user := &v1.User{}
+	if err = cli.Cache().Get(ctx, types.NamespacedName{Name: userName}, user); err != nil {
+		return nil, err
+	}
+	if v1.IsPlatformAdmin(user) {
+		return nil, nil
+	}
+	return &ObjectFilter{user: user, determiner: determiner}, nil
+}
+
+// Belongs tells if obj belongs to user, obj failed to judge is treated as not.
+func (f *ObjectFilter) Belongs(obj *unstructured.Unstructured) bool {
+	ok, err := f.determiner(f.user, obj)
+	if err != nil {
+		clog.Debug("judge %v %v of user %v failed: %v", obj.GetKind(), obj.GetName(), f.user.Name, err)
+		return false
+	}
+	return ok
+}
+
+// Filter drops items of list or rows of table not belong to user in place,
+// it tells if anything of obj left to user.
+func (f *ObjectFilter) Filter(obj *unstructured.Unstructured) bool {
+	switch {
+	case obj.IsList():
+		items, _ := obj.Object["items"].([]interface{})
+		obj.Object["items"] = f.filterNested(items, func(item map[string]interface{}) map[string]interface{} { return item })
+		return true
+	case obj.GetKind() == "Table":
+		// rows of table carry the object or its metadata, rows without
+		// object are dropped because there is nothing to judge
+		rows, _ := obj.Object["rows"].([]interface{})
+		left := f.filterNested(rows, func(row map[string]interface{}) map[string]interface{} {
+			object, _ := row["object"].(map[string]interface{})
+			return object
+		})
+		obj.Object["rows"] = left
+		return len(left) > 0 || len(rows) == 0
+	default:
+		return f.Belongs(obj)
+	}
+}
+
+func (f *ObjectFilter) filterNested(in []interface{}, objectOf func(map[string]interface{}) map[string]interface{}) []interface{} {
+	out := make([]interface{}, 0, len(in))
+	for _, v := range in {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		object := objectOf(m)
+		if object == nil {
+			continue
+		}
+		if f.Belongs(&unstructured.Unstructured{Object: object}) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// ModifyResponse filters the json body of list and watch response, other
+// responses are left untouched.
+func (f *ObjectFilter) ModifyResponse(r *http.Response) error {
+	if r.StatusCode != http.StatusOK || !strings.Contains(r.Header.Get("Content-Type"), "json") {
+		return nil
+	}
+
+	body := r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		reader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return err
+		}
+		body = readCloser{Reader: reader, close: r.Body.Close}
+		delete(r.Header, "Content-Encoding")
+	}
+
+	if r.Request != nil && IsWatch(r.Request.URL) {
+		r.Body = f.filterWatch(body)
+		r.ContentLength = -1
+		delete(r.Header, "Content-Length")
+		return nil
+	}
+
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return err
+	}
+	obj := &unstructured.Unstructured{}
+	if err = obj.UnmarshalJSON(data); err != nil {
+		clog.Warn("decode response failed, response is not filtered: %v", err)
+	} else {
+		f.Filter(obj)
+		if data, err = obj.MarshalJSON(); err != nil {
+			return err
+		}
+	}
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	r.ContentLength = int64(len(data))
+	r.Header["Content-Length"] = []string{fmt.Sprint(len(data))}
+	return nil
+}
+
+// watchEvent is the json frame of watch stream
+type watchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// filterWatch drops events of objects not belong to user from the stream
+func (f *ObjectFilter) filterWatch(body io.ReadCloser) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		decoder := json.NewDecoder(body)
+		encoder := json.NewEncoder(pw)
+		for {
+			event := &watchEvent{}
+			if err := decoder.Decode(event); err != nil {
+				if err == io.EOF {
+					err = nil
+				}
+				pw.CloseWithError(err)
+				return
+			}
+			// bookmark and error events are always passed
+			if event.Type == "ADDED" || event.Type == "MODIFIED" || event.Type == "DELETED" {
+				obj := &unstructured.Unstructured{}
+				if err := obj.UnmarshalJSON(event.Object); err != nil {
+					pw.CloseWithError(err)
+					return
+				}
+				if !f.Filter(obj) {
+					continue
+				}
+				raw, err := obj.MarshalJSON()
+				if err != nil {
+					pw.CloseWithError(err)
+					return
+				}
+				event.Object = raw
+			}
+			if err := encoder.Encode(event); err != nil {
+				// reader closed by client
+				return
+			}
+		}
+	}()
+	return readCloser{Reader: pr, close: func() error {
+		pr.Close()
+		return body.Close()
+	}}
+}
+
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (r readCloser) Close() error {
+	return r.close()
+}
+
+// AcceptJSON drops protobuf from accept header of request so that the
+// response could be decoded and filtered.
+func AcceptJSON(header http.Header) {
+	var accepts []string
+	for _, accept := range strings.Split(header.Get("Accept"), ",") {
+		if len(strings.TrimSpace(accept)) > 0 && !strings.Contains(accept, "protobuf") {
+			accepts = append(accepts, strings.TrimSpace(accept))
+		}
+	}
+	if len(accepts) == 0 {
+		accepts = []string{"application/json"}
+	}
+	header.Set("Accept", strings.Join(accepts, ","))
+}
+
+// IsWatch tells if the request url is a watch, like /api/v1/namespaces?watch=true
+// or the legacy /api/v1/watch/namespaces
+func IsWatch(u *url.URL) bool {
+	if watch, _ := strconv.ParseBool(u.Query().Get("watch")); watch {
+		return true
+	}
+	return u.Path != trimWatch(u.Path)
+}
+
+// trimWatch trims the legacy watch segment of path, /api/v1/watch/namespaces
+// is trimmed into /api/v1/namespaces.
+func trimWatch(k8sPath string) string {
+	ss := strings.Split(k8sPath, "/")
+	idx := -1
+	switch {
+	case strings.HasPrefix(k8sPath, "/api/"):
+		idx = 3
+	case strings.HasPrefix(k8sPath, "/apis/"):
+		idx = 4
+	}
+	if idx > 0 && len(ss) > idx && ss[idx] == "watch" {
+		ss = append(ss[:idx], ss[idx+1:]...)
+	}
+	return strings.Join(ss, "/")
+}
+
+type objectFilterKey struct{}
+
+// WithObjectFilter returns a copy of ctx carries the filter for response
+func WithObjectFilter(ctx context.Context, f *ObjectFilter) context.Context {
+	return context.WithValue(ctx, objectFilterKey{}, f)
+}
+
+// ModifyResponse filters response by the filter carried by context of
+// request, it is used by proxy shared between requests.
+func ModifyResponse(r *http.Response) error {
+	if r.Request == nil {
+		return nil
+	}
+	f, ok := r.Request.Context().Value(objectFilterKey{}).(*ObjectFilter)
+	if !ok || f == nil {
+		return nil
+	}
+	return f.ModifyResponse(r)
+}
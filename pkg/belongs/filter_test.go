@@ -0,0 +1,148 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package belongs
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubecube-io/kubecube/pkg/apis"
+	v1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/multicluster/client/fake"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+func newObjectFilter(t *testing.T, k8sPath string) *ObjectFilter {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	apis.AddToScheme(scheme)
+
+	cli := fake.NewFakeClients(&fake.Options{Scheme: scheme, Objs: []client.Object{
+		&v1.User{ObjectMeta: metav1.ObjectMeta{Name: "alice"}, Status: v1.UserStatus{BelongTenants: []string{"tenant-1"}}},
+		&v1.User{ObjectMeta: metav1.ObjectMeta{Name: "admin"}, Status: v1.UserStatus{PlatformAdmin: true}},
+	}})
+	f, err := NewObjectFilter(context.Background(), cli, k8sPath, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if admin, _ := NewObjectFilter(context.Background(), cli, k8sPath, "admin"); admin != nil {
+		t.Fatalf("platform admin should not be filtered")
+	}
+	return f
+}
+
+func namespaceJSON(name, tenant string) string {
+	return `{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"` + name + `","labels":{"` + constants.HncTenantLabel + `":"` + tenant + `"}}}`
+}
+
+func newResponse(rawURL, body string) *http.Response {
+	u, _ := url.Parse(rawURL)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    &http.Request{URL: u},
+	}
+}
+
+func TestNewObjectFilter(t *testing.T) {
+	for _, p := range []string{"/api/v1/namespaces/ns-1", "/api/v1/namespaces/ns-1/pods", "/version"} {
+		if f := newObjectFilter(t, p); f != nil {
+			t.Fatalf("%v should not be filtered", p)
+		}
+	}
+	for _, p := range []string{"/api/v1/namespaces", "/api/v1/watch/nodes", "/apis/tenant.kubecube.io/v1/projects"} {
+		if f := newObjectFilter(t, p); f == nil {
+			t.Fatalf("%v should be filtered", p)
+		}
+	}
+}
+
+func TestFilterList(t *testing.T) {
+	f := newObjectFilter(t, "/api/v1/namespaces")
+
+	resp := newResponse("/api/v1/namespaces", `{"apiVersion":"v1","kind":"NamespaceList","items":[`+
+		namespaceJSON("ns-1", "tenant-1")+`,`+namespaceJSON("ns-2", "tenant-2")+`]}`)
+	if err := f.ModifyResponse(resp); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(data), "ns-1") || strings.Contains(string(data), "ns-2") {
+		t.Fatalf("only ns-1 should be listed, got %s", data)
+	}
+
+	resp = newResponse("/api/v1/namespaces", `{"apiVersion":"meta.k8s.io/v1","kind":"Table","rows":[`+
+		`{"cells":["ns-1"],"object":`+namespaceJSON("ns-1", "tenant-1")+`},{"cells":["ns-2"],"object":`+namespaceJSON("ns-2", "tenant-2")+`}]}`)
+	if err := f.ModifyResponse(resp); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = io.ReadAll(resp.Body)
+	if !strings.Contains(string(data), "ns-1") || strings.Contains(string(data), "ns-2") {
+		t.Fatalf("only row of ns-1 should be left, got %s", data)
+	}
+}
+
+func TestFilterWatch(t *testing.T) {
+	f := newObjectFilter(t, "/api/v1/namespaces")
+
+	resp := newResponse("/api/v1/namespaces?watch=true",
+		`{"type":"ADDED","object":`+namespaceJSON("ns-1", "tenant-1")+"}\n"+
+			`{"type":"ADDED","object":`+namespaceJSON("ns-2", "tenant-2")+"}\n"+
+			`{"type":"BOOKMARK","object":{"kind":"Namespace","metadata":{"resourceVersion":"10"}}}`+"\n")
+	if err := f.ModifyResponse(resp); err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var types []string
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		event := &watchEvent{}
+		if err := decoder.Decode(event); err != nil {
+			if err != io.EOF {
+				t.Fatal(err)
+			}
+			break
+		}
+		types = append(types, event.Type)
+		if event.Type == "ADDED" && !strings.Contains(string(event.Object), "ns-1") {
+			t.Fatalf("only event of ns-1 should be passed, got %s", event.Object)
+		}
+	}
+	if len(types) != 2 || types[1] != "BOOKMARK" {
+		t.Fatalf("unexpected events: %v", types)
+	}
+}
+
+func TestAcceptJSON(t *testing.T) {
+	header := http.Header{"Accept": []string{"application/vnd.kubernetes.protobuf, application/json"}}
+	AcceptJSON(header)
+	if header.Get("Accept") != "application/json" {
+		t.Fatalf("protobuf should be dropped, got %v", header.Get("Accept"))
+	}
+}
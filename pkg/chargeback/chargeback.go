@@ -0,0 +1,81 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package chargeback samples hard, requested and used resources of projects
+// over time and prices them into showback and chargeback reports.
+//
+// Samples are written into local data dir by the leader only, so reports are
+// only served by the leader and other replicas forward report requests to it.
+// Data dir should be on a persistent volume to keep samples over restarts.
+package chargeback
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kubecube-io/kubecube/pkg/tsdb"
+)
+
+// Options of chargeback, sampling is disabled if SampleIntervalMinutes is 0
+type Options struct {
+	DataDir               string
+	SampleIntervalMinutes int
+	RetentionDays         int
+	PriceFile             string
+}
+
+var (
+	Config = Options{}
+
+	once     sync.Once
+	store    *tsdb.Store
+	storeErr error
+
+	// sampling is true when samples are collected by current process
+	sampling atomic.Bool
+)
+
+// Enabled tells if samples are collected
+func (o Options) Enabled() bool {
+	return o.SampleIntervalMinutes > 0 && len(o.DataDir) > 0
+}
+
+func (o Options) Interval() time.Duration {
+	return time.Duration(o.SampleIntervalMinutes) * time.Minute
+}
+
+// Store returns the store samples are kept in, it is opened once
+func Store() (*tsdb.Store, error) {
+	once.Do(func() {
+		store, storeErr = tsdb.Open(tsdb.Options{
+			Dir:       Config.DataDir,
+			Retention: time.Duration(Config.RetentionDays) * 24 * time.Hour,
+		})
+	})
+	return store, storeErr
+}
+
+// Sampling tells if samples are collected by current process, that is the
+// process is the leader
+func Sampling() bool {
+	return sampling.Load()
+}
+
+// SetSampling marks if samples are collected by current process
+func SetSampling(on bool) {
+	sampling.Store(on)
+}
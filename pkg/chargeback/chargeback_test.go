@@ -0,0 +1,285 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chargeback
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	clienttesting "k8s.io/client-go/testing"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	metricsfake "k8s.io/metrics/pkg/client/clientset/versioned/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
+	mgrclient "github.com/kubecube-io/kubecube/pkg/multicluster/client"
+	"github.com/kubecube-io/kubecube/pkg/multicluster/client/fake"
+	"github.com/kubecube-io/kubecube/pkg/tsdb"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+	"github.com/kubecube-io/kubecube/pkg/utils/env"
+)
+
+func newScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	quotav1.AddToScheme(scheme)
+	return scheme
+}
+
+func pod(ns, name, cpu, memory string, phase v1.PodPhase) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name},
+		Spec: v1.PodSpec{Containers: []v1.Container{{
+			Name: "c",
+			Resources: v1.ResourceRequirements{Requests: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse(cpu),
+				v1.ResourceMemory: resource.MustParse(memory),
+			}},
+		}}},
+		Status: v1.PodStatus{Phase: phase},
+	}
+}
+
+func pvc(ns, name, request, capacity string, phase v1.PersistentVolumeClaimPhase) *v1.PersistentVolumeClaim {
+	c := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name},
+		Spec: v1.PersistentVolumeClaimSpec{Resources: v1.ResourceRequirements{Requests: v1.ResourceList{
+			v1.ResourceStorage: resource.MustParse(request),
+		}}},
+		Status: v1.PersistentVolumeClaimStatus{Phase: phase},
+	}
+	if len(capacity) > 0 {
+		c.Status.Capacity = v1.ResourceList{v1.ResourceStorage: resource.MustParse(capacity)}
+	}
+	return c
+}
+
+func TestCollect(t *testing.T) {
+	scheme := newScheme()
+	pivot := fake.NewFakeClients(&fake.Options{
+		Scheme: scheme,
+		Objs: []client.Object{
+			&quotav1.CubeResourceQuota{
+				ObjectMeta: metav1.ObjectMeta{Name: "c1.tenant.t1", Labels: map[string]string{constants.ClusterLabel: "c1"}},
+				Spec:       quotav1.CubeResourceQuotaSpec{Target: quotav1.TargetObj{Kind: quotav1.TenantObj, Name: "t1"}},
+			},
+			&quotav1.CubeResourceQuota{
+				ObjectMeta: metav1.ObjectMeta{Name: "c1.project.p1", Labels: map[string]string{constants.ClusterLabel: "c1"}},
+				Spec: quotav1.CubeResourceQuotaSpec{
+					Target:      quotav1.TargetObj{Kind: quotav1.ProjectObj, Name: "p1"},
+					ParentQuota: "c1.tenant.t1",
+					Hard: v1.ResourceList{
+						v1.ResourceRequestsCPU:    resource.MustParse("4"),
+						v1.ResourceRequestsMemory: resource.MustParse("8Gi"),
+						v1.ResourceLimitsCPU:      resource.MustParse("8"),
+					},
+				},
+			},
+			&quotav1.CubeResourceQuota{
+				ObjectMeta: metav1.ObjectMeta{Name: "c2.project.p2", Labels: map[string]string{constants.ClusterLabel: "c2", constants.TenantLabel: "t2"}},
+				Spec: quotav1.CubeResourceQuotaSpec{
+					Target: quotav1.TargetObj{Kind: quotav1.ProjectObj, Name: "p2"},
+					Hard:   v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("2")},
+				},
+			},
+		},
+	})
+	member := fake.NewFakeClients(&fake.Options{
+		Scheme: scheme,
+		Objs: []client.Object{
+			&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns1", Labels: map[string]string{constants.HncProjectLabel: "p1"}}},
+			&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns2", Labels: map[string]string{constants.HncProjectLabel: "p1"}}},
+			&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "other"}},
+			pod("ns1", "a", "1", "1Gi", v1.PodRunning),
+			pod("ns2", "b", "500m", "512Mi", v1.PodPending),
+			pod("ns2", "done", "8", "8Gi", v1.PodSucceeded),
+			pod("other", "c", "8", "8Gi", v1.PodRunning),
+			pvc("ns1", "data", "10Gi", "12Gi", v1.ClaimBound),
+			pvc("ns2", "pending", "5Gi", "", v1.ClaimPending),
+			pvc("ns2", "lost", "100Gi", "", v1.ClaimLost),
+			pvc("other", "data", "100Gi", "100Gi", v1.ClaimBound),
+		},
+	})
+	// tracker of fake metrics clientset does not list pod metrics by
+	// resource pods, so list is served by reactor
+	member.Metrics().(*metricsfake.Clientset).PrependReactor("list", "pods", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, &metricsv1beta1.PodMetricsList{Items: []metricsv1beta1.PodMetrics{{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "a"},
+			Containers: []metricsv1beta1.ContainerMetrics{{
+				Name:  "c",
+				Usage: v1.ResourceList{v1.ResourceCPU: resource.MustParse("250m"), v1.ResourceMemory: resource.MustParse("256Mi")},
+			}},
+		}}}, nil
+	})
+	clusterOf := func(cluster string) (mgrclient.Client, error) {
+		if cluster == "c1" {
+			return member, nil
+		}
+		return nil, os.ErrNotExist
+	}
+
+	now := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+	samples, err := NewCollector(pivot.Direct(), clusterOf, 10*time.Minute).Collect(context.Background(), now)
+	if err == nil {
+		t.Errorf("want error of unreachable cluster c2")
+	}
+	if len(samples) != 2 {
+		t.Fatalf("want 2 samples, got %v", len(samples))
+	}
+
+	p1, p2 := samples[0], samples[1]
+	if p1.Labels[LabelCluster] != "c1" || p1.Labels[LabelTenant] != "t1" || p1.Labels[LabelProject] != "p1" {
+		t.Errorf("unexpected labels of p1: %v", p1.Labels)
+	}
+	want := map[string]float64{
+		valueSeconds:                 600,
+		"hard/requests.cpu":          4,
+		"hard/requests.memory":       8,
+		"requested/requests.cpu":     1.5,
+		"requested/requests.memory":  1.5,
+		"used/requests.cpu":          0.25,
+		"used/requests.memory":       0.25,
+		"requested/requests.storage": 15,
+		"used/requests.storage":      12,
+	}
+	for k, v := range want {
+		if math.Abs(p1.Values[k]-v) > 1e-9 {
+			t.Errorf("want %v of p1 to be %v, got %v", k, v, p1.Values[k])
+		}
+	}
+	if _, ok := p1.Values["hard/limits.cpu"]; ok {
+		t.Errorf("limits should not be sampled")
+	}
+
+	// hard is sampled even if cluster is unreachable
+	if p2.Labels[LabelTenant] != "t2" || p2.Values["hard/requests.cpu"] != 2 || len(p2.Values) != 2 {
+		t.Errorf("unexpected sample of p2: %+v", p2)
+	}
+}
+
+func TestReport(t *testing.T) {
+	dir := t.TempDir()
+	priceFile := filepath.Join(dir, "prices.yaml")
+	os.WriteFile(priceFile, []byte(`
+currency: CNY
+default:
+  requests.cpu: 0.1
+  requests.memory: 0.05
+clusters:
+  c2:
+    requests.cpu: 0.2
+`), 0o644)
+	prices, err := LoadPriceTable(priceFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := tsdb.Open(tsdb.Options{Dir: filepath.Join(dir, "data")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 6; i++ {
+		at := start.Add(time.Duration(i) * 30 * time.Minute)
+		store.Append(
+			tsdb.Sample{
+				Time:   at,
+				Labels: map[string]string{LabelCluster: "c1", LabelTenant: "t1", LabelProject: "p1"},
+				Values: map[string]float64{valueSeconds: 1800, "hard/requests.cpu": 4, "hard/requests.memory": 8, "used/requests.cpu": 1},
+			},
+			tsdb.Sample{
+				Time:   at,
+				Labels: map[string]string{LabelCluster: "c2", LabelTenant: "t1", LabelProject: "p2"},
+				Values: map[string]float64{valueSeconds: 1800, "hard/requests.cpu": 2},
+			},
+		)
+	}
+
+	opts := ReportOptions{Start: start, End: start.Add(2 * time.Hour), GroupBy: GroupByProject, Basis: BasisHard}
+	report, err := GenerateReport(store, prices, opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 4 samples of half an hour are in range
+	if len(report.Items) != 2 {
+		t.Fatalf("want 2 items, got %+v", report.Items)
+	}
+	p1, p2 := report.Items[0], report.Items[1]
+	if p1.Project != "p1" || p1.Usage[v1.ResourceRequestsCPU] != 8 || math.Abs(p1.Total-(8*0.1+16*0.05)) > 1e-9 {
+		t.Errorf("unexpected item of p1: %+v", p1)
+	}
+	if p2.Project != "p2" || math.Abs(p2.Total-4*0.2) > 1e-9 {
+		t.Errorf("unexpected item of p2: %+v", p2)
+	}
+	if math.Abs(report.Total-2.4) > 1e-9 || report.Currency != "CNY" {
+		t.Errorf("unexpected report total %v %v", report.Total, report.Currency)
+	}
+
+	opts.GroupBy, opts.Basis = GroupByTenant, BasisUsed
+	report, _ = GenerateReport(store, prices, opts, tsdb.Matcher{LabelCluster: "c1"})
+	if len(report.Items) != 1 || report.Items[0].Tenant != "t1" || math.Abs(report.Total-0.2) > 1e-9 {
+		t.Errorf("unexpected report of used: %+v", report)
+	}
+
+	buf := &bytes.Buffer{}
+	if err = report.WriteCSV(buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "tenant,requests.cpu (unit-hours)") || !strings.HasPrefix(lines[1], "t1,2.0000,0.2000,") {
+		t.Errorf("unexpected csv: %v", buf.String())
+	}
+
+	opts.GroupBy = "namespace"
+	if _, err = GenerateReport(store, prices, opts, nil); err == nil {
+		t.Errorf("want error of invalid groupBy")
+	}
+}
+
+func TestLeaderIP(t *testing.T) {
+	holder := "kubecube-7d9f8-abcde_0f1e2d3c-4b5a-6978-8695-a4b3c2d1e0f9"
+	cli := fake.NewFakeClients(&fake.Options{Scheme: newScheme(), Objs: []client.Object{
+		&coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: constants.CubeLeaderElectionID, Namespace: env.CubeNamespace()},
+			Spec:       coordinationv1.LeaseSpec{HolderIdentity: &holder},
+		},
+		&v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "kubecube-7d9f8-abcde", Namespace: env.CubeNamespace()},
+			Status:     v1.PodStatus{PodIP: "10.0.0.12"},
+		},
+	}})
+
+	ip, err := LeaderIP(context.Background(), cli.Direct())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip != "10.0.0.12" {
+		t.Errorf("want ip of leader pod, got %v", ip)
+	}
+}
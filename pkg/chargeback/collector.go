@@ -0,0 +1,294 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chargeback
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
+	"github.com/kubecube-io/kubecube/pkg/clients"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	mgrclient "github.com/kubecube-io/kubecube/pkg/multicluster/client"
+	"github.com/kubecube-io/kubecube/pkg/quota"
+	"github.com/kubecube-io/kubecube/pkg/tsdb"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+// Labels of samples
+const (
+	LabelCluster = "cluster"
+	LabelTenant  = "tenant"
+	LabelProject = "project"
+)
+
+// valueSeconds is the key of sample value tells how long the sample stands for
+const valueSeconds = "seconds"
+
+const gib = 1 << 30
+
+// Resources are the resources priced, cpu is counted in cores, memory and
+// storage are counted in GiB and gpu is counted in cards. Storage requested
+// is the requests of claims and storage used is the capacity of bound claims.
+var Resources = []v1.ResourceName{
+	v1.ResourceRequestsCPU,
+	v1.ResourceRequestsMemory,
+	quota.ResourceNvidiaGPU,
+	v1.ResourceRequestsStorage,
+}
+
+// podResources maps resources of pod to the priced resources
+var podResources = map[v1.ResourceName]v1.ResourceName{
+	v1.ResourceCPU:    v1.ResourceRequestsCPU,
+	v1.ResourceMemory: v1.ResourceRequestsMemory,
+	"nvidia.com/gpu":  quota.ResourceNvidiaGPU,
+}
+
+// ClusterClientFunc returns the client of cluster where pods and metrics
+// of projects are read from.
+type ClusterClientFunc func(cluster string) (mgrclient.Client, error)
+
+// ClusterClient returns the client of cluster managed by kubecube
+func ClusterClient(cluster string) (mgrclient.Client, error) {
+	cli := clients.Interface().Kubernetes(cluster)
+	if cli == nil {
+		return nil, fmt.Errorf("cluster %v not found", cluster)
+	}
+	return cli, nil
+}
+
+// Collector samples hard of project quotas, requests of pods and usage of
+// pods reported by metrics server for each project of each cluster.
+type Collector struct {
+	pivot     client.Reader
+	clusterOf ClusterClientFunc
+	interval  time.Duration
+}
+
+func NewCollector(pivot client.Reader, clusterOf ClusterClientFunc, interval time.Duration) *Collector {
+	return &Collector{pivot: pivot, clusterOf: clusterOf, interval: interval}
+}
+
+// projectKey identifies a project in cluster
+type projectKey struct {
+	cluster string
+	project string
+}
+
+type projectSample struct {
+	tenant    string
+	hard      v1.ResourceList
+	requested v1.ResourceList
+	used      v1.ResourceList
+}
+
+// Collect returns samples of projects at now, each sample stands for the
+// interval of collector. Clusters can not be read such as unreachable ones
+// only have hard sampled, and the errors are returned with samples.
+func (c *Collector) Collect(ctx context.Context, now time.Time) ([]tsdb.Sample, error) {
+	list := &quotav1.CubeResourceQuotaList{}
+	if err := c.pivot.List(ctx, list); err != nil {
+		return nil, err
+	}
+	quotas := make(map[string]*quotav1.CubeResourceQuota, len(list.Items))
+	for i := range list.Items {
+		quotas[list.Items[i].Name] = &list.Items[i]
+	}
+
+	projects := make(map[projectKey]*projectSample)
+	clusters := make(map[string]struct{})
+	for _, q := range list.Items {
+		if q.Spec.Target.Kind != quotav1.ProjectObj || q.DeletionTimestamp != nil {
+			continue
+		}
+		cluster := q.Labels[constants.ClusterLabel]
+		tenant := q.Labels[constants.TenantLabel]
+		if parent, ok := quotas[q.Spec.ParentQuota]; ok && parent.Spec.Target.Kind == quotav1.TenantObj {
+			tenant = parent.Spec.Target.Name
+		}
+		projects[projectKey{cluster: cluster, project: q.Spec.Target.Name}] = &projectSample{
+			tenant:    tenant,
+			hard:      q.Spec.Hard,
+			requested: v1.ResourceList{},
+			used:      v1.ResourceList{},
+		}
+		clusters[cluster] = struct{}{}
+	}
+
+	var errs []error
+	for cluster := range clusters {
+		if err := c.collectCluster(ctx, cluster, projects); err != nil {
+			errs = append(errs, fmt.Errorf("collect cluster %v failed: %v", cluster, err))
+		}
+	}
+
+	keys := make([]projectKey, 0, len(projects))
+	for k := range projects {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].cluster != keys[j].cluster {
+			return keys[i].cluster < keys[j].cluster
+		}
+		return keys[i].project < keys[j].project
+	})
+
+	samples := make([]tsdb.Sample, 0, len(keys))
+	for _, k := range keys {
+		p := projects[k]
+		values := map[string]float64{valueSeconds: c.interval.Seconds()}
+		for _, rs := range Resources {
+			putValue(values, BasisHard, rs, p.hard)
+			putValue(values, BasisRequested, rs, p.requested)
+			putValue(values, BasisUsed, rs, p.used)
+		}
+		samples = append(samples, tsdb.Sample{
+			Time:   now,
+			Labels: map[string]string{LabelCluster: k.cluster, LabelTenant: p.tenant, LabelProject: k.project},
+			Values: values,
+		})
+	}
+
+	return samples, utilerrors.NewAggregate(errs)
+}
+
+// collectCluster sums requests and usage of pods and claims into projects of
+// cluster by the project label of namespaces
+func (c *Collector) collectCluster(ctx context.Context, cluster string, projects map[projectKey]*projectSample) error {
+	cli, err := c.clusterOf(cluster)
+	if err != nil {
+		return err
+	}
+
+	selector, err := labels.Parse(constants.HncProjectLabel)
+	if err != nil {
+		return err
+	}
+	nsList := &v1.NamespaceList{}
+	if err = cli.Direct().List(ctx, nsList, &client.ListOptions{LabelSelector: selector}); err != nil {
+		return err
+	}
+	owners := make(map[string]*projectSample, len(nsList.Items))
+	for _, ns := range nsList.Items {
+		if p, ok := projects[projectKey{cluster: cluster, project: ns.Labels[constants.HncProjectLabel]}]; ok {
+			owners[ns.Name] = p
+		}
+	}
+	if len(owners) == 0 {
+		return nil
+	}
+
+	pods := &v1.PodList{}
+	if err = cli.Direct().List(ctx, pods); err != nil {
+		return err
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		p, ok := owners[pod.Namespace]
+		if !ok || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		reqs, _ := quota.PodRequestsAndLimits(pod)
+		addPodResources(p.requested, reqs)
+	}
+
+	pvcs := &v1.PersistentVolumeClaimList{}
+	if err = cli.Direct().List(ctx, pvcs); err != nil {
+		return err
+	}
+	for i := range pvcs.Items {
+		pvc := &pvcs.Items[i]
+		p, ok := owners[pvc.Namespace]
+		if !ok || pvc.Status.Phase == v1.ClaimLost {
+			continue
+		}
+		addStorage(p.requested, pvc.Spec.Resources.Requests)
+		if pvc.Status.Phase == v1.ClaimBound {
+			addStorage(p.used, pvc.Status.Capacity)
+		}
+	}
+
+	// usage is optional since metrics server may not be installed
+	metrics, err := cli.Metrics().MetricsV1beta1().PodMetricses(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		clog.Warn("list pod metrics of cluster %v failed: %v", cluster, err)
+		return nil
+	}
+	for _, m := range metrics.Items {
+		p, ok := owners[m.Namespace]
+		if !ok {
+			continue
+		}
+		for _, container := range m.Containers {
+			addPodResources(p.used, container.Usage)
+		}
+	}
+	return nil
+}
+
+func addPodResources(list, new v1.ResourceList) {
+	for name, quantity := range new {
+		rs, ok := podResources[name]
+		if !ok {
+			continue
+		}
+		value := list[rs]
+		value.Add(quantity)
+		list[rs] = value
+	}
+}
+
+func addStorage(list, new v1.ResourceList) {
+	quantity, ok := new[v1.ResourceStorage]
+	if !ok {
+		return
+	}
+	value := list[v1.ResourceRequestsStorage]
+	value.Add(quantity)
+	list[v1.ResourceRequestsStorage] = value
+}
+
+func putValue(values map[string]float64, basis Basis, rs v1.ResourceName, list v1.ResourceList) {
+	q, ok := list[rs]
+	if !ok {
+		return
+	}
+	values[valueKey(basis, rs)] = unitsOf(rs, q)
+}
+
+// unitsOf converts quantity into the unit resource is priced in
+func unitsOf(rs v1.ResourceName, q resource.Quantity) float64 {
+	switch rs {
+	case v1.ResourceRequestsMemory, v1.ResourceRequestsStorage:
+		return q.AsApproximateFloat64() / gib
+	default:
+		return q.AsApproximateFloat64()
+	}
+}
+
+func valueKey(basis Basis, rs v1.ResourceName) string {
+	return string(basis) + "/" + string(rs)
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chargeback
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+	"github.com/kubecube-io/kubecube/pkg/utils/env"
+)
+
+// LeaderIP returns the pod ip of leader which samples are kept by. Holder
+// identity of lease is "<hostname>_<uuid>" and hostname is the pod name.
+func LeaderIP(ctx context.Context, cli client.Reader) (string, error) {
+	lease := &coordinationv1.Lease{}
+	err := cli.Get(ctx, types.NamespacedName{Name: constants.CubeLeaderElectionID, Namespace: env.CubeNamespace()}, lease)
+	if err != nil {
+		return "", err
+	}
+	if lease.Spec.HolderIdentity == nil || len(*lease.Spec.HolderIdentity) == 0 {
+		return "", fmt.Errorf("no leader holds lease %v", lease.Name)
+	}
+	podName, _, _ := strings.Cut(*lease.Spec.HolderIdentity, "_")
+
+	pod := &v1.Pod{}
+	if err = cli.Get(ctx, types.NamespacedName{Name: podName, Namespace: env.CubeNamespace()}, pod); err != nil {
+		return "", err
+	}
+	if len(pod.Status.PodIP) == 0 {
+		return "", fmt.Errorf("leader pod %v has no ip", podName)
+	}
+	return pod.Status.PodIP, nil
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chargeback
+
+import (
+	"fmt"
+	"os"
+
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// PriceTable is the price of resources per unit per hour, for example:
+//
+//	currency: CNY
+//	default:
+//	  requests.cpu: 0.1
+//	  requests.memory: 0.05
+//	clusters:
+//	  gpu-cluster:
+//	    requests.cpu: 0.2
+//	    requests.nvidia.com/gpu: 8
+//
+// Prices of cluster override the default ones, resources without price are
+// free.
+type PriceTable struct {
+	Currency string                                 `json:"currency,omitempty"`
+	Default  map[v1.ResourceName]float64            `json:"default,omitempty"`
+	Clusters map[string]map[v1.ResourceName]float64 `json:"clusters,omitempty"`
+}
+
+// LoadPriceTable loads price table from yaml file, all resources are free
+// if path is empty
+func LoadPriceTable(path string) (*PriceTable, error) {
+	table := &PriceTable{}
+	if len(path) == 0 {
+		return table, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = yaml.Unmarshal(data, table); err != nil {
+		return nil, fmt.Errorf("parse price table %v failed: %v", path, err)
+	}
+	if err = table.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid price table %v: %v", path, err)
+	}
+	return table, nil
+}
+
+// Validate checks prices are not negative
+func (t *PriceTable) Validate() error {
+	check := func(prices map[v1.ResourceName]float64) error {
+		for rs, price := range prices {
+			if price < 0 {
+				return fmt.Errorf("price of %v is negative", rs)
+			}
+		}
+		return nil
+	}
+	if err := check(t.Default); err != nil {
+		return err
+	}
+	for cluster, prices := range t.Clusters {
+		if err := check(prices); err != nil {
+			return fmt.Errorf("cluster %v: %v", cluster, err)
+		}
+	}
+	return nil
+}
+
+// PriceOf returns price of resource per unit per hour in cluster
+func (t *PriceTable) PriceOf(cluster string, rs v1.ResourceName) float64 {
+	if price, ok := t.Clusters[cluster][rs]; ok {
+		return price
+	}
+	return t.Default[rs]
+}
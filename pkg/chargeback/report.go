@@ -0,0 +1,232 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chargeback
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/kubecube-io/kubecube/pkg/tsdb"
+)
+
+// Basis is what resources are charged by
+type Basis string
+
+const (
+	// BasisHard charges hard of quota, that is what reserved for project
+	BasisHard Basis = "hard"
+	// BasisRequested charges requests of running pods
+	BasisRequested Basis = "requested"
+	// BasisUsed charges usage of pods reported by metrics server
+	BasisUsed Basis = "used"
+)
+
+type GroupBy string
+
+const (
+	GroupByTenant  GroupBy = "tenant"
+	GroupByProject GroupBy = "project"
+	GroupByCluster GroupBy = "cluster"
+)
+
+// ReportOptions are options to build report with
+type ReportOptions struct {
+	Start   time.Time
+	End     time.Time
+	GroupBy GroupBy
+	Basis   Basis
+}
+
+func (o *ReportOptions) Validate() error {
+	switch o.GroupBy {
+	case GroupByTenant, GroupByProject, GroupByCluster:
+	default:
+		return fmt.Errorf("groupBy must be one of tenant, project and cluster")
+	}
+	switch o.Basis {
+	case BasisHard, BasisRequested, BasisUsed:
+	default:
+		return fmt.Errorf("basis must be one of hard, requested and used")
+	}
+	if !o.Start.Before(o.End) {
+		return fmt.Errorf("start must be before end")
+	}
+	return nil
+}
+
+// ReportItem is the cost of a group, labels not grouped by are empty
+type ReportItem struct {
+	Cluster string `json:"cluster,omitempty"`
+	Tenant  string `json:"tenant,omitempty"`
+	Project string `json:"project,omitempty"`
+	// Usage is the amount of resources in unit-hours
+	Usage map[v1.ResourceName]float64 `json:"usage"`
+	Cost  map[v1.ResourceName]float64 `json:"cost"`
+	Total float64                     `json:"total"`
+}
+
+type Report struct {
+	Start    time.Time    `json:"start"`
+	End      time.Time    `json:"end"`
+	GroupBy  GroupBy      `json:"groupBy"`
+	Basis    Basis        `json:"basis"`
+	Currency string       `json:"currency,omitempty"`
+	Items    []ReportItem `json:"items"`
+	Total    float64      `json:"total"`
+}
+
+// GenerateReport builds report from samples in store matched by matcher
+func GenerateReport(store *tsdb.Store, prices *PriceTable, opts ReportOptions, matcher tsdb.Matcher) (*Report, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	samples, err := store.Query(opts.Start, opts.End, matcher)
+	if err != nil {
+		return nil, err
+	}
+	return BuildReport(samples, prices, opts), nil
+}
+
+// BuildReport integrates samples over the time they stand for and prices
+// them with prices of their cluster
+func BuildReport(samples []tsdb.Sample, prices *PriceTable, opts ReportOptions) *Report {
+	report := &Report{
+		Start:    opts.Start,
+		End:      opts.End,
+		GroupBy:  opts.GroupBy,
+		Basis:    opts.Basis,
+		Currency: prices.Currency,
+		Items:    []ReportItem{},
+	}
+
+	groups := make(map[groupKey]*ReportItem)
+	for _, s := range samples {
+		hours := s.Values[valueSeconds] / 3600
+		if hours <= 0 {
+			continue
+		}
+		cluster := s.Labels[LabelCluster]
+		key := groupOf(s.Labels, opts.GroupBy)
+		item, ok := groups[key]
+		if !ok {
+			item = &ReportItem{
+				Cluster: key.cluster,
+				Tenant:  key.tenant,
+				Project: key.project,
+				Usage:   map[v1.ResourceName]float64{},
+				Cost:    map[v1.ResourceName]float64{},
+			}
+			groups[key] = item
+		}
+		for _, rs := range Resources {
+			value, ok := s.Values[valueKey(opts.Basis, rs)]
+			if !ok {
+				continue
+			}
+			usage := value * hours
+			cost := usage * prices.PriceOf(cluster, rs)
+			item.Usage[rs] += usage
+			item.Cost[rs] += cost
+			item.Total += cost
+			report.Total += cost
+		}
+	}
+
+	for _, item := range groups {
+		report.Items = append(report.Items, *item)
+	}
+	sort.Slice(report.Items, func(i, j int) bool {
+		a, b := report.Items[i], report.Items[j]
+		if a.Cluster != b.Cluster {
+			return a.Cluster < b.Cluster
+		}
+		if a.Tenant != b.Tenant {
+			return a.Tenant < b.Tenant
+		}
+		return a.Project < b.Project
+	})
+	return report
+}
+
+type groupKey struct {
+	cluster string
+	tenant  string
+	project string
+}
+
+// groupOf returns the labels of group sample belongs to, project is grouped
+// with its tenant
+func groupOf(labels map[string]string, groupBy GroupBy) groupKey {
+	switch groupBy {
+	case GroupByCluster:
+		return groupKey{cluster: labels[LabelCluster]}
+	case GroupByProject:
+		return groupKey{tenant: labels[LabelTenant], project: labels[LabelProject]}
+	default:
+		return groupKey{tenant: labels[LabelTenant]}
+	}
+}
+
+// WriteCSV writes report as csv, a row for each group with usage and cost
+// of each resource
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+
+	header := []string{string(r.GroupBy)}
+	if r.GroupBy == GroupByProject {
+		header = []string{string(GroupByTenant), string(GroupByProject)}
+	}
+	for _, rs := range Resources {
+		header = append(header, string(rs)+" (unit-hours)", string(rs)+" (cost)")
+	}
+	header = append(header, "total")
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, item := range r.Items {
+		var row []string
+		switch r.GroupBy {
+		case GroupByCluster:
+			row = []string{item.Cluster}
+		case GroupByProject:
+			row = []string{item.Tenant, item.Project}
+		default:
+			row = []string{item.Tenant}
+		}
+		for _, rs := range Resources {
+			row = append(row, formatFloat(item.Usage[rs]), formatFloat(item.Cost[rs]))
+		}
+		row = append(row, formatFloat(item.Total))
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 4, 64)
+}
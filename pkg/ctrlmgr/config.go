@@ -27,6 +27,14 @@ type Config struct {
 	ScoutWaitTimeoutSeconds int
 	// ScoutInitialDelaySeconds the time that wait for warden start
 	ScoutInitialDelaySeconds int
+	// KeyIdleTimeoutDays disables keys not used for such days, never disable if 0
+	KeyIdleTimeoutDays int
+	// LdapSyncIntervalMinutes is the interval of syncing ldap users with directory, never sync if 0
+	LdapSyncIntervalMinutes int
+	// QuotaDriftCheckIntervalMinutes is the interval of checking drift of cube resource quotas, never check if 0
+	QuotaDriftCheckIntervalMinutes int
+	// QuotaDriftRepair repairs drifted cube resource quotas found by periodic check if true
+	QuotaDriftRepair bool
 }
 
 func (c *Config) Validate() []error {
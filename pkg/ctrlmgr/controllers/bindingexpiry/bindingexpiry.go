@@ -0,0 +1,149 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bindingexpiry
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/apiserver/middlewares/audit"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/ctrlmgr/options"
+	auditinfo "github.com/kubecube-io/kubecube/pkg/utils/audit"
+)
+
+// BindingExpiryReconciler removes expired scope bindings from users and
+// groups, wardens of every cluster clean up the bindings rendered for them
+// once the spec changed.
+type BindingExpiryReconciler struct {
+	client.Client
+
+	// kind is the kind of object reconciled, user or group
+	kind string
+	// newObject returns an empty User or Group
+	newObject func() client.Object
+	// scopeBindings returns pointer to scope bindings of object
+	scopeBindings func(obj client.Object) *[]userv1.ScopeBinding
+
+	now func() time.Time
+}
+
+func newUserReconciler(cli client.Client) *BindingExpiryReconciler {
+	return &BindingExpiryReconciler{
+		Client:    cli,
+		kind:      "user",
+		newObject: func() client.Object { return &userv1.User{} },
+		scopeBindings: func(obj client.Object) *[]userv1.ScopeBinding {
+			return &obj.(*userv1.User).Spec.ScopeBindings
+		},
+		now: time.Now,
+	}
+}
+
+func newGroupReconciler(cli client.Client) *BindingExpiryReconciler {
+	return &BindingExpiryReconciler{
+		Client:    cli,
+		kind:      "group",
+		newObject: func() client.Object { return &userv1.Group{} },
+		scopeBindings: func(obj client.Object) *[]userv1.ScopeBinding {
+			return &obj.(*userv1.Group).Spec.ScopeBindings
+		},
+		now: time.Now,
+	}
+}
+
+//+kubebuilder:rbac:groups=user.kubecube.io,resources=users,verbs=get;list;watch;update
+//+kubebuilder:rbac:groups=user.kubecube.io,resources=groups,verbs=get;list;watch;update
+
+func (r *BindingExpiryReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var active, expired []userv1.ScopeBinding
+	now := r.now()
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		obj := r.newObject()
+		if err := r.Get(ctx, types.NamespacedName{Name: req.Name}, obj); err != nil {
+			return err
+		}
+		bindings := r.scopeBindings(obj)
+		active = userv1.ActiveScopeBindings(*bindings, now)
+		expired = nil
+		if len(active) == len(*bindings) || obj.GetDeletionTimestamp() != nil {
+			return nil
+		}
+		for _, b := range *bindings {
+			if b.IsExpired(now) {
+				expired = append(expired, b)
+			}
+		}
+		*bindings = active
+		return r.Update(ctx, obj)
+	})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	for _, b := range expired {
+		clog.Info("scope binding of %v %v expired at %v: type (%v), scope (%v), role (%v)", r.kind, req.Name, b.ExpireTime, b.ScopeType, b.ScopeName, b.Role)
+		reportExpired(r.kind, req.Name, b)
+	}
+
+	// check again when the next binding expires
+	return ctrl.Result{RequeueAfter: userv1.NextScopeBindingExpiry(active, now)}, nil
+}
+
+func reportExpired(kind string, subject string, b userv1.ScopeBinding) {
+	body, _ := json.Marshal(struct {
+		Kind    string              `json:"kind"`
+		Binding userv1.ScopeBinding `json:"binding"`
+	}{kind, b})
+	audit.ReportEvent(&audit.Event{
+		EventName:         auditinfo.ExpireBinding.EventName,
+		Description:       auditinfo.ExpireBinding.Description,
+		RequestParameters: string(body),
+		ResourceReports: []audit.Resource{{
+			ResourceType: auditinfo.ExpireBinding.ResourceType,
+			ResourceName: subject,
+		}},
+	})
+}
+
+// SetupWithManager sets up the controllers of users and groups with the Manager.
+func SetupWithManager(mgr ctrl.Manager, _ *options.Options) error {
+	err := ctrl.NewControllerManagedBy(mgr).
+		Named("user-binding-expiry").
+		For(&userv1.User{}).
+		Complete(newUserReconciler(mgr.GetClient()))
+	if err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("group-binding-expiry").
+		For(&userv1.Group{}).
+		Complete(newGroupReconciler(mgr.GetClient()))
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bindingexpiry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+)
+
+func TestReconcileUser(t *testing.T) {
+	t.Setenv("AUDIT_IS_ENABLE", "false")
+
+	now := time.Now()
+	at := func(d time.Duration) *metav1.Time {
+		return &metav1.Time{Time: now.Add(d).Truncate(time.Second)}
+	}
+	scheme := runtime.NewScheme()
+	userv1.AddToScheme(scheme)
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&userv1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "alice"},
+		Spec: userv1.UserSpec{ScopeBindings: []userv1.ScopeBinding{
+			{ScopeType: userv1.PlatformScope, ScopeName: "platform", Role: "platform-admin", ExpireTime: at(-time.Minute)},
+			{ScopeType: userv1.TenantScope, ScopeName: "tenant-1", Role: "tenant-admin", ExpireTime: at(time.Hour)},
+			{ScopeType: userv1.ProjectScope, ScopeName: "project-1", Role: "reviewer"},
+		}},
+	}).Build()
+
+	r := newUserReconciler(cli)
+	r.now = func() time.Time { return now }
+
+	res, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "alice"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := at(time.Hour).Sub(now); res.RequeueAfter != want {
+		t.Fatalf("should requeue when next binding expires, want %v got %v", want, res.RequeueAfter)
+	}
+
+	user := &userv1.User{}
+	if err = cli.Get(context.Background(), types.NamespacedName{Name: "alice"}, user); err != nil {
+		t.Fatal(err)
+	}
+	if len(user.Spec.ScopeBindings) != 2 || user.Spec.ScopeBindings[0].ScopeName != "tenant-1" {
+		t.Fatalf("expired binding should be removed only, got %+v", user.Spec.ScopeBindings)
+	}
+
+	// nothing expires any more once the tenant binding removed
+	r.now = func() time.Time { return now.Add(2 * time.Hour) }
+	res, err = r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "alice"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.RequeueAfter != 0 {
+		t.Fatalf("should not requeue without expiring binding, got %v", res.RequeueAfter)
+	}
+}
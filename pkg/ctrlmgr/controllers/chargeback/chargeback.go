@@ -0,0 +1,72 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chargeback
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/kubecube-io/kubecube/pkg/chargeback"
+	"github.com/kubecube-io/kubecube/pkg/clients"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/ctrlmgr/options"
+	"github.com/kubecube-io/kubecube/pkg/tsdb"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+// SetupWithManager adds periodic sampling of resources of projects for
+// chargeback into manager, the sampling only runs on leader.
+func SetupWithManager(mgr ctrl.Manager, _ *options.Options) error {
+	if !chargeback.Config.Enabled() {
+		return nil
+	}
+	store, err := chargeback.Store()
+	if err != nil {
+		return err
+	}
+	interval := chargeback.Config.Interval()
+
+	return mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		chargeback.SetSampling(true)
+		defer chargeback.SetSampling(false)
+		wait.UntilWithContext(ctx, func(ctx context.Context) {
+			pivot := clients.Interface().Kubernetes(constants.LocalCluster).Direct()
+			collectOnce(ctx, chargeback.NewCollector(pivot, chargeback.ClusterClient, interval), store)
+		}, interval)
+		return nil
+	}))
+}
+
+func collectOnce(ctx context.Context, collector *chargeback.Collector, store *tsdb.Store) {
+	now := time.Now()
+	samples, err := collector.Collect(ctx, now)
+	if err != nil {
+		clog.Warn("collect samples of chargeback failed: %v", err)
+	}
+	if len(samples) > 0 {
+		if err = store.Append(samples...); err != nil {
+			clog.Error("append samples of chargeback failed: %v", err)
+		}
+	}
+	if err = store.Compact(now); err != nil {
+		clog.Warn("compact samples of chargeback failed: %v", err)
+	}
+}
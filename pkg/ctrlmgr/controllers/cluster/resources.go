@@ -44,6 +44,7 @@ const (
 	kubeConfigSecretName = "kubeconfigs"
 	tlsSecretName        = "cube-tls-secret"
 	webhookName          = "warden-validating-webhook-configuration"
+	mutatingWebhookName  = "warden-mutating-webhook-configuration"
 	appKey               = "kubecube.io/app"
 	masterMark           = "node-role.kubernetes.io/master"
 	existsOp             = "Exists"
@@ -151,6 +152,14 @@ func deployResources(ctx context.Context, cli client.Client, memberCluster, pivo
 		return err
 	}
 
+	// create mutating webhook to target cluster if pivot cluster has it
+	if mwh := makeWardenMutatingWebhook(); mwh != nil {
+		err = createResource(ctx, mwh, cli, memberCluster.Name, "mutatingWebhookConfiguration")
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -530,6 +539,24 @@ func makeWardenWebhook() *v1.ValidatingWebhookConfiguration {
 	}
 }
 
+func makeWardenMutatingWebhook() *v1.MutatingWebhookConfiguration {
+	pClient := clients.Interface().Kubernetes(constants.LocalCluster).Cache()
+	wh := v1.MutatingWebhookConfiguration{}
+	key := types.NamespacedName{Name: mutatingWebhookName}
+	err := pClient.Get(context.Background(), key, &wh)
+	if err != nil {
+		log.Warn(err.Error())
+		return nil
+	}
+
+	return &v1.MutatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: wh.Name,
+		},
+		Webhooks: wh.Webhooks,
+	}
+}
+
 func makeTLSSecret() *corev1.Secret {
 	pClient := clients.Interface().Kubernetes(constants.LocalCluster).Cache()
 	secret := corev1.Secret{}
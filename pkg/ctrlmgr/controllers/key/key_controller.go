@@ -0,0 +1,148 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package key
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/authentication/session"
+	"github.com/kubecube-io/kubecube/pkg/clients"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/ctrlmgr/options"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+const (
+	reasonExpired  = "key expired"
+	reasonDisabled = "key disabled"
+)
+
+// KeyReconciler maintains phase of keys, disables keys not used for a
+// long time and revokes tokens issued by keys no longer available.
+type KeyReconciler struct {
+	client.Client
+
+	// idleTimeout disables keys not used for such duration, never disable if 0
+	idleTimeout time.Duration
+}
+
+func newReconciler(mgr manager.Manager, idleTimeoutDays int) (*KeyReconciler, error) {
+	return &KeyReconciler{
+		Client:      mgr.GetClient(),
+		idleTimeout: time.Duration(idleTimeoutDays) * 24 * time.Hour,
+	}, nil
+}
+
+//+kubebuilder:rbac:groups=user.kubecube.io,resources=keys,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=user.kubecube.io,resources=keys/status,verbs=get;update;patch
+
+func (r *KeyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	key := &userv1.Key{}
+	if err := r.Get(ctx, req.NamespacedName, key); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	now := time.Now()
+
+	lastUsed := key.CreationTimestamp.Time
+	if key.Status.LastUsedTime != nil && key.Status.LastUsedTime.After(lastUsed) {
+		lastUsed = key.Status.LastUsedTime.Time
+	}
+
+	// disable stale key
+	if r.idleTimeout > 0 && !key.Spec.Disabled {
+		if now.Sub(lastUsed) >= r.idleTimeout {
+			clog.Info("key %v of user %v not used since %v, disable it", key.Name, key.Spec.User, lastUsed)
+			key.Spec.Disabled = true
+			if err := r.Update(ctx, key); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{}, r.invalidate(ctx, key, userv1.KeyDisabled, fmt.Sprintf("not used since %v", lastUsed.Format(time.RFC3339)))
+		}
+	}
+
+	switch {
+	case key.Spec.Disabled:
+		// keep the reason of disabled by idle
+		reason := reasonDisabled
+		if key.Status.Phase == userv1.KeyDisabled && len(key.Status.Reason) > 0 {
+			reason = key.Status.Reason
+		}
+		return ctrl.Result{}, r.invalidate(ctx, key, userv1.KeyDisabled, reason)
+	case !key.IsAvailable(now):
+		return ctrl.Result{}, r.invalidate(ctx, key, userv1.KeyExpired, reasonExpired)
+	}
+
+	if err := r.updateStatus(ctx, key, userv1.KeyActive, ""); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// check again when the key expires or becomes stale
+	var requeueAfter time.Duration
+	if key.Spec.ExpireTime != nil {
+		requeueAfter = key.Spec.ExpireTime.Sub(now)
+	}
+	if r.idleTimeout > 0 {
+		idleLeft := r.idleTimeout - now.Sub(lastUsed)
+		if requeueAfter == 0 || idleLeft < requeueAfter {
+			requeueAfter = idleLeft
+		}
+	}
+
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// invalidate revokes tokens issued by key and records the phase
+func (r *KeyReconciler) invalidate(ctx context.Context, key *userv1.Key, phase userv1.KeyPhase, reason string) error {
+	sessions := session.NewManager(clients.Interface().Kubernetes(constants.LocalCluster))
+	if err := sessions.RevokeByKey(ctx, key.Name, session.ReasonKeyInvalid); err != nil {
+		return err
+	}
+	return r.updateStatus(ctx, key, phase, reason)
+}
+
+func (r *KeyReconciler) updateStatus(ctx context.Context, key *userv1.Key, phase userv1.KeyPhase, reason string) error {
+	if key.Status.Phase == phase && key.Status.Reason == reason {
+		return nil
+	}
+	key.Status.Phase = phase
+	key.Status.Reason = reason
+	return r.Status().Update(ctx, key)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func SetupWithManager(mgr ctrl.Manager, opts *options.Options) error {
+	r, err := newReconciler(mgr, opts.KeyIdleTimeoutDays)
+	if err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&userv1.Key{}).
+		Complete(r)
+}
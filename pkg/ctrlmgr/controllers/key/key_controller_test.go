@@ -0,0 +1,51 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package key
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+)
+
+func TestRequeueWhenIdle(t *testing.T) {
+	scheme := runtime.NewScheme()
+	userv1.AddToScheme(scheme)
+	key := &userv1.Key{
+		ObjectMeta: metav1.ObjectMeta{Name: "ak", CreationTimestamp: metav1.NewTime(time.Now().Add(-48 * time.Hour))},
+		Status:     userv1.KeyStatus{LastUsedTime: &metav1.Time{Time: time.Now().Add(-20 * time.Hour)}},
+	}
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(key).WithStatusSubresource(key).Build()
+	r := &KeyReconciler{Client: cli, idleTimeout: 24 * time.Hour}
+
+	res, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "ak"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// key should be checked again when it has been idle for idle timeout
+	if res.RequeueAfter <= 3*time.Hour || res.RequeueAfter > 4*time.Hour {
+		t.Fatalf("want requeue after about 4h, got %v", res.RequeueAfter)
+	}
+}
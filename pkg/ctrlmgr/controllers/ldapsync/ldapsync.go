@@ -0,0 +1,58 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ldapsync
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/kubecube-io/kubecube/pkg/authentication/identityprovider/ldap"
+	"github.com/kubecube-io/kubecube/pkg/authentication/ldapsync"
+	"github.com/kubecube-io/kubecube/pkg/clients"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/ctrlmgr/options"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+// SetupWithManager adds periodic ldap directory sync into manager, the sync
+// only runs on leader.
+func SetupWithManager(mgr ctrl.Manager, opts *options.Options) error {
+	if !ldap.IsLdapOpen() || opts.LdapSyncIntervalMinutes <= 0 {
+		return nil
+	}
+	interval := time.Duration(opts.LdapSyncIntervalMinutes) * time.Minute
+
+	return mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		wait.UntilWithContext(ctx, syncOnce, interval)
+		return nil
+	}))
+}
+
+func syncOnce(ctx context.Context) {
+	syncer := ldapsync.NewSyncer(clients.Interface().Kubernetes(constants.LocalCluster), ldap.GetProvider())
+	result, err := syncer.Sync(ctx, false)
+	if err != nil {
+		clog.Error("sync ldap directory failed: %v", err)
+	}
+	if result != nil {
+		clog.Info("sync ldap directory done, %v users changed", result.Total)
+	}
+}
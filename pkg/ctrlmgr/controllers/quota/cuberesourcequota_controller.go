@@ -18,38 +18,66 @@ package quota
 
 import (
 	"context"
+	"fmt"
 	"reflect"
+	"sync"
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/retry"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
+	"github.com/kubecube-io/kubecube/pkg/clients"
 	"github.com/kubecube-io/kubecube/pkg/clog"
 	"github.com/kubecube-io/kubecube/pkg/ctrlmgr/options"
+	mgrclient "github.com/kubecube-io/kubecube/pkg/multicluster/client"
 	"github.com/kubecube-io/kubecube/pkg/quota"
 	"github.com/kubecube-io/kubecube/pkg/quota/cube"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+	"github.com/kubecube-io/kubecube/pkg/utils/worker"
 )
 
 // CubeResourceQuotaReconciler reconciles a CubeResourceQuota object
 type CubeResourceQuotaReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// Affected enqueues nodes pool quotas when nodes of their cluster
+	// join, leave or change.
+	Affected chan event.GenericEvent
+
+	// nodesWorker lists nodes pool quotas of clusters whose nodes changed
+	// and sends them to Affected, so that node informer handlers of member
+	// clusters never block on listing or sending.
+	nodesWorker worker.Interface
+
+	// watchedClusters are the clusters whose nodes are watched
+	watchedClusters sync.Map
+
+	Recorder record.EventRecorder
 }
 
-func newReconciler(mgr manager.Manager) (reconcile.Reconciler, error) {
+func newReconciler(mgr manager.Manager) (*CubeResourceQuotaReconciler, error) {
 	r := &CubeResourceQuotaReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Affected: make(chan event.GenericEvent),
+		Recorder: mgr.GetEventRecorderFor("cuberesourcequota-controller"),
 	}
+	r.nodesWorker = worker.New("nodes-pool", 0, nil, r.enqueueNodesPools)
 	return r, nil
 }
 
@@ -80,6 +108,10 @@ func (r *CubeResourceQuotaReconciler) Reconcile(ctx context.Context, req ctrl.Re
 		return ctrl.Result{}, nil
 	}
 
+	if cube.IsNodesPool(cubeQuota) {
+		return ctrl.Result{}, r.syncNodesPool(ctx, cubeQuota)
+	}
+
 	// init status of cube resource cubeQuota when create
 	err = r.initCubeQuotaStatus(ctx, cubeQuota)
 	if err != nil {
@@ -91,7 +123,12 @@ func (r *CubeResourceQuotaReconciler) Reconcile(ctx context.Context, req ctrl.Re
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, quotaOperator.UpdateParentStatus(false)
+	err = quotaOperator.UpdateParentStatus(false)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, r.syncElastic(ctx, cubeQuota)
 }
 
 func (r *CubeResourceQuotaReconciler) ensureFinalizer(ctx context.Context, cubeQuota *quotav1.CubeResourceQuota) error {
@@ -168,6 +205,7 @@ func (r *CubeResourceQuotaReconciler) ensureSpecAndStatusConsistent(ctx context.
 			if err != nil {
 				return err
 			}
+			quota.KeepRecordedStatus(cubeQuota, newQuota)
 			newQuota.Status = cubeQuota.Status
 			err = r.Status().Update(ctx, newQuota, &client.SubResourceUpdateOptions{})
 			if err != nil {
@@ -183,6 +221,160 @@ func (r *CubeResourceQuotaReconciler) ensureSpecAndStatusConsistent(ctx context.
 	return nil
 }
 
+// syncNodesPool populates hard of nodes pool quota with allocatable of nodes
+// in pool and used with committed hard of tenant quotas
+func (r *CubeResourceQuotaReconciler) syncNodesPool(ctx context.Context, cubeQuota *quotav1.CubeResourceQuota) error {
+	cluster := cubeQuota.Labels[constants.ClusterLabel]
+	cli := clients.Interface().Kubernetes(cluster)
+	if cli == nil {
+		return fmt.Errorf("cluster %v of nodes pool quota %v not found", cluster, cubeQuota.Name)
+	}
+
+	if err := r.watchNodes(ctx, cluster, cli); err != nil {
+		return err
+	}
+
+	hard, err := cube.NodesPoolHard(ctx, cli.Cache(), cubeQuota.Spec.Target.Name)
+	if err != nil {
+		return err
+	}
+	changed, err := cube.RefreshNodesPoolStatus(cubeQuota, hard, r.Client)
+	if err != nil || !changed {
+		return err
+	}
+
+	clog.Info("hard of nodes pool quota %v refreshed to %v", cubeQuota.Name, hard)
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		newQuota := &quotav1.CubeResourceQuota{}
+		err := r.Get(ctx, types.NamespacedName{Name: cubeQuota.Name}, newQuota)
+		if err != nil {
+			return err
+		}
+		quota.KeepRecordedStatus(cubeQuota, newQuota)
+		newQuota.Status = cubeQuota.Status
+		return r.Status().Update(ctx, newQuota, &client.SubResourceUpdateOptions{})
+	})
+}
+
+// watchNodes watches nodes of cluster once so that nodes pool quotas of
+// cluster are recomputed when nodes join, leave or change
+func (r *CubeResourceQuotaReconciler) watchNodes(ctx context.Context, cluster string, cli mgrclient.Client) error {
+	if _, loaded := r.watchedClusters.LoadOrStore(cluster, struct{}{}); loaded {
+		return nil
+	}
+
+	informer, err := cli.Cache().GetInformer(ctx, &v1.Node{})
+	if err != nil {
+		r.watchedClusters.Delete(cluster)
+		return err
+	}
+	_, err = informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			r.nodesWorker.AddRateLimited(cluster)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldNode, ok1 := oldObj.(*v1.Node)
+			newNode, ok2 := newObj.(*v1.Node)
+			if !ok1 || !ok2 {
+				return
+			}
+			if oldNode.Labels[constants.LabelNodePool] != newNode.Labels[constants.LabelNodePool] ||
+				oldNode.Spec.Unschedulable != newNode.Spec.Unschedulable ||
+				!reflect.DeepEqual(oldNode.Status.Allocatable, newNode.Status.Allocatable) {
+				r.nodesWorker.AddRateLimited(cluster)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			r.nodesWorker.AddRateLimited(cluster)
+		},
+	})
+	if err != nil {
+		r.watchedClusters.Delete(cluster)
+		return err
+	}
+
+	clog.Info("watching nodes of cluster %v for nodes pool quotas", cluster)
+
+	return nil
+}
+
+// enqueueNodesPools is the reconcile func of nodes worker, key is the
+// cluster whose nodes changed
+func (r *CubeResourceQuotaReconciler) enqueueNodesPools(key worker.QueueKey) error {
+	cluster, ok := key.(string)
+	if !ok {
+		return nil
+	}
+	quotas := &quotav1.CubeResourceQuotaList{}
+	err := r.List(context.Background(), quotas, client.MatchingLabels{constants.ClusterLabel: cluster})
+	if err != nil {
+		clog.Warn("list cube resource quotas of cluster %v failed: %v", cluster, err)
+		return err
+	}
+	for i := range quotas.Items {
+		if cube.IsNodesPool(&quotas.Items[i]) {
+			r.Affected <- event.GenericEvent{Object: &quotas.Items[i]}
+		}
+	}
+	return nil
+}
+
+// syncElastic refreshes borrowed and reclaiming status of elastic children
+// of quota, borrowers are flagged once used of children exceeds hard of quota
+func (r *CubeResourceQuotaReconciler) syncElastic(ctx context.Context, cubeQuota *quotav1.CubeResourceQuota) error {
+	list := &quotav1.CubeResourceQuotaList{}
+	if err := r.List(ctx, list); err != nil {
+		return err
+	}
+	var children []*quotav1.CubeResourceQuota
+	hasElastic := false
+	for i := range list.Items {
+		c := &list.Items[i]
+		if c.Spec.ParentQuota != cubeQuota.Name || c.DeletionTimestamp != nil {
+			continue
+		}
+		children = append(children, c)
+		if quota.IsElastic(c) || len(c.Status.Borrowed) > 0 || len(c.Status.Reclaiming) > 0 {
+			hasElastic = true
+		}
+	}
+	if !hasElastic {
+		return nil
+	}
+
+	reclaiming := quota.Reclaiming(cubeQuota.Spec.Hard, children)
+	for _, c := range children {
+		borrowed := quota.Borrowed(c)
+		if quota.ResourceListEqual(borrowed, c.Status.Borrowed) && quota.ResourceListEqual(reclaiming[c.Name], c.Status.Reclaiming) {
+			continue
+		}
+
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			newQuota := &quotav1.CubeResourceQuota{}
+			err := r.Get(ctx, types.NamespacedName{Name: c.Name}, newQuota)
+			if err != nil {
+				return err
+			}
+			newQuota.Status.Borrowed = quota.Borrowed(newQuota)
+			newQuota.Status.Reclaiming = reclaiming[c.Name]
+			return r.Status().Update(ctx, newQuota, &client.SubResourceUpdateOptions{})
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(reclaiming[c.Name]) > 0 {
+			clog.Info("borrowed %v of elastic quota %v is being reclaimed by policy %v", reclaiming[c.Name], c.Name, quota.ReclaimPolicyOf(c))
+			r.Recorder.Eventf(c, v1.EventTypeWarning, "Reclaiming", "siblings need borrowed resources back, reclaiming %v by policy %v", reclaiming[c.Name], quota.ReclaimPolicyOf(c))
+		} else if len(c.Status.Reclaiming) > 0 {
+			r.Recorder.Eventf(c, v1.EventTypeNormal, "Reclaimed", "borrowed resources are returned")
+		}
+	}
+
+	return nil
+}
+
 // ifUpdateUsed keep resource of hard and used same
 func (r *CubeResourceQuotaReconciler) ifUpdateUsed(hard, used v1.ResourceList) (v1.ResourceList, bool) {
 	needUpdate := false
@@ -202,6 +394,15 @@ func SetupWithManager(mgr ctrl.Manager, _ *options.Options) error {
 		return err
 	}
 
+	err = mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		r.nodesWorker.Run(1, ctx.Done())
+		<-ctx.Done()
+		return nil
+	}))
+	if err != nil {
+		return err
+	}
+
 	// filter update event
 	predicateFunc := predicate.Funcs{
 		CreateFunc: func(event event.CreateEvent) bool {
@@ -232,8 +433,40 @@ func SetupWithManager(mgr ctrl.Manager, _ *options.Options) error {
 		},
 	}
 
+	// parent recomputes borrowing of children once usage of child changed
+	childPredicateFunc := predicate.Funcs{
+		CreateFunc: func(event event.CreateEvent) bool {
+			return false
+		},
+		UpdateFunc: func(updateEvent event.UpdateEvent) bool {
+			oldObj, ok := updateEvent.ObjectOld.(*quotav1.CubeResourceQuota)
+			if !ok {
+				return false
+			}
+			newObj, ok := updateEvent.ObjectNew.(*quotav1.CubeResourceQuota)
+			if !ok || len(newObj.Spec.ParentQuota) == 0 {
+				return false
+			}
+			return !reflect.DeepEqual(oldObj.Spec, newObj.Spec) || !reflect.DeepEqual(oldObj.Status.Used, newObj.Status.Used)
+		},
+		DeleteFunc: func(deleteEvent event.DeleteEvent) bool {
+			return true
+		},
+		GenericFunc: func(genericEvent event.GenericEvent) bool {
+			return false
+		},
+	}
+	enqueueParent := handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+		q, ok := obj.(*quotav1.CubeResourceQuota)
+		if !ok || len(q.Spec.ParentQuota) == 0 {
+			return nil
+		}
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: q.Spec.ParentQuota}}}
+	})
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&quotav1.CubeResourceQuota{}).
-		WithEventFilter(predicateFunc).
+		For(&quotav1.CubeResourceQuota{}, builder.WithPredicates(predicateFunc)).
+		Watches(&quotav1.CubeResourceQuota{}, enqueueParent, builder.WithPredicates(childPredicateFunc)).
+		WatchesRawSource(&source.Channel{Source: r.Affected}, &handler.EnqueueRequestForObject{}, builder.WithPredicates(predicateFunc)).
 		Complete(r)
 }
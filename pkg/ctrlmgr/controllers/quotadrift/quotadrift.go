@@ -0,0 +1,60 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quotadrift
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/kubecube-io/kubecube/pkg/clients"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/ctrlmgr/options"
+	"github.com/kubecube-io/kubecube/pkg/quota/drift"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+// SetupWithManager adds periodic drift check of cube resource quotas into
+// manager, the check only runs on leader.
+func SetupWithManager(mgr ctrl.Manager, opts *options.Options) error {
+	if opts.QuotaDriftCheckIntervalMinutes <= 0 {
+		return nil
+	}
+	interval := time.Duration(opts.QuotaDriftCheckIntervalMinutes) * time.Minute
+	recorder := mgr.GetEventRecorderFor("cuberesourcequota-drift")
+
+	return mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		wait.UntilWithContext(ctx, func(ctx context.Context) {
+			pivot := clients.Interface().Kubernetes(constants.LocalCluster).Direct()
+			checkOnce(ctx, drift.NewDetector(pivot, drift.MemberReader, recorder), !opts.QuotaDriftRepair)
+		}, interval)
+		return nil
+	}))
+}
+
+func checkOnce(ctx context.Context, detector *drift.Detector, dryRun bool) {
+	result, err := detector.Check(ctx, dryRun)
+	if err != nil {
+		clog.Warn("check drift of cube resource quotas failed: %v", err)
+	}
+	if result != nil {
+		clog.Info("check drift of cube resource quotas done, %v checked, %v drifted", result.Checked, len(result.Drifts))
+	}
+}
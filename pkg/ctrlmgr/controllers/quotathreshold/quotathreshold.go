@@ -0,0 +1,265 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quotathreshold
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/apiserver/middlewares/audit"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/ctrlmgr/options"
+	"github.com/kubecube-io/kubecube/pkg/notification"
+	"github.com/kubecube-io/kubecube/pkg/quota"
+	auditinfo "github.com/kubecube-io/kubecube/pkg/utils/audit"
+)
+
+const (
+	EventReasonWarning   = "ThresholdWarning"
+	EventReasonCritical  = "ThresholdCritical"
+	EventReasonRecovered = "ThresholdRecovered"
+)
+
+// ThresholdReconciler evaluates utilisation of cube resource quotas against
+// their thresholds. Levels are remembered in status, so events, audit and
+// notification are only emitted when level of a resource changed.
+type ThresholdReconciler struct {
+	client.Client
+
+	Recorder record.EventRecorder
+}
+
+// levelChange is the change of threshold level of a resource
+type levelChange struct {
+	Resource v1.ResourceName        `json:"resource"`
+	From     quotav1.ThresholdLevel `json:"from"`
+	To       quotav1.ThresholdLevel `json:"to"`
+	Used     string                 `json:"used"`
+	Hard     string                 `json:"hard"`
+	Percent  int64                  `json:"percent"`
+}
+
+//+kubebuilder:rbac:groups=quota.kubecube.io,resources=cuberesourcequota,verbs=get;list;watch
+//+kubebuilder:rbac:groups=quota.kubecube.io,resources=cuberesourcequota/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=user.kubecube.io,resources=users,verbs=get;list;watch
+
+func (r *ThresholdReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var changes []levelChange
+	cubeQuota := &quotav1.CubeResourceQuota{}
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := r.Get(ctx, types.NamespacedName{Name: req.Name}, cubeQuota); err != nil {
+			return err
+		}
+		changes = nil
+		if cubeQuota.DeletionTimestamp != nil {
+			return nil
+		}
+		levels := quota.ThresholdLevels(cubeQuota)
+		changes = changesOf(cubeQuota, cubeQuota.Status.ThresholdLevels, levels)
+		if len(changes) == 0 {
+			return nil
+		}
+		cubeQuota.Status.ThresholdLevels = levels
+		return r.Status().Update(ctx, cubeQuota, &client.SubResourceUpdateOptions{})
+	})
+	if err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if len(changes) == 0 {
+		return ctrl.Result{}, nil
+	}
+
+	clog.Info("threshold levels of cube resource quota %v changed: %v", cubeQuota.Name, summary(changes))
+	for _, c := range changes {
+		switch c.To {
+		case quotav1.ThresholdCritical:
+			r.Recorder.Eventf(cubeQuota, v1.EventTypeWarning, EventReasonCritical, "%v used %v of %v (%v%%)", c.Resource, c.Used, c.Hard, c.Percent)
+		case quotav1.ThresholdWarning:
+			r.Recorder.Eventf(cubeQuota, v1.EventTypeWarning, EventReasonWarning, "%v used %v of %v (%v%%)", c.Resource, c.Used, c.Hard, c.Percent)
+		default:
+			r.Recorder.Eventf(cubeQuota, v1.EventTypeNormal, EventReasonRecovered, "%v used %v of %v (%v%%)", c.Resource, c.Used, c.Hard, c.Percent)
+		}
+	}
+	reportChanged(cubeQuota, changes)
+	notify(ctx, r.Client, cubeQuota, changes)
+
+	return ctrl.Result{}, nil
+}
+
+// changesOf returns changes from recorded levels to current levels, sorted
+// by resource name
+func changesOf(q *quotav1.CubeResourceQuota, recorded, current map[v1.ResourceName]quotav1.ThresholdLevel) []levelChange {
+	levelOf := func(levels map[v1.ResourceName]quotav1.ThresholdLevel, rs v1.ResourceName) quotav1.ThresholdLevel {
+		if l, ok := levels[rs]; ok {
+			return l
+		}
+		return quotav1.ThresholdNormal
+	}
+
+	names := make(map[v1.ResourceName]struct{}, len(recorded)+len(current))
+	for rs := range recorded {
+		names[rs] = struct{}{}
+	}
+	for rs := range current {
+		names[rs] = struct{}{}
+	}
+
+	var changes []levelChange
+	for rs := range names {
+		from, to := levelOf(recorded, rs), levelOf(current, rs)
+		if from == to {
+			continue
+		}
+		used, hard := quota.UsedOf(q)[rs], q.Status.Hard[rs]
+		percent, _ := quota.Utilisation(q, rs)
+		changes = append(changes, levelChange{
+			Resource: rs,
+			From:     from,
+			To:       to,
+			Used:     used.String(),
+			Hard:     hard.String(),
+			Percent:  int64(percent),
+		})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Resource < changes[j].Resource
+	})
+	return changes
+}
+
+func summary(changes []levelChange) string {
+	parts := make([]string, 0, len(changes))
+	for _, c := range changes {
+		parts = append(parts, fmt.Sprintf("%v %v -> %v, used %v of %v (%v%%)", c.Resource, c.From, c.To, c.Used, c.Hard, c.Percent))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func reportChanged(q *quotav1.CubeResourceQuota, changes []levelChange) {
+	body, _ := json.Marshal(struct {
+		Target  quotav1.TargetObj `json:"target"`
+		Changes []levelChange     `json:"changes"`
+	}{q.Spec.Target, changes})
+	audit.ReportEvent(&audit.Event{
+		EventName:         auditinfo.CrossQuotaThreshold.EventName,
+		Description:       auditinfo.CrossQuotaThreshold.Description,
+		RequestParameters: string(body),
+		ResourceReports: []audit.Resource{{
+			ResourceType: auditinfo.CrossQuotaThreshold.ResourceType,
+			ResourceName: q.Name,
+		}},
+	})
+}
+
+// notify sends notification in background, thresholds work without
+// notification
+func notify(ctx context.Context, cli client.Reader, q *quotav1.CubeResourceQuota, changes []levelChange) {
+	sender := notification.GetSender()
+	if sender == nil {
+		return
+	}
+	to := receiversOf(ctx, cli, q)
+	if len(to) == 0 {
+		clog.Debug("no receiver of thresholds of cube resource quota %v, skip notification", q.Name)
+		return
+	}
+	msg := &notification.Message{
+		To:      to,
+		Subject: fmt.Sprintf("Quota of %v %v is %v", strings.ToLower(string(q.Spec.Target.Kind)), q.Spec.Target.Name, quota.ThresholdLevelOf(q)),
+		Body: fmt.Sprintf("Utilisation of cube resource quota %v crossed thresholds.\n\n%v\n",
+			q.Name, strings.ReplaceAll(summary(changes), "; ", "\n")),
+	}
+	go func() {
+		if err := sender.Send(context.Background(), msg); err != nil {
+			clog.Warn("send notification %q failed: %v", msg.Subject, err)
+		}
+	}()
+}
+
+// receiversOf returns receivers of thresholds of quota, platform admins
+// receive the notification if no receiver is set
+func receiversOf(ctx context.Context, cli client.Reader, q *quotav1.CubeResourceQuota) []string {
+	if q.Spec.Thresholds != nil && len(q.Spec.Thresholds.Receivers) > 0 {
+		return q.Spec.Thresholds.Receivers
+	}
+	users := &userv1.UserList{}
+	if err := cli.List(ctx, users); err != nil {
+		clog.Warn("list users failed: %v", err)
+		return nil
+	}
+	var to []string
+	for i := range users.Items {
+		u := &users.Items[i]
+		if userv1.IsPlatformAdmin(u) && len(u.Spec.Email) > 0 {
+			to = append(to, u.Spec.Email)
+		}
+	}
+	sort.Strings(to)
+	return to
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func SetupWithManager(mgr ctrl.Manager, _ *options.Options) error {
+	r := &ThresholdReconciler{
+		Client:   mgr.GetClient(),
+		Recorder: mgr.GetEventRecorderFor("cuberesourcequota-threshold"),
+	}
+
+	// only changes of thresholds, used and hard could change levels
+	predicateFunc := predicate.Funcs{
+		UpdateFunc: func(updateEvent event.UpdateEvent) bool {
+			oldObj, ok := updateEvent.ObjectOld.(*quotav1.CubeResourceQuota)
+			if !ok {
+				return false
+			}
+			newObj, ok := updateEvent.ObjectNew.(*quotav1.CubeResourceQuota)
+			if !ok {
+				return false
+			}
+			return !reflect.DeepEqual(oldObj.Spec.Thresholds, newObj.Spec.Thresholds) ||
+				!reflect.DeepEqual(oldObj.Status.Used, newObj.Status.Used) ||
+				!reflect.DeepEqual(oldObj.Status.Charged, newObj.Status.Charged) ||
+				!reflect.DeepEqual(oldObj.Status.Hard, newObj.Status.Hard) ||
+				!reflect.DeepEqual(oldObj.Status.ThresholdLevels, newObj.Status.ThresholdLevels)
+		},
+		DeleteFunc: func(deleteEvent event.DeleteEvent) bool {
+			return false
+		},
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("cuberesourcequota-threshold").
+		For(&quotav1.CubeResourceQuota{}, builder.WithPredicates(predicateFunc)).
+		Complete(r)
+}
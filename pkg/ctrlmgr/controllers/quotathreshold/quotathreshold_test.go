@@ -0,0 +1,150 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quotathreshold
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/notification"
+)
+
+type fakeSender struct {
+	sent chan *notification.Message
+}
+
+func (s *fakeSender) Send(_ context.Context, msg *notification.Message) error {
+	s.sent <- msg
+	return nil
+}
+
+func TestReconcile(t *testing.T) {
+	t.Setenv("AUDIT_IS_ENABLE", "false")
+	sender := &fakeSender{sent: make(chan *notification.Message, 1)}
+	notification.SetSender(sender)
+	defer notification.SetSender(nil)
+
+	scheme := runtime.NewScheme()
+	quotav1.AddToScheme(scheme)
+	q := &quotav1.CubeResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "project-1"},
+		Spec: quotav1.CubeResourceQuotaSpec{
+			Target:     quotav1.TargetObj{Kind: quotav1.ProjectObj, Name: "project-1"},
+			Hard:       v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("10")},
+			Thresholds: &quotav1.QuotaThresholds{Receivers: []string{"alice@example.com"}},
+		},
+		Status: quotav1.CubeResourceQuotaStatus{
+			Hard: v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("10")},
+			Used: v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("9")},
+		},
+	}
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithStatusSubresource(&quotav1.CubeResourceQuota{}).WithObjects(q).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &ThresholdReconciler{Client: cli, Recorder: recorder}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "project-1"}}
+
+	if _, err := r.Reconcile(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	got := &quotav1.CubeResourceQuota{}
+	cli.Get(context.Background(), req.NamespacedName, got)
+	if got.Status.ThresholdLevels[v1.ResourceRequestsCPU] != quotav1.ThresholdWarning {
+		t.Fatalf("level of cpu should be warning, got %v", got.Status.ThresholdLevels)
+	}
+	if len(recorder.Events) != 1 {
+		t.Fatalf("want 1 event, got %v", len(recorder.Events))
+	}
+	<-recorder.Events
+	select {
+	case msg := <-sender.sent:
+		if len(msg.To) != 1 || msg.To[0] != "alice@example.com" {
+			t.Errorf("notification should be sent to receivers, got %+v", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("notification should be sent")
+	}
+
+	// remembered level is not notified again
+	if _, err := r.Reconcile(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(recorder.Events) != 0 {
+		t.Fatalf("unchanged level should not be recorded again, got %v events", len(recorder.Events))
+	}
+
+	got.Status.Used = v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("2")}
+	if err := cli.Status().Update(context.Background(), got); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	cli.Get(context.Background(), req.NamespacedName, got)
+	if len(got.Status.ThresholdLevels) != 0 {
+		t.Fatalf("level should be recovered, got %v", got.Status.ThresholdLevels)
+	}
+	if e := <-recorder.Events; e != "Normal ThresholdRecovered requests.cpu used 2 of 10 (20%)" {
+		t.Errorf("unexpected event %q", e)
+	}
+	<-sender.sent
+}
+
+func TestReceiversOf(t *testing.T) {
+	scheme := runtime.NewScheme()
+	userv1.AddToScheme(scheme)
+	newUser := func(name, email string, admin bool) *userv1.User {
+		return &userv1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       userv1.UserSpec{Email: email},
+			Status:     userv1.UserStatus{PlatformAdmin: admin},
+		}
+	}
+	ctx := context.Background()
+	q := &quotav1.CubeResourceQuota{ObjectMeta: metav1.ObjectMeta{Name: "project-1"}}
+
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		newUser("admin", "admin@example.com", true),
+		newUser("root", "", true),
+		newUser("alice", "alice@example.com", false),
+	).Build()
+	if to := receiversOf(ctx, cli, q); len(to) != 1 || to[0] != "admin@example.com" {
+		t.Errorf("platform admins should receive notification without receivers, got %v", to)
+	}
+
+	q.Spec.Thresholds = &quotav1.QuotaThresholds{Receivers: []string{"bob@example.com"}}
+	if to := receiversOf(ctx, cli, q); len(to) != 1 || to[0] != "bob@example.com" {
+		t.Errorf("receivers should be notified, got %v", to)
+	}
+
+	q.Spec.Thresholds = nil
+	cli = fake.NewClientBuilder().WithScheme(scheme).WithObjects(newUser("alice", "alice@example.com", false)).Build()
+	if to := receiversOf(ctx, cli, q); len(to) != 0 {
+		t.Errorf("nobody should be notified, got %v", to)
+	}
+}
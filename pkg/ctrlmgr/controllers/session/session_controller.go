@@ -0,0 +1,82 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/authentication/session"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/ctrlmgr/options"
+)
+
+// SessionReconciler deletes sessions once all tokens of them expired
+type SessionReconciler struct {
+	client.Client
+}
+
+func newReconciler(mgr manager.Manager) (*SessionReconciler, error) {
+	return &SessionReconciler{
+		Client: mgr.GetClient(),
+	}, nil
+}
+
+//+kubebuilder:rbac:groups=user.kubecube.io,resources=sessions,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=user.kubecube.io,resources=sessions/status,verbs=get;update;patch
+
+func (r *SessionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	s := &userv1.Session{}
+	if err := r.Get(ctx, req.NamespacedName, s); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	// revoked session will not be refreshed anymore, so it will be collected
+	// as well when its last token expired
+	remain := time.Until(session.ExpireTime(s))
+	if remain > 0 {
+		return ctrl.Result{RequeueAfter: remain}, nil
+	}
+
+	clog.Debug("session %v of user %v expired, delete it", s.Name, s.Spec.User)
+	if err := r.Delete(ctx, s); err != nil && !errors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func SetupWithManager(mgr ctrl.Manager, _ *options.Options) error {
+	r, err := newReconciler(mgr)
+	if err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&userv1.Session{}).
+		Complete(r)
+}
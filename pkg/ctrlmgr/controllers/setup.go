@@ -23,8 +23,15 @@ import (
 
 	"github.com/kubecube-io/kubecube/pkg/clog"
 	"github.com/kubecube-io/kubecube/pkg/ctrlmgr/controllers/binding"
+	"github.com/kubecube-io/kubecube/pkg/ctrlmgr/controllers/bindingexpiry"
+	"github.com/kubecube-io/kubecube/pkg/ctrlmgr/controllers/chargeback"
 	cluster "github.com/kubecube-io/kubecube/pkg/ctrlmgr/controllers/cluster"
+	"github.com/kubecube-io/kubecube/pkg/ctrlmgr/controllers/key"
+	"github.com/kubecube-io/kubecube/pkg/ctrlmgr/controllers/ldapsync"
 	"github.com/kubecube-io/kubecube/pkg/ctrlmgr/controllers/quota"
+	"github.com/kubecube-io/kubecube/pkg/ctrlmgr/controllers/quotadrift"
+	"github.com/kubecube-io/kubecube/pkg/ctrlmgr/controllers/quotathreshold"
+	"github.com/kubecube-io/kubecube/pkg/ctrlmgr/controllers/session"
 	"github.com/kubecube-io/kubecube/pkg/ctrlmgr/options"
 	"github.com/kubecube-io/kubecube/pkg/utils/ctrlopts"
 )
@@ -37,6 +44,13 @@ func init() {
 	setupFns["cuberesourcequota"] = quota.SetupWithManager
 	setupFns["clusterrolebinding"] = binding.SetupClusterRoleBindingReconcilerWithManager
 	setupFns["rolebinding"] = binding.SetupRoleBindingReconcilerWithManager
+	setupFns["session"] = session.SetupWithManager
+	setupFns["key"] = key.SetupWithManager
+	setupFns["ldapsync"] = ldapsync.SetupWithManager
+	setupFns["bindingexpiry"] = bindingexpiry.SetupWithManager
+	setupFns["quotadrift"] = quotadrift.SetupWithManager
+	setupFns["quotathreshold"] = quotathreshold.SetupWithManager
+	setupFns["chargeback"] = chargeback.SetupWithManager
 }
 
 // SetupWithManager set up controllers into manager
@@ -34,6 +34,7 @@ import (
 	"github.com/kubecube-io/kubecube/pkg/ctrlmgr/options"
 	"github.com/kubecube-io/kubecube/pkg/ctrlmgr/webhooks"
 	"github.com/kubecube-io/kubecube/pkg/multicluster"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
 	"github.com/kubecube-io/kubecube/pkg/utils/env"
 	"github.com/kubecube-io/kubecube/pkg/utils/exit"
 )
@@ -66,7 +67,7 @@ func NewCtrlMgrWithOpts(options *Config) *ControllerManager {
 		LeaderElection:          options.LeaderElect,
 		MetricsBindAddress:      "0",
 		HealthProbeBindAddress:  "0",
-		LeaderElectionID:        "kube-cube-manager",
+		LeaderElectionID:        constants.CubeLeaderElectionID,
 		LeaderElectionNamespace: env.CubeNamespace(),
 	})
 
@@ -83,7 +84,7 @@ func NewCtrlMgrWithOpts(options *Config) *ControllerManager {
 }
 
 func (m *ControllerManager) Initialize() error {
-	err := controllers.SetupWithManager(m.CtrlMgr, m.EnableControllers, &options.Options{ScoutWaitTimeoutSeconds: m.ScoutWaitTimeoutSeconds, ScoutInitialDelaySeconds: m.ScoutInitialDelaySeconds})
+	err := controllers.SetupWithManager(m.CtrlMgr, m.EnableControllers, &options.Options{ScoutWaitTimeoutSeconds: m.ScoutWaitTimeoutSeconds, ScoutInitialDelaySeconds: m.ScoutInitialDelaySeconds, KeyIdleTimeoutDays: m.KeyIdleTimeoutDays, LdapSyncIntervalMinutes: m.LdapSyncIntervalMinutes, QuotaDriftCheckIntervalMinutes: m.QuotaDriftCheckIntervalMinutes, QuotaDriftRepair: m.QuotaDriftRepair})
 	if err != nil {
 		return err
 	}
@@ -28,6 +28,7 @@ import (
 
 	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
 	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/quota"
 	"github.com/kubecube-io/kubecube/pkg/quota/cube"
 )
 
@@ -91,6 +92,31 @@ func (r *CubeResourceQuotaValidator) Handle(ctx context.Context, req admission.R
 		currentQuota = nil
 	}
 
+	if cube.IsNodesPool(currentQuota) && len(currentQuota.Spec.Hard) > 0 {
+		reason := fmt.Sprintf("hard of nodes pool quota %v is derived from nodes and should be empty", currentQuota.Name)
+		clog.Warn(reason)
+		return admission.Denied(reason)
+	}
+
+	if err := quota.ValidateElastic(currentQuota); err != nil {
+		reason := fmt.Sprintf("elastic of cube resource quota %v is invalid: %v", currentQuota.Name, err)
+		clog.Warn(reason)
+		return admission.Denied(reason)
+	}
+
+	if currentQuota != nil {
+		if err := quota.ValidateThresholds(currentQuota); err != nil {
+			reason := fmt.Sprintf("thresholds of cube resource quota %v is invalid: %v", currentQuota.Name, err)
+			clog.Warn(reason)
+			return admission.Denied(reason)
+		}
+		if err := quota.ValidateDefaultRequests(currentQuota); err != nil {
+			reason := fmt.Sprintf("default requests of cube resource quota %v is invalid: %v", currentQuota.Name, err)
+			clog.Warn(reason)
+			return admission.Denied(reason)
+		}
+	}
+
 	q := cube.NewQuotaOperator(r.Client, currentQuota, oldQuota, context.Background())
 
 	if req.Operation != v1.Delete {
@@ -0,0 +1,94 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notification
+
+import (
+	"context"
+	"sync"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// Message is the notification sent to users
+type Message struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Sender sends notification to users, smtp sender is built in and others
+// can be plugged in by SetSender.
+type Sender interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+var (
+	Config  = SMTPConfig{}
+	Webhook = WebhookConfig{}
+
+	lock   sync.Mutex
+	sender Sender
+)
+
+// SetSender replaces the sender used by kubecube
+func SetSender(s Sender) {
+	lock.Lock()
+	defer lock.Unlock()
+	sender = s
+}
+
+// GetSender returns the sender plugged in, or the sender fanning out to
+// smtp and webhook which are configured, nil means notification is not
+// available.
+func GetSender() Sender {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if sender != nil {
+		return sender
+	}
+	var senders multiSender
+	if Config.IsEnable() {
+		senders = append(senders, NewSMTPSender(Config))
+	}
+	if Webhook.IsEnable() {
+		senders = append(senders, NewWebhookSender(Webhook))
+	}
+	switch len(senders) {
+	case 0:
+		return nil
+	case 1:
+		sender = senders[0]
+	default:
+		sender = senders
+	}
+	return sender
+}
+
+// multiSender sends message by all the senders, failure of one sender
+// does not stop the others
+type multiSender []Sender
+
+func (m multiSender) Send(ctx context.Context, msg *Message) error {
+	var errs []error
+	for _, s := range m {
+		if err := s.Send(ctx, msg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
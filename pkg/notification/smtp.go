@@ -0,0 +1,193 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SMTPTLSNone sends mail in plain text
+	SMTPTLSNone = "none"
+	// SMTPStartTLS upgrades connection by STARTTLS if server supports it
+	SMTPStartTLS = "starttls"
+	// SMTPTLS connects to server by implicit tls, usually port 465
+	SMTPTLS = "tls"
+
+	smtpTimeout = 30 * time.Second
+)
+
+type SMTPConfig struct {
+	SMTPHost     string `yaml:"smtpHost,omitempty"`
+	SMTPPort     int    `yaml:"smtpPort,omitempty"`
+	SMTPUsername string `yaml:"smtpUsername,omitempty"`
+	SMTPPassword string `yaml:"smtpPassword,omitempty"`
+	SMTPFrom     string `yaml:"smtpFrom,omitempty"`
+	SMTPTLSMode  string `yaml:"smtpTLSMode,omitempty"`
+	// SMTPInsecureSkipVerify skips verifying certificate of smtp server
+	SMTPInsecureSkipVerify bool `yaml:"smtpInsecureSkipVerify,omitempty"`
+}
+
+func (c SMTPConfig) IsEnable() bool {
+	return c.SMTPHost != ""
+}
+
+type smtpSender struct {
+	cfg SMTPConfig
+}
+
+func NewSMTPSender(cfg SMTPConfig) Sender {
+	return &smtpSender{cfg: cfg}
+}
+
+func (s *smtpSender) Send(ctx context.Context, msg *Message) error {
+	if len(msg.To) == 0 {
+		return errors.New("no recipient of message")
+	}
+	from, err := mail.ParseAddress(s.cfg.SMTPFrom)
+	if err != nil {
+		return fmt.Errorf("invalid smtp from address %q: %v", s.cfg.SMTPFrom, err)
+	}
+	to := make([]*mail.Address, 0, len(msg.To))
+	for _, addr := range msg.To {
+		parsed, err := mail.ParseAddress(addr)
+		if err != nil {
+			return fmt.Errorf("invalid recipient %q: %v", addr, err)
+		}
+		to = append(to, parsed)
+	}
+
+	c, err := s.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if err = s.hello(c); err != nil {
+		return err
+	}
+	if err = c.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err = c.Rcpt(addr.Address); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(compose(from, to, msg)); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func (s *smtpSender) dial(ctx context.Context) (*smtp.Client, error) {
+	port := s.cfg.SMTPPort
+	if port == 0 {
+		port = 25
+	}
+	addr := net.JoinHostPort(s.cfg.SMTPHost, strconv.Itoa(port))
+
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{}
+	if s.cfg.SMTPTLSMode == SMTPTLS {
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: s.tlsConfig()}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("connect to smtp server %v failed: %v", addr, err)
+	}
+	// the whole conversation shares the deadline
+	if err = conn.SetDeadline(time.Now().Add(smtpTimeout)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c, err := smtp.NewClient(conn, s.cfg.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// hello upgrades connection and authenticates if required
+func (s *smtpSender) hello(c *smtp.Client) error {
+	if s.cfg.SMTPTLSMode == SMTPStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("smtp server does not support STARTTLS")
+		}
+		if err := c.StartTLS(s.tlsConfig()); err != nil {
+			return err
+		}
+	}
+	if s.cfg.SMTPUsername == "" {
+		return nil
+	}
+	if ok, _ := c.Extension("AUTH"); !ok {
+		return errors.New("smtp server does not support AUTH")
+	}
+	// PlainAuth refuses to send password over plain connection unless server is localhost
+	return c.Auth(smtp.PlainAuth("", s.cfg.SMTPUsername, s.cfg.SMTPPassword, s.cfg.SMTPHost))
+}
+
+func (s *smtpSender) tlsConfig() *tls.Config {
+	return &tls.Config{ServerName: s.cfg.SMTPHost, InsecureSkipVerify: s.cfg.SMTPInsecureSkipVerify}
+}
+
+// compose builds plain text mail, header injection is avoided by encoding subject
+func compose(from *mail.Address, to []*mail.Address, msg *Message) []byte {
+	recipients := make([]string, 0, len(to))
+	for _, addr := range to {
+		recipients = append(recipients, addr.String())
+	}
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(buf, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	buf.WriteString("\r\n")
+	body := strings.ReplaceAll(msg.Body, "\r\n", "\n")
+	buf.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
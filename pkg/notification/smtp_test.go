@@ -0,0 +1,143 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notification
+
+import (
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// fakeSMTPServer is a minimal smtp stand-in records the mail it receives
+type fakeSMTPServer struct {
+	listener net.Listener
+	received chan string
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTPServer{listener: l, received: make(chan string, 1)}
+	t.Cleanup(func() { l.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *fakeSMTPServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeSMTPServer) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost ESMTP")
+
+	var mail strings.Builder
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch cmd {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250-localhost")
+			tp.PrintfLine("250 8BITMIME")
+		case "MAIL", "RCPT":
+			mail.WriteString(line + "\n")
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			mail.Write(data)
+			tp.PrintfLine("250 OK")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			s.received <- mail.String()
+			return
+		default:
+			tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func TestSMTPSend(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	sender := NewSMTPSender(SMTPConfig{
+		SMTPHost:    "127.0.0.1",
+		SMTPPort:    server.port(),
+		SMTPFrom:    "KubeCube <noreply@example.com>",
+		SMTPTLSMode: SMTPTLSNone,
+	})
+
+	err := sender.Send(context.Background(), &Message{
+		To:      []string{"alice@example.com"},
+		Subject: "Reset password\r\nBcc: eve@example.com",
+		Body:    "line 1\n.\nline 3",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mail := <-server.received
+	for _, want := range []string{
+		"MAIL FROM:<noreply@example.com>",
+		"RCPT TO:<alice@example.com>",
+		"To: <alice@example.com>",
+		"Subject: =?utf-8?q?",
+		"line 1\n.\nline 3",
+	} {
+		if !strings.Contains(mail, want) {
+			t.Fatalf("mail should contain %q:\n%s", want, mail)
+		}
+	}
+	if strings.Contains(mail, "\nBcc:") {
+		t.Fatalf("header should not be injected by subject:\n%s", mail)
+	}
+}
+
+func TestSMTPSendRequiresStartTLS(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	sender := NewSMTPSender(SMTPConfig{
+		SMTPHost:    "127.0.0.1",
+		SMTPPort:    server.port(),
+		SMTPFrom:    "noreply@example.com",
+		SMTPTLSMode: SMTPStartTLS,
+	})
+	if err := sender.Send(context.Background(), &Message{To: []string{"alice@example.com"}}); err == nil {
+		t.Fatal("should fail if server does not support STARTTLS")
+	}
+}
+
+func TestSMTPSendInvalidRecipient(t *testing.T) {
+	sender := NewSMTPSender(SMTPConfig{SMTPHost: "127.0.0.1", SMTPPort: 1, SMTPFrom: "noreply@example.com"})
+	err := sender.Send(context.Background(), &Message{To: []string{"alice@example.com>\r\nBcc: eve@example.com"}})
+	if err == nil || !strings.Contains(err.Error(), "invalid recipient") {
+		t.Fatalf("invalid recipient should be rejected before connecting, got %v", err)
+	}
+}
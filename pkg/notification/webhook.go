@@ -0,0 +1,82 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const webhookTimeout = 10 * time.Second
+
+type WebhookConfig struct {
+	WebhookURL string `yaml:"webhookURL,omitempty"`
+	// WebhookToken is sent as bearer token if set
+	WebhookToken string `yaml:"webhookToken,omitempty"`
+}
+
+func (c WebhookConfig) IsEnable() bool {
+	return c.WebhookURL != ""
+}
+
+type webhookSender struct {
+	cfg    WebhookConfig
+	client *http.Client
+}
+
+// NewWebhookSender returns the sender posts message as json to url of config,
+// recipients are left to the receiver to resolve.
+func NewWebhookSender(cfg WebhookConfig) Sender {
+	return &webhookSender{cfg: cfg, client: &http.Client{Timeout: webhookTimeout}}
+}
+
+type webhookPayload struct {
+	To      []string `json:"to,omitempty"`
+	Subject string   `json:"subject"`
+	Body    string   `json:"body"`
+}
+
+func (s *webhookSender) Send(ctx context.Context, msg *Message) error {
+	body, err := json.Marshal(webhookPayload{To: msg.To, Subject: msg.Subject, Body: msg.Body})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(s.cfg.WebhookToken) > 0 {
+		req.Header.Set("Authorization", "Bearer "+s.cfg.WebhookToken)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook responded %v: %s", resp.Status, msg)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebhookSend(t *testing.T) {
+	var got webhookPayload
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	sender := NewWebhookSender(WebhookConfig{WebhookURL: server.URL, WebhookToken: "token"})
+	err := sender.Send(context.Background(), &Message{Subject: "quota warning", Body: "cpu used 80%"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Subject != "quota warning" || got.Body != "cpu used 80%" || len(got.To) != 0 {
+		t.Fatalf("unexpected payload %+v", got)
+	}
+	if auth != "Bearer token" {
+		t.Fatalf("token should be sent, got %q", auth)
+	}
+}
+
+func TestWebhookSendFailed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	sender := NewWebhookSender(WebhookConfig{WebhookURL: server.URL})
+	if err := sender.Send(context.Background(), &Message{Subject: "quota warning"}); err == nil {
+		t.Fatal("should fail if webhook responded error")
+	}
+}
+
+func TestMultiSenderSendsAll(t *testing.T) {
+	var received int
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received++
+	}))
+	defer ok.Close()
+	failed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer failed.Close()
+
+	sender := multiSender{NewWebhookSender(WebhookConfig{WebhookURL: failed.URL}), NewWebhookSender(WebhookConfig{WebhookURL: ok.URL})}
+	if err := sender.Send(context.Background(), &Message{Subject: "quota warning"}); err == nil {
+		t.Fatal("should fail if one of senders failed")
+	}
+	if received != 1 {
+		t.Fatalf("failure of one sender should not stop the others, got %v sent", received)
+	}
+}
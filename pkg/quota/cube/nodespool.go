@@ -0,0 +1,176 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cube
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
+	"github.com/kubecube-io/kubecube/pkg/quota"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+// allocatableToQuota maps allocatable of node to the quota resources it bounds,
+// limits are not bounded by nodes pool so that they could be overcommitted.
+var allocatableToQuota = map[v1.ResourceName][]v1.ResourceName{
+	v1.ResourceCPU:              {v1.ResourceRequestsCPU, v1.ResourceCPU},
+	v1.ResourceMemory:           {v1.ResourceRequestsMemory, v1.ResourceMemory},
+	v1.ResourceEphemeralStorage: {v1.ResourceRequestsEphemeralStorage, v1.ResourceEphemeralStorage},
+	"nvidia.com/gpu":            {quota.ResourceNvidiaGPU},
+	v1.ResourcePods:             {v1.ResourcePods},
+}
+
+// IsNodesPool tells if target of quota is nodes pool
+func IsNodesPool(q *quotav1.CubeResourceQuota) bool {
+	return q != nil && q.Spec.Target.Kind == quotav1.NodesPoolObj
+}
+
+// NodesPoolHard returns the hard of nodes pool derived from allocatable of
+// schedulable nodes in pool.
+func NodesPoolHard(ctx context.Context, cli client.Reader, pool string) (v1.ResourceList, error) {
+	nodes, err := PoolNodes(ctx, cli, pool)
+	if err != nil {
+		return nil, err
+	}
+	return NodesHard(nodes), nil
+}
+
+// PoolNodes returns the schedulable nodes of nodes pool. Nodes of pool are
+// labeled with the name of pool, and global pool contains all the nodes of
+// cluster. Cordoned and deleting nodes take no new pods so they are skipped.
+func PoolNodes(ctx context.Context, cli client.Reader, pool string) ([]v1.Node, error) {
+	opts := []client.ListOption{}
+	if pool != quotav1.GlobalNodesPool {
+		opts = append(opts, client.MatchingLabels{constants.LabelNodePool: pool})
+	}
+	list := &v1.NodeList{}
+	if err := cli.List(ctx, list, opts...); err != nil {
+		return nil, err
+	}
+
+	nodes := make([]v1.Node, 0, len(list.Items))
+	for _, node := range list.Items {
+		if node.Spec.Unschedulable || node.DeletionTimestamp != nil {
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// NodesHard sums allocatable of nodes into the resources of quota
+func NodesHard(nodes []v1.Node) v1.ResourceList {
+	hard := v1.ResourceList{}
+	for rs, quotaNames := range allocatableToQuota {
+		for _, name := range quotaNames {
+			hard[name] = quota.ZeroQ()
+		}
+		for _, node := range nodes {
+			allocatable, ok := node.Status.Allocatable[rs]
+			if !ok {
+				continue
+			}
+			for _, name := range quotaNames {
+				q := hard[name]
+				q.Add(allocatable)
+				hard[name] = q
+			}
+		}
+	}
+	return hard
+}
+
+// RefreshNodesPoolStatus sets hard of pool status to the given and refreshes
+// used as the committed hard of sub quotas, it tells if status changed.
+func RefreshNodesPoolStatus(pool *quotav1.CubeResourceQuota, hard v1.ResourceList, cli client.Client) (bool, error) {
+	old := pool.Status.DeepCopy()
+
+	pool.Status.Hard = hard
+	if pool.Status.Used == nil {
+		pool.Status.Used = v1.ResourceList{}
+	}
+	for rs := range hard {
+		if _, ok := pool.Status.Used[rs]; !ok {
+			pool.Status.Used[rs] = quota.ZeroQ()
+		}
+	}
+	if pool.Status.SubResourceQuotas == nil {
+		pool.Status.SubResourceQuotas = make([]string, 0)
+	}
+
+	if _, err := refreshUsedResource(nil, nil, pool, cli); err != nil {
+		return false, err
+	}
+
+	return !quotaStatusEqual(old, &pool.Status), nil
+}
+
+func quotaStatusEqual(s1, s2 *quotav1.CubeResourceQuotaStatus) bool {
+	if !reflect.DeepEqual(s1.SubResourceQuotas, s2.SubResourceQuotas) {
+		return false
+	}
+	return quota.ResourceListEqual(s1.Hard, s2.Hard) && quota.ResourceListEqual(s1.Used, s2.Used)
+}
+
+// isExceedPool tells if committed hard of sub quotas exceeds hard of nodes
+// pool after current applied, only resources bounded by pool are checked.
+func isExceedPool(current, old, pool *quotav1.CubeResourceQuota) (bool, string) {
+	if pool.Status.Hard == nil {
+		return true, fmt.Sprintf("capacity of nodes pool quota %v is not computed yet", pool.Name)
+	}
+
+	cluster, poolCluster := current.Labels[constants.ClusterLabel], pool.Labels[constants.ClusterLabel]
+	if cluster != poolCluster {
+		return true, fmt.Sprintf("nodes pool quota %v is of cluster %v but not %v", pool.Name, poolCluster, cluster)
+	}
+
+	for rs, poolHard := range pool.Status.Hard {
+		currentHard, ok := current.Spec.Hard[rs]
+		if !ok {
+			currentHard = quota.ZeroQ()
+		}
+		oldHard := quota.ZeroQ()
+		if old != nil {
+			if h, ok := old.Spec.Hard[rs]; ok {
+				oldHard = h
+			}
+		}
+
+		changed := currentHard.DeepCopy()
+		changed.Sub(oldHard)
+		// shrinking is always allowed even if pool is overcommitted
+		if changed.Cmp(quota.ZeroQ()) <= 0 {
+			continue
+		}
+
+		committed, ok := pool.Status.Used[rs]
+		if !ok {
+			committed = resource.Quantity{}
+		}
+		if isExceed(poolHard, committed.DeepCopy(), changed) {
+			return true, fmt.Sprintf("overload, resource(%v), nodes pool allocatable(%v), committed(%v), changed(%v)", rs, poolHard.String(), committed.String(), changed.String())
+		}
+	}
+
+	return false, ""
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cube
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+func newNode(name, pool, cpu string, unschedulable bool) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{constants.LabelNodePool: pool}},
+		Spec:       v1.NodeSpec{Unschedulable: unschedulable},
+		Status: v1.NodeStatus{Allocatable: v1.ResourceList{
+			v1.ResourceCPU:    resource.MustParse(cpu),
+			v1.ResourceMemory: resource.MustParse("8Gi"),
+			v1.ResourcePods:   resource.MustParse("110"),
+		}},
+	}
+}
+
+func TestNodesPoolHard(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		newNode("node-1", "gpu", "4", false),
+		newNode("node-2", "gpu", "8", false),
+		newNode("node-3", "gpu", "8", true),
+		newNode("node-4", "", "2", false),
+	).Build()
+
+	hard, err := NodesPoolHard(context.Background(), cli, "gpu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cpu := hard[v1.ResourceRequestsCPU]
+	if cpu.Cmp(resource.MustParse("12")) != 0 {
+		t.Errorf("requests.cpu of pool should be 12, got %v", cpu.String())
+	}
+	if _, ok := hard[v1.ResourceLimitsCPU]; ok {
+		t.Errorf("limits should not be bounded by pool")
+	}
+
+	hard, err = NodesPoolHard(context.Background(), cli, quotav1.GlobalNodesPool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cpu = hard[v1.ResourceCPU]
+	if cpu.Cmp(resource.MustParse("14")) != 0 {
+		t.Errorf("cpu of global pool should be 14, got %v", cpu.String())
+	}
+}
+
+func TestTenantQuotaOverloadPool(t *testing.T) {
+	pool := &quotav1.CubeResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "pivot-cluster.gpu", Labels: map[string]string{constants.ClusterLabel: "pivot-cluster"}},
+		Spec:       quotav1.CubeResourceQuotaSpec{Target: quotav1.TargetObj{Kind: quotav1.NodesPoolObj, Name: "gpu"}},
+		Status: quotav1.CubeResourceQuotaStatus{
+			Hard: v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("12")},
+			Used: v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("8")},
+		},
+	}
+	scheme := runtime.NewScheme()
+	quotav1.AddToScheme(scheme)
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(pool).Build()
+
+	tenantQuota := func(cpu, cluster string) *quotav1.CubeResourceQuota {
+		return &quotav1.CubeResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: cluster + ".tenant-1", Labels: map[string]string{constants.ClusterLabel: cluster}},
+			Spec: quotav1.CubeResourceQuotaSpec{
+				ParentQuota: pool.Name,
+				Target:      quotav1.TargetObj{Kind: quotav1.TenantObj, Name: "tenant-1"},
+				Hard:        v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse(cpu), v1.ResourceLimitsCPU: resource.MustParse("100")},
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		current *quotav1.CubeResourceQuota
+		old     *quotav1.CubeResourceQuota
+		want    bool
+	}{
+		{"fits pool", tenantQuota("4", "pivot-cluster"), nil, false},
+		{"exceeds pool", tenantQuota("5", "pivot-cluster"), nil, true},
+		{"grows within pool", tenantQuota("6", "pivot-cluster"), tenantQuota("2", "pivot-cluster"), false},
+		{"other cluster", tenantQuota("1", "member-cluster"), nil, true},
+	}
+	for _, tt := range tests {
+		overload, reason, err := NewQuotaOperator(cli, tt.current, tt.old, context.Background()).Overload()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if overload != tt.want {
+			t.Errorf("%v: want overload %v, got %v %v", tt.name, tt.want, overload, reason)
+		}
+	}
+}
@@ -80,8 +80,8 @@ func (o *QuotaOperator) Overload() (bool, string, error) {
 	currentQuota := o.CurrentQuota
 	oldQuota := o.OldQuota
 
-	// todo: there is must be a way limit the hard of node pool kind
-	if isTenantKind(currentQuota, oldQuota) {
+	// hard of nodes pool quota is derived from nodes but not limited by parent
+	if IsNodesPool(currentQuota) {
 		return false, "", nil
 	}
 
@@ -90,6 +90,15 @@ func (o *QuotaOperator) Overload() (bool, string, error) {
 		return false, "", err
 	}
 
+	// tenant quotas are limited by allocatable of nodes pool they belong to
+	if isTenantKind(currentQuota, oldQuota) {
+		if !IsNodesPool(parentQuota) {
+			return false, "", nil
+		}
+		isOverload, reason := isExceedPool(currentQuota, oldQuota, parentQuota)
+		return isOverload, reason, nil
+	}
+
 	isOverload, reason := isExceedParent(currentQuota, oldQuota, parentQuota)
 
 	return isOverload, reason, nil
@@ -143,6 +152,7 @@ func (o *QuotaOperator) UpdateParentStatus(flush bool) error {
 		if err != nil {
 			return err
 		}
+		quota.KeepRecordedStatus(refreshed, newQuota)
 		newQuota.Status = refreshed.Status
 		err = o.Client.Status().Update(o.Context, newQuota)
 		if err != nil {
@@ -166,12 +176,9 @@ func isTenantKind(quotas ...*quotav1.CubeResourceQuota) bool {
 
 // InitStatus initialize status of quota
 func InitStatus(current *quotav1.CubeResourceQuota) {
+	// hard of nodes pool quota is populated with allocatable of nodes
+	// by RefreshNodesPoolStatus
 	current.Status.Hard = current.Spec.Hard
-	// if target object of quota is NodesPool, we should use the physical resource
-	// as value to the hard of the status
-	//if current.Spec.Target.Kind == quotav1.NodesPoolObj {
-	//	current.Status.Hard = physicalResourceFrom(current.Spec.Target.Name)
-	//}
 
 	used := make(map[v1.ResourceName]resource.Quantity)
 	for k := range current.Spec.Hard {
@@ -197,9 +204,14 @@ func AllowedDel(current *quotav1.CubeResourceQuota) bool {
 // AllowedUpdate return false if hard of current is less than old status
 // otherwise true
 func AllowedUpdate(current, old *quotav1.CubeResourceQuota) bool {
-	for _, rs := range quota.ResourceNames {
+	// used of nodes pool is bounded by allocatable of nodes but not spec
+	if IsNodesPool(current) {
+		return true
+	}
+
+	oldUsed := quota.UsedOf(old)
+	for _, rs := range quota.ResourceNamesOf(current.Spec.Hard, oldUsed) {
 		currentHard := current.Spec.Hard
-		oldUsed := old.Status.Used
 
 		cHard, ok := currentHard[rs]
 		if !ok {
@@ -216,6 +228,11 @@ func AllowedUpdate(current, old *quotav1.CubeResourceQuota) bool {
 			continue
 		}
 
+		// used of elastic quota is bounded by max but not the guaranteed hard
+		if max, ok := quota.ElasticMax(current, rs); ok {
+			cHard = max
+		}
+
 		if cHard.Cmp(oUsed) == -1 {
 			return false
 		}
@@ -234,3 +251,24 @@ func IsRelyOnObj(quotas ...*quotav1.CubeResourceQuota) bool {
 	}
 	return false
 }
+
+// CheckHardChange tells if hard of quota could be changed to hard, that is
+// hard is not less than used of quota and the change fits free capacity
+// of parent. The reason is returned if the change is not allowed.
+func CheckHardChange(ctx context.Context, cli client.Client, old *quotav1.CubeResourceQuota, hard v1.ResourceList) (bool, string, error) {
+	current := old.DeepCopy()
+	current.Spec.Hard = hard
+
+	if !AllowedUpdate(current, old) {
+		return false, fmt.Sprintf("hard of cube resource quota %v is less than used %v", old.Name, quota.UsedOf(old)), nil
+	}
+	if err := quota.ValidateElastic(current); err != nil {
+		return false, err.Error(), nil
+	}
+
+	isOverload, reason, err := NewQuotaOperator(cli, current, old, ctx).Overload()
+	if err != nil || isOverload {
+		return false, reason, err
+	}
+	return true, "", nil
+}
@@ -34,9 +34,9 @@ import (
 )
 
 func isExceedParent(current, old, parent *quotav1.CubeResourceQuota) (bool, string) {
-	for _, rs := range quota.ResourceNames {
+	for _, rs := range quota.ResourceNamesOf(parent.Spec.Hard, current.Spec.Hard) {
 		pHard := parent.Spec.Hard
-		pUsed := parent.Status.Used
+		pUsed := quota.UsedOf(parent)
 		cHard := current.Spec.Hard
 
 		parentHard, ok := pHard[rs]
@@ -82,6 +82,7 @@ func isExceedParent(current, old, parent *quotav1.CubeResourceQuota) (bool, stri
 
 func refreshUsedResource(current, old, parent *quotav1.CubeResourceQuota, cli client.Client) (*quotav1.CubeResourceQuota, error) {
 	newParentUsed := quota.ClearQuotas(parent.Status.Used)
+	resourceNames := quota.ResourceNamesOf(newParentUsed)
 
 	for _, sub := range parent.Status.SubResourceQuotas {
 		subResourceQuota, name, err := getCubeResourceQuota(cli, sub)
@@ -112,7 +113,7 @@ func refreshUsedResource(current, old, parent *quotav1.CubeResourceQuota, cli cl
 
 		clog.Info("populate used of CubeResourceQuota %v with subResourceQuota %v", parent.Name, sub)
 
-		for _, rs := range quota.ResourceNames {
+		for _, rs := range resourceNames {
 			// continue if parent used quota had no that resource
 			newUsed, ok := newParentUsed[rs]
 			if !ok {
@@ -0,0 +1,100 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cube
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
+)
+
+const goldStorage v1.ResourceName = "gold.storageclass.storage.k8s.io/requests.storage"
+
+func TestIsExceedParentCounts(t *testing.T) {
+	parent := &quotav1.CubeResourceQuota{
+		Spec: quotav1.CubeResourceQuotaSpec{Hard: v1.ResourceList{
+			v1.ResourceServicesLoadBalancers: resource.MustParse("2"),
+			goldStorage:                      resource.MustParse("100Gi"),
+		}},
+		Status: quotav1.CubeResourceQuotaStatus{Used: v1.ResourceList{
+			v1.ResourceServicesLoadBalancers: resource.MustParse("1"),
+			goldStorage:                      resource.MustParse("60Gi"),
+		}},
+	}
+	project := func(lb, gold string) *quotav1.CubeResourceQuota {
+		hard := v1.ResourceList{v1.ResourceServicesLoadBalancers: resource.MustParse(lb)}
+		if len(gold) > 0 {
+			hard[goldStorage] = resource.MustParse(gold)
+		}
+		return &quotav1.CubeResourceQuota{Spec: quotav1.CubeResourceQuotaSpec{Hard: hard}}
+	}
+
+	tests := []struct {
+		name    string
+		current *quotav1.CubeResourceQuota
+		want    bool
+	}{
+		{"within parent", project("1", "40Gi"), false},
+		{"too many load balancers", project("2", "40Gi"), true},
+		{"too much gold storage", project("1", "50Gi"), true},
+		{"gold storage unset", project("1", ""), true},
+	}
+	for _, tt := range tests {
+		if overload, reason := isExceedParent(tt.current, nil, parent); overload != tt.want {
+			t.Errorf("%v: want overload %v, got %v %v", tt.name, tt.want, overload, reason)
+		}
+	}
+
+	// storage class that parent not had is not allowed
+	current := project("1", "40Gi")
+	current.Spec.Hard["silver.storageclass.storage.k8s.io/requests.storage"] = resource.MustParse("1Gi")
+	if overload, _ := isExceedParent(current, nil, parent); !overload {
+		t.Errorf("storage class that parent not had should be overload")
+	}
+}
+
+func TestChargedCountsAsUsed(t *testing.T) {
+	parent := &quotav1.CubeResourceQuota{
+		Spec: quotav1.CubeResourceQuotaSpec{Hard: v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("10")}},
+		Status: quotav1.CubeResourceQuotaStatus{
+			Used:    v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("4")},
+			Charged: v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("3")},
+		},
+	}
+	project := &quotav1.CubeResourceQuota{Spec: quotav1.CubeResourceQuotaSpec{Hard: v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("4")}}}
+	if overload, _ := isExceedParent(project, nil, parent); !overload {
+		t.Errorf("project should not take capacity charged by pods of parent")
+	}
+	project.Spec.Hard[v1.ResourceRequestsCPU] = resource.MustParse("3")
+	if overload, reason := isExceedParent(project, nil, parent); overload {
+		t.Errorf("project within free capacity of parent should not overload: %v", reason)
+	}
+
+	// hard of parent can not be shrunk below what it really consumed
+	current := parent.DeepCopy()
+	current.Spec.Hard[v1.ResourceRequestsCPU] = resource.MustParse("6")
+	if AllowedUpdate(current, parent) {
+		t.Errorf("hard less than used and charged should not be allowed")
+	}
+	current.Spec.Hard[v1.ResourceRequestsCPU] = resource.MustParse("7")
+	if !AllowedUpdate(current, parent) {
+		t.Errorf("hard equal to used and charged should be allowed")
+	}
+}
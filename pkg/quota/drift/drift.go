@@ -0,0 +1,293 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package drift
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
+	"github.com/kubecube-io/kubecube/pkg/clients"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/quota"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+// EventReasonDrifted is the reason of event recorded on drifted quota
+const EventReasonDrifted = "UsedDrifted"
+
+// MemberReaderFunc returns the reader of member cluster where ResourceQuotas
+// of project quotas live in.
+type MemberReaderFunc func(cluster string) (client.Reader, error)
+
+// MemberReader reads member cluster directly but not from cache, stale cache
+// would be reported as drift.
+func MemberReader(cluster string) (client.Reader, error) {
+	cli := clients.Interface().Kubernetes(cluster)
+	if cli == nil {
+		return nil, fmt.Errorf("cluster %v not found", cluster)
+	}
+	return cli.Direct(), nil
+}
+
+// QuotaDrift is the difference between recorded status of CubeResourceQuota
+// and the one recomputed from its child quotas.
+type QuotaDrift struct {
+	Quota   string            `json:"quota"`
+	Cluster string            `json:"cluster"`
+	Target  quotav1.TargetObj `json:"target"`
+
+	RecordedUsed v1.ResourceList `json:"recordedUsed"`
+	ActualUsed   v1.ResourceList `json:"actualUsed"`
+	// MissingSubs are child quotas not recorded in status
+	MissingSubs []string `json:"missingSubs,omitempty"`
+	// StaleSubs are recorded child quotas that no longer exist
+	StaleSubs []string `json:"staleSubs,omitempty"`
+
+	Repaired bool `json:"repaired"`
+}
+
+// Result is the result of check, drifts are not repaired if DryRun is true
+type Result struct {
+	DryRun  bool         `json:"dryRun"`
+	Checked int          `json:"checked"`
+	Drifts  []QuotaDrift `json:"drifts"`
+}
+
+// Detector recomputes Used and SubResourceQuotas of CubeResourceQuotas from
+// the actual child quotas across all clusters and compares them with status.
+// Hard of child CubeResourceQuotas is summed for tenant and nodes pool quotas,
+// and hard of ResourceQuotas in member cluster is summed for project quotas.
+type Detector struct {
+	pivot    client.Client
+	memberOf MemberReaderFunc
+	// recorder is optional, drifts are recorded as events if set
+	recorder record.EventRecorder
+}
+
+func NewDetector(pivot client.Client, memberOf MemberReaderFunc, recorder record.EventRecorder) *Detector {
+	return &Detector{pivot: pivot, memberOf: memberOf, recorder: recorder}
+}
+
+// Check checks the given quotas or all quotas if names is empty, the drifts
+// are repaired unless dryRun. Quotas can not be checked such as those of
+// unreachable clusters are skipped and the errors are returned with result.
+func (d *Detector) Check(ctx context.Context, dryRun bool, names ...string) (*Result, error) {
+	list := &quotav1.CubeResourceQuotaList{}
+	if err := d.pivot.List(ctx, list); err != nil {
+		return nil, err
+	}
+
+	wanted := sets.NewString(names...)
+	subQuotas := make(map[string][]string)
+	clusters := sets.NewString()
+	for _, q := range list.Items {
+		if len(q.Spec.ParentQuota) > 0 && q.DeletionTimestamp == nil {
+			subQuotas[q.Spec.ParentQuota] = append(subQuotas[q.Spec.ParentQuota], fmt.Sprintf("%v.%v", q.Name, quota.SubFix))
+		}
+		if q.Spec.Target.Kind == quotav1.ProjectObj && (wanted.Len() == 0 || wanted.Has(q.Name)) {
+			clusters.Insert(q.Labels[constants.ClusterLabel])
+		}
+	}
+	hards := make(map[string]v1.ResourceList, len(list.Items))
+	for _, q := range list.Items {
+		hards[fmt.Sprintf("%v.%v", q.Name, quota.SubFix)] = q.Spec.Hard
+	}
+
+	// ResourceQuotas of project quotas are listed once per cluster
+	var errs []error
+	unreachable := sets.NewString()
+	for _, cluster := range clusters.List() {
+		if err := d.listResourceQuotas(ctx, cluster, subQuotas, hards); err != nil {
+			errs = append(errs, fmt.Errorf("list ResourceQuotas of cluster %v failed: %v", cluster, err))
+			unreachable.Insert(cluster)
+		}
+	}
+
+	result := &Result{DryRun: dryRun, Drifts: []QuotaDrift{}}
+	for i := range list.Items {
+		q := &list.Items[i]
+		if wanted.Len() > 0 && !wanted.Has(q.Name) {
+			continue
+		}
+		if q.DeletionTimestamp != nil || q.Status.Used == nil {
+			continue
+		}
+		cluster := q.Labels[constants.ClusterLabel]
+		if q.Spec.Target.Kind == quotav1.ProjectObj && unreachable.Has(cluster) {
+			continue
+		}
+
+		result.Checked++
+		drift := compare(q, subQuotas[q.Name], hards)
+		if drift == nil {
+			resetMetrics(q.Name)
+			continue
+		}
+
+		clog.Warn("used of cube resource quota %v drifted, recorded %v, actual %v, missing subs %v, stale subs %v",
+			q.Name, drift.RecordedUsed, drift.ActualUsed, drift.MissingSubs, drift.StaleSubs)
+		observeDrift(drift)
+		if d.recorder != nil {
+			d.recorder.Eventf(q, v1.EventTypeWarning, EventReasonDrifted, "used drifted from child quotas: %v", drift.summary())
+		}
+
+		if !dryRun {
+			repaired, err := d.repair(ctx, q, drift)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("repair cube resource quota %v failed: %v", q.Name, err))
+			}
+			drift.Repaired = repaired
+			if repaired {
+				repairsTotal.Inc()
+				resetMetrics(q.Name)
+			}
+		}
+		result.Drifts = append(result.Drifts, *drift)
+	}
+
+	return result, utilerrors.NewAggregate(errs)
+}
+
+// listResourceQuotas adds ResourceQuotas of cluster into subQuotas of their
+// parent and records hard of them into hards
+func (d *Detector) listResourceQuotas(ctx context.Context, cluster string, subQuotas map[string][]string, hards map[string]v1.ResourceList) error {
+	cli, err := d.memberOf(cluster)
+	if err != nil {
+		return err
+	}
+	rqs := &v1.ResourceQuotaList{}
+	if err = cli.List(ctx, rqs, client.HasLabels{constants.CubeQuotaLabel}); err != nil {
+		return err
+	}
+	for _, rq := range rqs.Items {
+		parent := rq.Labels[constants.CubeQuotaLabel]
+		if len(parent) == 0 || rq.DeletionTimestamp != nil {
+			continue
+		}
+		sub := fmt.Sprintf("%v.%v.%v", rq.Name, rq.Namespace, quota.SubFix)
+		subQuotas[parent] = append(subQuotas[parent], sub)
+		hards[sub] = rq.Spec.Hard
+	}
+	return nil
+}
+
+// compare recomputes used of quota in the way of refreshing used, returns
+// nil if nothing drifted
+func compare(q *quotav1.CubeResourceQuota, subs []string, hards map[string]v1.ResourceList) *QuotaDrift {
+	actualUsed := v1.ResourceList{}
+	for rs := range q.Status.Used {
+		actualUsed[rs] = quota.ZeroQ()
+	}
+	for _, name := range quota.ResourceNamesOf(actualUsed) {
+		used, ok := actualUsed[name]
+		if !ok {
+			continue
+		}
+		for _, sub := range subs {
+			if h, ok := hards[sub][name]; ok {
+				used.Add(h)
+			}
+		}
+		actualUsed[name] = used
+	}
+
+	recordedSubs, actualSubs := sets.NewString(q.Status.SubResourceQuotas...), sets.NewString(subs...)
+	drift := &QuotaDrift{
+		Quota:        q.Name,
+		Cluster:      q.Labels[constants.ClusterLabel],
+		Target:       q.Spec.Target,
+		RecordedUsed: q.Status.Used,
+		ActualUsed:   actualUsed,
+		MissingSubs:  actualSubs.Difference(recordedSubs).List(),
+		StaleSubs:    recordedSubs.Difference(actualSubs).List(),
+	}
+
+	if len(drift.MissingSubs) > 0 || len(drift.StaleSubs) > 0 {
+		return drift
+	}
+	for rs, actual := range actualUsed {
+		if recorded := q.Status.Used[rs]; recorded.Cmp(actual) != 0 {
+			return drift
+		}
+	}
+	return nil
+}
+
+// repair updates status of quota with the recomputed one, quota changed
+// since checked is left to the next check
+func (d *Detector) repair(ctx context.Context, q *quotav1.CubeResourceQuota, drift *QuotaDrift) (bool, error) {
+	repaired := false
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		newQuota := &quotav1.CubeResourceQuota{}
+		if err := d.pivot.Get(ctx, types.NamespacedName{Name: q.Name}, newQuota); err != nil {
+			return err
+		}
+		if newQuota.ResourceVersion != q.ResourceVersion {
+			clog.Info("cube resource quota %v changed since checked, skip repair", q.Name)
+			return nil
+		}
+		newQuota.Status.Used = drift.ActualUsed
+		subs := sets.NewString(newQuota.Status.SubResourceQuotas...)
+		subs.Insert(drift.MissingSubs...)
+		subs.Delete(drift.StaleSubs...)
+		newQuota.Status.SubResourceQuotas = subs.List()
+		if err := d.pivot.Status().Update(ctx, newQuota); err != nil {
+			return err
+		}
+		repaired = true
+		return nil
+	})
+	if repaired {
+		clog.Info("used of cube resource quota %v repaired to %v", q.Name, drift.ActualUsed)
+	}
+	return repaired, err
+}
+
+func (d *QuotaDrift) summary() string {
+	names := make([]string, 0, len(d.ActualUsed))
+	for rs := range d.ActualUsed {
+		names = append(names, string(rs))
+	}
+	sort.Strings(names)
+
+	var parts []string
+	for _, name := range names {
+		rs := v1.ResourceName(name)
+		actual := d.ActualUsed[rs]
+		if recorded := d.RecordedUsed[rs]; recorded.Cmp(actual) != 0 {
+			parts = append(parts, fmt.Sprintf("%v recorded %v actual %v", rs, recorded.String(), actual.String()))
+		}
+	}
+	if len(d.MissingSubs) > 0 {
+		parts = append(parts, fmt.Sprintf("missing subs %v", d.MissingSubs))
+	}
+	if len(d.StaleSubs) > 0 {
+		parts = append(parts, fmt.Sprintf("stale subs %v", d.StaleSubs))
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package drift
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+func newQuota(name, parent string, kind quotav1.TargetKind, cpu string, used string, subs ...string) *quotav1.CubeResourceQuota {
+	q := &quotav1.CubeResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{constants.ClusterLabel: "pivot-cluster"}},
+		Spec: quotav1.CubeResourceQuotaSpec{
+			ParentQuota: parent,
+			Target:      quotav1.TargetObj{Kind: kind, Name: name},
+			Hard:        v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse(cpu)},
+		},
+		Status: quotav1.CubeResourceQuotaStatus{
+			Used:              v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse(used)},
+			SubResourceQuotas: subs,
+		},
+	}
+	return q
+}
+
+func TestCheck(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	quotav1.AddToScheme(scheme)
+
+	pivot := fake.NewClientBuilder().WithScheme(scheme).WithStatusSubresource(&quotav1.CubeResourceQuota{}).WithObjects(
+		// phantom usage of removed project-2
+		newQuota("tenant-1", "", quotav1.TenantObj, "10", "6", "project-1.quota", "project-2.quota"),
+		newQuota("project-1", "tenant-1", quotav1.ProjectObj, "4", "1", "rq-1.ns-1.quota"),
+		newQuota("project-3", "", quotav1.ProjectObj, "4", "2", "rq-3.ns-3.quota"),
+	).Build()
+	member := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&v1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: "rq-1", Namespace: "ns-1", Labels: map[string]string{constants.CubeQuotaLabel: "project-1"}},
+			Spec:       v1.ResourceQuotaSpec{Hard: v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("1")}},
+		},
+		&v1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: "rq-1-missed", Namespace: "ns-1", Labels: map[string]string{constants.CubeQuotaLabel: "project-1"}},
+			Spec:       v1.ResourceQuotaSpec{Hard: v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("2")}},
+		},
+		&v1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: "rq-3", Namespace: "ns-3", Labels: map[string]string{constants.CubeQuotaLabel: "project-3"}},
+			Spec:       v1.ResourceQuotaSpec{Hard: v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("2")}},
+		},
+	).Build()
+	memberOf := func(cluster string) (client.Reader, error) {
+		if cluster != "pivot-cluster" {
+			return nil, fmt.Errorf("cluster %v not found", cluster)
+		}
+		return member, nil
+	}
+	recorder := record.NewFakeRecorder(10)
+	d := NewDetector(pivot, memberOf, recorder)
+
+	result, err := d.Check(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Checked != 3 || len(result.Drifts) != 2 {
+		t.Fatalf("want 3 checked and 2 drifted, got %+v", result)
+	}
+	drifts := map[string]QuotaDrift{}
+	for _, drift := range result.Drifts {
+		drifts[drift.Quota] = drift
+	}
+	tenant := drifts["tenant-1"]
+	if cpu := tenant.ActualUsed[v1.ResourceRequestsCPU]; cpu.Cmp(resource.MustParse("4")) != 0 || strings.Join(tenant.StaleSubs, ",") != "project-2.quota" {
+		t.Errorf("unexpected drift of tenant: %+v", tenant)
+	}
+	project := drifts["project-1"]
+	if cpu := project.ActualUsed[v1.ResourceRequestsCPU]; cpu.Cmp(resource.MustParse("3")) != 0 || strings.Join(project.MissingSubs, ",") != "rq-1-missed.ns-1.quota" {
+		t.Errorf("unexpected drift of project: %+v", project)
+	}
+	if len(recorder.Events) != 2 {
+		t.Errorf("want 2 events, got %v", len(recorder.Events))
+	}
+
+	// dry run leaves status untouched
+	got := &quotav1.CubeResourceQuota{}
+	pivot.Get(context.Background(), types.NamespacedName{Name: "tenant-1"}, got)
+	if cpu := got.Status.Used[v1.ResourceRequestsCPU]; cpu.Cmp(resource.MustParse("6")) != 0 {
+		t.Errorf("status should not be repaired in dry run, got %v", got.Status)
+	}
+
+	result, err = d.Check(context.Background(), false, "tenant-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Checked != 1 || len(result.Drifts) != 1 || !result.Drifts[0].Repaired {
+		t.Fatalf("tenant-1 should be repaired, got %+v", result)
+	}
+	pivot.Get(context.Background(), types.NamespacedName{Name: "tenant-1"}, got)
+	if cpu := got.Status.Used[v1.ResourceRequestsCPU]; cpu.Cmp(resource.MustParse("4")) != 0 || strings.Join(got.Status.SubResourceQuotas, ",") != "project-1.quota" {
+		t.Errorf("status of tenant-1 should be repaired, got %v", got.Status)
+	}
+
+	if result, _ = d.Check(context.Background(), true, "tenant-1"); len(result.Drifts) != 0 {
+		t.Errorf("repaired quota should not drift, got %+v", result.Drifts)
+	}
+}
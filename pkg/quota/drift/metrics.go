@@ -0,0 +1,63 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package drift
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// usedDrift is recorded used minus actual used of drifted quotas, series
+	// of quota is removed once it is consistent again
+	usedDrift = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kubecube_cube_resource_quota_used_drift",
+		Help: "Recorded used minus used recomputed from child quotas of drifted cube resource quota",
+	}, []string{"quota", "resource"})
+
+	subQuotasDrift = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kubecube_cube_resource_quota_sub_quotas_drift",
+		Help: "Number of missing and stale child quotas recorded in drifted cube resource quota",
+	}, []string{"quota", "type"})
+
+	repairsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "kubecube_cube_resource_quota_drift_repairs_total",
+		Help: "Total number of drifted cube resource quotas repaired",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(usedDrift, subQuotasDrift, repairsTotal)
+}
+
+func observeDrift(d *QuotaDrift) {
+	resetMetrics(d.Quota)
+	for rs, actual := range d.ActualUsed {
+		recorded := d.RecordedUsed[rs]
+		if recorded.Cmp(actual) == 0 {
+			continue
+		}
+		usedDrift.WithLabelValues(d.Quota, string(rs)).Set(recorded.AsApproximateFloat64() - actual.AsApproximateFloat64())
+	}
+	subQuotasDrift.WithLabelValues(d.Quota, "missing").Set(float64(len(d.MissingSubs)))
+	subQuotasDrift.WithLabelValues(d.Quota, "stale").Set(float64(len(d.StaleSubs)))
+}
+
+func resetMetrics(quota string) {
+	usedDrift.DeletePartialMatch(prometheus.Labels{"quota": quota})
+	subQuotasDrift.DeletePartialMatch(prometheus.Labels{"quota": quota})
+}
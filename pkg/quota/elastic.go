@@ -0,0 +1,152 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quota
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
+)
+
+// Elastic project quota guarantees its hard and may use more up to max of
+// elastic by borrowing capacity of parent that siblings do not use. Siblings
+// can always grow within their guaranteed hard, and when used of siblings
+// exceeds hard of parent the borrowed resources are reclaimed.
+
+// IsElastic tells if quota could borrow resources from siblings
+func IsElastic(q *quotav1.CubeResourceQuota) bool {
+	return q != nil && q.Spec.Elastic != nil
+}
+
+// ElasticMax returns the borrowing ceiling of resource, false if the
+// resource can not be borrowed
+func ElasticMax(q *quotav1.CubeResourceQuota, rs v1.ResourceName) (resource.Quantity, bool) {
+	if !IsElastic(q) {
+		return resource.Quantity{}, false
+	}
+	max, ok := q.Spec.Elastic.Max[rs]
+	return max, ok
+}
+
+// ReclaimPolicyOf returns reclaim policy of elastic quota with default
+func ReclaimPolicyOf(q *quotav1.CubeResourceQuota) quotav1.ReclaimPolicy {
+	if !IsElastic(q) || len(q.Spec.Elastic.ReclaimPolicy) == 0 {
+		return quotav1.ReclaimFlag
+	}
+	return q.Spec.Elastic.ReclaimPolicy
+}
+
+// IsReclaiming tells if any borrowed resource of quota is being reclaimed
+func IsReclaiming(q *quotav1.CubeResourceQuota) bool {
+	for _, v := range q.Status.Reclaiming {
+		if v.Sign() > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateElastic validates elastic setting of quota
+func ValidateElastic(q *quotav1.CubeResourceQuota) error {
+	if !IsElastic(q) {
+		return nil
+	}
+	if q.Spec.Target.Kind != quotav1.ProjectObj {
+		return fmt.Errorf("elastic is only supported by quota of project")
+	}
+	switch q.Spec.Elastic.ReclaimPolicy {
+	case "", quotav1.ReclaimFlag, quotav1.ReclaimBlockNewPods:
+	default:
+		return fmt.Errorf("unknown reclaim policy %v", q.Spec.Elastic.ReclaimPolicy)
+	}
+	for rs, max := range q.Spec.Elastic.Max {
+		hard, ok := q.Spec.Hard[rs]
+		if !ok {
+			return fmt.Errorf("max of resource(%v) is set but hard not", rs)
+		}
+		if max.Cmp(hard) < 0 {
+			return fmt.Errorf("max of resource(%v) %v is less than hard %v", rs, max.String(), hard.String())
+		}
+	}
+	return nil
+}
+
+// Borrowed returns used beyond guaranteed hard of resources could be
+// borrowed, it is empty if nothing borrowed
+func Borrowed(q *quotav1.CubeResourceQuota) v1.ResourceList {
+	borrowed := v1.ResourceList{}
+	if !IsElastic(q) {
+		return borrowed
+	}
+	for rs := range q.Spec.Elastic.Max {
+		used, hard := q.Status.Used[rs], q.Spec.Hard[rs]
+		if used.Cmp(hard) > 0 {
+			used.Sub(hard)
+			borrowed[rs] = used
+		}
+	}
+	return borrowed
+}
+
+// SumUsed sums used of quotas
+func SumUsed(quotas ...*quotav1.CubeResourceQuota) v1.ResourceList {
+	sum := v1.ResourceList{}
+	for _, q := range quotas {
+		for rs, used := range q.Status.Used {
+			s, ok := sum[rs]
+			if !ok {
+				s = ZeroQ()
+			}
+			s.Add(used)
+			sum[rs] = s
+		}
+	}
+	return sum
+}
+
+// Reclaiming computes the borrowed resources each elastic quota has to return
+// when used of children exceeds hard of parent, keyed by name of quota. Every
+// borrower is asked to return its borrowed up to the exceeded amount.
+func Reclaiming(parentHard v1.ResourceList, children []*quotav1.CubeResourceQuota) map[string]v1.ResourceList {
+	total := SumUsed(children...)
+	reclaiming := make(map[string]v1.ResourceList, len(children))
+	for _, c := range children {
+		reclaiming[c.Name] = v1.ResourceList{}
+	}
+
+	for rs, hard := range parentHard {
+		over := total[rs]
+		if over.Cmp(hard) <= 0 {
+			continue
+		}
+		over.Sub(hard)
+		for _, c := range children {
+			borrowed, ok := Borrowed(c)[rs]
+			if !ok {
+				continue
+			}
+			if borrowed.Cmp(over) > 0 {
+				borrowed = over.DeepCopy()
+			}
+			reclaiming[c.Name][rs] = borrowed
+		}
+	}
+	return reclaiming
+}
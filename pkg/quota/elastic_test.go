@@ -0,0 +1,110 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quota
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
+)
+
+func newProjectQuota(name, hard, max, used string) *quotav1.CubeResourceQuota {
+	q := &quotav1.CubeResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: quotav1.CubeResourceQuotaSpec{
+			Target: quotav1.TargetObj{Kind: quotav1.ProjectObj, Name: name},
+			Hard:   v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse(hard)},
+		},
+		Status: quotav1.CubeResourceQuotaStatus{Used: v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse(used)}},
+	}
+	if len(max) > 0 {
+		q.Spec.Elastic = &quotav1.ElasticQuota{Max: v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse(max)}}
+	}
+	return q
+}
+
+func TestValidateElastic(t *testing.T) {
+	if err := ValidateElastic(newProjectQuota("p1", "4", "8", "0")); err != nil {
+		t.Errorf("valid elastic: %v", err)
+	}
+	if err := ValidateElastic(newProjectQuota("p1", "4", "2", "0")); err == nil {
+		t.Errorf("max less than hard should be invalid")
+	}
+	q := newProjectQuota("p1", "4", "8", "0")
+	q.Spec.Target.Kind = quotav1.TenantObj
+	if err := ValidateElastic(q); err == nil {
+		t.Errorf("elastic of tenant quota should be invalid")
+	}
+	q = newProjectQuota("p1", "4", "8", "0")
+	q.Spec.Elastic.Max[v1.ResourceRequestsMemory] = resource.MustParse("1Gi")
+	if err := ValidateElastic(q); err == nil {
+		t.Errorf("max of resource without hard should be invalid")
+	}
+}
+
+func TestReclaiming(t *testing.T) {
+	parentHard := v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("10")}
+	borrower := newProjectQuota("p1", "4", "8", "7")
+	idle := newProjectQuota("p2", "6", "", "2")
+
+	borrowed := Borrowed(borrower)[v1.ResourceRequestsCPU]
+	if borrowed.Cmp(resource.MustParse("3")) != 0 {
+		t.Errorf("want borrowed 3, got %v", borrowed.String())
+	}
+
+	// p2 uses less than its guaranteed, nothing to reclaim
+	reclaiming := Reclaiming(parentHard, []*quotav1.CubeResourceQuota{borrower, idle})
+	if len(reclaiming["p1"]) != 0 || len(reclaiming["p2"]) != 0 {
+		t.Errorf("nothing should be reclaimed, got %v", reclaiming)
+	}
+
+	// p2 grows back to its guaranteed, 2 of borrowed by p1 is reclaimed
+	idle.Status.Used[v1.ResourceRequestsCPU] = resource.MustParse("5")
+	reclaiming = Reclaiming(parentHard, []*quotav1.CubeResourceQuota{borrower, idle})
+	if r := reclaiming["p1"][v1.ResourceRequestsCPU]; r.Cmp(resource.MustParse("2")) != 0 {
+		t.Errorf("want reclaiming 2 of p1, got %v", reclaiming)
+	}
+	if len(reclaiming["p2"]) != 0 {
+		t.Errorf("p2 borrows nothing, got %v", reclaiming["p2"])
+	}
+}
+
+func TestKeepRecordedStatus(t *testing.T) {
+	latest := newProjectQuota("p1", "4", "8", "5")
+	latest.Status.Borrowed = v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("1")}
+	latest.Status.Reclaiming = v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("1")}
+
+	// used is recomputed by populating, borrowed follows it
+	stale := newProjectQuota("p1", "4", "8", "6")
+	KeepRecordedStatus(stale, latest)
+	if !ResourceListEqual(stale.Status.Reclaiming, latest.Status.Reclaiming) {
+		t.Errorf("reclaiming should be kept, got %v", stale.Status.Reclaiming)
+	}
+	if !ResourceListEqual(stale.Status.Borrowed, v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("2")}) {
+		t.Errorf("borrowed should follow used, got %v", stale.Status.Borrowed)
+	}
+
+	stale = newProjectQuota("p1", "4", "", "6")
+	KeepRecordedStatus(stale, latest)
+	if !ResourceListEqual(stale.Status.Borrowed, latest.Status.Borrowed) {
+		t.Errorf("borrowed should be kept, got %v", stale.Status.Borrowed)
+	}
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quota
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+// Pods of namespaces without ResourceQuota are charged against the nearest
+// project or tenant quota limiting each resource. Their usage is recorded
+// in status charged of the quota by warden of member cluster.
+
+// ClusterQuotas are the cube resource quotas of a cluster indexed by
+// name and target
+type ClusterQuotas struct {
+	ByName   map[string]*quotav1.CubeResourceQuota
+	Projects map[string]*quotav1.CubeResourceQuota
+	Tenants  map[string]*quotav1.CubeResourceQuota
+}
+
+// ListClusterQuotas lists cube resource quotas of cluster from pivot cluster
+func ListClusterQuotas(ctx context.Context, pivotClient client.Reader, cluster string) (*ClusterQuotas, error) {
+	list := &quotav1.CubeResourceQuotaList{}
+	if err := pivotClient.List(ctx, list, client.MatchingLabels{constants.ClusterLabel: cluster}); err != nil {
+		return nil, err
+	}
+	cq := &ClusterQuotas{
+		ByName:   make(map[string]*quotav1.CubeResourceQuota),
+		Projects: make(map[string]*quotav1.CubeResourceQuota),
+		Tenants:  make(map[string]*quotav1.CubeResourceQuota),
+	}
+	for i := range list.Items {
+		q := &list.Items[i]
+		cq.ByName[q.Name] = q
+		switch q.Spec.Target.Kind {
+		case quotav1.ProjectObj:
+			cq.Projects[q.Spec.Target.Name] = q
+		case quotav1.TenantObj:
+			cq.Tenants[q.Spec.Target.Name] = q
+		}
+	}
+	return cq, nil
+}
+
+// LevelsOf returns the quotas of project and tenant of namespace, the
+// nearest first. Tenant quota is the parent of project quota if any.
+func (cq *ClusterQuotas) LevelsOf(ns *v1.Namespace) []*quotav1.CubeResourceQuota {
+	var levels []*quotav1.CubeResourceQuota
+	project, tenant := ns.Labels[constants.HncProjectLabel], ns.Labels[constants.HncTenantLabel]
+	pq := cq.Projects[project]
+	if len(project) > 0 && pq != nil {
+		levels = append(levels, pq)
+		if parent, ok := cq.ByName[pq.Spec.ParentQuota]; ok {
+			return append(levels, parent)
+		}
+	}
+	if tq := cq.Tenants[tenant]; len(tenant) > 0 && tq != nil {
+		levels = append(levels, tq)
+	}
+	return levels
+}
+
+// OfTenant returns the quotas of tenant and its projects
+func (cq *ClusterQuotas) OfTenant(tenant string) []*quotav1.CubeResourceQuota {
+	tq := cq.Tenants[tenant]
+	var quotas []*quotav1.CubeResourceQuota
+	for _, q := range cq.ByName {
+		if q == tq || (tq != nil && q.Spec.ParentQuota == tq.Name) || q.Labels[constants.TenantLabel] == tenant {
+			quotas = append(quotas, q)
+		}
+	}
+	return quotas
+}
+
+// LimitOf returns the hard limit of resource in quota, false if the
+// resource is not limited
+func LimitOf(q *quotav1.CubeResourceQuota, rs v1.ResourceName) (resource.Quantity, bool) {
+	if hard, ok := q.Status.Hard[rs]; ok {
+		return hard, true
+	}
+	hard, ok := q.Spec.Hard[rs]
+	return hard, ok
+}
+
+// LevelFor returns the nearest quota of levels which limits resource
+func LevelFor(levels []*quotav1.CubeResourceQuota, rs v1.ResourceName) *quotav1.CubeResourceQuota {
+	for _, q := range levels {
+		if _, ok := LimitOf(q, rs); ok {
+			return q
+		}
+	}
+	return nil
+}
+
+// ChargedByPods sums usage of running pods in namespaces of tenant without
+// ResourceQuota by the quota they are charged against, keyed by name of quota
+func ChargedByPods(ctx context.Context, cli client.Reader, cq *ClusterQuotas, tenant string) (map[string]v1.ResourceList, error) {
+	nsList := &v1.NamespaceList{}
+	if err := cli.List(ctx, nsList, client.MatchingLabels{constants.HncTenantLabel: tenant}); err != nil {
+		return nil, err
+	}
+	rqs := &v1.ResourceQuotaList{}
+	if err := cli.List(ctx, rqs, client.HasLabels{constants.CubeQuotaLabel}); err != nil {
+		return nil, err
+	}
+	budgeted := make(map[string]bool)
+	for _, rq := range rqs.Items {
+		budgeted[rq.Namespace] = true
+	}
+
+	charged := make(map[string]v1.ResourceList)
+	for i := range nsList.Items {
+		item := &nsList.Items[i]
+		if budgeted[item.Name] {
+			continue
+		}
+		levels := cq.LevelsOf(item)
+		if len(levels) == 0 {
+			continue
+		}
+		pods := &v1.PodList{}
+		if err := cli.List(ctx, pods, client.InNamespace(item.Name)); err != nil {
+			return nil, err
+		}
+		for j := range pods.Items {
+			p := &pods.Items[j]
+			if p.Status.Phase == v1.PodSucceeded || p.Status.Phase == v1.PodFailed {
+				continue
+			}
+			for rs, value := range PodUsage(p) {
+				q := LevelFor(levels, rs)
+				if q == nil {
+					continue
+				}
+				if charged[q.Name] == nil {
+					charged[q.Name] = v1.ResourceList{}
+				}
+				sum := charged[q.Name][rs]
+				sum.Add(value)
+				charged[q.Name][rs] = sum
+			}
+		}
+	}
+	return charged, nil
+}
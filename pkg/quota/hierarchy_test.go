@@ -0,0 +1,114 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quota
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+func TestChargedByPods(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	quotav1.AddToScheme(scheme)
+
+	labels := map[string]string{constants.ClusterLabel: "c1"}
+	pivot := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&quotav1.CubeResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: "c1.tenant.t1", Labels: labels},
+			Spec: quotav1.CubeResourceQuotaSpec{
+				Target: quotav1.TargetObj{Kind: quotav1.TenantObj, Name: "t1"},
+				Hard:   v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("10"), v1.ResourcePods: resource.MustParse("10")},
+			},
+		},
+		&quotav1.CubeResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: "c1.project.p1", Labels: labels},
+			Spec: quotav1.CubeResourceQuotaSpec{
+				Target:      quotav1.TargetObj{Kind: quotav1.ProjectObj, Name: "p1"},
+				ParentQuota: "c1.tenant.t1",
+				Hard:        v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("4")},
+			},
+		},
+	).Build()
+
+	newPod := func(ns, name, cpu string, phase v1.PodPhase) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name},
+			Spec: v1.PodSpec{Containers: []v1.Container{{Name: "c", Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse(cpu)},
+			}}}},
+			Status: v1.PodStatus{Phase: phase},
+		}
+	}
+	nsLabels := func(project string) map[string]string {
+		return map[string]string{constants.HncTenantLabel: "t1", constants.HncProjectLabel: project}
+	}
+	local := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns1", Labels: nsLabels("p1")}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns2", Labels: nsLabels("p2")}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "budgeted", Labels: nsLabels("p1")}},
+		&v1.ResourceQuota{ObjectMeta: metav1.ObjectMeta{Namespace: "budgeted", Name: "rq", Labels: map[string]string{constants.CubeQuotaLabel: "c1.project.p1"}}},
+		newPod("ns1", "a", "3", v1.PodRunning),
+		newPod("ns1", "done", "3", v1.PodSucceeded),
+		newPod("ns2", "b", "5", v1.PodRunning),
+		newPod("budgeted", "c", "8", v1.PodRunning),
+	).Build()
+
+	ctx := context.Background()
+	cq, err := ListClusterQuotas(ctx, pivot, "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(cq.OfTenant("t1")); n != 2 {
+		t.Errorf("want 2 quotas of tenant, got %v", n)
+	}
+	charged, err := ChargedByPods(ctx, local, cq, "t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]v1.ResourceList{
+		"c1.project.p1": {v1.ResourceRequestsCPU: resource.MustParse("3")},
+		"c1.tenant.t1":  {v1.ResourceRequestsCPU: resource.MustParse("5"), v1.ResourcePods: resource.MustParse("2")},
+	}
+	if len(charged) != len(want) {
+		t.Fatalf("want %v, got %v", want, charged)
+	}
+	for name, list := range want {
+		if !ResourceListEqual(charged[name], list) {
+			t.Errorf("charged of %v: want %v, got %v", name, list, charged[name])
+		}
+	}
+
+	used := UsedOf(&quotav1.CubeResourceQuota{Status: quotav1.CubeResourceQuotaStatus{
+		Used:    v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("4")},
+		Charged: charged["c1.tenant.t1"],
+	}})
+	if cpu := used[v1.ResourceRequestsCPU]; cpu.Cmp(resource.MustParse("9")) != 0 {
+		t.Errorf("want used 9 with charged, got %v", cpu.String())
+	}
+}
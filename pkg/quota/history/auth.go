@@ -0,0 +1,60 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	rbacv1 "k8s.io/api/rbac/v1"
+
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/authorizer/rbac"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/tsdb"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+// WardenPath is where warden serves history of local cluster
+const WardenPath = "/api/v1/warden/history/quota"
+
+// CanQuery tells if user can query history matched by matcher, platform
+// admins can query all tenants, tenant admins can query their own tenant and
+// project admins can query their own project
+func CanQuery(r rbac.Interface, user string, matcher tsdb.Matcher) bool {
+	if u, err := r.GetUser(user); err == nil && userv1.IsPlatformAdmin(&u) {
+		return true
+	}
+	if tenant := matcher[LabelTenant]; len(tenant) > 0 && isAdmin(r, user, constants.TenantAdmin, constants.TenantNsPrefix+tenant) {
+		return true
+	}
+	if project := matcher[LabelProject]; len(project) > 0 && isAdmin(r, user, constants.ProjectAdmin, constants.ProjectNsPrefix+project) {
+		return true
+	}
+	return false
+}
+
+// isAdmin tells if user is bound to admin role in namespace
+func isAdmin(r rbac.Interface, user, role, namespace string) bool {
+	users, err := r.UsersFor(rbacv1.RoleRef{APIGroup: constants.K8sGroupRBAC, Kind: constants.KindClusterRole, Name: role}, namespace)
+	if err != nil {
+		clog.Warn("resolve users of %v in namespace %v failed: %v", role, namespace, err)
+	}
+	for _, u := range users {
+		if u.Name == user {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package history samples hard and used of project and tenant quotas of
+// local cluster and usage of their namespaces reported by metrics server,
+// and queries the trends of them grouped by tenant, project or cluster.
+package history
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kubecube-io/kubecube/pkg/tsdb"
+)
+
+// Labels of samples
+const (
+	LabelCluster = "cluster"
+	LabelTenant  = "tenant"
+	LabelProject = "project"
+	// LabelLevel tells if sample is of project or tenant
+	LabelLevel = "level"
+)
+
+const (
+	LevelTenant  = "tenant"
+	LevelProject = "project"
+)
+
+// Kinds of values, a value of sample is keyed by "<kind>/<resource>"
+const (
+	KindHard  = "hard"
+	KindUsed  = "used"
+	KindUsage = "usage"
+)
+
+// Options of history, sampling is disabled if SampleIntervalMinutes is 0
+type Options struct {
+	DataDir               string
+	SampleIntervalMinutes int
+	RetentionDays         int
+	DownsampleAfterDays   int
+	DownsampleMinutes     int
+}
+
+var (
+	Config = Options{}
+
+	once     sync.Once
+	store    *tsdb.Store
+	storeErr error
+)
+
+// Enabled tells if samples are collected
+func (o Options) Enabled() bool {
+	return o.SampleIntervalMinutes > 0 && len(o.DataDir) > 0
+}
+
+func (o Options) Interval() time.Duration {
+	return time.Duration(o.SampleIntervalMinutes) * time.Minute
+}
+
+// Store returns the store samples are kept in, it is opened once
+func Store() (*tsdb.Store, error) {
+	once.Do(func() {
+		store, storeErr = tsdb.Open(tsdb.Options{
+			Dir:             Config.DataDir,
+			Retention:       time.Duration(Config.RetentionDays) * 24 * time.Hour,
+			DownsampleAfter: time.Duration(Config.DownsampleAfterDays) * 24 * time.Hour,
+			DownsampleStep:  time.Duration(Config.DownsampleMinutes) * time.Minute,
+		})
+	})
+	return store, storeErr
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	clienttesting "k8s.io/client-go/testing"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	metricsfake "k8s.io/metrics/pkg/client/clientset/versioned/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
+	"github.com/kubecube-io/kubecube/pkg/multicluster/client/fake"
+	"github.com/kubecube-io/kubecube/pkg/tsdb"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+func TestSample(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	quotav1.AddToScheme(scheme)
+
+	cpu := func(hard, used string) (v1.ResourceList, v1.ResourceList) {
+		return v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse(hard)}, v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse(used)}
+	}
+	crq := func(name, cluster string, kind quotav1.TargetKind, target, parent, hard, used string) client.Object {
+		q := &quotav1.CubeResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{constants.ClusterLabel: cluster}},
+			Spec:       quotav1.CubeResourceQuotaSpec{Target: quotav1.TargetObj{Kind: kind, Name: target}, ParentQuota: parent},
+		}
+		q.Spec.Hard, q.Status.Used = cpu(hard, used)
+		return q
+	}
+	pivot := fake.NewFakeClients(&fake.Options{Scheme: scheme, Objs: []client.Object{
+		crq("c1.tenant.t1", "c1", quotav1.TenantObj, "t1", "", "10", "6"),
+		crq("c1.gpu.tenant.t1", "c1", quotav1.TenantObj, "t1", "", "2", "1"),
+		crq("c1.project.p1", "c1", quotav1.ProjectObj, "p1", "c1.tenant.t1", "6", "2"),
+		crq("c2.project.p2", "c2", quotav1.ProjectObj, "p2", "", "4", "4"),
+	}})
+	local := fake.NewFakeClients(&fake.Options{Scheme: scheme, Objs: []client.Object{
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns1", Labels: map[string]string{constants.HncTenantLabel: "t1", constants.HncProjectLabel: "p1"}}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns2", Labels: map[string]string{constants.HncTenantLabel: "t1"}}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "other"}},
+	}})
+	// tracker of fake metrics clientset does not list pod metrics by
+	// resource pods, so list is served by reactor
+	local.Metrics().(*metricsfake.Clientset).PrependReactor("list", "pods", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		usage := func(ns, cpu string) metricsv1beta1.PodMetrics {
+			return metricsv1beta1.PodMetrics{
+				ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: "pod"},
+				Containers: []metricsv1beta1.ContainerMetrics{{Name: "c", Usage: v1.ResourceList{v1.ResourceCPU: resource.MustParse(cpu)}}},
+			}
+		}
+		return true, &metricsv1beta1.PodMetricsList{Items: []metricsv1beta1.PodMetrics{usage("ns1", "1500m"), usage("ns2", "500m"), usage("other", "4")}}, nil
+	})
+
+	now := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+	samples, err := NewSampler("c1", pivot.Direct(), local.Direct(), local.Metrics()).Sample(context.Background(), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 2 {
+		t.Fatalf("want samples of p1 and t1, got %+v", samples)
+	}
+	p1, t1 := samples[0], samples[1]
+	if p1.Labels[LabelLevel] != LevelProject || p1.Labels[LabelTenant] != "t1" || p1.Labels[LabelProject] != "p1" || p1.Labels[LabelCluster] != "c1" {
+		t.Errorf("unexpected labels of p1: %v", p1.Labels)
+	}
+	want := map[string]float64{"hard/requests.cpu": 6, "used/requests.cpu": 2, "usage/requests.cpu": 1.5}
+	for k, v := range want {
+		if p1.Values[k] != v {
+			t.Errorf("want %v of p1 to be %v, got %v", k, v, p1.Values[k])
+		}
+	}
+	// quotas of tenant in nodes pools are summed
+	want = map[string]float64{"hard/requests.cpu": 12, "used/requests.cpu": 7, "usage/requests.cpu": 2}
+	for k, v := range want {
+		if t1.Values[k] != v {
+			t.Errorf("want %v of t1 to be %v, got %v", k, v, t1.Values[k])
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	store, err := tsdb.Open(tsdb.Options{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+	sample := func(at time.Time, level, tenant, project string, used float64) tsdb.Sample {
+		labels := map[string]string{LabelCluster: "c1", LabelLevel: level, LabelTenant: tenant}
+		if len(project) > 0 {
+			labels[LabelProject] = project
+		}
+		return tsdb.Sample{Time: at, Labels: labels, Values: map[string]float64{"used/requests.cpu": used}}
+	}
+	for i := 0; i < 4; i++ {
+		at := start.Add(time.Duration(i) * 30 * time.Minute)
+		store.Append(
+			sample(at, LevelProject, "t1", "p1", float64(i)),
+			sample(at, LevelProject, "t1", "p2", 1),
+			sample(at, LevelTenant, "t1", "", 10),
+			sample(at, LevelTenant, "t2", "", 5),
+		)
+	}
+
+	values := url.Values{}
+	values.Set("start", start.Format(time.RFC3339))
+	values.Set("step", "1h")
+	values.Set("tenant", "t1")
+	opts, matcher, err := ParseQuery(values, start.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := Query(store, opts, matcher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Series) != 2 || result.Series[0].Project != "p1" || len(result.Series[0].Points) != 2 {
+		t.Fatalf("unexpected series: %+v", result.Series)
+	}
+	if p := result.Series[0].Points[1]; !p.Time.Equal(start.Add(time.Hour)) || p.Values["used/requests.cpu"] != 2.5 {
+		t.Errorf("unexpected point of p1: %+v", p)
+	}
+
+	opts.GroupBy = GroupByCluster
+	result, _ = Query(store, opts, nil)
+	if len(result.Series) != 1 || result.Series[0].Cluster != "c1" || result.Series[0].Points[0].Values["used/requests.cpu"] != 15 {
+		t.Fatalf("unexpected series of cluster: %+v", result.Series)
+	}
+
+	merged := MergeSeries(result.Series, []Series{{Cluster: "c2", Points: result.Series[0].Points}}, result.Series)
+	if len(merged) != 2 || merged[0].Points[0].Values["used/requests.cpu"] != 30 || merged[1].Cluster != "c2" {
+		t.Errorf("unexpected merged series: %+v", merged)
+	}
+
+	values.Set("step", "1s")
+	if _, _, err = ParseQuery(values, start.Add(2*time.Hour)); err == nil {
+		t.Errorf("want error of step less than 1m")
+	}
+	values.Set("step", "1m")
+	values.Set("groupBy", "namespace")
+	if _, _, err = ParseQuery(values, start.Add(2*time.Hour)); err == nil {
+		t.Errorf("want error of invalid groupBy")
+	}
+}
@@ -0,0 +1,288 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/kubecube-io/kubecube/pkg/tsdb"
+)
+
+const (
+	// defaultPeriod is the period of query when start is not given
+	defaultPeriod = 7 * 24 * time.Hour
+
+	// defaultPoints is the number of points of series when step is not given
+	defaultPoints = 200
+
+	// maxPoints limits the number of points of series
+	maxPoints = 10000
+)
+
+type GroupBy string
+
+const (
+	GroupByTenant  GroupBy = "tenant"
+	GroupByProject GroupBy = "project"
+	GroupByCluster GroupBy = "cluster"
+)
+
+// QueryOptions are options of range query, samples are averaged in steps
+// aligned to start
+type QueryOptions struct {
+	Start   time.Time
+	End     time.Time
+	Step    time.Duration
+	GroupBy GroupBy
+}
+
+func (o *QueryOptions) Validate() error {
+	switch o.GroupBy {
+	case GroupByTenant, GroupByProject, GroupByCluster:
+	default:
+		return fmt.Errorf("groupBy must be one of tenant, project and cluster")
+	}
+	if !o.Start.Before(o.End) {
+		return fmt.Errorf("start must be before end")
+	}
+	if o.Step < time.Minute {
+		return fmt.Errorf("step must not be less than 1m")
+	}
+	if o.End.Sub(o.Start)/o.Step > maxPoints {
+		return fmt.Errorf("too many points, step must be greater than %v", o.End.Sub(o.Start)/maxPoints)
+	}
+	return nil
+}
+
+// ParseQuery parses options and matcher of query from url values of start,
+// end, step, groupBy, tenant and project
+func ParseQuery(values url.Values, now time.Time) (QueryOptions, tsdb.Matcher, error) {
+	opts := QueryOptions{End: now, GroupBy: GroupByProject}
+	if v := values.Get("groupBy"); len(v) > 0 {
+		opts.GroupBy = GroupBy(v)
+	}
+	if v := values.Get("end"); len(v) > 0 {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return opts, nil, fmt.Errorf("end must be RFC3339 time")
+		}
+		opts.End = t
+	}
+	opts.Start = opts.End.Add(-defaultPeriod)
+	if v := values.Get("start"); len(v) > 0 {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return opts, nil, fmt.Errorf("start must be RFC3339 time")
+		}
+		opts.Start = t
+	}
+	if v := values.Get("step"); len(v) > 0 {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return opts, nil, fmt.Errorf("step must be duration such as 10m or 1h")
+		}
+		opts.Step = d
+	} else {
+		opts.Step = (opts.End.Sub(opts.Start) / defaultPoints).Truncate(time.Minute)
+		if opts.Step < time.Minute {
+			opts.Step = time.Minute
+		}
+	}
+
+	matcher := tsdb.Matcher{}
+	for _, label := range []string{LabelTenant, LabelProject} {
+		if v := values.Get(label); len(v) > 0 {
+			matcher[label] = v
+		}
+	}
+	return opts, matcher, opts.Validate()
+}
+
+// Values encodes options and matcher into url values, so that all clusters
+// are queried with the same steps
+func (o QueryOptions) Values(matcher tsdb.Matcher) url.Values {
+	values := url.Values{}
+	values.Set("start", o.Start.Format(time.RFC3339))
+	values.Set("end", o.End.Format(time.RFC3339))
+	values.Set("step", o.Step.String())
+	values.Set("groupBy", string(o.GroupBy))
+	for k, v := range matcher {
+		values.Set(k, v)
+	}
+	return values
+}
+
+// Point is the values of series in step beginning at time
+type Point struct {
+	Time   time.Time          `json:"time"`
+	Values map[string]float64 `json:"values"`
+}
+
+// Series is the trend of a group, labels not grouped by are empty
+type Series struct {
+	Cluster string  `json:"cluster,omitempty"`
+	Tenant  string  `json:"tenant,omitempty"`
+	Project string  `json:"project,omitempty"`
+	Points  []Point `json:"points"`
+}
+
+type Result struct {
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Step    string    `json:"step"`
+	GroupBy GroupBy   `json:"groupBy"`
+	Series  []Series  `json:"series"`
+	// UnavailableClusters are the clusters whose history can not be got
+	UnavailableClusters []string `json:"unavailableClusters,omitempty"`
+}
+
+func NewResult(opts QueryOptions) *Result {
+	return &Result{
+		Start:   opts.Start,
+		End:     opts.End,
+		Step:    opts.Step.String(),
+		GroupBy: opts.GroupBy,
+		Series:  []Series{},
+	}
+}
+
+// Query returns series of samples in store matched by matcher
+func Query(store *tsdb.Store, opts QueryOptions, matcher tsdb.Matcher) (*Result, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	m := tsdb.Matcher{LabelLevel: levelOf(opts.GroupBy)}
+	for k, v := range matcher {
+		m[k] = v
+	}
+	samples, err := store.Query(opts.Start, opts.End, m)
+	if err != nil {
+		return nil, err
+	}
+	result := NewResult(opts)
+	result.Series = BuildSeries(samples, opts)
+	return result, nil
+}
+
+// BuildSeries averages samples of each project or tenant in steps and sums
+// them by group. Samples of projects are used if grouped by project, or
+// those of tenants are used.
+func BuildSeries(samples []tsdb.Sample, opts QueryOptions) []Series {
+	level := levelOf(opts.GroupBy)
+	var matched []tsdb.Sample
+	for _, sample := range samples {
+		if sample.Labels[LabelLevel] == level {
+			matched = append(matched, sample)
+		}
+	}
+
+	acc := accumulator{}
+	for _, sample := range tsdb.Downsample(matched, opts.Start, opts.Step) {
+		key := groupKey{}
+		switch opts.GroupBy {
+		case GroupByCluster:
+			key.cluster = sample.Labels[LabelCluster]
+		case GroupByTenant:
+			key.tenant = sample.Labels[LabelTenant]
+		case GroupByProject:
+			key.tenant, key.project = sample.Labels[LabelTenant], sample.Labels[LabelProject]
+		}
+		acc.add(key, sample.Time, sample.Values)
+	}
+	return acc.series()
+}
+
+// MergeSeries sums points of the same group and time, it merges series
+// queried from clusters with the same options
+func MergeSeries(lists ...[]Series) []Series {
+	acc := accumulator{}
+	for _, list := range lists {
+		for _, s := range list {
+			key := groupKey{cluster: s.Cluster, tenant: s.Tenant, project: s.Project}
+			for _, p := range s.Points {
+				acc.add(key, p.Time, p.Values)
+			}
+		}
+	}
+	return acc.series()
+}
+
+func levelOf(groupBy GroupBy) string {
+	if groupBy == GroupByProject {
+		return LevelProject
+	}
+	return LevelTenant
+}
+
+type groupKey struct {
+	cluster string
+	tenant  string
+	project string
+}
+
+// accumulator sums values by group and unix nano of time
+type accumulator map[groupKey]map[int64]map[string]float64
+
+func (a accumulator) add(key groupKey, t time.Time, values map[string]float64) {
+	points, ok := a[key]
+	if !ok {
+		points = make(map[int64]map[string]float64)
+		a[key] = points
+	}
+	sum, ok := points[t.UnixNano()]
+	if !ok {
+		sum = make(map[string]float64)
+		points[t.UnixNano()] = sum
+	}
+	for k, v := range values {
+		sum[k] += v
+	}
+}
+
+func (a accumulator) series() []Series {
+	keys := make([]groupKey, 0, len(a))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].cluster != keys[j].cluster {
+			return keys[i].cluster < keys[j].cluster
+		}
+		if keys[i].tenant != keys[j].tenant {
+			return keys[i].tenant < keys[j].tenant
+		}
+		return keys[i].project < keys[j].project
+	})
+
+	result := make([]Series, 0, len(keys))
+	for _, k := range keys {
+		times := make([]int64, 0, len(a[k]))
+		for t := range a[k] {
+			times = append(times, t)
+		}
+		sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+		s := Series{Cluster: k.cluster, Tenant: k.tenant, Project: k.project, Points: make([]Point, 0, len(times))}
+		for _, t := range times {
+			s.Points = append(s.Points, Point{Time: time.Unix(0, t).UTC(), Values: a[k][t]})
+		}
+		result = append(result, s)
+	}
+	return result
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/metrics/pkg/client/clientset/versioned"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/quota"
+	"github.com/kubecube-io/kubecube/pkg/tsdb"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+// usageResources maps resources of pod metrics to the resources of quota
+var usageResources = map[v1.ResourceName]v1.ResourceName{
+	v1.ResourceCPU:    v1.ResourceRequestsCPU,
+	v1.ResourceMemory: v1.ResourceRequestsMemory,
+}
+
+// Sampler samples quotas of projects and tenants in cluster, values are in
+// base units such as cores and bytes.
+type Sampler struct {
+	cluster string
+	pivot   client.Reader
+	local   client.Reader
+	metrics versioned.Interface
+}
+
+func NewSampler(cluster string, pivot, local client.Reader, metrics versioned.Interface) *Sampler {
+	return &Sampler{cluster: cluster, pivot: pivot, local: local, metrics: metrics}
+}
+
+// sampleKey identifies a project or tenant in cluster
+type sampleKey struct {
+	level   string
+	tenant  string
+	project string
+}
+
+type entry struct {
+	hard  v1.ResourceList
+	used  v1.ResourceList
+	usage v1.ResourceList
+}
+
+// Sample returns samples of projects and tenants at now. Quotas of the same
+// project or tenant such as those in different nodes pools are summed.
+// Usage is left out if metrics server is not available.
+func (s *Sampler) Sample(ctx context.Context, now time.Time) ([]tsdb.Sample, error) {
+	list := &quotav1.CubeResourceQuotaList{}
+	if err := s.pivot.List(ctx, list, client.MatchingLabels{constants.ClusterLabel: s.cluster}); err != nil {
+		return nil, err
+	}
+	quotas := make(map[string]*quotav1.CubeResourceQuota, len(list.Items))
+	for i := range list.Items {
+		quotas[list.Items[i].Name] = &list.Items[i]
+	}
+
+	entries := make(map[sampleKey]*entry)
+	for _, q := range list.Items {
+		if q.DeletionTimestamp != nil {
+			continue
+		}
+		var key sampleKey
+		switch q.Spec.Target.Kind {
+		case quotav1.TenantObj:
+			key = sampleKey{level: LevelTenant, tenant: q.Spec.Target.Name}
+		case quotav1.ProjectObj:
+			tenant := q.Labels[constants.TenantLabel]
+			if parent, ok := quotas[q.Spec.ParentQuota]; ok && parent.Spec.Target.Kind == quotav1.TenantObj {
+				tenant = parent.Spec.Target.Name
+			}
+			key = sampleKey{level: LevelProject, tenant: tenant, project: q.Spec.Target.Name}
+		default:
+			continue
+		}
+		e, ok := entries[key]
+		if !ok {
+			e = &entry{hard: v1.ResourceList{}, used: v1.ResourceList{}, usage: v1.ResourceList{}}
+			entries[key] = e
+		}
+		hard := q.Status.Hard
+		if len(hard) == 0 {
+			hard = q.Spec.Hard
+		}
+		addResourceList(e.hard, hard)
+		addResourceList(e.used, quota.UsedOf(&q))
+	}
+
+	if err := s.sampleUsage(ctx, entries); err != nil {
+		return nil, err
+	}
+
+	keys := make([]sampleKey, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].level != keys[j].level {
+			return keys[i].level < keys[j].level
+		}
+		if keys[i].tenant != keys[j].tenant {
+			return keys[i].tenant < keys[j].tenant
+		}
+		return keys[i].project < keys[j].project
+	})
+
+	samples := make([]tsdb.Sample, 0, len(keys))
+	for _, k := range keys {
+		e := entries[k]
+		values := make(map[string]float64)
+		putValues(values, KindHard, e.hard)
+		putValues(values, KindUsed, e.used)
+		putValues(values, KindUsage, e.usage)
+		sampleLabels := map[string]string{LabelCluster: s.cluster, LabelLevel: k.level, LabelTenant: k.tenant}
+		if k.level == LevelProject {
+			sampleLabels[LabelProject] = k.project
+		}
+		samples = append(samples, tsdb.Sample{Time: now, Labels: sampleLabels, Values: values})
+	}
+	return samples, nil
+}
+
+// sampleUsage sums usage of pods into projects and tenants by the labels
+// of their namespaces
+func (s *Sampler) sampleUsage(ctx context.Context, entries map[sampleKey]*entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	selector, err := labels.Parse(constants.HncTenantLabel)
+	if err != nil {
+		return err
+	}
+	nsList := &v1.NamespaceList{}
+	if err = s.local.List(ctx, nsList, &client.ListOptions{LabelSelector: selector}); err != nil {
+		return err
+	}
+	owners := make(map[string][]*entry, len(nsList.Items))
+	for _, ns := range nsList.Items {
+		tenant, project := ns.Labels[constants.HncTenantLabel], ns.Labels[constants.HncProjectLabel]
+		if e, ok := entries[sampleKey{level: LevelTenant, tenant: tenant}]; ok {
+			owners[ns.Name] = append(owners[ns.Name], e)
+		}
+		if e, ok := entries[sampleKey{level: LevelProject, tenant: tenant, project: project}]; ok && len(project) > 0 {
+			owners[ns.Name] = append(owners[ns.Name], e)
+		}
+	}
+	if len(owners) == 0 {
+		return nil
+	}
+
+	// usage is optional since metrics server may not be installed
+	metrics, err := s.metrics.MetricsV1beta1().PodMetricses(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		clog.Warn("list pod metrics of cluster %v failed: %v", s.cluster, err)
+		return nil
+	}
+	for _, m := range metrics.Items {
+		for _, e := range owners[m.Namespace] {
+			for _, container := range m.Containers {
+				for name, quantity := range container.Usage {
+					rs, ok := usageResources[name]
+					if !ok {
+						continue
+					}
+					value := e.usage[rs]
+					value.Add(quantity)
+					e.usage[rs] = value
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func addResourceList(list, new v1.ResourceList) {
+	for name, quantity := range new {
+		value := list[name]
+		value.Add(quantity)
+		list[name] = value
+	}
+}
+
+func putValues(values map[string]float64, kind string, list v1.ResourceList) {
+	for rs, q := range list {
+		values[kind+"/"+string(rs)] = q.AsApproximateFloat64()
+	}
+}
@@ -150,6 +150,7 @@ func (o *QuotaOperator) UpdateParentStatus(flush bool) error {
 		if err != nil {
 			return err
 		}
+		quota.KeepRecordedStatus(refreshed, newQuota)
 		newQuota.Status = refreshed.Status
 		err = o.PivotClient.Status().Update(o.Context, newQuota)
 		if err != nil {
@@ -43,9 +43,9 @@ func (o *QuotaOperator) isExceedParent(parent *quotav1.CubeResourceQuota) (bool,
 		return true, fmt.Sprintf("can not get namespace of ResourceQuota(%v/%v)", o.CurrentQuota.Name, o.CurrentQuota.Namespace)
 	}
 
-	for _, rs := range quota.ResourceNames {
+	for _, rs := range quota.ResourceNamesOf(parent.Spec.Hard, current.Spec.Hard) {
 		pHard := parent.Spec.Hard
-		pUsed := parent.Status.Used
+		pUsed := quota.UsedOf(parent)
 		cHard := current.Spec.Hard
 
 		parentHard, ok := pHard[rs]
@@ -74,7 +74,7 @@ func (o *QuotaOperator) isExceedParent(parent *quotav1.CubeResourceQuota) (bool,
 		if !ok {
 			if allowedUnsetField {
 				clog.Warn("allowed ResourceQuota(%v/%v) unset field %v", current.Name, current.Namespace, rs)
-				return false, ""
+				continue
 			} else {
 				return true, fmt.Sprintf("less resource(%v) but parent quota had", rs)
 			}
@@ -85,6 +85,14 @@ func (o *QuotaOperator) isExceedParent(parent *quotav1.CubeResourceQuota) (bool,
 		changed := currentHard.DeepCopy()
 		changed.Sub(oldHard)
 
+		// elastic parent could grow beyond its hard by borrowing
+		if max, ok := quota.ElasticMax(parent, rs); ok && changed.Sign() > 0 {
+			if exceed, reason := o.isExceedElastic(parent, rs, parentUsed.DeepCopy(), changed, max); exceed {
+				return true, reason
+			}
+			continue
+		}
+
 		if isExceed(parentHard, parentUsed, changed) {
 			return true, fmt.Sprintf("overload, resource(%v), parent hard(%v), parent used(%v), changed(%v)", rs, parentHard.String(), parentUsed.String(), changed.String())
 		}
@@ -93,6 +101,67 @@ func (o *QuotaOperator) isExceedParent(parent *quotav1.CubeResourceQuota) (bool,
 	return false, ""
 }
 
+// isExceedElastic tells if growth of elastic parent exceeds the limit. Growth
+// within guaranteed hard is always allowed, and growth beyond hard is borrowed
+// from unused capacity of siblings under the same tenant quota.
+func (o *QuotaOperator) isExceedElastic(parent *quotav1.CubeResourceQuota, rs v1.ResourceName, parentUsed, changed, max resource.Quantity) (bool, string) {
+	parentHard := parent.Spec.Hard[rs]
+	newUsed := parentUsed.DeepCopy()
+	newUsed.Add(changed)
+	if newUsed.Cmp(parentHard) <= 0 {
+		return false, ""
+	}
+
+	if reclaiming, ok := parent.Status.Reclaiming[rs]; ok && reclaiming.Sign() > 0 {
+		return true, fmt.Sprintf("borrowed resource(%v) of quota %v is being reclaimed, reclaiming(%v)", rs, parent.Name, reclaiming.String())
+	}
+	if newUsed.Cmp(max) > 0 {
+		return true, fmt.Sprintf("overload, resource(%v), parent max(%v), parent used(%v), changed(%v)", rs, max.String(), parentUsed.String(), changed.String())
+	}
+
+	if len(parent.Spec.ParentQuota) == 0 {
+		return true, fmt.Sprintf("overload, resource(%v), parent hard(%v), parent used(%v), changed(%v)", rs, parentHard.String(), parentUsed.String(), changed.String())
+	}
+	tenant := &quotav1.CubeResourceQuota{}
+	if err := o.PivotClient.Get(o.Context, types.NamespacedName{Name: parent.Spec.ParentQuota}, tenant); err != nil {
+		return true, fmt.Sprintf("get quota %v to borrow from failed: %v", parent.Spec.ParentQuota, err)
+	}
+	siblings, err := o.siblingsOf(parent)
+	if err != nil {
+		return true, fmt.Sprintf("list siblings of quota %v failed: %v", parent.Name, err)
+	}
+
+	tenantHard, ok := tenant.Spec.Hard[rs]
+	if !ok {
+		return true, fmt.Sprintf("can not borrow a resource(%v) that quota %v not had", rs, tenant.Name)
+	}
+	siblingsUsed := quota.SumUsed(siblings...)[rs]
+	total := siblingsUsed.DeepCopy()
+	total.Add(newUsed)
+	if total.Cmp(tenantHard) > 0 {
+		return true, fmt.Sprintf("overload, resource(%v), not enough unused capacity to borrow, tenant hard(%v), siblings used(%v), parent used(%v), changed(%v)",
+			rs, tenantHard.String(), siblingsUsed.String(), parentUsed.String(), changed.String())
+	}
+
+	return false, ""
+}
+
+// siblingsOf returns quotas share the same parent quota with quota
+func (o *QuotaOperator) siblingsOf(q *quotav1.CubeResourceQuota) ([]*quotav1.CubeResourceQuota, error) {
+	list := &quotav1.CubeResourceQuotaList{}
+	if err := o.PivotClient.List(o.Context, list); err != nil {
+		return nil, err
+	}
+	var siblings []*quotav1.CubeResourceQuota
+	for i := range list.Items {
+		s := &list.Items[i]
+		if s.Spec.ParentQuota == q.Spec.ParentQuota && s.Name != q.Name && s.DeletionTimestamp == nil {
+			siblings = append(siblings, s)
+		}
+	}
+	return siblings, nil
+}
+
 func (o *QuotaOperator) allowQuotaUnsetField() (bool, error) {
 	labelKey := env.DetachedNamespaceLabelKey()
 	if labelKey == "" {
@@ -116,6 +185,7 @@ func (o *QuotaOperator) allowQuotaUnsetField() (bool, error) {
 
 func refreshUsedResource(current, old *v1.ResourceQuota, parent *quotav1.CubeResourceQuota, cli client.Client) (*quotav1.CubeResourceQuota, error) {
 	newParentUsed := quota.ClearQuotas(parent.Status.Used)
+	resourceNames := quota.ResourceNamesOf(newParentUsed)
 
 	for _, sub := range parent.Status.SubResourceQuotas {
 		subResourceQuota, name, ns, err := getResourceQuota(cli, sub)
@@ -146,7 +216,7 @@ func refreshUsedResource(current, old *v1.ResourceQuota, parent *quotav1.CubeRes
 
 		clog.Info("populate used of CubeResourceQuota %v with subResourceQuota %v", parent.Name, sub)
 
-		for _, rs := range quota.ResourceNames {
+		for _, rs := range resourceNames {
 			// continue if parent used quota had no that resource
 			newUsed, ok := newParentUsed[rs]
 			if !ok {
@@ -163,6 +233,9 @@ func refreshUsedResource(current, old *v1.ResourceQuota, parent *quotav1.CubeRes
 	}
 
 	parent.Status.Used = newParentUsed
+	if quota.IsElastic(parent) {
+		parent.Status.Borrowed = quota.Borrowed(parent)
+	}
 	clog.Info("refreshed sub resource quota of %v is %v", parent.Name, parent.Status.SubResourceQuotas)
 	clog.Debug("refreshed used of CubeResourceQuota %v is %v", parent.Name, newParentUsed)
 
@@ -0,0 +1,91 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+func cpuList(cpu string) v1.ResourceList {
+	return v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse(cpu)}
+}
+
+func TestIsExceedElastic(t *testing.T) {
+	tenant := &quotav1.CubeResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "tenant-1"},
+		Spec:       quotav1.CubeResourceQuotaSpec{Target: quotav1.TargetObj{Kind: quotav1.TenantObj}, Hard: cpuList("10")},
+	}
+	project1 := &quotav1.CubeResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "project-1"},
+		Spec: quotav1.CubeResourceQuotaSpec{
+			ParentQuota: "tenant-1",
+			Target:      quotav1.TargetObj{Kind: quotav1.ProjectObj},
+			Hard:        cpuList("4"),
+			Elastic:     &quotav1.ElasticQuota{Max: cpuList("8")},
+		},
+		Status: quotav1.CubeResourceQuotaStatus{Used: cpuList("4")},
+	}
+	project2 := &quotav1.CubeResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "project-2"},
+		Spec:       quotav1.CubeResourceQuotaSpec{ParentQuota: "tenant-1", Target: quotav1.TargetObj{Kind: quotav1.ProjectObj}, Hard: cpuList("6")},
+		Status:     quotav1.CubeResourceQuotaStatus{Used: cpuList("3")},
+	}
+	scheme := runtime.NewScheme()
+	quotav1.AddToScheme(scheme)
+	pivot := fake.NewClientBuilder().WithScheme(scheme).WithObjects(tenant, project1, project2).Build()
+
+	rq := func(cpu string) *v1.ResourceQuota {
+		return &v1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: "rq", Namespace: "ns", Labels: map[string]string{constants.CubeQuotaLabel: "project-1"}},
+			Spec:       v1.ResourceQuotaSpec{Hard: cpuList(cpu)},
+		}
+	}
+
+	tests := []struct {
+		name string
+		cpu  string
+		want bool
+	}{
+		{"borrow idle capacity of sibling", "3", false},
+		{"not enough idle capacity", "4", true},
+		{"beyond max", "5", true},
+	}
+	for _, tt := range tests {
+		o := &QuotaOperator{PivotClient: pivot, CurrentQuota: rq(tt.cpu), Context: context.Background()}
+		if overload, reason := o.isExceedParent(project1.DeepCopy()); overload != tt.want {
+			t.Errorf("%v: want overload %v, got %v %v", tt.name, tt.want, overload, reason)
+		}
+	}
+
+	// growth is denied while borrowed is being reclaimed
+	reclaiming := project1.DeepCopy()
+	reclaiming.Status.Reclaiming = cpuList("1")
+	o := &QuotaOperator{PivotClient: pivot, CurrentQuota: rq("1"), Context: context.Background()}
+	if overload, _ := o.isExceedParent(reclaiming); !overload {
+		t.Errorf("growth should be denied while reclaiming")
+	}
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quota
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+// PodRequestsAndLimits returns the requests and limits of pod as scheduler
+// computes them, overhead of pod included
+func PodRequestsAndLimits(pod *v1.Pod) (reqs, limits v1.ResourceList) {
+	reqs, limits = v1.ResourceList{}, v1.ResourceList{}
+	for _, container := range pod.Spec.Containers {
+		addResourceList(reqs, container.Resources.Requests)
+		addResourceList(limits, container.Resources.Limits)
+	}
+	// init containers define the minimum of any resource
+	for _, container := range pod.Spec.InitContainers {
+		maxResourceList(reqs, container.Resources.Requests)
+		maxResourceList(limits, container.Resources.Limits)
+	}
+
+	// Add overhead for running a pod to the sum of requests and to non-zero limits:
+	if pod.Spec.Overhead != nil {
+		addResourceList(reqs, pod.Spec.Overhead)
+
+		for name, quantity := range pod.Spec.Overhead {
+			if value, ok := limits[name]; ok && !value.IsZero() {
+				value.Add(quantity)
+				limits[name] = value
+			}
+		}
+	}
+	return
+}
+
+// PodUsage returns the usage of pod in names of quota resources, which is
+// charged against quota when pod is created
+func PodUsage(pod *v1.Pod) v1.ResourceList {
+	reqs, limits := PodRequestsAndLimits(pod)
+	usage := v1.ResourceList{v1.ResourcePods: resource.MustParse("1")}
+	for rs, quotaRs := range map[v1.ResourceName]v1.ResourceName{
+		v1.ResourceCPU:      v1.ResourceRequestsCPU,
+		v1.ResourceMemory:   v1.ResourceRequestsMemory,
+		constants.GpuNvidia: ResourceNvidiaGPU,
+	} {
+		if q, ok := reqs[rs]; ok {
+			usage[quotaRs] = q
+		}
+	}
+	for rs, quotaRs := range map[v1.ResourceName]v1.ResourceName{
+		v1.ResourceCPU:    v1.ResourceLimitsCPU,
+		v1.ResourceMemory: v1.ResourceLimitsMemory,
+	} {
+		if q, ok := limits[rs]; ok {
+			usage[quotaRs] = q
+		}
+	}
+	return usage
+}
+
+// ValidateDefaultRequests validates default requests setting of quota
+func ValidateDefaultRequests(q *quotav1.CubeResourceQuota) error {
+	for rs, value := range q.Spec.DefaultRequests {
+		if rs != v1.ResourceCPU && rs != v1.ResourceMemory {
+			return fmt.Errorf("only default requests of cpu and memory are supported, got %v", rs)
+		}
+		if value.Sign() < 0 {
+			return fmt.Errorf("default request of %v must not be negative", rs)
+		}
+	}
+	return nil
+}
+
+// ApplyDefaultRequests sets defaults to containers of pod which have no
+// requests nor limits of the resource, true is returned if pod is changed
+func ApplyDefaultRequests(pod *v1.Pod, defaults v1.ResourceList) bool {
+	changed := false
+	apply := func(containers []v1.Container) {
+		for i := range containers {
+			res := &containers[i].Resources
+			for rs, value := range defaults {
+				if _, ok := res.Requests[rs]; ok {
+					continue
+				}
+				// requests default to limits by apiserver
+				if _, ok := res.Limits[rs]; ok {
+					continue
+				}
+				if res.Requests == nil {
+					res.Requests = v1.ResourceList{}
+				}
+				res.Requests[rs] = value.DeepCopy()
+				changed = true
+			}
+		}
+	}
+	apply(pod.Spec.InitContainers)
+	apply(pod.Spec.Containers)
+	return changed
+}
+
+// addResourceList adds the resources in newList to list
+func addResourceList(list, new v1.ResourceList) {
+	for name, quantity := range new {
+		if value, ok := list[name]; !ok {
+			list[name] = quantity.DeepCopy()
+		} else {
+			value.Add(quantity)
+			list[name] = value
+		}
+	}
+}
+
+// maxResourceList sets list to the greater of list/newList for every resource
+// either list
+func maxResourceList(list, new v1.ResourceList) {
+	for name, quantity := range new {
+		if value, ok := list[name]; !ok {
+			list[name] = quantity.DeepCopy()
+			continue
+		} else {
+			if quantity.Cmp(value) > 0 {
+				list[name] = quantity.DeepCopy()
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quota
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
+)
+
+func TestApplyDefaultRequests(t *testing.T) {
+	pod := &v1.Pod{Spec: v1.PodSpec{Containers: []v1.Container{
+		{Name: "none"},
+		{Name: "cpu", Resources: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("2")}}},
+		{Name: "limits", Resources: v1.ResourceRequirements{Limits: v1.ResourceList{v1.ResourceMemory: resource.MustParse("1Gi")}}},
+	}}}
+	defaults := v1.ResourceList{v1.ResourceCPU: resource.MustParse("100m"), v1.ResourceMemory: resource.MustParse("128Mi")}
+	if !ApplyDefaultRequests(pod, defaults) {
+		t.Fatalf("pod should be changed")
+	}
+	usage := PodUsage(pod)
+	want := v1.ResourceList{
+		v1.ResourcePods:           resource.MustParse("1"),
+		v1.ResourceRequestsCPU:    resource.MustParse("2200m"),
+		v1.ResourceRequestsMemory: resource.MustParse("256Mi"),
+		v1.ResourceLimitsMemory:   resource.MustParse("1Gi"),
+	}
+	if len(usage) != len(want) {
+		t.Fatalf("want usage %v, got %v", want, usage)
+	}
+	for rs, q := range want {
+		if got := usage[rs]; got.Cmp(q) != 0 {
+			t.Errorf("want %v of %v, got %v", rs, q.String(), got.String())
+		}
+	}
+	if ApplyDefaultRequests(pod, defaults) {
+		t.Errorf("defaults should be applied only once")
+	}
+}
+
+func TestValidateDefaultRequests(t *testing.T) {
+	q := &quotav1.CubeResourceQuota{}
+	q.Spec.DefaultRequests = v1.ResourceList{v1.ResourceCPU: resource.MustParse("100m")}
+	if err := ValidateDefaultRequests(q); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	q.Spec.DefaultRequests[v1.ResourceRequestsCPU] = resource.MustParse("1")
+	if err := ValidateDefaultRequests(q); err == nil {
+		t.Errorf("want error of unsupported resource")
+	}
+	q.Spec.DefaultRequests = v1.ResourceList{v1.ResourceMemory: resource.MustParse("-1")}
+	if err := ValidateDefaultRequests(q); err == nil {
+		t.Errorf("want error of negative default")
+	}
+}
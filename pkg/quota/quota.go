@@ -17,6 +17,8 @@ limitations under the License.
 package quota
 
 import (
+	v1 "k8s.io/api/core/v1"
+
 	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
 )
 
@@ -34,4 +36,42 @@ type Interface interface {
 	UpdateParentStatus(flush bool) error
 }
 
+// KeepRecordedStatus keeps status recorded by other controllers and apis,
+// such as threshold levels, history, charged, borrowed and reclaiming, when
+// status of latest quota is overwritten by the one computed from stale quota.
+// Borrowed of elastic quota follows the used computed.
+func KeepRecordedStatus(stale, latest *quotav1.CubeResourceQuota) {
+	stale.Status.ThresholdLevels = latest.Status.ThresholdLevels
+	stale.Status.History = latest.Status.History
+	stale.Status.Charged = latest.Status.Charged
+	stale.Status.Reclaiming = latest.Status.Reclaiming
+	if IsElastic(stale) {
+		stale.Status.Borrowed = Borrowed(stale)
+	} else {
+		stale.Status.Borrowed = latest.Status.Borrowed
+	}
+}
+
+// UsedOf returns used of quota including usage of pods charged against
+// it directly, that is what the quota really consumed
+func UsedOf(q *quotav1.CubeResourceQuota) v1.ResourceList {
+	if len(q.Status.Charged) == 0 {
+		return q.Status.Used
+	}
+	used := v1.ResourceList{}
+	addResourceList(used, q.Status.Used)
+	addResourceList(used, q.Status.Charged)
+	return used
+}
+
+// AppendHistory appends record to history and keeps the latest
+// MaxQuotaHistory records only
+func AppendHistory(history []quotav1.QuotaChangeRecord, record quotav1.QuotaChangeRecord) []quotav1.QuotaChangeRecord {
+	history = append(history, record)
+	if len(history) > quotav1.MaxQuotaHistory {
+		history = history[len(history)-quotav1.MaxQuotaHistory:]
+	}
+	return history
+}
+
 // todo: to make cube resource quota and resource quota to one interface
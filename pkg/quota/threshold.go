@@ -0,0 +1,107 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quota
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+
+	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
+)
+
+const (
+	DefaultWarningPercent  int32 = 80
+	DefaultCriticalPercent int32 = 95
+)
+
+// ThresholdsOf returns warning and critical percentage of quota with defaults
+func ThresholdsOf(q *quotav1.CubeResourceQuota) (warning, critical int32) {
+	warning, critical = DefaultWarningPercent, DefaultCriticalPercent
+	if q.Spec.Thresholds == nil {
+		return
+	}
+	if q.Spec.Thresholds.Warning > 0 {
+		warning = q.Spec.Thresholds.Warning
+	}
+	if q.Spec.Thresholds.Critical > 0 {
+		critical = q.Spec.Thresholds.Critical
+	}
+	return
+}
+
+// ValidateThresholds validates thresholds setting of quota
+func ValidateThresholds(q *quotav1.CubeResourceQuota) error {
+	if q.Spec.Thresholds == nil {
+		return nil
+	}
+	if q.Spec.Thresholds.Warning < 0 || q.Spec.Thresholds.Critical < 0 {
+		return fmt.Errorf("thresholds must not be negative")
+	}
+	warning, critical := ThresholdsOf(q)
+	if warning >= critical {
+		return fmt.Errorf("warning threshold %v%% must be less than critical threshold %v%%", warning, critical)
+	}
+	if critical > 100 {
+		return fmt.Errorf("critical threshold %v%% must not be greater than 100%%", critical)
+	}
+	return nil
+}
+
+// Utilisation returns used of resource in percentage of status hard, false
+// if resource is not limited
+func Utilisation(q *quotav1.CubeResourceQuota, rs v1.ResourceName) (float64, bool) {
+	hard, ok := q.Status.Hard[rs]
+	if !ok || hard.Sign() <= 0 {
+		return 0, false
+	}
+	used := UsedOf(q)[rs]
+	return used.AsApproximateFloat64() * 100 / hard.AsApproximateFloat64(), true
+}
+
+// ThresholdLevels computes the level of resources whose utilisation crossed
+// thresholds, it is nil if thresholds of quota are not set
+func ThresholdLevels(q *quotav1.CubeResourceQuota) map[v1.ResourceName]quotav1.ThresholdLevel {
+	if q.Spec.Thresholds == nil {
+		return nil
+	}
+	warning, critical := ThresholdsOf(q)
+	levels := make(map[v1.ResourceName]quotav1.ThresholdLevel)
+	for rs := range q.Status.Hard {
+		percent, ok := Utilisation(q, rs)
+		switch {
+		case !ok:
+		case percent >= float64(critical):
+			levels[rs] = quotav1.ThresholdCritical
+		case percent >= float64(warning):
+			levels[rs] = quotav1.ThresholdWarning
+		}
+	}
+	return levels
+}
+
+// ThresholdLevelOf returns the highest level among resources of quota
+func ThresholdLevelOf(q *quotav1.CubeResourceQuota) quotav1.ThresholdLevel {
+	level := quotav1.ThresholdNormal
+	for _, l := range q.Status.ThresholdLevels {
+		if l == quotav1.ThresholdCritical {
+			return l
+		}
+		level = l
+	}
+	return level
+}
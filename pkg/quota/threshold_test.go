@@ -0,0 +1,85 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quota
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
+)
+
+func TestThresholdLevels(t *testing.T) {
+	q := &quotav1.CubeResourceQuota{
+		Status: quotav1.CubeResourceQuotaStatus{
+			Hard: v1.ResourceList{
+				v1.ResourceRequestsCPU:    resource.MustParse("10"),
+				v1.ResourceRequestsMemory: resource.MustParse("10Gi"),
+				v1.ResourceLimitsCPU:      resource.MustParse("10"),
+				v1.ResourceLimitsMemory:   resource.MustParse("0"),
+			},
+			Used: v1.ResourceList{
+				v1.ResourceRequestsCPU:    resource.MustParse("8"),
+				v1.ResourceRequestsMemory: resource.MustParse("9728Mi"),
+				v1.ResourceLimitsCPU:      resource.MustParse("7900m"),
+				v1.ResourceLimitsMemory:   resource.MustParse("0"),
+			},
+		},
+	}
+	if levels := ThresholdLevels(q); levels != nil {
+		t.Fatalf("levels should be nil without thresholds, got %v", levels)
+	}
+
+	q.Spec.Thresholds = &quotav1.QuotaThresholds{}
+	levels := ThresholdLevels(q)
+	if len(levels) != 2 || levels[v1.ResourceRequestsCPU] != quotav1.ThresholdWarning || levels[v1.ResourceRequestsMemory] != quotav1.ThresholdCritical {
+		t.Fatalf("unexpected levels with default thresholds: %v", levels)
+	}
+
+	q.Spec.Thresholds = &quotav1.QuotaThresholds{Warning: 70, Critical: 80}
+	levels = ThresholdLevels(q)
+	if len(levels) != 3 || levels[v1.ResourceRequestsCPU] != quotav1.ThresholdCritical || levels[v1.ResourceLimitsCPU] != quotav1.ThresholdWarning {
+		t.Fatalf("unexpected levels with custom thresholds: %v", levels)
+	}
+
+	q.Status.ThresholdLevels = levels
+	if level := ThresholdLevelOf(q); level != quotav1.ThresholdCritical {
+		t.Errorf("want critical level, got %v", level)
+	}
+}
+
+func TestValidateThresholds(t *testing.T) {
+	tests := []struct {
+		thresholds *quotav1.QuotaThresholds
+		valid      bool
+	}{
+		{nil, true},
+		{&quotav1.QuotaThresholds{}, true},
+		{&quotav1.QuotaThresholds{Warning: 50, Critical: 100}, true},
+		{&quotav1.QuotaThresholds{Warning: 96}, false},
+		{&quotav1.QuotaThresholds{Warning: 80, Critical: 120}, false},
+		{&quotav1.QuotaThresholds{Warning: -1}, false},
+	}
+	for _, tt := range tests {
+		q := &quotav1.CubeResourceQuota{Spec: quotav1.CubeResourceQuotaSpec{Thresholds: tt.thresholds}}
+		if err := ValidateThresholds(q); (err == nil) != tt.valid {
+			t.Errorf("%+v: want valid %v, got %v", tt.thresholds, tt.valid, err)
+		}
+	}
+}
@@ -17,6 +17,9 @@ limitations under the License.
 package quota
 
 import (
+	"sort"
+	"strings"
+
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 )
@@ -46,15 +49,82 @@ var ResourceNames = []v1.ResourceName{
 
 	// counts
 	v1.ResourcePods,
+	v1.ResourceConfigMaps,
+	v1.ResourceSecrets,
+	v1.ResourcePersistentVolumeClaims,
+	v1.ResourceServices,
+	v1.ResourceServicesLoadBalancers,
 	// todo: support resource quota bellow in the future
-	//v1.ResourceConfigMaps,
-	//v1.ResourceSecrets,
 	//v1.ResourceReplicationControllers,
 	//v1.ResourceQuotas,
-	//v1.ResourcePersistentVolumeClaims,
-	//v1.ResourceServices,
 	//v1.ResourceServicesNodePorts,
-	//v1.ResourceServicesLoadBalancers,
+}
+
+// storageClassSuffix is the suffix of storage class resources,
+// such as gold.storageclass.storage.k8s.io/requests.storage
+const storageClassSuffix = ".storageclass.storage.k8s.io/"
+
+// IsStorageClassResource tells if resource is storage requests or pvc
+// count of a specific storage class
+func IsStorageClassResource(rs v1.ResourceName) bool {
+	i := strings.Index(string(rs), storageClassSuffix)
+	if i <= 0 {
+		return false
+	}
+	name := v1.ResourceName(string(rs)[i+len(storageClassSuffix):])
+	return name == v1.ResourceRequestsStorage || name == v1.ResourcePersistentVolumeClaims
+}
+
+// IsGPUResource tells if resource is requests of a gpu extended resource,
+// such as requests.nvidia.com/gpu or requests.nvidia.com/mig-1g.5gb
+func IsGPUResource(rs v1.ResourceName) bool {
+	name := string(rs)
+	if !strings.HasPrefix(name, v1.DefaultResourceRequestsPrefix) {
+		return false
+	}
+	name = strings.TrimPrefix(name, v1.DefaultResourceRequestsPrefix)
+	i := strings.Index(name, "/")
+	if i <= 0 {
+		return false
+	}
+	return strings.Contains(name[:i], "nvidia.com") || strings.Contains(name[i+1:], "gpu")
+}
+
+// IsSupported tells if resource is limited by quota hierarchy
+func IsSupported(rs v1.ResourceName) bool {
+	return isBuiltin(rs) || IsStorageClassResource(rs) || IsGPUResource(rs)
+}
+
+func isBuiltin(rs v1.ResourceName) bool {
+	for _, name := range ResourceNames {
+		if name == rs {
+			return true
+		}
+	}
+	return false
+}
+
+// ResourceNamesOf returns ResourceNames and the storage class and gpu
+// resources appeared in given resource lists
+func ResourceNamesOf(lists ...v1.ResourceList) []v1.ResourceName {
+	names := make([]v1.ResourceName, len(ResourceNames))
+	copy(names, ResourceNames)
+
+	var extended []v1.ResourceName
+	seen := make(map[v1.ResourceName]bool)
+	for _, l := range lists {
+		for rs := range l {
+			if seen[rs] || isBuiltin(rs) || !IsSupported(rs) {
+				continue
+			}
+			seen[rs] = true
+			extended = append(extended, rs)
+		}
+	}
+	// keep the order stable so that reason of overload is deterministic
+	sort.Slice(extended, func(i, j int) bool { return extended[i] < extended[j] })
+
+	return append(names, extended...)
 }
 
 // ZeroQ give the value of zero
@@ -70,3 +140,18 @@ func ClearQuotas(l v1.ResourceList) v1.ResourceList {
 
 	return l
 }
+
+// ResourceListEqual tells if two resource lists have the same resources
+// with equal quantities
+func ResourceListEqual(a, b v1.ResourceList) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for rs, qa := range a {
+		qb, ok := b[rs]
+		if !ok || qa.Cmp(qb) != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quota
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestIsSupported(t *testing.T) {
+	tests := []struct {
+		rs   v1.ResourceName
+		want bool
+	}{
+		{v1.ResourceServicesLoadBalancers, true},
+		{v1.ResourcePersistentVolumeClaims, true},
+		{"gold.storageclass.storage.k8s.io/requests.storage", true},
+		{"gold.storageclass.storage.k8s.io/persistentvolumeclaims", true},
+		{".storageclass.storage.k8s.io/requests.storage", false},
+		{"gold.storageclass.storage.k8s.io/limits.storage", false},
+		{"requests.nvidia.com/mig-1g.5gb", true},
+		{"requests.amd.com/gpu", true},
+		{"requests.example.com/foo", false},
+		{"count/deployments.apps", false},
+	}
+	for _, tt := range tests {
+		if got := IsSupported(tt.rs); got != tt.want {
+			t.Errorf("%v: want %v, got %v", tt.rs, tt.want, got)
+		}
+	}
+}
+
+func TestResourceNamesOf(t *testing.T) {
+	names := ResourceNamesOf(v1.ResourceList{
+		"silver.storageclass.storage.k8s.io/requests.storage": resource.MustParse("10Gi"),
+		ResourceNvidiaGPU:        resource.MustParse("1"),
+		"count/deployments.apps": resource.MustParse("1"),
+	}, v1.ResourceList{
+		"gold.storageclass.storage.k8s.io/requests.storage":   resource.MustParse("10Gi"),
+		"silver.storageclass.storage.k8s.io/requests.storage": resource.MustParse("10Gi"),
+	})
+
+	if len(names) != len(ResourceNames)+2 {
+		t.Fatalf("want %v resource names, got %v", len(ResourceNames)+2, names)
+	}
+	extended := names[len(ResourceNames):]
+	if extended[0] != "gold.storageclass.storage.k8s.io/requests.storage" || extended[1] != "silver.storageclass.storage.k8s.io/requests.storage" {
+		t.Errorf("extended resource names should be sorted, got %v", extended)
+	}
+}
@@ -0,0 +1,325 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package tsdb is a small embedded time-series store keeps samples in
+// local files, one file of json lines per day. It is meant for low
+// frequency samples such as those of quotas but not for metrics.
+package tsdb
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	segmentLayout = "2006-01-02"
+	segmentSuffix = ".jsonl"
+
+	day = 24 * time.Hour
+)
+
+// Sample is the values of series identified by labels at time
+type Sample struct {
+	Time   time.Time          `json:"time"`
+	Labels map[string]string  `json:"labels"`
+	Values map[string]float64 `json:"values"`
+}
+
+// Matcher matches samples whose labels equal to all labels of matcher,
+// empty matcher matches all samples
+type Matcher map[string]string
+
+func (m Matcher) Matches(labels map[string]string) bool {
+	for k, v := range m {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+type Options struct {
+	// Dir is the directory samples are stored in
+	Dir string
+	// Retention is how long samples are kept, forever if zero
+	Retention time.Duration
+	// DownsampleAfter is the age of samples downsampled to DownsampleStep
+	// by compaction, samples are never downsampled if either is zero
+	DownsampleAfter time.Duration
+	DownsampleStep  time.Duration
+}
+
+// Store is safe for concurrent use in process, but the directory must not
+// be shared by processes.
+type Store struct {
+	opts Options
+	lock sync.RWMutex
+}
+
+// Open opens store in directory, the directory is created if not exists
+func Open(opts Options) (*Store, error) {
+	if len(opts.Dir) == 0 {
+		return nil, fmt.Errorf("directory of store is empty")
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Store{opts: opts}, nil
+}
+
+// Append appends samples into segments of their day
+func (s *Store) Append(samples ...Sample) error {
+	segments := make(map[string][]Sample)
+	for _, sample := range samples {
+		name := segmentOf(sample.Time)
+		segments[name] = append(segments[name], sample)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for name, samples := range segments {
+		if err := s.appendSegment(name, samples); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) appendSegment(name string, samples []Sample) error {
+	f, err := os.OpenFile(filepath.Join(s.opts.Dir, name), os.O_CREATE|os.O_APPEND|os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	// terminate line partially written by crash, so the samples appended
+	// are not merged into it
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err = f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			w.WriteByte('\n')
+		}
+	}
+	enc := json.NewEncoder(w)
+	for i := range samples {
+		if err = enc.Encode(&samples[i]); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err = w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Query returns samples in [start, end) matched by matcher, sorted by time
+func (s *Store) Query(start, end time.Time, matcher Matcher) ([]Sample, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	names, err := s.segments()
+	if err != nil {
+		return nil, err
+	}
+	var result []Sample
+	for _, name := range names {
+		begin, _ := time.Parse(segmentLayout, strings.TrimSuffix(name, segmentSuffix))
+		if !begin.Before(end) || !begin.Add(day).After(start) {
+			continue
+		}
+		samples, err := s.readSegment(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, sample := range samples {
+			if sample.Time.Before(start) || !sample.Time.Before(end) || !matcher.Matches(sample.Labels) {
+				continue
+			}
+			result = append(result, sample)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Time.Before(result[j].Time)
+	})
+	return result, nil
+}
+
+// Compact removes segments out of retention and downsamples segments
+// older than DownsampleAfter
+func (s *Store) Compact(now time.Time) error {
+	retain := s.opts.Retention > 0
+	downsample := s.opts.DownsampleAfter > 0 && s.opts.DownsampleStep > 0
+	if !retain && !downsample {
+		return nil
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	names, err := s.segments()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		begin, _ := time.Parse(segmentLayout, strings.TrimSuffix(name, segmentSuffix))
+		end := begin.Add(day)
+		switch {
+		case retain && !end.After(now.Add(-s.opts.Retention)):
+			err = os.Remove(filepath.Join(s.opts.Dir, name))
+		case downsample && !end.After(now.Add(-s.opts.DownsampleAfter)):
+			err = s.downsampleSegment(name, begin)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// downsampleSegment rewrites segment with samples downsampled, segments
+// downsampled already are kept as they are
+func (s *Store) downsampleSegment(name string, begin time.Time) error {
+	samples, err := s.readSegment(name)
+	if err != nil {
+		return err
+	}
+	downsampled := Downsample(samples, begin, s.opts.DownsampleStep)
+	if len(downsampled) == len(samples) {
+		return nil
+	}
+	tmp := name + ".tmp"
+	_ = os.Remove(filepath.Join(s.opts.Dir, tmp))
+	if err = s.appendSegment(tmp, downsampled); err != nil {
+		return err
+	}
+	return os.Rename(filepath.Join(s.opts.Dir, tmp), filepath.Join(s.opts.Dir, name))
+}
+
+// Downsample averages values of each series identified by labels in steps
+// aligned to start, the time of downsampled sample is the beginning of its
+// step. A value is averaged over the samples having it. Samples returned
+// are sorted by time.
+func Downsample(samples []Sample, start time.Time, step time.Duration) []Sample {
+	type bucket struct {
+		sample Sample
+		counts map[string]int
+	}
+	var buckets []*bucket
+	index := make(map[string]*bucket)
+	for _, sample := range samples {
+		n := sample.Time.Sub(start) / step
+		if sample.Time.Before(start.Add(n * step)) {
+			n--
+		}
+		at := start.Add(n * step)
+		key := at.String() + "|" + seriesKey(sample.Labels)
+		b, ok := index[key]
+		if !ok {
+			b = &bucket{
+				sample: Sample{Time: at, Labels: sample.Labels, Values: make(map[string]float64)},
+				counts: make(map[string]int),
+			}
+			index[key] = b
+			buckets = append(buckets, b)
+		}
+		for k, v := range sample.Values {
+			b.sample.Values[k] += v
+			b.counts[k]++
+		}
+	}
+
+	result := make([]Sample, 0, len(buckets))
+	for _, b := range buckets {
+		for k, count := range b.counts {
+			b.sample.Values[k] /= float64(count)
+		}
+		result = append(result, b.sample)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Time.Before(result[j].Time)
+	})
+	return result
+}
+
+// seriesKey identifies series by labels in stable order
+func seriesKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	b := &strings.Builder{}
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(labels[k])
+		b.WriteByte(',')
+	}
+	return b.String()
+}
+
+// segments returns names of segment files sorted by day
+func (s *Store) segments() ([]string, error) {
+	entries, err := os.ReadDir(s.opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		if _, err := time.Parse(segmentLayout, strings.TrimSuffix(name, segmentSuffix)); err != nil {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *Store) readSegment(name string) ([]Sample, error) {
+	f, err := os.Open(filepath.Join(s.opts.Dir, name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var samples []Sample
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		sample := Sample{}
+		if err = json.Unmarshal(scanner.Bytes(), &sample); err != nil {
+			// skip line partially written by crash
+			continue
+		}
+		samples = append(samples, sample)
+	}
+	return samples, scanner.Err()
+}
+
+func segmentOf(t time.Time) string {
+	return t.UTC().Format(segmentLayout) + segmentSuffix
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tsdb
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(Options{Dir: dir, Retention: 48 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := time.Date(2023, 3, 1, 23, 0, 0, 0, time.UTC)
+	var samples []Sample
+	for i := 0; i < 4; i++ {
+		for _, project := range []string{"p1", "p2"} {
+			samples = append(samples, Sample{
+				Time:   base.Add(time.Duration(i) * time.Hour),
+				Labels: map[string]string{"tenant": "t1", "project": project},
+				Values: map[string]float64{"cpu": float64(i)},
+			})
+		}
+	}
+	if err = s.Append(samples...); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.Query(base, base.Add(3*time.Hour), Matcher{"project": "p1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("want 3 samples, got %v", len(got))
+	}
+	for i, sample := range got {
+		if sample.Labels["project"] != "p1" || sample.Values["cpu"] != float64(i) || !sample.Time.Equal(base.Add(time.Duration(i)*time.Hour)) {
+			t.Errorf("unexpected sample %v: %+v", i, sample)
+		}
+	}
+
+	got, _ = s.Query(base.Add(time.Hour), base.Add(24*time.Hour), nil)
+	if len(got) != 6 {
+		t.Fatalf("want 6 samples of all projects, got %v", len(got))
+	}
+
+	// partially written line is ignored
+	f, err := os.OpenFile(filepath.Join(dir, "2023-03-02.jsonl"), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"time":"2023-03-02T05:00:00Z","lab`)
+	f.Close()
+	if err = s.Append(Sample{Time: base.Add(6 * time.Hour), Labels: map[string]string{"project": "p1"}}); err != nil {
+		t.Fatal(err)
+	}
+	got, err = s.Query(base, base.Add(24*time.Hour), nil)
+	if err != nil || len(got) != 9 {
+		t.Fatalf("want 9 samples, got %v: %v", len(got), err)
+	}
+
+	if err = s.Compact(base.Add(49 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = s.Query(base, base.Add(24*time.Hour), nil)
+	if len(got) != 7 {
+		t.Fatalf("want 7 samples after segment of first day removed, got %v", len(got))
+	}
+}
+
+func TestDownsample(t *testing.T) {
+	s, err := Open(Options{Dir: t.TempDir(), DownsampleAfter: 24 * time.Hour, DownsampleStep: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+	var samples []Sample
+	for i := 0; i < 6; i++ {
+		values := map[string]float64{"cpu": float64(i)}
+		if i%2 == 0 {
+			values["gpu"] = 1
+		}
+		samples = append(samples,
+			Sample{Time: base.Add(time.Duration(i) * 20 * time.Minute), Labels: map[string]string{"project": "p1"}, Values: values},
+			Sample{Time: base.Add(time.Duration(i) * 20 * time.Minute), Labels: map[string]string{"project": "p2"}, Values: map[string]float64{"cpu": 1}},
+		)
+	}
+	if err = s.Append(samples...); err != nil {
+		t.Fatal(err)
+	}
+
+	// segment of today is not downsampled
+	if err = s.Compact(base.Add(12 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.Query(base, base.Add(day), nil); len(got) != 12 {
+		t.Fatalf("want 12 samples before downsampled, got %v", len(got))
+	}
+
+	for i := 0; i < 2; i++ {
+		if err = s.Compact(base.Add(48 * time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		got, _ := s.Query(base, base.Add(day), Matcher{"project": "p1"})
+		if len(got) != 2 {
+			t.Fatalf("want 2 samples of p1 downsampled, got %v", len(got))
+		}
+		if !got[1].Time.Equal(base.Add(time.Hour)) || got[1].Values["cpu"] != 4 || got[1].Values["gpu"] != 1 {
+			t.Errorf("unexpected downsampled sample: %+v", got[1])
+		}
+	}
+}
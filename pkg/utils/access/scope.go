@@ -0,0 +1,115 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package access
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+// ScopeFromContext returns the scope of token carried by request, nil if no limit.
+func ScopeFromContext(c *gin.Context) *userv1.KeyScope {
+	v, ok := c.Get(constants.TokenScope)
+	if !ok {
+		return nil
+	}
+	scope, _ := v.(*userv1.KeyScope)
+	return scope
+}
+
+// AllowScope tells if the request with http method to namespace of cluster
+// is within the scope, empty namespace means cluster scoped request.
+func AllowScope(ctx context.Context, scope *userv1.KeyScope, cli client.Reader, cluster, namespace, method string) (bool, error) {
+	if scope == nil {
+		return true, nil
+	}
+
+	if scope.ReadOnly && !isReadMethod(method) {
+		return false, nil
+	}
+
+	if len(scope.Clusters) > 0 && !sets.NewString(scope.Clusters...).Has(cluster) {
+		return false, nil
+	}
+
+	if len(scope.Tenants) == 0 && len(scope.Projects) == 0 {
+		return true, nil
+	}
+
+	// cluster scoped resources are not belong to any tenant or project
+	if len(namespace) == 0 {
+		return false, nil
+	}
+
+	ns := &corev1.Namespace{}
+	err := cli.Get(ctx, types.NamespacedName{Name: namespace}, ns)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	tenant, project := ns.Labels[constants.HncTenantLabel], ns.Labels[constants.HncProjectLabel]
+	if strings.HasPrefix(ns.Name, constants.TenantNsPrefix) {
+		tenant = strings.TrimPrefix(ns.Name, constants.TenantNsPrefix)
+	}
+	if strings.HasPrefix(ns.Name, constants.ProjectNsPrefix) {
+		project = strings.TrimPrefix(ns.Name, constants.ProjectNsPrefix)
+	}
+
+	if len(scope.Tenants) > 0 && !sets.NewString(scope.Tenants...).Has(tenant) {
+		return false, nil
+	}
+	if len(scope.Projects) > 0 && !sets.NewString(scope.Projects...).Has(project) {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// NamespaceOfURL returns the namespace which the k8s api url refers to,
+// like /api/v1/namespaces/{namespace}/pods or /api/v1/namespaces/{namespace}.
+func NamespaceOfURL(url string) string {
+	ss := strings.Split(strings.Trim(url, "/"), "/")
+	for i := 0; i < len(ss)-1; i++ {
+		if ss[i] == constants.ResourceNamespaces {
+			return ss[i+1]
+		}
+	}
+	return ""
+}
+
+func isReadMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
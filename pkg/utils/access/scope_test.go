@@ -0,0 +1,92 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package access
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+func TestAllowScope(t *testing.T) {
+	scheme := runtime.NewScheme()
+	corev1.AddToScheme(scheme)
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-1", Labels: map[string]string{
+			constants.HncTenantLabel:  "tenant-1",
+			constants.HncProjectLabel: "project-1",
+		}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-2", Labels: map[string]string{
+			constants.HncTenantLabel:  "tenant-2",
+			constants.HncProjectLabel: "project-2",
+		}}},
+	).Build()
+
+	tests := []struct {
+		name      string
+		scope     *userv1.KeyScope
+		cluster   string
+		namespace string
+		method    string
+		want      bool
+	}{
+		{"no limit", nil, "c1", "", http.MethodDelete, true},
+		{"read only allow get", &userv1.KeyScope{ReadOnly: true}, "c1", "ns-1", http.MethodGet, true},
+		{"read only deny post", &userv1.KeyScope{ReadOnly: true}, "c1", "ns-1", http.MethodPost, false},
+		{"cluster allowed", &userv1.KeyScope{Clusters: []string{"c1"}}, "c1", "", http.MethodGet, true},
+		{"cluster denied", &userv1.KeyScope{Clusters: []string{"c1"}}, "c2", "", http.MethodGet, false},
+		{"tenant allowed", &userv1.KeyScope{Tenants: []string{"tenant-1"}}, "c1", "ns-1", http.MethodGet, true},
+		{"tenant denied", &userv1.KeyScope{Tenants: []string{"tenant-1"}}, "c1", "ns-2", http.MethodGet, false},
+		{"tenant deny cluster scoped", &userv1.KeyScope{Tenants: []string{"tenant-1"}}, "c1", "", http.MethodGet, false},
+		{"project allowed", &userv1.KeyScope{Projects: []string{"project-2"}}, "c1", "ns-2", http.MethodGet, true},
+		{"project and tenant", &userv1.KeyScope{Tenants: []string{"tenant-1"}, Projects: []string{"project-2"}}, "c1", "ns-2", http.MethodGet, false},
+		{"namespace not found", &userv1.KeyScope{Projects: []string{"project-2"}}, "c1", "ns-3", http.MethodGet, false},
+	}
+
+	for _, tt := range tests {
+		got, err := AllowScope(context.Background(), tt.scope, cli, tt.cluster, tt.namespace, tt.method)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%v: want %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestNamespaceOfURL(t *testing.T) {
+	tests := map[string]string{
+		"/api/v1/namespaces/ns-1/pods":                    "ns-1",
+		"/api/v1/namespaces/ns-1":                         "ns-1",
+		"/apis/apps/v1/namespaces/ns-1/deployments/app-1": "ns-1",
+		"/api/v1/nodes":                                   "",
+		"/api/v1/namespaces":                              "",
+	}
+	for url, want := range tests {
+		if got := NamespaceOfURL(url); got != want {
+			t.Errorf("url %v: want %v, got %v", url, want, got)
+		}
+	}
+}
@@ -57,10 +57,44 @@ func SetAuditInfo(c *gin.Context, eventInfo *EventInfo, resourceName string, Req
 var (
 	CreateUser       = &EventInfo{"createUser", "createUser", "user"}
 	UpdateUser       = &EventInfo{"updateUser", "updateUser", "user"}
+	Logout           = &EventInfo{"logout", "logout", "session"}
+	RevokeSession    = &EventInfo{"revokeSession", "revokeSession", "session"}
+	StartImpersonate = &EventInfo{"startImpersonate", "startImpersonate", "session"}
+	EndImpersonate   = &EventInfo{"endImpersonate", "endImpersonate", "session"}
 	DeleteKey        = &EventInfo{"deleteKey", "deleteKey", "key"}
 	CreateKey        = &EventInfo{"createKey", "createKey", "key"}
+	CreateRobot      = &EventInfo{"createRobot", "createRobot", "robot"}
+	UpdateRobot      = &EventInfo{"updateRobot", "updateRobot", "robot"}
+	DeleteRobot      = &EventInfo{"deleteRobot", "deleteRobot", "robot"}
+	CreateRobotKey   = &EventInfo{"createRobotKey", "createRobotKey", "key"}
+	DeleteRobotKey   = &EventInfo{"deleteRobotKey", "deleteRobotKey", "key"}
+	CreateGroup      = &EventInfo{"createGroup", "createGroup", "group"}
+	UpdateGroup      = &EventInfo{"updateGroup", "updateGroup", "group"}
+	DeleteGroup      = &EventInfo{"deleteGroup", "deleteGroup", "group"}
+	SyncLdap         = &EventInfo{"syncLdap", "syncLdap", "user"}
+	ResetPassword    = &EventInfo{"resetPassword", "resetPassword", "user"}
+	GrantBinding     = &EventInfo{"grantBinding", "grantBinding", "binding"}
+	ExpireBinding    = &EventInfo{"expireBinding", "expireBinding", "binding"}
+
+	CreateAccessRequest  = &EventInfo{"createAccessRequest", "createAccessRequest", "accessrequest"}
+	ApproveAccessRequest = &EventInfo{"approveAccessRequest", "approveAccessRequest", "accessrequest"}
+	RejectAccessRequest  = &EventInfo{"rejectAccessRequest", "rejectAccessRequest", "accessrequest"}
+	CancelAccessRequest  = &EventInfo{"cancelAccessRequest", "cancelAccessRequest", "accessrequest"}
+	CommentAccessRequest = &EventInfo{"commentAccessRequest", "commentAccessRequest", "accessrequest"}
+
+	UpdateAuthItems   = &EventInfo{"updateAuthItems", "updateAuthItems", "clusterrole"}
+	RollbackAuthItems = &EventInfo{"rollbackAuthItems", "rollbackAuthItems", "clusterrole"}
+
 	CreateConfigMap  = &EventInfo{"createConfigMap", "createConfigMap", "configmap"}
 	DeleteConfigMap  = &EventInfo{"deleteConfigMap", "deleteConfigMap", "configmap"}
 	UpdateConfigMap  = &EventInfo{"updateConfigMap", "updateConfigMap", "configmap"}
 	RolloutConfigMap = &EventInfo{"rolloutConfigMap", "rolloutConfigMap", "configmap"}
+
+	RecalculateQuota    = &EventInfo{"recalculateQuota", "recalculateQuota", "cuberesourcequota"}
+	CrossQuotaThreshold = &EventInfo{"crossQuotaThreshold", "crossQuotaThreshold", "cuberesourcequota"}
+
+	CreateQuotaRequest  = &EventInfo{"createQuotaRequest", "createQuotaRequest", "quotarequest"}
+	ApproveQuotaRequest = &EventInfo{"approveQuotaRequest", "approveQuotaRequest", "quotarequest"}
+	RejectQuotaRequest  = &EventInfo{"rejectQuotaRequest", "rejectQuotaRequest", "quotarequest"}
+	CancelQuotaRequest  = &EventInfo{"cancelQuotaRequest", "cancelQuotaRequest", "quotarequest"}
 )
@@ -35,6 +35,9 @@ const (
 	DefaultPivotCubeClusterIPSvc = "kubecube:7443"
 
 	DefaultAuditURL = "http://audit:8888/api/v1/cube/audit/cube"
+
+	// CubeLeaderElectionID is the name of lease which leader of cube holds
+	CubeLeaderElectionID = "kube-cube-manager"
 )
 
 // http content
@@ -62,6 +65,10 @@ const (
 	AuthorizationHeader        = "Authorization"
 	DefaultTokenExpireDuration = 3600 // 1 hour
 	UserName                   = "userName"
+	SessionID                  = "sessionID"
+	TokenScope                 = "tokenScope"
+	// Impersonator is the real user who acts as the request user
+	Impersonator = "impersonator"
 )
 
 // k8s api resources
@@ -79,6 +86,9 @@ const (
 
 // rbac related constant
 const (
+	// AdminUser is the built-in user of platform admin
+	AdminUser = "admin"
+
 	PlatformAdmin = "platform-admin"
 	TenantAdmin   = "tenant-admin"
 	ProjectAdmin  = "project-admin"
@@ -127,6 +137,10 @@ const (
 	RbacLabel = "kubecube.io/rbac"
 	// RoleLabel indicates the role of rbac policy
 	RoleLabel = "kubecube.io/role"
+	// ClusterRoleLabel indicates the ClusterRole which revision belongs to
+	ClusterRoleLabel = "kubecube.io/clusterrole"
+	// ChangedByAnnotation records the user who made the revision
+	ChangedByAnnotation = "kubecube.io/changed-by"
 
 	// CrdLabel indicates the crds kubecube need to dispatch
 	CrdLabel = "kubecube.io/crds"
@@ -222,6 +236,8 @@ const (
 	LabelNodeTenant = "node.kubecube.io/tenant"
 	LabelNodeStatus = "node.kubecube.io/status"
 	LabelNodeNs     = "node.kubecube.io/ns"
+	// LabelNodePool indicates the nodes pool which node belongs to
+	LabelNodePool = "node.kubecube.io/pool"
 
 	ValueNodeShare      = "share"
 	ValueNodeAssigned   = "assigned"
@@ -260,4 +276,28 @@ const (
 const (
 	// LabelRelationship mark a RoleBinding or ClusterRoleBindings belongs
 	LabelRelationship = "user.kubecube.io/relationship"
+
+	// LabelGroupRelationship mark a RoleBinding or ClusterRoleBindings belongs to group
+	LabelGroupRelationship = "user.kubecube.io/group-relationship"
+
+	// TokensRevokedAtAnnotation records unix time on User, tokens of the user issued before it are revoked
+	TokensRevokedAtAnnotation = "user.kubecube.io/tokens-revoked-at"
+
+	// LdapSyncForbiddenAnnotation marks User forbidden by ldap sync because user is removed from directory
+	LdapSyncForbiddenAnnotation = "user.kubecube.io/ldap-sync-forbidden"
+
+	// LdapSyncBindingsAnnotation lists scope bindings of User added by ldap sync, separated by comma
+	LdapSyncBindingsAnnotation = "user.kubecube.io/ldap-sync-bindings"
+
+	// ScimExternalIDAnnotation records externalId of User or Group given by scim client
+	ScimExternalIDAnnotation = "user.kubecube.io/scim-external-id"
+
+	// PasswordResetTokenAnnotation records sha256 of password reset token on User
+	PasswordResetTokenAnnotation = "user.kubecube.io/password-reset-token"
+
+	// PasswordResetExpireAnnotation records unix time when password reset token expires
+	PasswordResetExpireAnnotation = "user.kubecube.io/password-reset-expire"
+
+	// BindingExpireTimeAnnotation carries RFC3339 time when the binding to create expires
+	BindingExpireTimeAnnotation = "user.kubecube.io/expire-time"
 )
@@ -16,12 +16,15 @@ limitations under the License.
 
 package errcode
 
+import "strings"
+
 var (
 	MissingParamUserName             = New(missingParam, "name")
 	MissingParamPassword             = New(missingParam, "password")
 	InvalidParameterPassword         = New(invalidParamValue, "password")
 	InvalidParameterPhone            = New(invalidParamValue, "phone")
 	InvalidParameterEmail            = New(invalidParamValue, "email")
+	InvalidParameterName             = New(invalidParamValue, "name")
 	MissingParamFile                 = New(missingParam, "file")
 	MissingParamNameOrPwdOrLoginType = New(missingParam, "name or password or login type")
 
@@ -32,8 +35,27 @@ var (
 	LdapConnectError  = New(ldapConnectError)
 	PasswordWrong     = New(passwordWrong)
 	UserIsDisabled    = New(userIsDisabled)
+	LdapNotEnabled    = New(ldapNotEnabled)
+	SamlNotEnabled    = New(samlNotEnabled)
+	SamlProviderError = New(samlProviderError)
+
+	PwdResetNotEnabled   = New(pwdResetNotEnabled)
+	InvalidPwdResetToken = New(invalidPwdResetToken)
+
+	ImpersonateAdmin = New(impersonateAdmin)
+	NotImpersonating = New(notImpersonating)
+
+	PolicyEvaluateError = New(policyEvaluateError)
 )
 
+func AccessRequestDecided(phase string) *ErrorInfo {
+	return New(accessRequestDecided, strings.ToLower(phase))
+}
+
+func DeniedByPolicy(reason string) *ErrorInfo {
+	return New(deniedByPolicy, reason)
+}
+
 func UserNameDuplicated(name string) *ErrorInfo {
 	return New(paramNotUnique, "name", name)
 }
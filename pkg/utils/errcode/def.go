@@ -53,11 +53,25 @@ var (
 	dealErrorType       = &ErrorInfo{http.StatusBadRequest, "deal fail, %v."}
 
 	// auth
-	authenticateError = &ErrorInfo{http.StatusUnauthorized, "Authenticate failed."}
-	userNotExist      = &ErrorInfo{http.StatusBadRequest, "User not exist."}
-	invalidToken      = &ErrorInfo{http.StatusUnauthorized, "Token invalid."}
-	forbidden         = &ErrorInfo{http.StatusForbidden, "Forbidden."}
-	ldapConnectError  = &ErrorInfo{http.StatusInternalServerError, "Connect to LDAP server failed."}
-	passwordWrong     = &ErrorInfo{http.StatusUnauthorized, "Username or password is wrong."}
-	userIsDisabled    = &ErrorInfo{http.StatusBadRequest, "User is disabled."}
+	authenticateError    = &ErrorInfo{http.StatusUnauthorized, "Authenticate failed."}
+	userNotExist         = &ErrorInfo{http.StatusBadRequest, "User not exist."}
+	invalidToken         = &ErrorInfo{http.StatusUnauthorized, "Token invalid."}
+	forbidden            = &ErrorInfo{http.StatusForbidden, "Forbidden."}
+	ldapConnectError     = &ErrorInfo{http.StatusInternalServerError, "Connect to LDAP server failed."}
+	passwordWrong        = &ErrorInfo{http.StatusUnauthorized, "Username or password is wrong."}
+	userIsDisabled       = &ErrorInfo{http.StatusBadRequest, "User is disabled."}
+	ldapNotEnabled       = &ErrorInfo{http.StatusBadRequest, "LDAP is not enabled."}
+	samlNotEnabled       = &ErrorInfo{http.StatusBadRequest, "SAML is not enabled."}
+	samlProviderError    = &ErrorInfo{http.StatusInternalServerError, "SAML service provider is not available."}
+	pwdResetNotEnabled   = &ErrorInfo{http.StatusBadRequest, "Password reset by mail is not enabled."}
+	invalidPwdResetToken = &ErrorInfo{http.StatusBadRequest, "Password reset link is invalid or expired."}
+	impersonateAdmin     = &ErrorInfo{http.StatusForbidden, "Platform admin can not be impersonated."}
+	notImpersonating     = &ErrorInfo{http.StatusBadRequest, "Current session is not an impersonation."}
+	accessRequestDecided = &ErrorInfo{http.StatusConflict, "Access request has been %v."}
+	deniedByPolicy       = &ErrorInfo{http.StatusForbidden, "Forbidden by policy: %v."}
+	policyEvaluateError  = &ErrorInfo{http.StatusInternalServerError, "Evaluate access policy failed."}
+
+	// quota
+	quotaRequestDecided = &ErrorInfo{http.StatusConflict, "Quota request has been %v."}
+	quotaChangeDenied   = &ErrorInfo{http.StatusConflict, "Quota change is not allowed: %v."}
 )
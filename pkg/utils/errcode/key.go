@@ -26,4 +26,7 @@ var (
 	MaxKeyErr         = New(&ErrorInfo{Code: http.StatusBadRequest, Message: "already have 5 credentials, can't create more."})
 	ServerErr         = New(&ErrorInfo{Code: http.StatusInternalServerError, Message: "server error."})
 	NotFoundErr       = New(&ErrorInfo{Code: http.StatusNotFound, Message: "not found"})
+	KeyUnavailableErr = New(&ErrorInfo{Code: http.StatusBadRequest, Message: "key is disabled or expired."})
+
+	InvalidParameterExpireTime = New(invalidParamValue, "expireTime")
 )
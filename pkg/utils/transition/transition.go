@@ -21,9 +21,11 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
@@ -120,6 +122,26 @@ func AddUserScopeBindings(user *userv1.User, scopeType, scopeName, role string)
 	}
 }
 
+// GrantUserScopeBinding adds scope binding to user like AddUserScopeBindings,
+// the expire time of binding is set even if the binding already exists, nil
+// expire time makes the binding permanent.
+func GrantUserScopeBinding(user *userv1.User, scopeType, scopeName, role string, expireTime *metav1.Time) {
+	for i, binding := range user.Spec.ScopeBindings {
+		if binding.ScopeName == scopeName && string(binding.ScopeType) == scopeType && binding.Role == role {
+			user.Spec.ScopeBindings[i].ExpireTime = expireTime
+			clog.Info("renew ScopeBinding for user %v: type (%v), scope (%v), role (%v), expire time (%v)", user.Name, scopeType, scopeName, role, expireTime)
+			return
+		}
+	}
+	user.Spec.ScopeBindings = append(user.Spec.ScopeBindings, userv1.ScopeBinding{
+		ScopeName:  scopeName,
+		ScopeType:  userv1.BindingScopeType(scopeType),
+		Role:       role,
+		ExpireTime: expireTime,
+	})
+	clog.Info("add ScopeBinding for user %v: type (%v), scope (%v), role (%v), expire time (%v)", user.Name, scopeType, scopeName, role, expireTime)
+}
+
 func RemoveUserScopeBindings(user *userv1.User, scopeType, scopeName, role string) {
 	newScopeBindings := []userv1.ScopeBinding{}
 	for _, binding := range user.Spec.ScopeBindings {
@@ -160,7 +182,21 @@ func RefreshUserStatus(ctx context.Context, user *userv1.User, cli client.Client
 	user.Status.BelongProjectInfos = make([]userv1.ProjectInfo, 0)
 	user.Status.PlatformAdmin = false
 
-	for _, binding := range user.Spec.ScopeBindings {
+	// user inherits scope bindings of groups it belongs to, expired bindings are ignored
+	now := time.Now()
+	bindings := userv1.ActiveScopeBindings(user.Spec.ScopeBindings, now)
+	groupList := userv1.GroupList{}
+	err := cli.List(ctx, &groupList)
+	if err != nil && !meta.IsNoMatchError(err) {
+		clog.Error("list groups error: %v", err)
+	}
+	for _, g := range groupList.Items {
+		if g.HasMember(user.Name) {
+			bindings = append(bindings, userv1.ActiveScopeBindings(g.Spec.ScopeBindings, now)...)
+		}
+	}
+
+	for _, binding := range bindings {
 		switch binding.ScopeType {
 		case userv1.TenantScope:
 			addUserToTenant(user, binding.ScopeName)
@@ -0,0 +1,74 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/metrics/pkg/client/clientset/versioned"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/quota/history"
+)
+
+// SetupWithManager adds periodic sampling of quotas of local cluster into
+// manager. Failures of store are logged but not returned, history should
+// not stop warden from starting.
+func SetupWithManager(mgr ctrl.Manager, pivotClient client.Reader, cluster string) error {
+	if !history.Config.Enabled() {
+		return nil
+	}
+	metrics, err := versioned.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return err
+	}
+	sampler := history.NewSampler(cluster, pivotClient, mgr.GetAPIReader(), metrics)
+
+	return mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		wait.UntilWithContext(ctx, func(ctx context.Context) {
+			sampleOnce(ctx, sampler)
+		}, history.Config.Interval())
+		return nil
+	}))
+}
+
+func sampleOnce(ctx context.Context, sampler *history.Sampler) {
+	store, err := history.Store()
+	if err != nil {
+		clog.Error("open store of quota history failed: %v", err)
+		return
+	}
+	now := time.Now()
+	samples, err := sampler.Sample(ctx, now)
+	if err != nil {
+		clog.Warn("sample quota history failed: %v", err)
+		return
+	}
+	if len(samples) > 0 {
+		if err = store.Append(samples...); err != nil {
+			clog.Error("append samples of quota history failed: %v", err)
+		}
+	}
+	if err = store.Compact(now); err != nil {
+		clog.Warn("compact samples of quota history failed: %v", err)
+	}
+}
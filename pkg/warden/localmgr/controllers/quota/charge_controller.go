@@ -0,0 +1,156 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quota
+
+import (
+	"context"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/quota"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+// chargeResyncPeriod refreshes charged of tenants periodically, because
+// hierarchy of quotas in pivot cluster is not watched
+const chargeResyncPeriod = 5 * time.Minute
+
+// ChargeReconciler records usage of pods charged against project and tenant
+// quotas directly into status charged of the quotas, so that quota changes,
+// thresholds and history see what the quota really consumed. Requests are
+// keyed by name of tenant.
+type ChargeReconciler struct {
+	client.Client
+	pivotClient client.Client
+	cluster     string
+}
+
+func (r *ChargeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	tenant := req.Name
+
+	cq, err := quota.ListClusterQuotas(ctx, r.pivotClient, r.cluster)
+	if err != nil {
+		clog.Warn("list cube resource quotas of cluster %v failed: %v", r.cluster, err)
+		return ctrl.Result{}, err
+	}
+	quotas := cq.OfTenant(tenant)
+	if len(quotas) == 0 {
+		return ctrl.Result{}, nil
+	}
+
+	charged, err := quota.ChargedByPods(ctx, r.Client, cq, tenant)
+	if err != nil {
+		clog.Warn("sum usage of pods charged against quotas of tenant %v failed: %v", tenant, err)
+		return ctrl.Result{}, err
+	}
+
+	for _, q := range quotas {
+		want := charged[q.Name]
+		if quota.ResourceListEqual(q.Status.Charged, want) {
+			continue
+		}
+		if err = r.updateCharged(ctx, q.Name, want); err != nil {
+			clog.Warn("update charged of cube resource quota %v failed: %v", q.Name, err)
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{RequeueAfter: chargeResyncPeriod}, nil
+}
+
+func (r *ChargeReconciler) updateCharged(ctx context.Context, name string, charged v1.ResourceList) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		q := &quotav1.CubeResourceQuota{}
+		if err := r.pivotClient.Get(ctx, types.NamespacedName{Name: name}, q); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		q.Status.Charged = charged
+		return r.pivotClient.Status().Update(ctx, q)
+	})
+}
+
+// tenantOfNamespace maps objects to the tenant of their namespace
+func (r *ChargeReconciler) tenantOfNamespace(ctx context.Context, obj client.Object) []reconcile.Request {
+	ns, ok := obj.(*v1.Namespace)
+	if !ok {
+		ns = &v1.Namespace{}
+		if err := r.Get(ctx, types.NamespacedName{Name: obj.GetNamespace()}, ns); err != nil {
+			return nil
+		}
+	}
+	tenant := ns.Labels[constants.HncTenantLabel]
+	if len(tenant) == 0 {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: tenant}}}
+}
+
+// SetupChargeWithManager sets up the controller charging pods against
+// quotas with the Manager.
+func SetupChargeWithManager(mgr ctrl.Manager, pivotClient client.Client, cluster string) error {
+	r := &ChargeReconciler{
+		Client:      mgr.GetClient(),
+		pivotClient: pivotClient,
+		cluster:     cluster,
+	}
+
+	// usage of pod changes only when it is created, deleted or finished
+	podPredicate := predicate.Funcs{
+		UpdateFunc: func(updateEvent event.UpdateEvent) bool {
+			oldObj, ok := updateEvent.ObjectOld.(*v1.Pod)
+			if !ok {
+				return false
+			}
+			newObj, ok := updateEvent.ObjectNew.(*v1.Pod)
+			if !ok {
+				return false
+			}
+			return oldObj.Status.Phase != newObj.Status.Phase
+		},
+		GenericFunc: func(genericEvent event.GenericEvent) bool {
+			return false
+		},
+	}
+	// namespaces budgeted by ResourceQuota are not charged
+	quotaPredicate := predicate.NewPredicateFuncs(isManagedResourceQuota)
+	nsPredicate := predicate.Funcs{
+		UpdateFunc: func(updateEvent event.UpdateEvent) bool {
+			return updateEvent.ObjectOld.GetLabels()[constants.HncProjectLabel] != updateEvent.ObjectNew.GetLabels()[constants.HncProjectLabel] ||
+				updateEvent.ObjectOld.GetLabels()[constants.HncTenantLabel] != updateEvent.ObjectNew.GetLabels()[constants.HncTenantLabel]
+		},
+	}
+
+	mapFunc := handler.EnqueueRequestsFromMapFunc(r.tenantOfNamespace)
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("cuberesourcequota-charge").
+		Watches(&v1.Pod{}, mapFunc, builder.WithPredicates(podPredicate)).
+		Watches(&v1.ResourceQuota{}, mapFunc, builder.WithPredicates(quotaPredicate)).
+		Watches(&v1.Namespace{}, mapFunc, builder.WithPredicates(nsPredicate)).
+		Complete(r)
+}
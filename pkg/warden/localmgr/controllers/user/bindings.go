@@ -0,0 +1,343 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+	"github.com/kubecube-io/kubecube/pkg/utils/hash"
+	"github.com/kubecube-io/kubecube/pkg/utils/transition"
+)
+
+// groupBindingPrefix avoids conflict of binding names between user and group
+const groupBindingPrefix = "group-"
+
+// bindingSubject is the rbac subject which scope bindings are rendered for
+type bindingSubject struct {
+	kind string
+	name string
+	// label is the label key used to mark bindings belong to subject
+	label string
+}
+
+func userSubject(user string) bindingSubject {
+	return bindingSubject{kind: v1.UserKind, name: user, label: constants.LabelRelationship}
+}
+
+func groupSubject(group string) bindingSubject {
+	return bindingSubject{kind: v1.GroupKind, name: group, label: constants.LabelGroupRelationship}
+}
+
+func (s bindingSubject) subjects() []v1.Subject {
+	return []v1.Subject{{
+		APIGroup: constants.K8sGroupRBAC,
+		Kind:     s.kind,
+		Name:     s.name,
+	}}
+}
+
+func (s bindingSubject) bindingName(role, namespace string) string {
+	if s.kind == v1.GroupKind {
+		return hash.GenerateBindingName(groupBindingPrefix+s.name, role, namespace)
+	}
+	return hash.GenerateBindingName(s.name, role, namespace)
+}
+
+// bindingRenderer renders scope bindings into RoleBindings and ClusterRoleBindings
+type bindingRenderer struct {
+	client.Client
+}
+
+// cleanOrphanBindings clean up bindings of subject which are no longer in scope bindings
+// or expired, the build-in ClusterRoleBindings generated are kept if keepGen is true.
+func (r *bindingRenderer) cleanOrphanBindings(ctx context.Context, subject bindingSubject, bindings []userv1.ScopeBinding, keepGen bool) error {
+	ls, err := labels.Parse(fmt.Sprintf("%v=%v", subject.label, subject.name))
+	if err != nil {
+		return err
+	}
+
+	bindingUnique := []string{}
+	for _, binding := range userv1.ActiveScopeBindings(bindings, time.Now()) {
+		bindingUnique = append(bindingUnique, transition.ScopeBindingUnique(binding))
+	}
+
+	bindingUniqueSet := sets.New[string](bindingUnique...)
+
+	crbs := &v1.ClusterRoleBindingList{}
+	err = r.List(ctx, crbs, &client.ListOptions{LabelSelector: ls})
+	if err != nil {
+		return err
+	}
+	for _, crb := range crbs.Items {
+		if isGenBinding(crb.Name) && keepGen {
+			continue
+		}
+		scopeType, scopeName, role, _, err := transition.TransBinding(crb.Labels, crb.Subjects[0], crb.RoleRef)
+		if err != nil {
+			clog.Warn(err.Error())
+			continue
+		}
+		if !bindingUniqueSet.Has(scopeName + scopeType + role) {
+			clog.Info("clean up orphan ClusterRoleBinding (%v)", crb.Name)
+			err = r.Delete(ctx, &crb)
+			if err != nil && errors.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+
+	rbs := &v1.RoleBindingList{}
+	err = r.List(ctx, rbs, &client.ListOptions{LabelSelector: ls})
+	if err != nil {
+		return err
+	}
+	for _, rb := range rbs.Items {
+		scopeType, scopeName, role, _, err := transition.TransBinding(rb.Labels, rb.Subjects[0], rb.RoleRef)
+		if err != nil {
+			clog.Warn(err.Error())
+			continue
+		}
+		if !bindingUniqueSet.Has(scopeName + scopeType + role) {
+			clog.Info("clean up orphan RoleBinding (%v/%v)", rb.Name, rb.Namespace)
+			err = r.Delete(ctx, &rb)
+			if err != nil && errors.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// refreshBindings refresh related RoleBindings under binding scope, expired
+// scope bindings are skipped.
+func (r *bindingRenderer) refreshBindings(ctx context.Context, subject bindingSubject, bindings []userv1.ScopeBinding) error {
+	var (
+		errs                  []error
+		needGenTenantBinding  bool
+		needGenProjectBinding bool
+	)
+
+	// ignore any errors happen in refreshing, return all errors if had.
+	for _, binding := range userv1.ActiveScopeBindings(bindings, time.Now()) {
+		if binding.ScopeType == userv1.PlatformScope {
+			errs = append(errs, r.refreshPlatformBinding(ctx, subject, binding))
+		}
+		if binding.ScopeType == userv1.TenantScope {
+			needGenTenantBinding = true
+			errs = append(errs, r.refreshNsBinding(ctx, subject, binding))
+		}
+		if binding.ScopeType == userv1.ProjectScope {
+			needGenProjectBinding = true
+			errs = append(errs, r.refreshNsBinding(ctx, subject, binding))
+		}
+	}
+
+	if needGenTenantBinding {
+		errs = append(errs, r.generateClusterRoleBinding(ctx, subject, userv1.TenantScope))
+	}
+
+	if needGenProjectBinding {
+		errs = append(errs, r.generateClusterRoleBinding(ctx, subject, userv1.ProjectScope))
+	}
+
+	if len(errs) > 0 {
+		// any error occurs when refreshing bindings will do retry
+		return utilerrors.NewAggregate(errs)
+	}
+
+	return nil
+}
+
+// generateClusterRoleBinding will generate default build-in ClusterRoleBinding for subject who belongs to tenant or project.
+func (r *bindingRenderer) generateClusterRoleBinding(ctx context.Context, subject bindingSubject, scopeType userv1.BindingScopeType) error {
+	clusterRoleBinding := &v1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				constants.RbacLabel:     constants.TrueStr,
+				subject.label:           subject.name,
+				constants.PlatformLabel: constants.ClusterRolePlatform,
+			},
+		},
+		Subjects: subject.subjects(),
+		RoleRef: v1.RoleRef{
+			APIGroup: constants.K8sGroupRBAC,
+			Kind:     constants.K8sKindClusterRole,
+		},
+	}
+
+	if scopeType == userv1.TenantScope {
+		clusterRoleBinding.RoleRef.Name = constants.TenantAdminCluster
+	}
+	if scopeType == userv1.ProjectScope {
+		clusterRoleBinding.RoleRef.Name = constants.ProjectAdminCluster
+	}
+
+	clusterRoleBinding.Name = "gen-" + subject.bindingName(clusterRoleBinding.RoleRef.Name, "")
+
+	return ignoreAlreadyExistErr(r.Create(ctx, clusterRoleBinding))
+}
+
+// refreshNsBinding refresh the RoleBinding of tenant or project under current cluster.
+func (r *bindingRenderer) refreshNsBinding(ctx context.Context, subject bindingSubject, binding userv1.ScopeBinding) error {
+	namespaces, err := r.toFindNamespacesByScopeBinding(ctx, binding)
+	if err != nil {
+		return err
+	}
+
+	lb := map[string]string{
+		constants.RbacLabel: constants.TrueStr,
+		subject.label:       subject.name,
+	}
+
+	if binding.ScopeType == userv1.TenantScope {
+		lb[constants.TenantLabel] = binding.ScopeName
+	}
+	if binding.ScopeType == userv1.ProjectScope {
+		lb[constants.ProjectLabel] = binding.ScopeName
+	}
+
+	var errs []error
+
+	for _, ns := range namespaces {
+		b := &v1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      subject.bindingName(binding.Role, ns.Name),
+				Namespace: ns.Name,
+				Labels:    lb,
+				// we do not need warden sync here, every warden should process user event in self cluster
+			},
+			RoleRef: v1.RoleRef{
+				APIGroup: constants.K8sGroupRBAC,
+				Kind:     constants.KindClusterRole,
+				Name:     binding.Role,
+			},
+			Subjects: subject.subjects(),
+		}
+		errs = append(errs, ignoreAlreadyExistErr(r.Create(ctx, b)))
+	}
+	if len(errs) > 0 {
+		// any error occurs when refreshing bindings will do retry
+		return utilerrors.NewAggregate(errs)
+	}
+
+	return nil
+}
+
+// refreshPlatformBinding refresh the ClusterRoleBinding under current cluster.
+func (r *bindingRenderer) refreshPlatformBinding(ctx context.Context, subject bindingSubject, binding userv1.ScopeBinding) error {
+	b := &v1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: subject.bindingName(binding.Role, ""),
+			Labels: map[string]string{
+				constants.RbacLabel:     constants.TrueStr,
+				subject.label:           subject.name,
+				constants.PlatformLabel: constants.ClusterRolePlatform,
+			},
+			// we do not need warden sync here, every warden should process user event in self cluster
+		},
+		RoleRef: v1.RoleRef{
+			APIGroup: constants.K8sGroupRBAC,
+			Kind:     constants.KindClusterRole,
+			Name:     binding.Role,
+		},
+		Subjects: subject.subjects(),
+	}
+
+	return ignoreAlreadyExistErr(r.Create(ctx, b))
+}
+
+// bindingsGc clean up RoleBindings or ClusterRoleBindings which are under scope bindings.
+func (r *bindingRenderer) bindingsGc(ctx context.Context, subject bindingSubject) error {
+	ls, err := labels.Parse(fmt.Sprintf("%v=%v", subject.label, subject.name))
+	if err != nil {
+		return err
+	}
+
+	crbs := &v1.ClusterRoleBindingList{}
+	err = r.List(ctx, crbs, &client.ListOptions{LabelSelector: ls})
+	if err != nil {
+		return err
+	}
+	for _, crb := range crbs.Items {
+		err = r.Delete(ctx, &crb)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	rbs := &v1.RoleBindingList{}
+	err = r.List(ctx, rbs, &client.ListOptions{LabelSelector: ls})
+	if err != nil {
+		return err
+	}
+	for _, rb := range rbs.Items {
+		err = r.Delete(ctx, &rb)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// toFindNamespacesByScopeBinding will find namespaces under tenant or project
+func (r *bindingRenderer) toFindNamespacesByScopeBinding(ctx context.Context, binding userv1.ScopeBinding) ([]corev1.Namespace, error) {
+	var labelSelectorStr string
+
+	if binding.ScopeType == userv1.TenantScope {
+		labelSelectorStr = fmt.Sprintf("%v=%v", constants.HncTenantLabel, binding.ScopeName)
+	}
+
+	if binding.ScopeType == userv1.ProjectScope {
+		labelSelectorStr = fmt.Sprintf("%v=%v", constants.HncProjectLabel, binding.ScopeName)
+	}
+
+	ls, err := labels.Parse(labelSelectorStr)
+	if err != nil {
+		return nil, err
+	}
+
+	nsList := &corev1.NamespaceList{}
+	err = r.List(ctx, nsList, &client.ListOptions{LabelSelector: ls})
+	if err != nil {
+		return nil, err
+	}
+
+	return nsList.Items, nil
+}
+
+func ignoreAlreadyExistErr(err error) error {
+	if errors.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/ctrlmgr/options"
+)
+
+const (
+	// finalizerGroup is used to clean up RoleBindings or ClusterRoleBindings which are under scope bindings of group
+	finalizerGroup = "group.finalizers.kubecube.io"
+)
+
+var _ reconcile.Reconciler = &GroupReconciler{}
+
+// GroupReconciler renders scope bindings of Group into bindings with Group subject
+type GroupReconciler struct {
+	client.Client
+
+	bindings *bindingRenderer
+}
+
+//+kubebuilder:rbac:groups=user.kubecube.io,resources=groups,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=user.kubecube.io,resources=groups/finalizers,verbs=update
+
+func (r *GroupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	group := &userv1.Group{}
+
+	err := r.Get(ctx, req.NamespacedName, group)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		clog.Error("get group %v failed: %v", req.Name, err)
+		return ctrl.Result{}, err
+	}
+
+	subject := groupSubject(group.Name)
+
+	if group.DeletionTimestamp != nil {
+		if !controllerutil.ContainsFinalizer(group, finalizerGroup) {
+			return ctrl.Result{}, nil
+		}
+		clog.Info("delete group %v and clean up for it", group.Name)
+		if err = r.bindings.bindingsGc(ctx, subject); err != nil {
+			return ctrl.Result{}, err
+		}
+		controllerutil.RemoveFinalizer(group, finalizerGroup)
+		return ctrl.Result{}, r.Update(ctx, group)
+	}
+
+	if !controllerutil.ContainsFinalizer(group, finalizerGroup) {
+		controllerutil.AddFinalizer(group, finalizerGroup)
+		if err = r.Update(ctx, group); err != nil {
+			clog.Error("add finalizers for group %v, failed: %v", group.Name, err)
+			return ctrl.Result{}, err
+		}
+	}
+
+	err = r.bindings.refreshBindings(ctx, subject, group.Spec.ScopeBindings)
+	if err != nil {
+		clog.Error("refresh bindings of group %v failed: %v", group.Name, err)
+		return ctrl.Result{}, err
+	}
+
+	now := time.Now()
+	keepGen := false
+	for _, b := range userv1.ActiveScopeBindings(group.Spec.ScopeBindings, now) {
+		if b.ScopeType == userv1.TenantScope || b.ScopeType == userv1.ProjectScope {
+			keepGen = true
+		}
+	}
+	err = r.bindings.cleanOrphanBindings(ctx, subject, group.Spec.ScopeBindings, keepGen)
+	if err != nil {
+		clog.Error("clean up orphan bindings of group %v failed: %v", group.Name, err)
+		return ctrl.Result{}, err
+	}
+
+	// reconcile again to clean up bindings once they expired
+	return ctrl.Result{RequeueAfter: userv1.NextScopeBindingExpiry(group.Spec.ScopeBindings, now)}, nil
+}
+
+// namespaceHandleFunc enqueues groups bound to the tenant or project of namespace
+func (r *GroupReconciler) namespaceHandleFunc() handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		var requests []reconcile.Request
+
+		tenant, project := extraTenantAndProject(obj.GetLabels())
+
+		groupList := userv1.GroupList{}
+		err := r.List(ctx, &groupList)
+		if err != nil {
+			clog.Error("list groups failed: %v", err)
+			return requests
+		}
+
+		for _, g := range groupList.Items {
+			for _, b := range g.Spec.ScopeBindings {
+				if (b.ScopeType == userv1.TenantScope && b.ScopeName == tenant) ||
+					(b.ScopeType == userv1.ProjectScope && b.ScopeName == project) {
+					requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: g.Name}})
+					break
+				}
+			}
+		}
+
+		return requests
+	}
+}
+
+// groupMembersHandler enqueues members of group when group changed, both of
+// old and new members are enqueued so that status of users are refreshed.
+var groupMembersHandler = handler.Funcs{
+	CreateFunc: func(_ context.Context, e event.CreateEvent, q workqueue.RateLimitingInterface) {
+		enqueueGroupMembers(q, e.Object)
+	},
+	UpdateFunc: func(_ context.Context, e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+		enqueueGroupMembers(q, e.ObjectOld, e.ObjectNew)
+	},
+	DeleteFunc: func(_ context.Context, e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+		enqueueGroupMembers(q, e.Object)
+	},
+}
+
+func enqueueGroupMembers(q workqueue.RateLimitingInterface, objs ...client.Object) {
+	members := sets.New[string]()
+	for _, obj := range objs {
+		if g, ok := obj.(*userv1.Group); ok {
+			members.Insert(g.Spec.Members...)
+		}
+	}
+	for _, m := range sets.List(members) {
+		q.Add(reconcile.Request{NamespacedName: types.NamespacedName{Name: m}})
+	}
+}
+
+// SetupGroupWithManager sets up the group controller with the Manager.
+func SetupGroupWithManager(mgr ctrl.Manager, _ *options.Options) error {
+	r := &GroupReconciler{
+		Client:   mgr.GetClient(),
+		bindings: &bindingRenderer{Client: mgr.GetClient()},
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&userv1.Group{}).
+		Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(r.namespaceHandleFunc()), namespacePredicateFn).
+		Complete(r)
+}
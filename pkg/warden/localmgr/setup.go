@@ -22,6 +22,7 @@ import (
 
 	"github.com/kubecube-io/kubecube/pkg/utils/ctrlopts"
 	"github.com/kubecube-io/kubecube/pkg/warden/localmgr/controllers/crds"
+	"github.com/kubecube-io/kubecube/pkg/warden/localmgr/controllers/history"
 	"github.com/kubecube-io/kubecube/pkg/warden/localmgr/controllers/hotplug"
 	project "github.com/kubecube-io/kubecube/pkg/warden/localmgr/controllers/project"
 	"github.com/kubecube-io/kubecube/pkg/warden/localmgr/controllers/quota"
@@ -31,6 +32,7 @@ import (
 	project2 "github.com/kubecube-io/kubecube/pkg/warden/localmgr/webhooks/project"
 	quota2 "github.com/kubecube-io/kubecube/pkg/warden/localmgr/webhooks/quota"
 	tenant2 "github.com/kubecube-io/kubecube/pkg/warden/localmgr/webhooks/tenant"
+	"github.com/kubecube-io/kubecube/pkg/warden/utils"
 )
 
 // setupControllersWithManager set up controllers into manager
@@ -77,6 +79,17 @@ func setupControllersWithManager(m *LocalManager, controllers string) error {
 		if err != nil {
 			return err
 		}
+		err = quota.SetupChargeWithManager(m.Manager, m.PivotClient.Direct(), m.Cluster)
+		if err != nil {
+			return err
+		}
+	}
+
+	if ctrlopts.IsControllerEnabled("history", ctrls) {
+		err = history.SetupWithManager(m.Manager, m.PivotClient.Direct(), m.Cluster)
+		if err != nil {
+			return err
+		}
 	}
 
 	if ctrlopts.IsControllerEnabled("user", ctrls) {
@@ -86,6 +99,13 @@ func setupControllersWithManager(m *LocalManager, controllers string) error {
 		}
 	}
 
+	if ctrlopts.IsControllerEnabled("group", ctrls) {
+		err = user.SetupGroupWithManager(m.Manager, nil)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -96,5 +116,7 @@ func setupWithWebhooks(m *LocalManager) {
 	hookServer.Register("/warden-validate-tenant-kubecube-io-v1-tenant", &webhook.Admission{Handler: tenant2.NewValidator(m.GetClient(), m.IsMemberCluster, decoder)})
 	hookServer.Register("/warden-validate-tenant-kubecube-io-v1-project", &webhook.Admission{Handler: project2.NewValidator(m.GetClient(), m.IsMemberCluster, decoder)})
 	hookServer.Register("/validate-core-kubernetes-v1-resource-quota", &webhook.Admission{Handler: quota2.NewValidator(m.PivotClient.Direct(), m.GetClient(), decoder)})
+	hookServer.Register("/warden-validate-core-kubernetes-v1-pod", &webhook.Admission{Handler: quota2.NewPodValidator(m.PivotClient.Cache(), m.GetClient(), utils.Cluster)})
+	hookServer.Register("/warden-mutate-core-kubernetes-v1-pod", &webhook.Admission{Handler: quota2.NewPodDefaulter(m.PivotClient.Cache(), m.GetClient(), utils.Cluster)})
 	hookServer.Register("/warden-validate-hotplug-kubecube-io-v1-hotplug", admisson.ValidatingWebhookFor(m.GetScheme(), hotplug2.NewHotplugValidator(m.IsMemberCluster)))
 }
@@ -0,0 +1,42 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quota
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+// namespaceInTenant returns the namespace if it belongs to tenant or project,
+// nil if not, so that pods of system namespaces skip reading quotas
+func namespaceInTenant(ctx context.Context, cli client.Reader, name string) (*v1.Namespace, error) {
+	ns := &v1.Namespace{}
+	if err := cli.Get(ctx, types.NamespacedName{Name: name}, ns); err != nil {
+		clog.Warn("get namespace %v failed: %v", name, err)
+		return nil, err
+	}
+	if len(ns.Labels[constants.HncTenantLabel]) == 0 && len(ns.Labels[constants.HncProjectLabel]) == 0 {
+		return nil, nil
+	}
+	return ns, nil
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/quota"
+)
+
+// PodDefaulter sets default requests of project or tenant quota to the
+// containers of new pods without requests, the nearest quota wins for each
+// resource. Pods are kept as they are if quota can not be got.
+type PodDefaulter struct {
+	// PivotClient reads cube resource quotas, it should be cached
+	PivotClient client.Reader
+	LocalClient client.Client
+	Cluster     string
+}
+
+func NewPodDefaulter(pivotClient client.Reader, localClient client.Client, cluster string) *PodDefaulter {
+	return &PodDefaulter{
+		PivotClient: pivotClient,
+		LocalClient: localClient,
+		Cluster:     cluster,
+	}
+}
+
+func (r *PodDefaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create || len(req.Namespace) == 0 {
+		return admission.Allowed("")
+	}
+
+	ns, err := namespaceInTenant(ctx, r.LocalClient, req.Namespace)
+	if err != nil || ns == nil {
+		return admission.Allowed("")
+	}
+	cq, err := quota.ListClusterQuotas(ctx, r.PivotClient, r.Cluster)
+	if err != nil {
+		clog.Warn("list cube resource quotas of cluster %v failed: %v", r.Cluster, err)
+		return admission.Allowed("")
+	}
+	levels := cq.LevelsOf(ns)
+	defaults := v1.ResourceList{}
+	for i := len(levels) - 1; i >= 0; i-- {
+		for rs, value := range levels[i].Spec.DefaultRequests {
+			defaults[rs] = value
+		}
+	}
+	if len(defaults) == 0 {
+		return admission.Allowed("")
+	}
+
+	pod := &v1.Pod{}
+	if err = json.Unmarshal(req.Object.Raw, pod); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if !quota.ApplyDefaultRequests(pod, defaults) {
+		return admission.Allowed("")
+	}
+	marshaled, err := json.Marshal(pod)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/quota"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+// mustSpecify are the resources pods must specify when they are limited
+// by quota, as ResourceQuota does
+var mustSpecify = []v1.ResourceName{
+	v1.ResourceRequestsCPU,
+	v1.ResourceRequestsMemory,
+	v1.ResourceLimitsCPU,
+	v1.ResourceLimitsMemory,
+}
+
+// PodValidator blocks new pods in namespaces whose elastic project quota is
+// reclaiming borrowed resources with BlockNewPods policy. Pods of namespaces
+// without ResourceQuota are charged against the nearest project or tenant
+// quota limiting the resource, so they can not escape the tenant budget.
+// Pods are allowed if the quota can not be got, a broken pivot cluster should
+// not block all the pods of member cluster. Pods of namespaces out of tenants
+// are skipped before reading any quota.
+type PodValidator struct {
+	// PivotClient reads cube resource quotas, it should be cached
+	PivotClient client.Reader
+	LocalClient client.Client
+	Cluster     string
+}
+
+func NewPodValidator(pivotClient client.Reader, localClient client.Client, cluster string) *PodValidator {
+	return &PodValidator{
+		PivotClient: pivotClient,
+		LocalClient: localClient,
+		Cluster:     cluster,
+	}
+}
+
+func (r *PodValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create || len(req.Namespace) == 0 {
+		return admission.Allowed("")
+	}
+
+	ns, err := namespaceInTenant(ctx, r.LocalClient, req.Namespace)
+	if err != nil || ns == nil {
+		return admission.Allowed("")
+	}
+
+	rqs := &v1.ResourceQuotaList{}
+	err = r.LocalClient.List(ctx, rqs, client.InNamespace(req.Namespace), client.HasLabels{constants.CubeQuotaLabel})
+	if err != nil {
+		clog.Warn("list ResourceQuotas of namespace %v failed: %v", req.Namespace, err)
+		return admission.Allowed("")
+	}
+
+	parents := sets.NewString()
+	for _, rq := range rqs.Items {
+		if parent := rq.Labels[constants.CubeQuotaLabel]; len(parent) > 0 {
+			parents.Insert(parent)
+		}
+	}
+
+	for _, name := range parents.List() {
+		q := &quotav1.CubeResourceQuota{}
+		if err = r.PivotClient.Get(ctx, types.NamespacedName{Name: name}, q); err != nil {
+			clog.Warn("get cube resource quota %v of namespace %v failed: %v", name, req.Namespace, err)
+			continue
+		}
+		if quota.ReclaimPolicyOf(q) == quotav1.ReclaimBlockNewPods && quota.IsReclaiming(q) {
+			reason := fmt.Sprintf("quota %v is reclaiming borrowed resources %v, new pods are blocked until they are returned", name, q.Status.Reclaiming)
+			clog.Debug(reason)
+			return admission.Denied(reason)
+		}
+	}
+
+	if len(rqs.Items) > 0 {
+		return admission.Allowed("")
+	}
+
+	pod := &v1.Pod{}
+	if err = json.Unmarshal(req.Object.Raw, pod); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	reason, err := r.checkHierarchy(ctx, ns, pod)
+	if err != nil {
+		clog.Warn("check quota hierarchy of pod %v/%v failed: %v", req.Namespace, pod.Name, err)
+		return admission.Allowed("")
+	}
+	if len(reason) > 0 {
+		clog.Debug(reason)
+		return admission.Denied(reason)
+	}
+
+	return admission.Allowed("")
+}
+
+// checkHierarchy charges usage of pod against the nearest quota limiting
+// each resource, the reason is returned if any quota is exceeded. Usage of
+// existing pods is read from status charged of quota, which is refreshed by
+// charge controller after pods are created, and nothing is reserved for the
+// pods being admitted. So pods created at the same time could exceed quota
+// together, the excess is seen by the later pods once charged is refreshed.
+func (r *PodValidator) checkHierarchy(ctx context.Context, ns *v1.Namespace, pod *v1.Pod) (string, error) {
+	cq, err := quota.ListClusterQuotas(ctx, r.PivotClient, r.Cluster)
+	if err != nil {
+		return "", err
+	}
+	levels := cq.LevelsOf(ns)
+	if len(levels) == 0 {
+		return "", nil
+	}
+
+	usage := quota.PodUsage(pod)
+	var reasons []string
+	for _, rs := range mustSpecify {
+		if _, ok := usage[rs]; ok {
+			continue
+		}
+		if q := quota.LevelFor(levels, rs); q != nil {
+			reasons = append(reasons, fmt.Sprintf("must specify %v for quota of %v", rs, levelName(q)))
+		}
+	}
+	if len(reasons) > 0 {
+		return strings.Join(reasons, "; "), nil
+	}
+
+	names := make([]string, 0, len(usage))
+	for rs := range usage {
+		names = append(names, string(rs))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		rs := v1.ResourceName(name)
+		q := quota.LevelFor(levels, rs)
+		if q == nil {
+			continue
+		}
+		hard, _ := quota.LimitOf(q, rs)
+		used := quota.UsedOf(q)[rs].DeepCopy()
+		requested := usage[rs]
+		total := used.DeepCopy()
+		total.Add(requested)
+		if total.Cmp(hard) > 0 {
+			reasons = append(reasons, fmt.Sprintf("exceeded quota of %v: requested %v=%v, used %v=%v, limited %v=%v",
+				levelName(q), rs, requested.String(), rs, used.String(), rs, hard.String()))
+		}
+	}
+	return strings.Join(reasons, "; "), nil
+}
+
+// levelName describes the level of quota in hierarchy for messages
+func levelName(q *quotav1.CubeResourceQuota) string {
+	return fmt.Sprintf("%v %v (cube resource quota %v)", strings.ToLower(string(q.Spec.Target.Kind)), q.Spec.Target.Name, q.Name)
+}
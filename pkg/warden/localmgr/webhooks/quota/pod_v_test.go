@@ -0,0 +1,177 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
+	"github.com/kubecube-io/kubecube/pkg/multicluster/client/fake"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+)
+
+func newPod(ns, name, cpu string) *v1.Pod {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name},
+		Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "c"}}},
+		Status:     v1.PodStatus{Phase: v1.PodRunning},
+	}
+	if len(cpu) > 0 {
+		pod.Spec.Containers[0].Resources.Requests = v1.ResourceList{v1.ResourceCPU: resource.MustParse(cpu)}
+	}
+	return pod
+}
+
+func podRequest(pod *v1.Pod) admission.Request {
+	raw, _ := json.Marshal(pod)
+	return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: admissionv1.Create,
+		Namespace: pod.Namespace,
+		Object:    runtime.RawExtension{Raw: raw},
+	}}
+}
+
+func TestPodHierarchy(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	quotav1.AddToScheme(scheme)
+
+	labels := map[string]string{constants.ClusterLabel: "c1"}
+	pivot := fake.NewFakeClients(&fake.Options{Scheme: scheme, Objs: []client.Object{
+		&quotav1.CubeResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: "c1.tenant.t1", Labels: labels},
+			Spec: quotav1.CubeResourceQuotaSpec{
+				Target:          quotav1.TargetObj{Kind: quotav1.TenantObj, Name: "t1"},
+				Hard:            v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("10"), v1.ResourcePods: resource.MustParse("3")},
+				DefaultRequests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1"), v1.ResourceMemory: resource.MustParse("1Gi")},
+			},
+			Status: quotav1.CubeResourceQuotaStatus{
+				Used:    v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("4")},
+				Charged: v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("5"), v1.ResourcePods: resource.MustParse("2")},
+			},
+		},
+		&quotav1.CubeResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: "c1.project.p1", Labels: labels},
+			Spec: quotav1.CubeResourceQuotaSpec{
+				Target:          quotav1.TargetObj{Kind: quotav1.ProjectObj, Name: "p1"},
+				ParentQuota:     "c1.tenant.t1",
+				Hard:            v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("4")},
+				DefaultRequests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("500m")},
+			},
+			Status: quotav1.CubeResourceQuotaStatus{Charged: v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("3")}},
+		},
+	}})
+	nsLabels := func(project string) map[string]string {
+		return map[string]string{constants.HncTenantLabel: "t1", constants.HncProjectLabel: project}
+	}
+	local := fake.NewFakeClients(&fake.Options{Scheme: scheme, Objs: []client.Object{
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns1", Labels: nsLabels("p1")}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns2", Labels: nsLabels("p2")}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "budgeted", Labels: nsLabels("p1")}},
+		&v1.ResourceQuota{ObjectMeta: metav1.ObjectMeta{Namespace: "budgeted", Name: "rq", Labels: map[string]string{constants.CubeQuotaLabel: "c1.project.p1"}}},
+		newPod("ns1", "a", "3"),
+		newPod("ns2", "b", "5"),
+		newPod("budgeted", "c", "8"),
+	}})
+	ctx := context.Background()
+
+	validator := NewPodValidator(pivot.Direct(), local.Direct(), "c1")
+	resp := validator.Handle(ctx, podRequest(newPod("ns1", "new", "500m")))
+	if !resp.Allowed {
+		t.Errorf("pod within quota of project should be allowed: %v", resp.Result.Message)
+	}
+	resp = validator.Handle(ctx, podRequest(newPod("ns1", "new", "2")))
+	if resp.Allowed || !strings.Contains(resp.Result.Message, "exceeded quota of project p1 (cube resource quota c1.project.p1): requested requests.cpu=2, used requests.cpu=3, limited requests.cpu=4") {
+		t.Errorf("pod exceeding quota of project should be denied: %v", resp.Result.Message)
+	}
+	// cpu of p2 is charged against tenant: used 4 by children and charged 5 by pod b
+	resp = validator.Handle(ctx, podRequest(newPod("ns2", "new", "2")))
+	if resp.Allowed || !strings.Contains(resp.Result.Message, "exceeded quota of tenant t1") {
+		t.Errorf("pod exceeding quota of tenant should be denied: %v", resp.Result.Message)
+	}
+	resp = validator.Handle(ctx, podRequest(newPod("ns2", "new", "")))
+	if resp.Allowed || !strings.Contains(resp.Result.Message, "must specify requests.cpu") {
+		t.Errorf("pod without requests should be denied: %v", resp.Result.Message)
+	}
+	// pods of namespaces with ResourceQuota are left to ResourceQuota
+	if resp = validator.Handle(ctx, podRequest(newPod("budgeted", "new", "100"))); !resp.Allowed {
+		t.Errorf("pod of namespace with ResourceQuota should be allowed: %v", resp.Result.Message)
+	}
+
+	defaulter := NewPodDefaulter(pivot.Direct(), local.Direct(), "c1")
+	resp = defaulter.Handle(ctx, podRequest(newPod("ns1", "new", "")))
+	if !resp.Allowed || len(resp.Patches) != 1 {
+		t.Fatalf("want patch of requests, got %+v", resp.Patches)
+	}
+	requests, _ := json.Marshal(resp.Patches[0].Value)
+	if resp.Patches[0].Path != "/spec/containers/0/resources/requests" || string(requests) != `{"cpu":"500m","memory":"1Gi"}` {
+		t.Errorf("unexpected patch: %v %s", resp.Patches[0].Path, requests)
+	}
+	if resp = defaulter.Handle(ctx, podRequest(newPod("ns1", "new", "1"))); len(resp.Patches) != 1 {
+		t.Errorf("want patch of memory only, got %+v", resp.Patches)
+	}
+}
+
+// countingReader counts reads of quotas from pivot cluster
+type countingReader struct {
+	client.Reader
+	reads int
+}
+
+func (c *countingReader) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	c.reads++
+	return c.Reader.Get(ctx, key, obj, opts...)
+}
+
+func (c *countingReader) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	c.reads++
+	return c.Reader.List(ctx, list, opts...)
+}
+
+func TestPodOutOfTenant(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	quotav1.AddToScheme(scheme)
+
+	pivot := &countingReader{Reader: fake.NewFakeClients(&fake.Options{Scheme: scheme}).Direct()}
+	local := fake.NewFakeClients(&fake.Options{Scheme: scheme, Objs: []client.Object{
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+	}})
+	ctx := context.Background()
+
+	if resp := NewPodValidator(pivot, local.Direct(), "c1").Handle(ctx, podRequest(newPod("kube-system", "new", ""))); !resp.Allowed {
+		t.Errorf("pod out of tenant should be allowed: %v", resp.Result.Message)
+	}
+	if resp := NewPodDefaulter(pivot, local.Direct(), "c1").Handle(ctx, podRequest(newPod("kube-system", "new", ""))); len(resp.Patches) > 0 {
+		t.Errorf("pod out of tenant should not be patched: %+v", resp.Patches)
+	}
+	if pivot.reads > 0 {
+		t.Errorf("quotas should not be read for pods out of tenant, got %v reads", pivot.reads)
+	}
+}
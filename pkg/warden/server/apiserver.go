@@ -23,8 +23,10 @@ import (
 	"time"
 
 	"github.com/kubecube-io/kubecube/pkg/clog"
+	quotahistory "github.com/kubecube-io/kubecube/pkg/quota/history"
 	"github.com/kubecube-io/kubecube/pkg/warden/reporter"
 	"github.com/kubecube-io/kubecube/pkg/warden/server/authproxy"
+	"github.com/kubecube-io/kubecube/pkg/warden/server/history"
 )
 
 var log clog.CubeLogger
@@ -37,6 +39,7 @@ type Server struct {
 	TlsCert                string
 	TlsKey                 string
 	LocalClusterKubeConfig string
+	Cluster                string
 
 	ready bool
 }
@@ -50,13 +53,16 @@ func (s *Server) Initialize() error {
 }
 
 func (s *Server) Run(stop <-chan struct{}) {
-	authProxyHandler, err := authproxy.NewHandler(s.LocalClusterKubeConfig)
+	authProxyHandler, err := authproxy.NewHandler(s.Cluster, s.LocalClusterKubeConfig)
 	if err != nil {
 		log.Fatal("new auth proxy handler failed: %v", err)
 	}
 
 	mux := http.NewServeMux()
 	mux.Handle("/", authProxyHandler)
+	if quotahistory.Config.Enabled() {
+		mux.Handle(quotahistory.WardenPath, history.NewHandler(s.Cluster, authProxyHandler.Client()))
+	}
 
 	s.Server = &http.Server{Handler: mux, Addr: fmt.Sprintf("%s:%d", s.BindAddr, s.Port)}
 
@@ -18,21 +18,26 @@ package authproxy
 
 import (
 	"context"
-
+	"encoding/json"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 
 	"github.com/kubecube-io/kubecube/pkg/authentication/authenticators"
 	"github.com/kubecube-io/kubecube/pkg/authentication/authenticators/jwt"
 	"github.com/kubecube-io/kubecube/pkg/authentication/authenticators/token"
+	"github.com/kubecube-io/kubecube/pkg/authentication/session"
+	"github.com/kubecube-io/kubecube/pkg/authorizer/policy"
+	"github.com/kubecube-io/kubecube/pkg/authorizer/rbac"
 	"github.com/kubecube-io/kubecube/pkg/belongs"
 	"github.com/kubecube-io/kubecube/pkg/clog"
 	"github.com/kubecube-io/kubecube/pkg/multicluster/client"
+	"github.com/kubecube-io/kubecube/pkg/utils/access"
 	"github.com/kubecube-io/kubecube/pkg/utils/constants"
 	requestutil "github.com/kubecube-io/kubecube/pkg/utils/request"
 	"github.com/kubecube-io/kubecube/pkg/warden/server/authproxy/proxy"
@@ -49,17 +54,24 @@ type Handler struct {
 
 	cli client.Client
 
+	// sessions tells if the token used was revoked
+	sessions session.Manager
+
+	// cluster is the name of cluster proxy to, tokens limited to
+	// clusters are not allowed if empty
+	cluster string
+
 	// proxy do real proxy action with any inbound stream
 	proxy *proxy.UpgradeAwareHandler
 }
 
-func NewHandler(localClusterKubeConfig string) (*Handler, error) {
+func NewHandler(cluster, localClusterKubeConfig string) (*Handler, error) {
 	// get cluster info from rest config
 	restConfig, err := clientcmd.BuildConfigFromFlags("", localClusterKubeConfig)
 	if err != nil {
 		return nil, err
 	}
-	h := &Handler{}
+	h := &Handler{cluster: cluster}
 	err = h.SetHandlerClientByRestConfig(restConfig)
 	if err != nil {
 		return nil, err
@@ -97,14 +109,25 @@ func (h *Handler) SetHandlerTS(restConfig *rest.Config) error {
 	p := proxy.NewUpgradeAwareHandler(target, ts, false, false, responder)
 	p.UpgradeTransport = upgradeTransport
 	p.UseRequestLocation = true
+	p.ModifyResponse = belongs.ModifyResponse
 
 	h.proxy = p
 
 	return nil
 }
 
+func (h *Handler) SetHandlerCluster(cluster string) {
+	h.cluster = cluster
+}
+
 func (h *Handler) SetHandlerClient(cli client.Client) {
 	h.cli = cli
+	h.sessions = session.NewManager(cli)
+}
+
+// Client returns the client of cluster proxy to
+func (h *Handler) Client() client.Client {
+	return h.cli
 }
 
 func (h *Handler) SetHandlerClientByRestConfig(restConfig *rest.Config) error {
@@ -113,20 +136,44 @@ func (h *Handler) SetHandlerClientByRestConfig(restConfig *rest.Config) error {
 		return err
 	}
 	h.cli = cli
+	h.sessions = session.NewManager(cli)
 	return nil
 }
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// parse token transfer to user info
-	userInfo, err := token.GetUserFromReq(r)
+	claims, err := token.GetClaimsFromReq(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	userInfo := claims.UserInfo
+
+	revoked, err := h.sessions.IsRevoked(r.Context(), claims)
 	if err != nil {
+		clog.Warn("check session of user %v failed: %v", userInfo.Username, err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if revoked {
 		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
 
+	allowed, err := access.AllowScope(r.Context(), claims.Scope, h.cli.Cache(), h.cluster, access.NamespaceOfURL(r.URL.Path), r.Method)
+	if err != nil {
+		clog.Warn("check scope of user %v failed: %v", userInfo.Username, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !allowed {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
 	clog.Debug("user(%v) access to %v with verb(%v)", userInfo.Username, r.URL.Path, r.Method)
 
-	allowed, err := belongs.RelationshipDetermine(context.Background(), h.cli, r.URL.Path, userInfo.Username)
+	allowed, err = belongs.RelationshipDetermine(context.Background(), h.cli, r.URL.Path, userInfo.Username)
 	if err != nil {
 		clog.Warn(err.Error())
 	} else if !allowed {
@@ -134,13 +181,66 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// objects of list and watch responses not belong to user are dropped
+	objectFilter, err := belongs.NewObjectFilter(r.Context(), h.cli, r.URL.Path, userInfo.Username)
+	if err != nil {
+		clog.Warn("build object filter of user %v failed: %v", userInfo.Username, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if objectFilter != nil {
+		belongs.AcceptJSON(r.Header)
+		r = r.WithContext(belongs.WithObjectFilter(r.Context(), objectFilter))
+	}
+
+	groups, err := rbac.GroupsOf(r.Context(), h.cli.Cache(), userInfo.Username)
+	if err != nil {
+		clog.Warn("get groups of user %v failed: %v", userInfo.Username, err)
+	}
+
+	// access policies and decision service are consulted before forwarding
+	attrs, err := policy.NewAttributes(r, r.URL.Path, h.cluster, userInfo.Username, groups)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	decision, err := policy.NewEvaluator(h.cli.Cache()).Evaluate(r.Context(), attrs)
+	if err != nil {
+		clog.Warn("evaluate policy of user %v failed: %v", userInfo.Username, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !decision.Allowed {
+		writeForbidden(w, decision.Reason)
+		return
+	}
+
 	err = requestutil.AddFieldManager(r, userInfo.Username)
 	if err != nil {
 		clog.Error("fail to add fieldManager due to %s", err)
 	}
 
-	// impersonate given user to access k8s-apiserver
+	// impersonate given user and its groups to access k8s-apiserver
 	r.Header.Set(constants.ImpersonateUserKey, userInfo.Username)
+	r.Header.Del(constants.ImpersonateGroupKey)
+	for _, g := range groups {
+		r.Header.Add(constants.ImpersonateGroupKey, g)
+	}
 	r.Header.Del(constants.AuthorizationHeader)
 	h.proxy.ServeHTTP(w, r)
 }
+
+// writeForbidden responds status of k8s so that clients like kubectl
+// could show the reason
+func writeForbidden(w http.ResponseWriter, reason string) {
+	status := metav1.Status{
+		TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+		Status:   metav1.StatusFailure,
+		Message:  reason,
+		Reason:   metav1.StatusReasonForbidden,
+		Code:     http.StatusForbidden,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	_ = json.NewEncoder(w).Encode(status)
+}
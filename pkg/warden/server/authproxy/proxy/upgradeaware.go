@@ -86,6 +86,8 @@ type UpgradeAwareHandler struct {
 	Responder ErrorResponder
 	// Reject to forward redirect response
 	RejectForwardingRedirects bool
+	// ModifyResponse, if specified, modifies responses of non-upgrade requests
+	ModifyResponse func(*http.Response) error
 }
 
 const defaultFlushInterval = 200 * time.Millisecond
@@ -261,6 +263,7 @@ func (h *UpgradeAwareHandler) ServeHTTP(w http.ResponseWriter, req *http.Request
 	proxy.Transport = h.Transport
 	proxy.FlushInterval = h.FlushInterval
 	proxy.ErrorLog = log.New(noSuppressPanicError{}, "", log.LstdFlags)
+	proxy.ModifyResponse = h.ModifyResponse
 	if h.RejectForwardingRedirects {
 		oldModifyResponse := proxy.ModifyResponse
 		proxy.ModifyResponse = func(response *http.Response) error {
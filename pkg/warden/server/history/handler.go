@@ -0,0 +1,113 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubecube-io/kubecube/pkg/authentication/authenticators/jwt"
+	"github.com/kubecube-io/kubecube/pkg/authentication/authenticators/token"
+	"github.com/kubecube-io/kubecube/pkg/authentication/session"
+	"github.com/kubecube-io/kubecube/pkg/authorizer/rbac"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/multicluster/client"
+	"github.com/kubecube-io/kubecube/pkg/quota/history"
+	"github.com/kubecube-io/kubecube/pkg/tsdb"
+	"github.com/kubecube-io/kubecube/pkg/utils/access"
+)
+
+// Handler serves range queries of quota history sampled in local cluster,
+// requests must carry token of kubecube as those proxied by auth proxy.
+type Handler struct {
+	rbac.Interface
+
+	cluster  string
+	cache    ctrlclient.Reader
+	sessions session.Manager
+	claims   func(r *http.Request) (*jwt.Claims, error)
+	store    func() (*tsdb.Store, error)
+	now      func() time.Time
+}
+
+func NewHandler(cluster string, cli client.Client) *Handler {
+	return &Handler{
+		Interface: &rbac.DefaultResolver{Cache: cli.Cache()},
+		cluster:   cluster,
+		cache:     cli.Cache(),
+		sessions:  session.NewManager(cli),
+		claims:    token.GetClaimsFromReq,
+		store:     history.Store,
+		now:       time.Now,
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	claims, err := h.claims(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	revoked, err := h.sessions.IsRevoked(r.Context(), claims)
+	if err != nil || revoked {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	// history is not of any namespace, so keys limited to tenants or
+	// projects are not allowed
+	allowed, err := access.AllowScope(r.Context(), claims.Scope, h.cache, h.cluster, "", r.Method)
+	if err != nil || !allowed {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	opts, matcher, err := history.ParseQuery(r.URL.Query(), h.now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !history.CanQuery(h, claims.UserInfo.Username, matcher) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	store, err := h.store()
+	if err != nil {
+		clog.Error("open store of quota history failed: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	result, err := history.Query(store, opts, matcher)
+	if err != nil {
+		clog.Error("query quota history failed: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(result); err != nil {
+		clog.Warn("write quota history failed: %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"k8s.io/api/authentication/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubecube-io/kubecube/pkg/apis"
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/authentication/authenticators/jwt"
+	"github.com/kubecube-io/kubecube/pkg/multicluster/client/fake"
+	"github.com/kubecube-io/kubecube/pkg/quota/history"
+	"github.com/kubecube-io/kubecube/pkg/tsdb"
+)
+
+func TestHandler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	apis.AddToScheme(scheme)
+	cli := fake.NewFakeClients(&fake.Options{Scheme: scheme, Objs: []client.Object{
+		&userv1.User{ObjectMeta: metav1.ObjectMeta{Name: "admin"}, Status: userv1.UserStatus{PlatformAdmin: true}},
+		&userv1.User{ObjectMeta: metav1.ObjectMeta{Name: "dave"}},
+	}})
+
+	store, err := tsdb.Open(tsdb.Options{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2023, 3, 2, 0, 0, 0, 0, time.UTC)
+	store.Append(tsdb.Sample{
+		Time:   now.Add(-time.Hour),
+		Labels: map[string]string{history.LabelCluster: "member-1", history.LabelLevel: history.LevelTenant, history.LabelTenant: "tenant-1"},
+		Values: map[string]float64{"hard/requests.cpu": 8},
+	})
+
+	h := NewHandler("member-1", cli)
+	h.store = func() (*tsdb.Store, error) { return store, nil }
+	h.now = func() time.Time { return now }
+	requester := ""
+	h.claims = func(r *http.Request) (*jwt.Claims, error) {
+		if len(requester) == 0 {
+			return nil, fmt.Errorf("no token")
+		}
+		return &jwt.Claims{UserInfo: v1beta1.UserInfo{Username: requester}}, nil
+	}
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, history.WardenPath+"?"+query, nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := get(""); w.Code != http.StatusUnauthorized {
+		t.Errorf("want 401 without token, got %v", w.Code)
+	}
+	requester = "dave"
+	if w := get("tenant=tenant-1"); w.Code != http.StatusForbidden {
+		t.Errorf("want 403 for user not admin, got %v", w.Code)
+	}
+	requester = "admin"
+	w := get("groupBy=tenant")
+	if w.Code != http.StatusOK {
+		t.Fatalf("want 200, got %v: %v", w.Code, w.Body.String())
+	}
+	result := &history.Result{}
+	json.Unmarshal(w.Body.Bytes(), result)
+	if len(result.Series) != 1 || result.Series[0].Tenant != "tenant-1" || result.Series[0].Points[0].Values["hard/requests.cpu"] != 8 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if w = get("groupBy=namespace"); w.Code != http.StatusBadRequest {
+		t.Errorf("want 400 for invalid groupBy, got %v", w.Code)
+	}
+}
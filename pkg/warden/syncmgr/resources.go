@@ -44,6 +44,9 @@ var syncResources = []client.Object{
 	&tenant.Tenant{},
 	&tenant.Project{},
 	&user.User{},
+	&user.Session{},
+	&user.Group{},
+	&user.AccessPolicy{},
 	&extension.ExternalResource{},
 	&quota.CubeResourceQuota{},
 }
@@ -60,6 +63,9 @@ var syncListResources = []client.ObjectList{
 	&tenant.TenantList{},
 	&tenant.ProjectList{},
 	&user.UserList{},
+	&user.SessionList{},
+	&user.GroupList{},
+	&user.AccessPolicyList{},
 	&extension.ExternalResourceList{},
 	&quota.CubeResourceQuotaList{},
 }
@@ -79,6 +85,12 @@ func newGenericObj(obj client.Object) (client.Object, error) {
 		return &v1.ClusterRoleBinding{}, nil
 	case *user.User:
 		return &user.User{}, nil
+	case *user.Session:
+		return &user.Session{}, nil
+	case *user.Group:
+		return &user.Group{}, nil
+	case *user.AccessPolicy:
+		return &user.AccessPolicy{}, nil
 	case *cluster.Cluster:
 		return &cluster.Cluster{}, nil
 	case *tenant.Project:
@@ -55,6 +55,7 @@ func NewWardenWithOpts(opts *Config) *Warden {
 		TlsKey:                 opts.TlsKey,
 		TlsCert:                opts.TlsCert,
 		LocalClusterKubeConfig: opts.LocalClusterKubeConfig,
+		Cluster:                opts.Cluster,
 	}
 
 	w.Reporter = &reporter.Reporter{